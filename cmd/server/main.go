@@ -60,6 +60,19 @@ func main() {
 	klineService := service.NewKlineService(db, cfg, logger)
 	klineService.StartAutoUpdate()
 
+	// 启动算法单调度
+	balanceService := service.NewBalanceService(db, cfg, logger)
+	orderService := service.NewOrderService(db, cfg, logger, balanceService)
+	orderService.StartAlgoScheduler()
+
+	// 启动永续合约资金费结算
+	fundingService := service.NewFundingService(db, cfg, logger, balanceService)
+	fundingService.StartScheduler()
+
+	// 启动现货杠杆借款计息
+	spotMarginService := service.NewSpotMarginService(db, cfg, logger, balanceService)
+	spotMarginService.StartScheduler()
+
 	// 创建 Echo 实例
 	e := echo.New()
 	e.HideBanner = true
@@ -3,8 +3,11 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+	"github.com/talkincode/quicksilver/internal/ccxt"
 	"github.com/talkincode/quicksilver/internal/model"
 	"github.com/talkincode/quicksilver/internal/service"
 )
@@ -265,10 +268,10 @@ func AdminAdjustBalance(balanceService *service.BalanceService) echo.HandlerFunc
 		}
 
 		var req struct {
-			Asset     string  `json:"asset"`
-			Amount    float64 `json:"amount"`
-			Operation string  `json:"operation"` // add 或 deduct
-			Note      string  `json:"note"`
+			Asset     string          `json:"asset"`
+			Amount    decimal.Decimal `json:"amount"`
+			Operation string          `json:"operation"` // add 或 deduct
+			Note      string          `json:"note"`
 		}
 
 		if err := c.Bind(&req); err != nil {
@@ -284,7 +287,7 @@ func AdminAdjustBalance(balanceService *service.BalanceService) echo.HandlerFunc
 			})
 		}
 
-		if req.Amount <= 0 {
+		if !req.Amount.IsPositive() {
 			return c.JSON(http.StatusBadRequest, map[string]string{
 				"error": "amount must be positive",
 			})
@@ -328,3 +331,144 @@ func AdminAdjustBalance(balanceService *service.BalanceService) echo.HandlerFunc
 		})
 	}
 }
+
+// AdminListMarkets 获取全部交易对 (管理员接口)
+func AdminListMarkets(marketService *service.MarketService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		list, err := marketService.ListMarkets()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch markets",
+			})
+		}
+
+		markets := make([]map[string]interface{}, 0, len(list))
+		for _, m := range list {
+			markets = append(markets, ccxt.TransformMarket(m))
+		}
+		return c.JSON(http.StatusOK, markets)
+	}
+}
+
+// AdminAddMarket 新增交易对 (管理员接口)
+func AdminAddMarket(marketService *service.MarketService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req service.AddMarketRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		m, err := marketService.AddMarket(req)
+		if err != nil {
+			if err.Error() == "market already exists" {
+				return c.JSON(http.StatusConflict, map[string]string{
+					"error": "market already exists",
+				})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, ccxt.TransformMarket(m))
+	}
+}
+
+// AdminSetMarketStatus 修改交易对状态：停用、暂停交易（只允许撤单）或恢复交易 (管理员接口)
+func AdminSetMarketStatus(marketService *service.MarketService, status string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 转换交易对格式: BTC-USDT -> BTC/USDT
+		symbol := strings.ReplaceAll(c.Param("symbol"), "-", "/")
+
+		m, err := marketService.SetMarketStatus(symbol, status)
+		if err != nil {
+			if err.Error() == "market not found" {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "market not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to update market status",
+			})
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformMarket(m))
+	}
+}
+
+// AdminSetUserFeeRate 设置用户手续费率，覆盖按成交额计算的等级费率 (管理员接口)
+func AdminSetUserFeeRate(feeService *service.FeeService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 解析用户 ID
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid user id",
+			})
+		}
+
+		var req service.SetFeeRateRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		rate, err := feeService.SetUserFeeRate(uint(id), req)
+		if err != nil {
+			if err.Error() == "user not found" {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "user not found",
+				})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(http.StatusOK, rate)
+	}
+}
+
+// AdminDeleteUserFeeRate 删除用户手续费率，恢复等级费率 (管理员接口)
+func AdminDeleteUserFeeRate(feeService *service.FeeService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 解析用户 ID
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid user id",
+			})
+		}
+
+		if err := feeService.DeleteUserFeeRate(uint(id)); err != nil {
+			if err.Error() == "user fee rate not found" {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "user fee rate not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to delete user fee rate",
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "user fee rate deleted",
+		})
+	}
+}
+
+// AdminGetInsuranceFunds 查询各结算币的保险基金余额 (管理员接口)
+func AdminGetInsuranceFunds(liquidationService *service.LiquidationService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		funds, err := liquidationService.GetInsuranceFunds()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch insurance funds",
+			})
+		}
+		return c.JSON(http.StatusOK, funds)
+	}
+}
@@ -331,3 +331,71 @@ func TestAdminDeleteUser(t *testing.T) {
 		assert.Equal(t, "inactive", deleted.Status)
 	})
 }
+
+// TestAdminMarkets 测试交易对管理接口
+func TestAdminMarkets(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	logger := testutil.NewTestLogger()
+
+	marketService := service.NewMarketService(db, cfg, logger)
+	e := echo.New()
+
+	t.Run("Add market", func(t *testing.T) {
+		// Given: 新交易对定义
+		body := []byte(`{"symbol":"SOL/USDT","price_tick":"0.01","min_notional":"5"}`)
+		req := httptest.NewRequest(http.MethodPost, "/admin/markets", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		// When: 调用处理器
+		require.NoError(t, AdminAddMarket(marketService)(e.NewContext(req, rec)))
+
+		// Then: 返回 CCXT market 格式
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "SOL/USDT", response["symbol"])
+		assert.Equal(t, true, response["active"])
+
+		// And: 重复新增返回冲突
+		req = httptest.NewRequest(http.MethodPost, "/admin/markets", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec = httptest.NewRecorder()
+		require.NoError(t, AdminAddMarket(marketService)(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("Halt and list markets", func(t *testing.T) {
+		// When: 暂停 BTC/USDT
+		req := httptest.NewRequest(http.MethodPost, "/admin/markets/BTC-USDT/halt", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("symbol")
+		c.SetParamValues("BTC-USDT")
+		require.NoError(t, AdminSetMarketStatus(marketService, "halted")(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// Then: 列表中 BTC/USDT 不可交易，状态为 halted
+		req = httptest.NewRequest(http.MethodGet, "/admin/markets", nil)
+		rec = httptest.NewRecorder()
+		require.NoError(t, AdminListMarkets(marketService)(e.NewContext(req, rec)))
+
+		var markets []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &markets))
+		require.Len(t, markets, 3)
+		assert.Equal(t, "BTC/USDT", markets[0]["symbol"])
+		assert.Equal(t, false, markets[0]["active"])
+		assert.Equal(t, "halted", markets[0]["info"].(map[string]interface{})["status"])
+	})
+
+	t.Run("Unknown market returns 404", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/markets/DOGE-USDT/resume", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("symbol")
+		c.SetParamValues("DOGE-USDT")
+		require.NoError(t, AdminSetMarketStatus(marketService, "active")(c))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -8,10 +8,12 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 
 	"github.com/talkincode/quicksilver/internal/ccxt"
 	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
 	"github.com/talkincode/quicksilver/internal/model"
 	"github.com/talkincode/quicksilver/internal/service"
 )
@@ -33,17 +35,35 @@ func ServerTime(c echo.Context) error {
 }
 
 // GetMarkets 获取交易对信息
-func GetMarkets(cfg *config.Config) echo.HandlerFunc {
+func GetMarkets(marketService *service.MarketService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		// 从配置读取交易对列表
-		markets := make([]map[string]interface{}, 0, len(cfg.Market.Symbols))
-		for _, symbol := range cfg.Market.Symbols {
-			markets = append(markets, ccxt.TransformMarket(symbol, cfg.Trading.MinOrderAmount))
+		// 从交易对注册表读取（含暂停交易的交易对，active 为 false）
+		list, err := marketService.ListMarkets()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch markets",
+			})
+		}
+
+		markets := make([]map[string]interface{}, 0, len(list))
+		for _, m := range list {
+			markets = append(markets, ccxt.TransformMarket(m))
 		}
 		return c.JSON(http.StatusOK, markets)
 	}
 }
 
+// orderErrorResponse 下单失败的响应体，带 CCXT 错误码时附加 code 字段
+func orderErrorResponse(err error) map[string]string {
+	resp := map[string]string{
+		"error": err.Error(),
+	}
+	if code := ccxt.ErrorCode(err); code != "" {
+		resp["code"] = code
+	}
+	return resp
+}
+
 // GetTicker 获取行情
 func GetTicker(db *gorm.DB) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -63,6 +83,36 @@ func GetTicker(db *gorm.DB) echo.HandlerFunc {
 	}
 }
 
+// GetOrderBook 获取合成 L2 订单簿 (CCXT fetchOrderBook)
+func GetOrderBook(db *gorm.DB, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 获取参数并转换格式: BTC-USDT -> BTC/USDT
+		symbol := c.Param("symbol")
+		symbol = strings.ReplaceAll(symbol, "-", "/")
+
+		// 获取档位数量限制，默认返回全部档位
+		limit := 0
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+				limit = parsedLimit
+			}
+		}
+
+		var ticker model.Ticker
+		if err := db.Where("symbol = ?", symbol).First(&ticker).Error; err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "ticker not found",
+			})
+		}
+
+		bookCfg := cfg.Trading.OrderBook.ForSymbol(symbol)
+		book := engine.DefaultOrderBookStore().Snapshot(&ticker, bookCfg, limit)
+
+		// 转换为 CCXT 格式
+		return c.JSON(http.StatusOK, ccxt.TransformOrderBook(book))
+	}
+}
+
 // GetTrades 获取最近成交
 func GetTrades(db *gorm.DB) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -186,9 +236,11 @@ func CreateOrder(orderService *service.OrderService) echo.HandlerFunc {
 		// 创建订单
 		order, err := orderService.CreateOrder(userID, req)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": err.Error(),
-			})
+			status := http.StatusBadRequest
+			if ccxt.ErrorCode(err) == ccxt.ErrExchangeNotAvailable {
+				status = http.StatusServiceUnavailable
+			}
+			return c.JSON(status, orderErrorResponse(err))
 		}
 
 		// 转换为 CCXT 格式
@@ -273,6 +325,302 @@ func CancelOrder(orderService *service.OrderService) echo.HandlerFunc {
 	}
 }
 
+// GetOrderByClientOrderID 根据 clientOrderId 查询订单（GET /order?clientOrderId=）
+func GetOrderByClientOrderID(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		order, err := orderService.GetOrderByClientOrderID(userID, c.QueryParam("clientOrderId"))
+		if err != nil {
+			return c.JSON(clientOrderErrorStatus(err), orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformOrder(order))
+	}
+}
+
+// CancelOrderByClientOrderID 根据 clientOrderId 撤销订单（DELETE /order?clientOrderId=）
+func CancelOrderByClientOrderID(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		order, err := orderService.CancelOrderByClientOrderID(userID, c.QueryParam("clientOrderId"))
+		if err != nil {
+			return c.JSON(clientOrderErrorStatus(err), orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformOrder(order))
+	}
+}
+
+// clientOrderErrorStatus 按 clientOrderId 查询/撤单失败时的 HTTP 状态码
+func clientOrderErrorStatus(err error) int {
+	if ccxt.ErrorCode(err) == ccxt.ErrOrderNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// GetTradingFees 获取用户当前的手续费率（CCXT fetchTradingFees）
+func GetTradingFees(feeService *service.FeeService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		fees, err := feeService.GetTradingFees(userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch trading fees",
+			})
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformTradingFees(fees.Rates, fees.Symbols))
+	}
+}
+
+// SetFeeTokenPayment 开启或关闭以平台币支付手续费
+func SetFeeTokenPayment(feeService *service.FeeService, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		user, err := feeService.SetFeeTokenPayment(userID, req.Enabled)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"enabled":  user.PayFeesWithToken,
+			"asset":    cfg.Trading.FeeToken.Asset,
+			"discount": cfg.Trading.FeeToken.Discount,
+		})
+	}
+}
+
+// CreateOrders 批量下单（CCXT createOrders），按请求顺序返回每笔订单的结果
+func CreateOrders(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req service.BatchOrdersRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request",
+			})
+		}
+
+		results, err := orderService.CreateOrders(userID, req)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		// 成功的订单返回 CCXT 订单，失败的返回错误信息和错误码
+		response := make([]interface{}, 0, len(results))
+		for _, r := range results {
+			if r.Error != nil {
+				response = append(response, orderErrorResponse(r.Error))
+				continue
+			}
+			response = append(response, ccxt.TransformOrder(r.Order))
+		}
+
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// CancelAllOrders 撤销全部未完成订单（CCXT cancelAllOrders），可按 symbol/side 过滤
+func CancelAllOrders(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		// 交易对支持 BTC-USDT 和 BTC/USDT 两种格式
+		req := service.CancelAllOrdersRequest{
+			Symbol: strings.ReplaceAll(c.QueryParam("symbol"), "-", "/"),
+			Side:   c.QueryParam("side"),
+		}
+
+		orders, err := orderService.CancelAllOrders(userID, req)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		response := make([]map[string]interface{}, 0, len(orders))
+		for i := range orders {
+			response = append(response, ccxt.TransformOrder(&orders[i]))
+		}
+
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// EditOrder 修改订单价格/数量（CCXT editOrder）
+func EditOrder(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var orderID uint
+		if _, err := fmt.Sscanf(c.Param("id"), "%d", &orderID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid order id",
+			})
+		}
+
+		var req service.EditOrderRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request",
+			})
+		}
+
+		order, err := orderService.EditOrder(userID, orderID, req)
+		if err != nil {
+			status := http.StatusBadRequest
+			if ccxt.ErrorCode(err) == ccxt.ErrOrderNotFound {
+				status = http.StatusNotFound
+			}
+			return c.JSON(status, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformOrder(order))
+	}
+}
+
+// CreateOrderList 创建订单组（OCO / bracket）
+func CreateOrderList(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req service.CreateOrderListRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request",
+			})
+		}
+
+		list, err := orderService.CreateOrderList(userID, req)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusCreated, ccxt.TransformOrderList(list))
+	}
+}
+
+// GetOrderList 获取订单组详情
+func GetOrderList(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var listID uint
+		if _, err := fmt.Sscanf(c.Param("id"), "%d", &listID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid order list id",
+			})
+		}
+
+		list, err := orderService.GetOrderList(userID, listID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformOrderList(list))
+	}
+}
+
+// CancelOrderList 撤销订单组
+func CancelOrderList(orderService *service.OrderService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var listID uint
+		if _, err := fmt.Sscanf(c.Param("id"), "%d", &listID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid order list id",
+			})
+		}
+
+		if err := orderService.CancelOrderList(userID, listID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		list, err := orderService.GetOrderList(userID, listID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformOrderList(list))
+	}
+}
+
 // GetOrders 获取订单列表
 func GetOrders(orderService *service.OrderService) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -358,3 +706,332 @@ func GetMyTrades(db *gorm.DB) echo.HandlerFunc {
 		return c.JSON(http.StatusOK, trades)
 	}
 }
+
+// GetPositions 获取永续合约持仓（CCXT fetchPositions），symbols 为逗号分隔的交易对（支持 BTC-USDT:USDT 格式）
+func GetPositions(positionService *service.PositionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var symbols []string
+		if param := c.QueryParam("symbols"); param != "" {
+			for _, symbol := range strings.Split(param, ",") {
+				symbols = append(symbols, strings.ReplaceAll(strings.TrimSpace(symbol), "-", "/"))
+			}
+		}
+
+		positions, err := positionService.GetPositions(userID, symbols)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch positions",
+			})
+		}
+
+		response := make([]map[string]interface{}, 0, len(positions))
+		for _, p := range positions {
+			response = append(response, ccxt.TransformPosition(p.Position, p.MarkPrice, p.UnrealizedPnl))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// SetLeverage 设置永续合约杠杆（CCXT setLeverage）
+func SetLeverage(positionService *service.PositionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req struct {
+			Symbol   string `json:"symbol"`
+			Leverage int    `json:"leverage"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		pos, err := positionService.SetLeverage(userID, strings.ReplaceAll(req.Symbol, "-", "/"), req.Leverage)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"symbol":     pos.Symbol,
+			"leverage":   pos.Leverage,
+			"marginMode": pos.MarginMode,
+		})
+	}
+}
+
+// GetFundingRate 获取永续合约的预测资金费率（CCXT fetchFundingRate），symbol 格式为 BTC-USDT:USDT
+func GetFundingRate(fundingService *service.FundingService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		symbol := strings.ReplaceAll(c.Param("symbol"), "-", "/")
+
+		info, err := fundingService.GetFundingRate(symbol)
+		if err != nil {
+			status := http.StatusNotFound
+			if ccxt.ErrorCode(err) == ccxt.ErrBadSymbol {
+				status = http.StatusBadRequest
+			}
+			return c.JSON(status, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformFundingRate(info.Current, info.Previous, info.Interval))
+	}
+}
+
+// GetFundingHistory 获取用户的资金费收支记录（CCXT fetchFundingHistory），支持 symbol、since（毫秒）、limit 参数
+func GetFundingHistory(fundingService *service.FundingService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		symbol := strings.ReplaceAll(c.QueryParam("symbol"), "-", "/")
+
+		var since *time.Time
+		if sinceStr := c.QueryParam("since"); sinceStr != "" {
+			if timestamp, err := strconv.ParseInt(sinceStr, 10, 64); err == nil {
+				t := time.UnixMilli(timestamp)
+				since = &t
+			}
+		}
+
+		limit := 0
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+				limit = parsedLimit
+			}
+		}
+
+		payments, err := fundingService.GetFundingHistory(userID, symbol, since, limit)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch funding history",
+			})
+		}
+
+		response := make([]map[string]interface{}, 0, len(payments))
+		for i := range payments {
+			response = append(response, ccxt.TransformFundingPayment(&payments[i]))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetMyLiquidations 获取用户的强平记录（CCXT fetchMyLiquidations），支持 symbol、since（毫秒）、limit 参数
+func GetMyLiquidations(liquidationService *service.LiquidationService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		symbol := strings.ReplaceAll(c.QueryParam("symbol"), "-", "/")
+
+		var since *time.Time
+		if sinceStr := c.QueryParam("since"); sinceStr != "" {
+			if timestamp, err := strconv.ParseInt(sinceStr, 10, 64); err == nil {
+				t := time.UnixMilli(timestamp)
+				since = &t
+			}
+		}
+
+		limit := 0
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+				limit = parsedLimit
+			}
+		}
+
+		liquidations, err := liquidationService.GetLiquidations(userID, symbol, since, limit)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch liquidations",
+			})
+		}
+
+		response := make([]map[string]interface{}, 0, len(liquidations))
+		for i := range liquidations {
+			response = append(response, ccxt.TransformLiquidation(&liquidations[i]))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// marginLoanRequest 借币/还币请求体（CCXT borrowMargin / repayMargin 的 code、amount 参数）
+type marginLoanRequest struct {
+	Code   string          `json:"code"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// BorrowMargin 现货杠杆借币（CCXT borrowMargin）
+func BorrowMargin(spotMarginService *service.SpotMarginService) echo.HandlerFunc {
+	return marginLoanHandler(spotMarginService.BorrowMargin)
+}
+
+// RepayMargin 现货杠杆还币（CCXT repayMargin），先还利息再还本金
+func RepayMargin(spotMarginService *service.SpotMarginService) echo.HandlerFunc {
+	return marginLoanHandler(spotMarginService.RepayMargin)
+}
+
+// marginLoanHandler 解析借币/还币请求并返回 CCXT 格式的借还款记录
+func marginLoanHandler(fn func(userID uint, asset string, amount decimal.Decimal) (*model.MarginLoan, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req marginLoanRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		loan, err := fn(userID, strings.ToUpper(req.Code), req.Amount)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if ccxt.ErrorCode(err) != "" {
+				status = http.StatusBadRequest
+			}
+			return c.JSON(status, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformMarginLoan(loan))
+	}
+}
+
+// GetBorrowInterest 获取用户的借款利息记录（CCXT fetchBorrowInterest），支持 code、since（毫秒）、limit 参数
+func GetBorrowInterest(spotMarginService *service.SpotMarginService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var since *time.Time
+		if sinceStr := c.QueryParam("since"); sinceStr != "" {
+			if timestamp, err := strconv.ParseInt(sinceStr, 10, 64); err == nil {
+				t := time.UnixMilli(timestamp)
+				since = &t
+			}
+		}
+
+		limit := 0
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+				limit = parsedLimit
+			}
+		}
+
+		records, err := spotMarginService.GetBorrowInterest(userID, strings.ToUpper(c.QueryParam("code")), since, limit)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch borrow interest",
+			})
+		}
+
+		response := make([]map[string]interface{}, 0, len(records))
+		for i := range records {
+			response = append(response, ccxt.TransformBorrowInterest(&records[i]))
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// SetMarginMode 设置永续合约的保证金模式（CCXT setMarginMode）：cross | isolated
+func SetMarginMode(positionService *service.PositionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req struct {
+			Symbol     string `json:"symbol"`
+			MarginMode string `json:"marginMode"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		pos, err := positionService.SetMarginMode(userID, strings.ReplaceAll(req.Symbol, "-", "/"), strings.ToLower(req.MarginMode))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"symbol":     pos.Symbol,
+			"leverage":   pos.Leverage,
+			"marginMode": pos.MarginMode,
+		})
+	}
+}
+
+// AddMargin 追加逐仓保证金（CCXT addMargin）
+func AddMargin(positionService *service.PositionService) echo.HandlerFunc {
+	return marginModificationHandler("add", positionService.AddMargin)
+}
+
+// ReduceMargin 减少逐仓保证金（CCXT reduceMargin）
+func ReduceMargin(positionService *service.PositionService) echo.HandlerFunc {
+	return marginModificationHandler("reduce", positionService.ReduceMargin)
+}
+
+// marginModificationHandler 解析逐仓保证金调整请求（symbol、amount）并返回 CCXT MarginModification 格式
+func marginModificationHandler(modType string, fn func(userID uint, symbol string, amount decimal.Decimal) (*model.Position, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 从认证中间件获取 user_id
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			// 测试环境：使用硬编码 userID
+			userID = 1
+		}
+
+		var req struct {
+			Symbol string          `json:"symbol"`
+			Amount decimal.Decimal `json:"amount"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+
+		pos, err := fn(userID, strings.ReplaceAll(req.Symbol, "-", "/"), req.Amount)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if ccxt.ErrorCode(err) != "" {
+				status = http.StatusBadRequest
+			}
+			return c.JSON(status, orderErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, ccxt.TransformMarginModification(pos, modType, req.Amount))
+	}
+}
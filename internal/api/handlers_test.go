@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -71,14 +72,16 @@ func TestServerTime(t *testing.T) {
 
 // TestGetMarkets 测试获取交易对列表
 func TestGetMarkets(t *testing.T) {
+	db := testutil.NewTestDB(t)
 	cfg := testutil.NewTestConfig()
+	marketService := service.NewMarketService(db, cfg, zap.NewNop())
 
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/v1/markets", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := GetMarkets(cfg)
+	handler := GetMarkets(marketService)
 	err := handler(c)
 
 	require.NoError(t, err)
@@ -93,6 +96,15 @@ func TestGetMarkets(t *testing.T) {
 	assert.Equal(t, "BTC", markets[0]["base"])
 	assert.Equal(t, "USDT", markets[0]["quote"])
 	assert.Equal(t, true, markets[0]["active"])
+
+	precision, ok := markets[0]["precision"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, 0.00000001, precision["amount"])
+	assert.Equal(t, 0.00000001, precision["price"])
+
+	limits, ok := markets[0]["limits"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, cfg.Trading.MinOrderAmount, limits["amount"].(map[string]interface{})["min"])
 }
 
 // TestGetTicker 测试获取行情数据
@@ -168,6 +180,62 @@ func TestGetTicker(t *testing.T) {
 	}
 }
 
+// TestGetOrderBook 测试获取订单簿
+func TestGetOrderBook(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	cfg.Trading.OrderBook.Levels = 10
+
+	ticker := &model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 50000.5,
+		BidPrice:  testutil.Float64Ptr(50000.0),
+		AskPrice:  testutil.Float64Ptr(50001.0),
+		Source:    "hyperliquid",
+	}
+	db.Create(ticker)
+
+	t.Run("Get order book with limit", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/v1/orderbook/BTC-USDT?limit=5", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("symbol")
+		c.SetParamValues("BTC-USDT")
+
+		err := GetOrderBook(db, cfg)(c)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Symbol string      `json:"symbol"`
+			Bids   [][]float64 `json:"bids"`
+			Asks   [][]float64 `json:"asks"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "BTC/USDT", response.Symbol)
+		require.Len(t, response.Bids, 5)
+		require.Len(t, response.Asks, 5)
+		assert.Equal(t, 50000.0, response.Bids[0][0])
+		assert.Equal(t, 50001.0, response.Asks[0][0])
+	})
+
+	t.Run("Get order book for unknown symbol", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/v1/orderbook/ETH-USDT", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("symbol")
+		c.SetParamValues("ETH-USDT")
+
+		err := GetOrderBook(db, cfg)(c)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
 // TestGetTrades 测试获取成交记录
 func TestGetTrades(t *testing.T) {
 	db := testutil.NewTestDB(t)
@@ -183,8 +251,8 @@ func TestGetTrades(t *testing.T) {
 			UserID:  user.ID,
 			Symbol:  "BTC/USDT",
 			Side:    "buy",
-			Price:   50000.0 + float64(i)*10,
-			Amount:  0.1,
+			Price:   decimal.NewFromFloat(50000.0 + float64(i)*10),
+			Amount:  decimal.NewFromFloat(0.1),
 		}
 		db.Create(trade)
 	}
@@ -311,6 +379,109 @@ func TestCreateOrder(t *testing.T) {
 	require.NoError(t, err)
 	// 由于没有余额和 ticker 数据，应该返回错误
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	t.Run("Unknown symbol returns BadSymbol code", func(t *testing.T) {
+		orderJSON := `{"symbol":"DOGE/USDT","side":"buy","type":"limit","amount":1,"price":0.1}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/order", strings.NewReader(orderJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		require.NoError(t, handler(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "BadSymbol", resp["code"])
+		assert.Contains(t, resp["error"], "unknown symbol DOGE/USDT")
+	})
+}
+
+// TestCreateStopOrder 测试通过 CCXT 参数创建止损单
+func TestCreateStopOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := zap.NewNop()
+
+	balanceService := service.NewBalanceService(db, cfg, logger)
+	orderService := service.NewOrderService(db, cfg, logger, balanceService)
+
+	user := testutil.SeedUser(t, db)
+	testutil.SeedBalance(t, db, user.ID, "BTC", 1.0)
+
+	e := echo.New()
+	orderJSON := `{"symbol":"BTC/USDT","side":"sell","type":"limit","amount":0.5,"price":47900,"stopLossPrice":48000}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/order", strings.NewReader(orderJSON))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", user.ID)
+
+	handler := CreateOrder(orderService)
+	require.NoError(t, handler(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "stop_loss_limit", response["type"])
+	assert.Equal(t, 48000.0, response["triggerPrice"])
+	assert.Equal(t, 48000.0, response["stopLossPrice"])
+	assert.Equal(t, 47900.0, response["price"])
+	assert.Equal(t, "new", response["status"])
+}
+
+// TestOrderList 测试创建和查询订单组
+func TestOrderList(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := zap.NewNop()
+
+	balanceService := service.NewBalanceService(db, cfg, logger)
+	orderService := service.NewOrderService(db, cfg, logger, balanceService)
+
+	user := testutil.SeedUser(t, db)
+	testutil.SeedBalance(t, db, user.ID, "BTC", 1.0)
+
+	e := echo.New()
+
+	// 创建 OCO
+	listJSON := `{"symbol":"BTC/USDT","type":"oco","side":"sell","amount":0.5,"price":52000,"stopPrice":48000}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/orderList", strings.NewReader(listJSON))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", user.ID)
+
+	require.NoError(t, CreateOrderList(orderService)(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var created map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, "OCO", created["contingencyType"])
+	assert.Equal(t, "executing", created["listOrderStatus"])
+	assert.Len(t, created["orders"], 2)
+
+	// 查询订单组
+	listID := created["id"].(string)
+	req = httptest.NewRequest(http.MethodGet, "/v1/orderList/"+listID, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(listID)
+	c.Set("user_id", user.ID)
+
+	require.NoError(t, GetOrderList(orderService)(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// 其他用户无法查询
+	req = httptest.NewRequest(http.MethodGet, "/v1/orderList/"+listID, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(listID)
+	c.Set("user_id", user.ID+1)
+
+	require.NoError(t, GetOrderList(orderService)(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 }
 
 // TestGetOrder 测试获取订单详情
@@ -407,6 +578,204 @@ func TestCancelOrder(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+// TestEditOrder 测试改单接口
+func TestEditOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := zap.NewNop()
+
+	balanceService := service.NewBalanceService(db, cfg, logger)
+	orderService := service.NewOrderService(db, cfg, logger, balanceService)
+
+	user := testutil.SeedUser(t, db)
+	testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 0)
+	price := decimal.NewFromInt(40000)
+	order, err := orderService.CreateOrder(user.ID, service.CreateOrderRequest{
+		Symbol: "BTC/USDT",
+		Side:   "buy",
+		Type:   "limit",
+		Amount: decimal.NewFromFloat(0.1),
+		Price:  &price,
+	})
+	require.NoError(t, err)
+
+	e := echo.New()
+	handler := EditOrder(orderService)
+
+	t.Run("Amend price and amount", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/v1/order/1", strings.NewReader(`{"amount":0.2,"price":"39000"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(fmt.Sprintf("%d", order.ID))
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, handler(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 39000.0, resp["price"])
+		assert.Equal(t, 0.2, resp["amount"])
+		assert.NotNil(t, resp["lastUpdateTimestamp"])
+	})
+
+	t.Run("Unknown order returns OrderNotFound", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/v1/order/999", strings.NewReader(`{"price":"39000"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("999")
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, handler(c))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "OrderNotFound", resp["code"])
+	})
+}
+
+// TestBatchOrders 测试批量下单和撤销全部订单接口
+func TestBatchOrders(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := zap.NewNop()
+
+	balanceService := service.NewBalanceService(db, cfg, logger)
+	orderService := service.NewOrderService(db, cfg, logger, balanceService)
+
+	user := testutil.SeedUser(t, db)
+	testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 0)
+	e := echo.New()
+
+	t.Run("Batch create returns per-item results", func(t *testing.T) {
+		body := `{"orders":[
+			{"symbol":"BTC/USDT","side":"buy","type":"limit","amount":0.1,"price":40000},
+			{"symbol":"DOGE/USDT","side":"buy","type":"limit","amount":1,"price":0.1}
+		]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/batchOrders", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, CreateOrders(orderService)(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp, 2)
+		assert.Equal(t, "new", resp[0]["status"])
+		assert.Equal(t, "BadSymbol", resp[1]["code"])
+	})
+
+	t.Run("Cancel all open orders for a symbol", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/v1/orders?symbol=BTC-USDT", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, CancelAllOrders(orderService)(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp, 1)
+		assert.Equal(t, "cancelled", resp[0]["status"])
+	})
+}
+
+// TestClientOrderIDEndpoints 测试按 clientOrderId 查询和撤单接口
+func TestClientOrderIDEndpoints(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := zap.NewNop()
+
+	balanceService := service.NewBalanceService(db, cfg, logger)
+	orderService := service.NewOrderService(db, cfg, logger, balanceService)
+
+	user := testutil.SeedUser(t, db)
+	testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 0)
+	e := echo.New()
+
+	// Given: 带 clientOrderId 的限价单
+	body := `{"symbol":"BTC/USDT","side":"buy","type":"limit","amount":0.1,"price":40000,"clientOrderId":"abc-1"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/order", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", user.ID)
+	require.NoError(t, CreateOrder(orderService)(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	t.Run("Get order by clientOrderId", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/order?clientOrderId=abc-1", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, GetOrderByClientOrderID(orderService)(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "abc-1", resp["clientOrderId"])
+	})
+
+	t.Run("Cancel order by clientOrderId", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/v1/order?clientOrderId=abc-1", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, CancelOrderByClientOrderID(orderService)(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "cancelled", resp["status"])
+	})
+
+	t.Run("Unknown clientOrderId", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/order?clientOrderId=nope", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID)
+
+		require.NoError(t, GetOrderByClientOrderID(orderService)(c))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// TestGetTradingFees 测试查询手续费率接口（CCXT fetchTradingFees）
+func TestGetTradingFees(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	feeService := service.NewFeeService(db, cfg, zap.NewNop())
+
+	user := testutil.SeedUser(t, db)
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tradingFees", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", user.ID)
+
+	require.NoError(t, GetTradingFees(feeService)(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Contains(t, resp, "BTC/USDT")
+	assert.Equal(t, cfg.Trading.MakerFeeRate, resp["BTC/USDT"]["maker"])
+	assert.Equal(t, cfg.Trading.TakerFeeRate, resp["BTC/USDT"]["taker"])
+	assert.Equal(t, true, resp["BTC/USDT"]["tierBased"])
+}
+
 // TestGetOrders 测试获取订单列表
 func TestGetOrders(t *testing.T) {
 	db := testutil.NewTestDB(t)
@@ -467,8 +836,8 @@ func TestGetOpenOrders(t *testing.T) {
 		Side:   "buy",
 		Type:   "limit",
 		Status: "new",
-		Price:  testutil.Float64Ptr(50000.0),
-		Amount: 0.1,
+		Price:  testutil.DecimalPtr(testutil.Float64Ptr(50000.0)),
+		Amount: decimal.NewFromFloat(0.1),
 	}
 	db.Create(newOrder)
 
@@ -478,8 +847,8 @@ func TestGetOpenOrders(t *testing.T) {
 		Side:   "buy",
 		Type:   "market",
 		Status: "filled",
-		Amount: 0.1,
-		Filled: 0.1,
+		Amount: decimal.NewFromFloat(0.1),
+		Filled: decimal.NewFromFloat(0.1),
 	}
 	db.Create(filledOrder)
 
@@ -532,8 +901,8 @@ func TestGetMyTrades(t *testing.T) {
 			UserID:  user1.ID,
 			Symbol:  "BTC/USDT",
 			Side:    "buy",
-			Price:   50000.0,
-			Amount:  0.1,
+			Price:   decimal.NewFromFloat(50000.0),
+			Amount:  decimal.NewFromFloat(0.1),
 		}
 		db.Create(trade)
 	}
@@ -544,8 +913,8 @@ func TestGetMyTrades(t *testing.T) {
 		UserID:  user2.ID,
 		Symbol:  "BTC/USDT",
 		Side:    "sell",
-		Price:   50000.0,
-		Amount:  0.1,
+		Price:   decimal.NewFromFloat(50000.0),
+		Amount:  decimal.NewFromFloat(0.1),
 	}
 	db.Create(trade)
 
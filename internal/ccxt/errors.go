@@ -0,0 +1,56 @@
+package ccxt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CCXT 标准错误码（与 ccxt 异常类名一致，客户端据此映射异常类型）
+const (
+	ErrBadSymbol            = "BadSymbol"            // 交易对不存在
+	ErrMarketClosed         = "MarketClosed"         // 交易对暂停交易
+	ErrInvalidOrder         = "InvalidOrder"         // 订单参数不符合交易对规则
+	ErrOrderNotFound        = "OrderNotFound"        // 订单不存在
+	ErrExchangeError        = "ExchangeError"        // 交易所拒绝请求（可重试）
+	ErrExchangeNotAvailable = "ExchangeNotAvailable" // 交易对暂时不可用
+	ErrInsufficientFunds    = "InsufficientFunds"    // 余额或保证金不足
+	ErrBadRequest           = "BadRequest"           // 请求参数错误
+)
+
+// Error 带 CCXT 标准错误码的错误
+type Error struct {
+	Code string
+	Err  error
+}
+
+// Error 返回原始错误信息
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError 创建带错误码的错误
+func NewError(code, format string, args ...interface{}) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// WithCode 为错误附加错误码，错误链中已有错误码时保持不变
+func WithCode(code string, err error) error {
+	if err == nil || ErrorCode(err) != "" {
+		return err
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// ErrorCode 提取错误链中的 CCXT 错误码，没有时返回空字符串
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
@@ -5,6 +5,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
+
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/fee"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
@@ -81,68 +86,199 @@ func TransformTicker(ticker *model.Ticker) map[string]interface{} {
 	return result
 }
 
+// TransformOrderList 转换订单组为 Binance orderList 风格格式
+// 组内仍有未完成（含未激活）订单时 listOrderStatus 为 executing，否则为 all_done
+func TransformOrderList(list *model.OrderList) map[string]interface{} {
+	listStatus := "all_done"
+	orders := make([]map[string]interface{}, len(list.Orders))
+	for i := range list.Orders {
+		switch list.Orders[i].Status {
+		case "new", "partially_filled", "pending":
+			listStatus = "executing"
+		}
+		orders[i] = TransformOrder(&list.Orders[i])
+	}
+
+	return map[string]interface{}{
+		"id":                strconv.FormatUint(uint64(list.ID), 10),
+		"listClientOrderId": list.ClientListID,
+		"symbol":            list.Symbol,
+		"contingencyType":   strings.ToUpper(list.Type),
+		"listOrderStatus":   listStatus,
+		"timestamp":         list.CreatedAt.UnixMilli(),
+		"datetime":          list.CreatedAt.Format(time.RFC3339Nano),
+		"orders":            orders,
+	}
+}
+
+// TransformOrderBook 将订单簿转换为 CCXT fetchOrderBook 格式
+// CCXT 格式: {"bids": [[price, amount], ...], "asks": [[price, amount], ...], ...}
+func TransformOrderBook(book *engine.OrderBook) map[string]interface{} {
+	bids := make([][]float64, len(book.Bids))
+	for i, level := range book.Bids {
+		bids[i] = []float64{level.Price, level.Amount}
+	}
+
+	asks := make([][]float64, len(book.Asks))
+	for i, level := range book.Asks {
+		asks[i] = []float64{level.Price, level.Amount}
+	}
+
+	return map[string]interface{}{
+		"symbol":    book.Symbol,
+		"bids":      bids,
+		"asks":      asks,
+		"timestamp": book.Timestamp.UnixMilli(),
+		"datetime":  book.Timestamp.Format(time.RFC3339Nano),
+		"nonce":     nil,
+	}
+}
+
 // TransformOrder 将内部 Order 模型转换为 CCXT 标准格式
 func TransformOrder(order *model.Order) map[string]interface{} {
-	var price interface{}
-	if order.Price != nil {
-		price = *order.Price
+	// 成交金额优先使用累计成交金额，旧订单没有该字段时按均价估算
+	cost := order.QuoteFilled.InexactFloat64()
+	if order.QuoteFilled.IsZero() && order.AveragePrice != nil {
+		cost = order.Filled.Mul(*order.AveragePrice).Round(engine.AssetScale).InexactFloat64()
 	}
 
-	remaining := order.Amount - order.Filled
+	// 条件单触发价
+	triggerPrice := optionalNumber(order.StopPrice)
+	var stopLossPrice, takeProfitPrice interface{}
+	switch order.Type {
+	case "stop_loss", "stop_loss_limit", "trailing_stop":
+		stopLossPrice = triggerPrice
+	case "take_profit", "take_profit_limit":
+		takeProfitPrice = triggerPrice
+	}
 
-	result := map[string]interface{}{
-		"id":            strconv.FormatUint(uint64(order.ID), 10),
-		"clientOrderId": order.ClientOrderID,
-		"timestamp":     order.CreatedAt.UnixMilli(),
-		"datetime":      order.CreatedAt.Format(time.RFC3339Nano),
-		"symbol":        order.Symbol,
-		"type":          order.Type,
-		"side":          order.Side,
-		"price":         price,
-		"amount":        order.Amount,
-		"filled":        order.Filled,
-		"remaining":     remaining,
-		"status":        order.Status,
-		"fee": map[string]interface{}{
-			"cost":     order.Fee,
+	timeInForce := order.TimeInForce
+	if timeInForce == "" {
+		timeInForce = "GTC"
+	}
+
+	remaining := order.Amount.Sub(order.Filled).InexactFloat64()
+
+	// 手续费：从收到的资产中扣除的部分和以平台币支付的部分
+	fees := []map[string]interface{}{}
+	if order.Fee.IsPositive() || !order.TokenFee.IsPositive() {
+		fees = append(fees, map[string]interface{}{
+			"cost":     order.Fee.InexactFloat64(),
 			"currency": order.FeeAsset,
-		},
+		})
+	}
+	if order.TokenFee.IsPositive() {
+		fees = append(fees, map[string]interface{}{
+			"cost":     order.TokenFee.InexactFloat64(),
+			"currency": order.TokenFeeAsset,
+		})
+	}
+
+	result := map[string]interface{}{
+		"id":                  strconv.FormatUint(uint64(order.ID), 10),
+		"clientOrderId":       order.ClientOrderID,
+		"timestamp":           order.CreatedAt.UnixMilli(),
+		"datetime":            order.CreatedAt.Format(time.RFC3339Nano),
+		"lastUpdateTimestamp": order.UpdatedAt.UnixMilli(),
+		"symbol":              order.Symbol,
+		"type":                order.Type,
+		"timeInForce":         timeInForce,
+		"postOnly":            order.PostOnly,
+		"reduceOnly":          order.ReduceOnly,
+		"side":                order.Side,
+		"price":               optionalNumber(order.Price),
+		"stopPrice":           triggerPrice,
+		"triggerPrice":        triggerPrice,
+		"stopLossPrice":       stopLossPrice,
+		"takeProfitPrice":     takeProfitPrice,
+		"trailingAmount":      optionalNumber(order.TrailingDelta),
+		"trailingPercent":     optionalNumber(order.TrailingPercent),
+		"amount":              order.Amount.InexactFloat64(),
+		"average":             optionalNumber(order.AveragePrice),
+		"cost":                cost,
+		"filled":              order.Filled.InexactFloat64(),
+		"remaining":           remaining,
+		"status":              order.Status,
+		"fee":                 fees[0],
+		"fees":                fees,
+	}
+
+	// 算法单执行进度
+	switch order.Type {
+	case "twap":
+		var nextSliceAt interface{}
+		if order.NextSliceAt != nil {
+			nextSliceAt = order.NextSliceAt.UnixMilli()
+		}
+		result["algo"] = map[string]interface{}{
+			"slices":      order.AlgoSlices,
+			"slicesSent":  order.AlgoSlicesSent,
+			"interval":    order.AlgoInterval,
+			"nextSliceAt": nextSliceAt,
+		}
+	case "iceberg":
+		result["algo"] = map[string]interface{}{
+			"visibleAmount": optionalNumber(order.VisibleAmount),
+			"slicesSent":    order.AlgoSlicesSent,
+		}
 	}
 
 	return result
 }
 
+// optionalNumber 将可选的精确数值转换为 CCXT 数字字段（未设置时为 nil）
+func optionalNumber(d *decimal.Decimal) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.InexactFloat64()
+}
+
 // TransformTrade 将内部 Trade 模型转换为 CCXT 标准格式
 func TransformTrade(trade *model.Trade) map[string]interface{} {
-	cost := trade.Price * trade.Amount
+	cost := trade.QuoteAmount
+	if cost.IsZero() {
+		cost = trade.Price.Mul(trade.Amount).Round(engine.AssetScale)
+	}
+
+	takerOrMaker := "taker"
+	if trade.IsMaker {
+		takerOrMaker = "maker"
+	}
 
 	return map[string]interface{}{
-		"id":        strconv.FormatUint(uint64(trade.ID), 10),
-		"order":     strconv.FormatUint(uint64(trade.OrderID), 10),
-		"symbol":    trade.Symbol,
-		"side":      trade.Side,
-		"price":     trade.Price,
-		"amount":    trade.Amount,
-		"cost":      cost,
-		"timestamp": trade.CreatedAt.UnixMilli(),
-		"datetime":  trade.CreatedAt.Format(time.RFC3339Nano),
+		"id":           strconv.FormatUint(uint64(trade.ID), 10),
+		"order":        strconv.FormatUint(uint64(trade.OrderID), 10),
+		"symbol":       trade.Symbol,
+		"side":         trade.Side,
+		"takerOrMaker": takerOrMaker,
+		"price":        trade.Price.InexactFloat64(),
+		"amount":       trade.Amount.InexactFloat64(),
+		"cost":         cost.InexactFloat64(),
+		"timestamp":    trade.CreatedAt.UnixMilli(),
+		"datetime":     trade.CreatedAt.Format(time.RFC3339Nano),
 		"fee": map[string]interface{}{
-			"cost":     trade.Fee,
-			"currency": "USDT", // 简化：假设所有费用用 USDT 支付
+			"cost":     trade.Fee.InexactFloat64(),
+			"currency": trade.FeeAsset,
 		},
 	}
 }
 
 // TransformBalance 将内部 Balance 模型转换为 CCXT 标准格式
 func TransformBalance(balance *model.Balance) map[string]interface{} {
-	total := balance.Available + balance.Locked
+	total := balance.Available.Add(balance.Locked)
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"currency": balance.Asset,
-		"free":     balance.Available,
-		"used":     balance.Locked,
-		"total":    total,
+		"free":     balance.Available.InexactFloat64(),
+		"used":     balance.Locked.InexactFloat64(),
+		"total":    total.InexactFloat64(),
+	}
+	// 现货杠杆负债（借款 + 未还利息），没有借款时不返回
+	if debt := balance.Borrowed.Add(balance.Interest); debt.IsPositive() {
+		result["debt"] = debt.InexactFloat64()
 	}
+	return result
 }
 
 // TransformBalances 将多个余额转换为 CCXT fetchBalance 响应格式
@@ -150,35 +286,243 @@ func TransformBalances(balances []*model.Balance) map[string]interface{} {
 	result := make(map[string]interface{})
 
 	for _, balance := range balances {
-		result[balance.Asset] = map[string]interface{}{
-			"free":  balance.Available,
-			"used":  balance.Locked,
-			"total": balance.Available + balance.Locked,
+		entry := map[string]interface{}{
+			"free":  balance.Available.InexactFloat64(),
+			"used":  balance.Locked.InexactFloat64(),
+			"total": balance.Available.Add(balance.Locked).InexactFloat64(),
 		}
+		if debt := balance.Borrowed.Add(balance.Interest); debt.IsPositive() {
+			entry["debt"] = debt.InexactFloat64()
+		}
+		result[balance.Asset] = entry
 	}
 
 	return result
 }
 
-// TransformMarket 将交易对信息转换为 CCXT market 格式
-func TransformMarket(symbol string, minAmount float64) map[string]interface{} {
-	parts := strings.Split(symbol, "/")
-	base := parts[0]
-	quote := "USDT"
-	if len(parts) > 1 {
-		quote = parts[1]
+// TransformMarket 将交易对定义转换为 CCXT market 格式
+// precision 使用 TICK_SIZE 模式（数量步长和价格最小变动单位），未设置的上限为 nil
+// info.status 为交易对原始状态（active | halted | inactive）；永续合约为线性合约，合约面值为 1 个基础币
+func TransformMarket(m *market.Market) map[string]interface{} {
+	var settle, contractSize interface{}
+	if m.IsSwap() {
+		settle, contractSize = m.Settle, 1
 	}
 
 	return map[string]interface{}{
-		"symbol": symbol,
-		"id":     symbol,
-		"base":   base,
-		"quote":  quote,
-		"active": true,
+		"symbol":       m.Symbol,
+		"id":           m.Symbol,
+		"base":         m.Base,
+		"quote":        m.Quote,
+		"settle":       settle,
+		"type":         m.Type(),
+		"spot":         !m.IsSwap(),
+		"swap":         m.IsSwap(),
+		"contract":     m.IsSwap(),
+		"linear":       m.IsSwap(),
+		"contractSize": contractSize,
+		"active":       m.Active(),
+		"info": map[string]interface{}{
+			"status": m.Status,
+		},
+		"precision": map[string]interface{}{
+			"amount": m.AmountStep.InexactFloat64(),
+			"price":  m.PriceTick.InexactFloat64(),
+		},
 		"limits": map[string]interface{}{
 			"amount": map[string]interface{}{
-				"min": minAmount,
+				"min": m.MinAmount.InexactFloat64(),
+				"max": optionalLimit(m.MaxAmount),
+			},
+			"price": map[string]interface{}{
+				"min": m.PriceTick.InexactFloat64(),
+				"max": nil,
+			},
+			"cost": map[string]interface{}{
+				"min": optionalLimit(m.MinNotional),
+				"max": nil,
+			},
+			"leverage": map[string]interface{}{
+				"min": nil,
+				"max": optionalLimit(decimal.NewFromInt(int64(m.MaxLeverage))),
 			},
 		},
 	}
 }
+
+// TransformPosition 将永续合约持仓转换为 CCXT position 格式（单向持仓，合约面值为 1 个基础币）
+func TransformPosition(pos *model.Position, markPrice, unrealizedPnl decimal.Decimal) map[string]interface{} {
+	notional := pos.Size.Mul(markPrice).Round(engine.AssetScale)
+
+	var percentage float64
+	if pos.Margin.IsPositive() {
+		percentage = unrealizedPnl.Div(pos.Margin).Mul(decimal.NewFromInt(100)).Round(2).InexactFloat64()
+	}
+
+	return map[string]interface{}{
+		"id":                      strconv.FormatUint(uint64(pos.ID), 10),
+		"symbol":                  pos.Symbol,
+		"timestamp":               pos.UpdatedAt.UnixMilli(),
+		"datetime":                pos.UpdatedAt.Format(time.RFC3339Nano),
+		"side":                    pos.Side,
+		"contracts":               pos.Size.InexactFloat64(),
+		"contractSize":            1,
+		"entryPrice":              pos.EntryPrice.InexactFloat64(),
+		"markPrice":               markPrice.InexactFloat64(),
+		"notional":                notional.InexactFloat64(),
+		"leverage":                pos.Leverage,
+		"marginMode":              pos.MarginMode,
+		"isolated":                pos.MarginMode == engine.MarginModeIsolated,
+		"hedged":                  false,
+		"collateral":              pos.Margin.InexactFloat64(),
+		"initialMargin":           pos.Margin.InexactFloat64(),
+		"initialMarginPercentage": 1 / float64(max(pos.Leverage, 1)),
+		"unrealizedPnl":           unrealizedPnl.InexactFloat64(),
+		"realizedPnl":             pos.RealizedPnl.InexactFloat64(),
+		"percentage":              percentage,
+		"info":                    pos,
+	}
+}
+
+// TransformMarginModification 将逐仓保证金调整结果转换为 CCXT addMargin / reduceMargin 响应格式
+// modType 为 add 或 reduce，total 为调整后的仓位保证金
+func TransformMarginModification(pos *model.Position, modType string, amount decimal.Decimal) map[string]interface{} {
+	_, _, settle := market.SplitSymbol(pos.Symbol)
+	return map[string]interface{}{
+		"symbol":     pos.Symbol,
+		"type":       modType,
+		"marginMode": pos.MarginMode,
+		"amount":     amount.InexactFloat64(),
+		"total":      pos.Margin.InexactFloat64(),
+		"code":       settle,
+		"status":     "ok",
+		"timestamp":  pos.UpdatedAt.UnixMilli(),
+		"datetime":   pos.UpdatedAt.Format(time.RFC3339Nano),
+		"info":       pos,
+	}
+}
+
+// TransformFundingRate 将资金费率转换为 CCXT fetchFundingRate 格式
+// current 为下一期的预测费率，previous 为最近一次结算（可为 nil）
+func TransformFundingRate(current, previous *model.FundingRate, interval time.Duration) map[string]interface{} {
+	result := map[string]interface{}{
+		"symbol":                   current.Symbol,
+		"timestamp":                current.CreatedAt.UnixMilli(),
+		"datetime":                 current.CreatedAt.Format(time.RFC3339Nano),
+		"markPrice":                current.MarkPrice.InexactFloat64(),
+		"indexPrice":               current.IndexPrice.InexactFloat64(),
+		"fundingRate":              current.Rate.InexactFloat64(),
+		"fundingTimestamp":         current.FundingTime.UnixMilli(),
+		"fundingDatetime":          current.FundingTime.Format(time.RFC3339Nano),
+		"previousFundingRate":      nil,
+		"previousFundingTimestamp": nil,
+		"previousFundingDatetime":  nil,
+		"interval":                 formatInterval(interval),
+		"info":                     current,
+	}
+	if previous != nil {
+		result["previousFundingRate"] = previous.Rate.InexactFloat64()
+		result["previousFundingTimestamp"] = previous.FundingTime.UnixMilli()
+		result["previousFundingDatetime"] = previous.FundingTime.Format(time.RFC3339Nano)
+	}
+	return result
+}
+
+// TransformFundingPayment 将资金费收支记录转换为 CCXT fetchFundingHistory 格式（amount 为负表示支付）
+func TransformFundingPayment(payment *model.FundingPayment) map[string]interface{} {
+	_, _, settle := market.SplitSymbol(payment.Symbol)
+	return map[string]interface{}{
+		"id":        strconv.FormatUint(uint64(payment.ID), 10),
+		"symbol":    payment.Symbol,
+		"code":      settle,
+		"timestamp": payment.FundingTime.UnixMilli(),
+		"datetime":  payment.FundingTime.Format(time.RFC3339Nano),
+		"amount":    payment.Amount.InexactFloat64(),
+		"info":      payment,
+	}
+}
+
+// TransformLiquidation 将强平记录转换为 CCXT fetchMyLiquidations 格式
+func TransformLiquidation(l *model.Liquidation) map[string]interface{} {
+	return map[string]interface{}{
+		"symbol":       l.Symbol,
+		"timestamp":    l.CreatedAt.UnixMilli(),
+		"datetime":     l.CreatedAt.Format(time.RFC3339Nano),
+		"contracts":    l.Size.InexactFloat64(),
+		"contractSize": 1,
+		"price":        l.Price.InexactFloat64(),
+		"baseValue":    l.Size.InexactFloat64(),
+		"quoteValue":   l.Size.Mul(l.Price).Round(engine.AssetScale).InexactFloat64(),
+		"info":         l,
+	}
+}
+
+// TransformMarginLoan 将借款/还款记录转换为 CCXT borrowMargin / repayMargin 响应格式
+// 还款时 amount 为归还的本金与利息之和
+func TransformMarginLoan(l *model.MarginLoan) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        strconv.FormatUint(uint64(l.ID), 10),
+		"currency":  l.Asset,
+		"amount":    l.Amount.Add(l.Interest).InexactFloat64(),
+		"symbol":    nil,
+		"timestamp": l.CreatedAt.UnixMilli(),
+		"datetime":  l.CreatedAt.Format(time.RFC3339Nano),
+		"info":      l,
+	}
+}
+
+// TransformBorrowInterest 将利息计提记录转换为 CCXT fetchBorrowInterest 格式（现货杠杆为全仓账户）
+func TransformBorrowInterest(r *model.BorrowInterest) map[string]interface{} {
+	return map[string]interface{}{
+		"account":        "cross",
+		"currency":       r.Asset,
+		"interest":       r.Interest.InexactFloat64(),
+		"interestRate":   r.Rate.InexactFloat64(),
+		"amountBorrowed": r.Borrowed.InexactFloat64(),
+		"marginMode":     "cross",
+		"timestamp":      r.AccruedAt.UnixMilli(),
+		"datetime":       r.AccruedAt.Format(time.RFC3339Nano),
+		"info":           r,
+	}
+}
+
+// formatInterval 将结算周期格式化为 CCXT 的 interval 字符串（如 8h、30m）
+func formatInterval(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d >= time.Minute && d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	default:
+		return d.String()
+	}
+}
+
+// TransformTradingFees 将用户手续费率转换为 CCXT fetchTradingFees 格式（按交易对索引）
+// info 中包含 30 天成交额、命中的等级以及是否为管理员设置的费率
+func TransformTradingFees(rates *fee.Rates, symbols []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(symbols))
+	for _, symbol := range symbols {
+		result[symbol] = map[string]interface{}{
+			"symbol":     symbol,
+			"maker":      rates.Maker,
+			"taker":      rates.Taker,
+			"percentage": true,
+			"tierBased":  !rates.Override,
+			"info": map[string]interface{}{
+				"volume_30d": rates.Volume.InexactFloat64(),
+				"tier":       rates.Tier,
+				"override":   rates.Override,
+			},
+		}
+	}
+	return result
+}
+
+// optionalLimit 限额为 0 表示不限，返回 nil
+func optionalLimit(limit decimal.Decimal) interface{} {
+	if limit.IsZero() {
+		return nil
+	}
+	return limit.InexactFloat64()
+}
@@ -4,9 +4,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
@@ -147,11 +150,11 @@ func TestTransformOrder(t *testing.T) {
 			Symbol:        "BTC/USDT",
 			Type:          "market",
 			Side:          "buy",
-			Price:         &price,
-			Amount:        0.5,
-			Filled:        0.3,
+			Price:         decimalPtr(price),
+			Amount:        decimal.NewFromFloat(0.5),
+			Filled:        decimal.NewFromFloat(0.3),
 			Status:        "open",
-			Fee:           0.015,
+			Fee:           decimal.NewFromFloat(0.015),
 			FeeAsset:      "USDT",
 			CreatedAt:     now,
 		}
@@ -188,9 +191,9 @@ func TestTransformOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Type:   "limit",
 			Side:   "sell",
-			Price:  &price,
-			Amount: 1.0,
-			Filled: 1.0,
+			Price:  decimalPtr(price),
+			Amount: decimal.NewFromFloat(1.0),
+			Filled: decimal.NewFromFloat(1.0),
 			Status: "closed",
 		}
 
@@ -206,6 +209,115 @@ func TestTransformOrder(t *testing.T) {
 		assert.Equal(t, "closed", result["status"])
 	})
 
+	t.Run("Transform post-only IOC fields", func(t *testing.T) {
+		price := 50000.0
+		order := &model.Order{
+			ID:          uint(333),
+			Symbol:      "BTC/USDT",
+			Type:        "limit",
+			Side:        "sell",
+			Price:       decimalPtr(price),
+			Amount:      decimal.NewFromFloat(1.0),
+			TimeInForce: "IOC",
+			Status:      "cancelled",
+		}
+
+		result := TransformOrder(order)
+		assert.Equal(t, "IOC", result["timeInForce"])
+		assert.Equal(t, false, result["postOnly"])
+
+		// 未设置时默认 GTC
+		order.TimeInForce = ""
+		order.PostOnly = true
+		result = TransformOrder(order)
+		assert.Equal(t, "GTC", result["timeInForce"])
+		assert.Equal(t, true, result["postOnly"])
+	})
+
+	t.Run("Transform partially filled order with average price", func(t *testing.T) {
+		price := 50000.0
+		average := 49950.0
+		order := &model.Order{
+			ID:           uint(222),
+			Symbol:       "BTC/USDT",
+			Type:         "limit",
+			Side:         "buy",
+			Price:        decimalPtr(price),
+			Amount:       decimal.NewFromFloat(1.0),
+			Filled:       decimal.NewFromFloat(0.4),
+			AveragePrice: decimalPtr(average),
+			Status:       "partially_filled",
+		}
+
+		result := TransformOrder(order)
+
+		assert.Equal(t, 49950.0, result["average"])
+		assert.InDelta(t, 0.4*49950.0, result["cost"], 1e-6)
+		assert.InDelta(t, 0.6, result["remaining"], 1e-9)
+		assert.Equal(t, "partially_filled", result["status"])
+	})
+
+	t.Run("Transform take profit order", func(t *testing.T) {
+		stopPrice := 52000.0
+		order := &model.Order{
+			ID:        uint(444),
+			Symbol:    "BTC/USDT",
+			Type:      "take_profit",
+			Side:      "sell",
+			Amount:    decimal.NewFromFloat(0.5),
+			StopPrice: decimalPtr(stopPrice),
+			Status:    "new",
+		}
+
+		result := TransformOrder(order)
+		assert.Equal(t, 52000.0, result["triggerPrice"])
+		assert.Equal(t, 52000.0, result["stopPrice"])
+		assert.Equal(t, 52000.0, result["takeProfitPrice"])
+		assert.Nil(t, result["stopLossPrice"])
+	})
+
+	t.Run("Transform trailing stop order", func(t *testing.T) {
+		stopPrice := 49000.0
+		trailingAmount := 1000.0
+		order := &model.Order{
+			ID:            uint(555),
+			Symbol:        "BTC/USDT",
+			Type:          "trailing_stop",
+			Side:          "sell",
+			Amount:        decimal.NewFromFloat(0.5),
+			StopPrice:     decimalPtr(stopPrice),
+			TrailingDelta: decimalPtr(trailingAmount),
+			Status:        "new",
+		}
+
+		result := TransformOrder(order)
+		assert.Equal(t, 49000.0, result["triggerPrice"])
+		assert.Equal(t, 1000.0, result["trailingAmount"])
+		assert.Nil(t, result["trailingPercent"])
+	})
+
+	t.Run("Transform iceberg order", func(t *testing.T) {
+		price := 51000.0
+		visible := 0.4
+		order := &model.Order{
+			ID:             uint(666),
+			Symbol:         "BTC/USDT",
+			Type:           "iceberg",
+			Side:           "sell",
+			Amount:         decimal.NewFromFloat(1.0),
+			Price:          decimalPtr(price),
+			VisibleAmount:  decimalPtr(visible),
+			AlgoSlicesSent: 2,
+			Status:         "partially_filled",
+		}
+
+		result := TransformOrder(order)
+		algo, ok := result["algo"].(map[string]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 0.4, algo["visibleAmount"])
+		assert.Equal(t, 2, algo["slicesSent"])
+	})
+
 	t.Run("Transform order without price", func(t *testing.T) {
 		// Given: 市价单可能没有价格
 		order := &model.Order{
@@ -213,7 +325,7 @@ func TestTransformOrder(t *testing.T) {
 			Symbol: "ETH/USDT",
 			Type:   "market",
 			Side:   "buy",
-			Amount: 2.0,
+			Amount: decimal.NewFromFloat(2.0),
 			Status: "new",
 		}
 
@@ -224,6 +336,85 @@ func TestTransformOrder(t *testing.T) {
 		price := result["price"]
 		assert.True(t, price == nil || price == 0.0)
 	})
+
+	t.Run("Fees paid in token are listed separately", func(t *testing.T) {
+		// Given: 部分手续费以平台币支付，部分从收到的 BTC 中扣除
+		order := &model.Order{
+			ID:            uint(222),
+			Symbol:        "BTC/USDT",
+			Type:          "limit",
+			Side:          "buy",
+			Amount:        decimal.NewFromFloat(0.2),
+			Filled:        decimal.NewFromFloat(0.2),
+			Fee:           decimal.NewFromFloat(0.0001),
+			FeeAsset:      "BTC",
+			TokenFee:      decimal.NewFromFloat(1.875),
+			TokenFeeAsset: "QSX",
+			Status:        "filled",
+		}
+
+		result := TransformOrder(order)
+
+		fees := result["fees"].([]map[string]interface{})
+		require.Len(t, fees, 2)
+		assert.Equal(t, "BTC", fees[0]["currency"])
+		assert.Equal(t, "QSX", fees[1]["currency"])
+		assert.Equal(t, 1.875, fees[1]["cost"])
+
+		// 只以平台币支付时 fee 为平台币手续费
+		order.Fee = decimal.Zero
+		fee := TransformOrder(order)["fee"].(map[string]interface{})
+		assert.Equal(t, "QSX", fee["currency"])
+	})
+}
+
+func TestTransformOrderList(t *testing.T) {
+	price := 52000.0
+	list := &model.OrderList{
+		ID:     uint(7),
+		Symbol: "BTC/USDT",
+		Type:   "oco",
+		Orders: []model.Order{
+			{ID: 1, Symbol: "BTC/USDT", Type: "limit", Side: "sell", Price: decimalPtr(price), Amount: decimal.NewFromFloat(0.5), Status: "filled"},
+			{ID: 2, Symbol: "BTC/USDT", Type: "stop_loss", Side: "sell", Amount: decimal.NewFromFloat(0.5), Status: "cancelled"},
+		},
+	}
+
+	result := TransformOrderList(list)
+	assert.Equal(t, "7", result["id"])
+	assert.Equal(t, "OCO", result["contingencyType"])
+	assert.Equal(t, "all_done", result["listOrderStatus"])
+	orders, ok := result["orders"].([]map[string]interface{})
+	require.True(t, ok)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "1", orders[0]["id"])
+
+	// 仍有未完成订单时为 executing
+	list.Orders[1].Status = "new"
+	assert.Equal(t, "executing", TransformOrderList(list)["listOrderStatus"])
+}
+
+func TestTransformOrderBook(t *testing.T) {
+	now := time.Now()
+	book := &engine.OrderBook{
+		Symbol: "BTC/USDT",
+		Bids: []engine.OrderBookLevel{
+			{Price: 49990.0, Amount: 1.0},
+			{Price: 49980.0, Amount: 2.0},
+		},
+		Asks: []engine.OrderBookLevel{
+			{Price: 50010.0, Amount: 1.5},
+		},
+		Timestamp: now,
+	}
+
+	result := TransformOrderBook(book)
+
+	assert.Equal(t, "BTC/USDT", result["symbol"])
+	assert.Equal(t, now.UnixMilli(), result["timestamp"])
+	assert.Equal(t, [][]float64{{49990.0, 1.0}, {49980.0, 2.0}}, result["bids"])
+	assert.Equal(t, [][]float64{{50010.0, 1.5}}, result["asks"])
+	assert.Nil(t, result["nonce"])
 }
 
 func TestTransformTrade(t *testing.T) {
@@ -236,9 +427,10 @@ func TestTransformTrade(t *testing.T) {
 			UserID:    1,
 			Symbol:    "BTC/USDT",
 			Side:      "buy",
-			Price:     50000.0,
-			Amount:    0.5,
-			Fee:       0.025,
+			Price:     decimal.NewFromFloat(50000.0),
+			Amount:    decimal.NewFromFloat(0.5),
+			Fee:       decimal.NewFromFloat(0.025),
+			FeeAsset:  "BTC",
 			CreatedAt: now,
 		}
 
@@ -250,6 +442,7 @@ func TestTransformTrade(t *testing.T) {
 		assert.Equal(t, "12345", result["order"])
 		assert.Equal(t, "BTC/USDT", result["symbol"])
 		assert.Equal(t, "buy", result["side"])
+		assert.Equal(t, "taker", result["takerOrMaker"])
 		assert.Equal(t, 50000.0, result["price"])
 		assert.Equal(t, 0.5, result["amount"])
 		assert.Equal(t, 25000.0, result["cost"]) // price * amount
@@ -260,6 +453,30 @@ func TestTransformTrade(t *testing.T) {
 		fee, ok := result["fee"].(map[string]interface{})
 		require.True(t, ok)
 		assert.Equal(t, 0.025, fee["cost"])
+		assert.Equal(t, "BTC", fee["currency"])
+	})
+
+	t.Run("Maker sell trade charges fee in quote", func(t *testing.T) {
+		// Given: 卖方挂单成交，手续费以 USDT 扣除
+		trade := &model.Trade{
+			ID:          uint(1000),
+			OrderID:     uint(12346),
+			Symbol:      "BTC/USDT",
+			Side:        "sell",
+			Price:       decimal.NewFromFloat(50000.0),
+			Amount:      decimal.NewFromFloat(0.1),
+			QuoteAmount: decimal.NewFromFloat(5000.0),
+			Fee:         decimal.NewFromFloat(2.5),
+			FeeAsset:    "USDT",
+			IsMaker:     true,
+		}
+
+		result := TransformTrade(trade)
+
+		assert.Equal(t, "maker", result["takerOrMaker"])
+		assert.Equal(t, 5000.0, result["cost"])
+		fee := result["fee"].(map[string]interface{})
+		assert.Equal(t, 2.5, fee["cost"])
 		assert.Equal(t, "USDT", fee["currency"])
 	})
 
@@ -280,8 +497,8 @@ func TestTransformTrade(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				trade := &model.Trade{
 					Symbol: "BTC/USDT",
-					Price:  tt.price,
-					Amount: tt.amount,
+					Price:  decimal.NewFromFloat(tt.price),
+					Amount: decimal.NewFromFloat(tt.amount),
 				}
 
 				result := TransformTrade(trade)
@@ -297,8 +514,8 @@ func TestTransformBalance(t *testing.T) {
 		balance := &model.Balance{
 			UserID:    1,
 			Asset:     "USDT",
-			Available: 10000.0,
-			Locked:    500.0,
+			Available: decimal.NewFromFloat(10000.0),
+			Locked:    decimal.NewFromFloat(500.0),
 		}
 
 		// When: 转换
@@ -314,9 +531,9 @@ func TestTransformBalance(t *testing.T) {
 	t.Run("Transform multiple balances", func(t *testing.T) {
 		// Given: 多个资产余额
 		balances := []*model.Balance{
-			{Asset: "BTC", Available: 1.5, Locked: 0.5},
-			{Asset: "ETH", Available: 10.0, Locked: 0.0},
-			{Asset: "USDT", Available: 5000.0, Locked: 1000.0},
+			{Asset: "BTC", Available: decimal.NewFromFloat(1.5), Locked: decimal.NewFromFloat(0.5)},
+			{Asset: "ETH", Available: decimal.NewFromFloat(10.0), Locked: decimal.NewFromFloat(0.0)},
+			{Asset: "USDT", Available: decimal.NewFromFloat(5000.0), Locked: decimal.NewFromFloat(1000.0)},
 		}
 
 		// When: 转换所有余额
@@ -347,12 +564,21 @@ func TestTransformBalance(t *testing.T) {
 
 func TestTransformMarket(t *testing.T) {
 	t.Run("Transform market info", func(t *testing.T) {
-		// Given: 交易对配置
-		symbol := "BTC/USDT"
-		minAmount := 0.0001
+		// Given: 交易对定义
+		m := &market.Market{
+			Symbol:      "BTC/USDT",
+			Base:        "BTC",
+			Quote:       "USDT",
+			AmountStep:  decimal.RequireFromString("0.00001"),
+			PriceTick:   decimal.RequireFromString("0.01"),
+			MinAmount:   decimal.RequireFromString("0.0001"),
+			MaxAmount:   decimal.NewFromInt(100),
+			MinNotional: decimal.NewFromInt(5),
+			Status:      market.StatusActive,
+		}
 
 		// When: 转换为 CCXT market 格式
-		result := TransformMarket(symbol, minAmount)
+		result := TransformMarket(m)
 
 		// Then: 验证格式
 		assert.Equal(t, "BTC/USDT", result["symbol"])
@@ -361,31 +587,240 @@ func TestTransformMarket(t *testing.T) {
 		assert.Equal(t, "USDT", result["quote"])
 		assert.Equal(t, true, result["active"])
 
+		precision, ok := result["precision"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, 0.00001, precision["amount"])
+		assert.Equal(t, 0.01, precision["price"])
+
 		limits, ok := result["limits"].(map[string]interface{})
 		require.True(t, ok)
 
 		amount, ok := limits["amount"].(map[string]interface{})
 		require.True(t, ok)
 		assert.Equal(t, 0.0001, amount["min"])
+		assert.Equal(t, 100.0, amount["max"])
+
+		price, ok := limits["price"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, 0.01, price["min"])
+
+		cost, ok := limits["cost"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, 5.0, cost["min"])
 	})
 
-	t.Run("Parse symbol correctly", func(t *testing.T) {
-		tests := []struct {
-			symbol string
-			base   string
-			quote  string
-		}{
-			{"BTC/USDT", "BTC", "USDT"},
-			{"ETH/USDT", "ETH", "USDT"},
-			{"SOL/USDT", "SOL", "USDT"},
+	t.Run("Inactive market without limits", func(t *testing.T) {
+		// Given: 暂停交易且未设置上限和最小名义价值的交易对
+		m := &market.Market{
+			Symbol:     "ETH/USDT",
+			Base:       "ETH",
+			Quote:      "USDT",
+			AmountStep: market.DefaultStep,
+			PriceTick:  market.DefaultStep,
+			Status:     market.StatusInactive,
 		}
 
-		for _, tt := range tests {
-			t.Run(tt.symbol, func(t *testing.T) {
-				result := TransformMarket(tt.symbol, 0.0001)
-				assert.Equal(t, tt.base, result["base"])
-				assert.Equal(t, tt.quote, result["quote"])
-			})
+		// When: 转换为 CCXT market 格式
+		result := TransformMarket(m)
+
+		// Then: active 为 false，未设置的限额为 nil
+		assert.Equal(t, false, result["active"])
+		limits := result["limits"].(map[string]interface{})
+		assert.Nil(t, limits["amount"].(map[string]interface{})["max"])
+		assert.Nil(t, limits["cost"].(map[string]interface{})["min"])
+		assert.Equal(t, "spot", result["type"])
+		assert.Nil(t, result["settle"])
+	})
+
+	t.Run("Perpetual market", func(t *testing.T) {
+		m := &market.Market{
+			Symbol:      "BTC/USDT:USDT",
+			Base:        "BTC",
+			Quote:       "USDT",
+			Settle:      "USDT",
+			AmountStep:  market.DefaultStep,
+			PriceTick:   market.DefaultStep,
+			MaxLeverage: 40,
+			Status:      market.StatusActive,
+		}
+
+		result := TransformMarket(m)
+
+		assert.Equal(t, "swap", result["type"])
+		assert.Equal(t, true, result["swap"])
+		assert.Equal(t, false, result["spot"])
+		assert.Equal(t, true, result["linear"])
+		assert.Equal(t, "USDT", result["settle"])
+		leverage := result["limits"].(map[string]interface{})["leverage"].(map[string]interface{})
+		assert.Equal(t, 40.0, leverage["max"])
+	})
+}
+
+func TestTransformPosition(t *testing.T) {
+	// Given: 10 倍杠杆空仓 0.2 @ 50000，保证金 1000
+	pos := &model.Position{
+		ID:          3,
+		Symbol:      "BTC/USDT:USDT",
+		Side:        "short",
+		Size:        decimal.NewFromFloat(0.2),
+		EntryPrice:  decimal.NewFromInt(50000),
+		Leverage:    10,
+		MarginMode:  "cross",
+		Margin:      decimal.NewFromInt(1000),
+		RealizedPnl: decimal.NewFromInt(25),
+		UpdatedAt:   time.Now(),
+	}
+
+	// When: 标记价格 49000，未实现盈利 200
+	result := TransformPosition(pos, decimal.NewFromInt(49000), decimal.NewFromInt(200))
+
+	// Then: 验证 CCXT position 字段
+	assert.Equal(t, "3", result["id"])
+	assert.Equal(t, "short", result["side"])
+	assert.Equal(t, 0.2, result["contracts"])
+	assert.Equal(t, 50000.0, result["entryPrice"])
+	assert.Equal(t, 49000.0, result["markPrice"])
+	assert.Equal(t, 9800.0, result["notional"])
+	assert.Equal(t, 10, result["leverage"])
+	assert.Equal(t, false, result["isolated"])
+	assert.Equal(t, 200.0, result["unrealizedPnl"])
+	assert.Equal(t, 25.0, result["realizedPnl"])
+	assert.Equal(t, 20.0, result["percentage"])
+}
+
+// decimalPtr 测试辅助：构造精确小数指针
+func decimalPtr(v float64) *decimal.Decimal {
+	d := decimal.NewFromFloat(v)
+	return &d
+}
+
+func TestTransformFunding(t *testing.T) {
+	fundingTime := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	t.Run("Funding rate with previous settlement", func(t *testing.T) {
+		current := &model.FundingRate{
+			Symbol:      "BTC/USDT:USDT",
+			Rate:        decimal.NewFromFloat(0.0003),
+			MarkPrice:   decimal.NewFromInt(50005),
+			IndexPrice:  decimal.NewFromInt(49995),
+			FundingTime: fundingTime.Add(8 * time.Hour),
+			CreatedAt:   fundingTime.Add(time.Hour),
 		}
+		previous := &model.FundingRate{Symbol: "BTC/USDT:USDT", Rate: decimal.NewFromFloat(-0.0001), FundingTime: fundingTime}
+
+		result := TransformFundingRate(current, previous, 8*time.Hour)
+
+		assert.Equal(t, 0.0003, result["fundingRate"])
+		assert.Equal(t, 50005.0, result["markPrice"])
+		assert.Equal(t, 49995.0, result["indexPrice"])
+		assert.Equal(t, fundingTime.Add(8*time.Hour).UnixMilli(), result["fundingTimestamp"])
+		assert.Equal(t, -0.0001, result["previousFundingRate"])
+		assert.Equal(t, fundingTime.UnixMilli(), result["previousFundingTimestamp"])
+		assert.Equal(t, "8h", result["interval"])
+
+		result = TransformFundingRate(current, nil, 30*time.Minute)
+		assert.Nil(t, result["previousFundingRate"])
+		assert.Equal(t, "30m", result["interval"])
+	})
+
+	t.Run("Funding payment uses the settle currency", func(t *testing.T) {
+		payment := &model.FundingPayment{
+			ID:          7,
+			Symbol:      "BTC/USDT:USDT",
+			Amount:      decimal.NewFromFloat(-1.0001),
+			FundingTime: fundingTime,
+		}
+
+		result := TransformFundingPayment(payment)
+
+		assert.Equal(t, "7", result["id"])
+		assert.Equal(t, "USDT", result["code"])
+		assert.Equal(t, -1.0001, result["amount"])
+		assert.Equal(t, fundingTime.UnixMilli(), result["timestamp"])
+	})
+}
+
+func TestTransformLiquidation(t *testing.T) {
+	createdAt := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	result := TransformLiquidation(&model.Liquidation{
+		Symbol:    "BTC/USDT:USDT",
+		Side:      "long",
+		Size:      decimal.NewFromFloat(0.5),
+		Price:     decimal.NewFromInt(44490),
+		CreatedAt: createdAt,
 	})
+
+	assert.Equal(t, "BTC/USDT:USDT", result["symbol"])
+	assert.Equal(t, 0.5, result["contracts"])
+	assert.Equal(t, 44490.0, result["price"])
+	assert.Equal(t, 22245.0, result["quoteValue"])
+	assert.Equal(t, createdAt.UnixMilli(), result["timestamp"])
+}
+
+// TestTransformMarginLoan 测试现货杠杆借还款、利息记录和余额负债的转换
+func TestTransformMarginLoan(t *testing.T) {
+	createdAt := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	t.Run("Repayment amount includes interest", func(t *testing.T) {
+		result := TransformMarginLoan(&model.MarginLoan{
+			ID:        7,
+			Asset:     "USDT",
+			Type:      "repay",
+			Amount:    decimal.NewFromFloat(0.3),
+			Interest:  decimal.NewFromFloat(0.2),
+			CreatedAt: createdAt,
+		})
+
+		assert.Equal(t, "7", result["id"])
+		assert.Equal(t, "USDT", result["currency"])
+		assert.Equal(t, 0.5, result["amount"])
+		assert.Nil(t, result["symbol"])
+		assert.Equal(t, createdAt.UnixMilli(), result["timestamp"])
+	})
+
+	t.Run("Borrow interest", func(t *testing.T) {
+		result := TransformBorrowInterest(&model.BorrowInterest{
+			Asset:     "BTC",
+			Borrowed:  decimal.NewFromFloat(0.2),
+			Rate:      decimal.NewFromFloat(0.0001),
+			Interest:  decimal.NewFromFloat(0.00002),
+			AccruedAt: createdAt,
+		})
+
+		assert.Equal(t, "BTC", result["currency"])
+		assert.Equal(t, 0.00002, result["interest"])
+		assert.Equal(t, 0.0001, result["interestRate"])
+		assert.Equal(t, 0.2, result["amountBorrowed"])
+		assert.Equal(t, "cross", result["marginMode"])
+		assert.Equal(t, createdAt.UnixMilli(), result["timestamp"])
+	})
+
+	t.Run("Balance debt is reported only when borrowed", func(t *testing.T) {
+		result := TransformBalances([]*model.Balance{
+			{Asset: "BTC", Available: decimal.NewFromFloat(0.2), Borrowed: decimal.NewFromFloat(0.2), Interest: decimal.NewFromFloat(0.00002)},
+			{Asset: "USDT", Available: decimal.NewFromInt(1000)},
+		})
+
+		assert.Equal(t, 0.20002, result["BTC"].(map[string]interface{})["debt"])
+		assert.NotContains(t, result["USDT"], "debt")
+	})
+}
+
+// TestTransformMarginModification 测试逐仓保证金调整结果的转换
+func TestTransformMarginModification(t *testing.T) {
+	updatedAt := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	result := TransformMarginModification(&model.Position{
+		Symbol:     "BTC/USDT:USDT",
+		MarginMode: "isolated",
+		Margin:     decimal.NewFromFloat(1500.2),
+		UpdatedAt:  updatedAt,
+	}, "add", decimal.NewFromInt(500))
+
+	assert.Equal(t, "BTC/USDT:USDT", result["symbol"])
+	assert.Equal(t, "add", result["type"])
+	assert.Equal(t, "isolated", result["marginMode"])
+	assert.Equal(t, 500.0, result["amount"])
+	assert.Equal(t, 1500.2, result["total"])
+	assert.Equal(t, "USDT", result["code"])
+	assert.Equal(t, updatedAt.UnixMilli(), result["timestamp"])
 }
@@ -36,11 +36,29 @@ type DatabaseConfig struct {
 }
 
 type MarketConfig struct {
-	UpdateInterval string            `mapstructure:"update_interval"`
-	DataSource     string            `mapstructure:"data_source"`
-	APIURL         string            `mapstructure:"api_url"`
-	Symbols        []string          `mapstructure:"symbols"`
-	Hyperliquid    HyperliquidConfig `mapstructure:"hyperliquid"`
+	UpdateInterval string                   `mapstructure:"update_interval"`
+	DataSource     string                   `mapstructure:"data_source"`
+	APIURL         string                   `mapstructure:"api_url"`
+	Symbols        []string                 `mapstructure:"symbols"`
+	Definitions    []MarketDefinitionConfig `mapstructure:"definitions"`
+	Hyperliquid    HyperliquidConfig        `mapstructure:"hyperliquid"`
+}
+
+// MarketDefinitionConfig 交易对规则（步长、限额、状态），零值字段使用默认值
+type MarketDefinitionConfig struct {
+	Symbol      string  `mapstructure:"symbol"`
+	Base        string  `mapstructure:"base"`         // 基础币，默认取交易对 "/" 前部分
+	Quote       string  `mapstructure:"quote"`        // 计价币，默认取交易对 "/" 后部分
+	AmountStep  float64 `mapstructure:"amount_step"`  // 数量步长，默认 0.00000001
+	PriceTick   float64 `mapstructure:"price_tick"`   // 价格最小变动单位，默认 0.00000001
+	MinAmount   float64 `mapstructure:"min_amount"`   // 最小数量，默认 trading.min_order_amount
+	MaxAmount   float64 `mapstructure:"max_amount"`   // 最大数量，0 表示不限
+	MinNotional float64 `mapstructure:"min_notional"` // 最小名义价值（数量 × 价格，计价币），0 表示不限
+	MaxLeverage int     `mapstructure:"max_leverage"` // 永续合约最大杠杆，0 表示使用 trading.perpetual.max_leverage
+	Status      string  `mapstructure:"status"`       // active(默认) | inactive
+
+	// MaintenanceMarginRate 永续合约维持保证金率，0 表示使用 trading.perpetual.maintenance_margin_rate
+	MaintenanceMarginRate float64 `mapstructure:"maintenance_margin_rate"`
 }
 
 type HyperliquidConfig struct {
@@ -53,6 +71,292 @@ type TradingConfig struct {
 	MakerFeeRate   float64 `mapstructure:"maker_fee_rate"`
 	TakerFeeRate   float64 `mapstructure:"taker_fee_rate"`
 	MinOrderAmount float64 `mapstructure:"min_order_amount"`
+	// FeeTiers 按 30 天成交额划分的手续费等级，未达到任何等级时使用 maker_fee_rate/taker_fee_rate
+	FeeTiers []FeeTierConfig `mapstructure:"fee_tiers"`
+	FeeToken FeeTokenConfig  `mapstructure:"fee_token"`
+	// MarketBuySlippage 市价买单冻结资金的滑点缓冲（0.05 表示按当前价上浮 5% 冻结），成交后按实际成交金额退还差额
+	MarketBuySlippage float64         `mapstructure:"market_buy_slippage"`
+	Liquidity         LiquidityConfig `mapstructure:"liquidity"`
+	OrderBook         OrderBookConfig `mapstructure:"order_book"`
+	Matching          MatchingConfig  `mapstructure:"matching"`
+	Algo              AlgoConfig      `mapstructure:"algo"`
+	Realism           RealismConfig   `mapstructure:"realism"`
+	Perpetual         PerpetualConfig `mapstructure:"perpetual"`
+	Funding           FundingConfig   `mapstructure:"funding"`
+
+	Liquidation LiquidationConfig `mapstructure:"liquidation"`
+	SpotMargin  SpotMarginConfig  `mapstructure:"spot_margin"`
+}
+
+// PerpetualConfig 永续合约配置（交易对符号为 BASE/QUOTE:SETTLE）
+type PerpetualConfig struct {
+	DefaultLeverage int `mapstructure:"default_leverage"` // 未设置杠杆时使用的杠杆，默认 1
+	MaxLeverage     int `mapstructure:"max_leverage"`     // 最大杠杆，默认 50；交易对可通过 max_leverage 覆盖
+
+	// MarkPrice 标记价格来源：mid(默认，盘口中间价) | index（同名现货 BASE/QUOTE 的最新价）
+	MarkPrice string `mapstructure:"mark_price"`
+	// MaintenanceMarginRate 维持保证金率（按标记价格计算的名义价值），默认 0.005；交易对可通过 maintenance_margin_rate 覆盖
+	MaintenanceMarginRate float64 `mapstructure:"maintenance_margin_rate"`
+}
+
+// Leverage 返回默认杠杆（不超过 maxLeverage）
+func (c *PerpetualConfig) Leverage(maxLeverage int) int {
+	leverage := c.DefaultLeverage
+	if leverage <= 0 {
+		leverage = 1
+	}
+	return min(leverage, maxLeverage)
+}
+
+// LeverageLimit 返回交易对的最大杠杆，marketMax 为交易对配置（0 表示未配置）
+func (c *PerpetualConfig) LeverageLimit(marketMax int) int {
+	if marketMax > 0 {
+		return marketMax
+	}
+	if c.MaxLeverage > 0 {
+		return c.MaxLeverage
+	}
+	return 50
+}
+
+// MaintenanceRate 返回交易对的维持保证金率，marketRate 为交易对配置（0 表示未配置）
+func (c *PerpetualConfig) MaintenanceRate(marketRate float64) float64 {
+	if marketRate > 0 {
+		return marketRate
+	}
+	if c.MaintenanceMarginRate > 0 {
+		return c.MaintenanceMarginRate
+	}
+	return 0.005
+}
+
+// LiquidationConfig 永续合约强平配置
+// 账户权益（结算币余额 + 未实现盈亏）低于维持保证金时，以市价单平掉该结算币下的全部持仓
+type LiquidationConfig struct {
+	FeeRate       float64 `mapstructure:"fee_rate"`       // 强平手续费率（按平仓成交金额），从剩余权益中扣除并计入保险基金
+	InsuranceFund float64 `mapstructure:"insurance_fund"` // 每个结算币保险基金的初始余额，用于承担穿仓亏损
+}
+
+// SpotMarginConfig 现货杠杆借贷配置：以账户全部资产（按 USDT 估值）为抵押借入资产，按小时计息
+// 保证金水平 = 总资产 / (借款 + 未还利息)
+type SpotMarginConfig struct {
+	HourlyRate       float64             `mapstructure:"hourly_rate"`       // 默认小时利率，资产未单独配置时使用
+	BorrowLevel      float64             `mapstructure:"borrow_level"`      // 借款后保证金水平不得低于该值，默认 2
+	LiquidationLevel float64             `mapstructure:"liquidation_level"` // 保证金水平低于该值时自动强平，默认 1.1
+	Assets           []BorrowAssetConfig `mapstructure:"assets"`            // 可借入的资产，为空时不开放借币
+}
+
+// BorrowAssetConfig 可借入资产的配置
+type BorrowAssetConfig struct {
+	Asset      string  `mapstructure:"asset"`
+	HourlyRate float64 `mapstructure:"hourly_rate"` // 小时利率，0 表示使用默认小时利率
+	MaxBorrow  float64 `mapstructure:"max_borrow"`  // 单个用户的最大借款数量，0 表示不限
+}
+
+// Enabled 是否开放借币
+func (c *SpotMarginConfig) Enabled() bool {
+	return len(c.Assets) > 0
+}
+
+// ForAsset 返回资产的借贷配置（小时利率已按默认值补全），资产不可借入时返回 false
+func (c *SpotMarginConfig) ForAsset(asset string) (BorrowAssetConfig, bool) {
+	for _, ac := range c.Assets {
+		if ac.Asset != asset {
+			continue
+		}
+		if ac.HourlyRate <= 0 {
+			ac.HourlyRate = c.HourlyRate
+		}
+		return ac, true
+	}
+	return BorrowAssetConfig{}, false
+}
+
+// MinBorrowLevel 借款后允许的最低保证金水平
+func (c *SpotMarginConfig) MinBorrowLevel() float64 {
+	if c.BorrowLevel > 0 {
+		return c.BorrowLevel
+	}
+	return 2
+}
+
+// MinLiquidationLevel 触发强平的保证金水平
+func (c *SpotMarginConfig) MinLiquidationLevel() float64 {
+	if c.LiquidationLevel > 0 {
+		return c.LiquidationLevel
+	}
+	return 1.1
+}
+
+// FundingConfig 永续合约资金费率配置：每个结算周期按费率在多空持仓之间结算资金费
+// 费率为正时多头支付、空头收取，金额 = 持仓数量 * 标记价格 * 费率
+type FundingConfig struct {
+	Enabled      bool    `mapstructure:"enabled"`
+	Interval     string  `mapstructure:"interval"`      // 结算周期，默认 8h；结算时间按周期对齐（UTC 00:00 起）
+	Mode         string  `mapstructure:"mode"`          // fixed(默认) | premium | replay
+	Rate         float64 `mapstructure:"rate"`          // fixed 模式的每周期费率
+	InterestRate float64 `mapstructure:"interest_rate"` // premium 模式叠加在溢价指数上的利率部分
+	Cap          float64 `mapstructure:"cap"`           // 费率绝对值上限，0 表示不限制
+	ReplayFile   string  `mapstructure:"replay_file"`   // replay 模式的费率文件（每行 symbol,rate），按交易对依次使用，用完后重复最后一条
+}
+
+// FeeTierConfig 手续费等级：最近 30 天成交额（计价币）达到 MinVolume 时适用的费率
+type FeeTierConfig struct {
+	MinVolume    float64 `mapstructure:"min_volume"`
+	MakerFeeRate float64 `mapstructure:"maker_fee_rate"`
+	TakerFeeRate float64 `mapstructure:"taker_fee_rate"`
+}
+
+// FeeTokenConfig 平台币抵扣手续费：开启的用户按折扣以平台币支付手续费
+// 买入平台币本身时手续费按折扣从收到的平台币中扣除
+type FeeTokenConfig struct {
+	Asset    string  `mapstructure:"asset"`    // 平台币资产，为空时不启用
+	Symbol   string  `mapstructure:"symbol"`   // 换算价格使用的行情交易对，默认 <asset>/USDT
+	Discount float64 `mapstructure:"discount"` // 折扣比例（0.25 表示按手续费的 75% 收取）
+}
+
+// Enabled 是否启用平台币抵扣手续费
+func (c *FeeTokenConfig) Enabled() bool {
+	return c.Asset != ""
+}
+
+// PriceSymbol 平台币的行情交易对
+func (c *FeeTokenConfig) PriceSymbol() string {
+	if c.Symbol != "" {
+		return c.Symbol
+	}
+	return c.Asset + "/USDT"
+}
+
+// AlgoConfig 算法单（TWAP / 冰山单）调度配置
+type AlgoConfig struct {
+	SchedulerInterval string `mapstructure:"scheduler_interval"` // 调度间隔，默认 1s
+}
+
+// RealismConfig 执行真实性模拟：下单确认/撮合延迟、随机拒单和按交易对的部分故障
+// 随机数由 Seed 初始化，相同种子和相同的请求顺序得到相同的模拟结果
+type RealismConfig struct {
+	Enabled        bool                  `mapstructure:"enabled"`
+	Seed           int64                 `mapstructure:"seed"`            // 随机种子，0 表示按启动时间生成
+	AckLatency     LatencyConfig         `mapstructure:"ack_latency"`     // 下单确认延迟（下单请求返回前）
+	FillLatency    LatencyConfig         `mapstructure:"fill_latency"`    // 撮合延迟（订单首次撮合前）
+	RejectRate     float64               `mapstructure:"reject_rate"`     // 随机拒单概率（0-1）
+	OutageRate     float64               `mapstructure:"outage_rate"`     // 每次撮合时交易对进入故障的概率（0-1）
+	OutageDuration string                `mapstructure:"outage_duration"` // 故障持续时间，默认 30s；故障期间拒绝新订单、暂停撮合
+	Symbols        []SymbolRealismConfig `mapstructure:"symbols"`         // 按交易对覆盖默认配置
+}
+
+// LatencyConfig 延迟分布（单位：毫秒）
+type LatencyConfig struct {
+	Distribution string  `mapstructure:"distribution"` // fixed(默认) | uniform | normal | exponential
+	Mean         float64 `mapstructure:"mean_ms"`      // fixed/normal/exponential 的平均延迟
+	StdDev       float64 `mapstructure:"stddev_ms"`    // normal 的标准差
+	Min          float64 `mapstructure:"min_ms"`       // uniform 的下限；所有分布的结果不小于 min
+	Max          float64 `mapstructure:"max_ms"`       // uniform 的上限；大于 0 时所有分布的结果不大于 max
+}
+
+// SymbolRealismConfig 单个交易对的执行真实性配置
+type SymbolRealismConfig struct {
+	Symbol         string        `mapstructure:"symbol"`
+	AckLatency     LatencyConfig `mapstructure:"ack_latency"`
+	FillLatency    LatencyConfig `mapstructure:"fill_latency"`
+	RejectRate     float64       `mapstructure:"reject_rate"`
+	OutageRate     float64       `mapstructure:"outage_rate"`
+	OutageDuration string        `mapstructure:"outage_duration"`
+}
+
+// ForSymbol 返回指定交易对生效的执行真实性配置（交易对配置覆盖全局默认值）
+func (c *RealismConfig) ForSymbol(symbol string) SymbolRealismConfig {
+	result := SymbolRealismConfig{
+		Symbol:         symbol,
+		AckLatency:     c.AckLatency,
+		FillLatency:    c.FillLatency,
+		RejectRate:     c.RejectRate,
+		OutageRate:     c.OutageRate,
+		OutageDuration: c.OutageDuration,
+	}
+
+	for _, sc := range c.Symbols {
+		if sc.Symbol != symbol {
+			continue
+		}
+		if sc.AckLatency != (LatencyConfig{}) {
+			result.AckLatency = sc.AckLatency
+		}
+		if sc.FillLatency != (LatencyConfig{}) {
+			result.FillLatency = sc.FillLatency
+		}
+		if sc.RejectRate > 0 {
+			result.RejectRate = sc.RejectRate
+		}
+		if sc.OutageRate > 0 {
+			result.OutageRate = sc.OutageRate
+		}
+		if sc.OutageDuration != "" {
+			result.OutageDuration = sc.OutageDuration
+		}
+		break
+	}
+
+	if result.OutageDuration == "" {
+		result.OutageDuration = "30s"
+	}
+	return result
+}
+
+// MatchingConfig 撮合模式配置
+type MatchingConfig struct {
+	Mode                string                 `mapstructure:"mode"`                  // external(默认): 只按外部行情成交 | internal: 优先与其他用户的挂单撮合
+	AmendPriority       string                 `mapstructure:"amend_priority"`        // 改单后的时间优先级：reset(默认): 重新排队 | keep: 保持 | reduce_only: 只减少数量时保持
+	SelfTradePrevention string                 `mapstructure:"self_trade_prevention"` // 同一用户订单相互成交时的处理：none(默认): 允许成交 | cancel_newest | cancel_oldest | cancel_both | decrement
+	Symbols             []SymbolMatchingConfig `mapstructure:"symbols"`               // 按交易对覆盖撮合模式
+}
+
+// SymbolMatchingConfig 单个交易对的撮合模式
+type SymbolMatchingConfig struct {
+	Symbol string `mapstructure:"symbol"`
+	Mode   string `mapstructure:"mode"`
+}
+
+// ModeForSymbol 返回指定交易对生效的撮合模式
+func (c *MatchingConfig) ModeForSymbol(symbol string) string {
+	for _, sc := range c.Symbols {
+		if sc.Symbol == symbol && sc.Mode != "" {
+			return sc.Mode
+		}
+	}
+	if c.Mode == "" {
+		return "external"
+	}
+	return c.Mode
+}
+
+// AmendKeepsPriority 改单后是否保留原有的时间优先级
+func (c *MatchingConfig) AmendKeepsPriority(priceChanged, amountIncreased bool) bool {
+	switch c.AmendPriority {
+	case "keep":
+		return true
+	case "reduce_only":
+		return !priceChanged && !amountIncreased
+	default:
+		return false
+	}
+}
+
+// LiquidityConfig 流动性模型配置（决定单次撮合最多可成交的数量）
+type LiquidityConfig struct {
+	Model       string                  `mapstructure:"model"`        // unlimited(默认) | fixed | volume
+	Depth       float64                 `mapstructure:"depth"`        // fixed: 盘口可成交数量；volume: 无成交量数据时的后备值
+	VolumeRatio float64                 `mapstructure:"volume_ratio"` // volume: 盘口数量 = 24h 成交量 * 比例
+	Symbols     []SymbolLiquidityConfig `mapstructure:"symbols"`      // 按交易对覆盖默认配置
+}
+
+// SymbolLiquidityConfig 单个交易对的流动性配置
+type SymbolLiquidityConfig struct {
+	Symbol      string  `mapstructure:"symbol"`
+	Model       string  `mapstructure:"model"`
+	Depth       float64 `mapstructure:"depth"`
+	VolumeRatio float64 `mapstructure:"volume_ratio"`
 }
 
 type AuthConfig struct {
@@ -95,6 +399,97 @@ func Load() (*Config, error) {
 	return &config, nil
 }
 
+// OrderBookConfig 合成 L2 订单簿配置
+// 启用后市价单和可立即成交的限价单按档位逐档成交（取代 Liquidity 模型）
+type OrderBookConfig struct {
+	Enabled    bool                    `mapstructure:"enabled"`
+	Levels     int                     `mapstructure:"levels"`      // 每侧档位数
+	PriceStep  float64                 `mapstructure:"price_step"`  // 相邻档位价格间距（相对比例，0.0005 = 5bp）
+	BaseSize   float64                 `mapstructure:"base_size"`   // 第一档挂单数量
+	SizeGrowth float64                 `mapstructure:"size_growth"` // 每远离一档挂单数量增加的比例
+	Symbols    []SymbolOrderBookConfig `mapstructure:"symbols"`     // 按交易对覆盖默认配置
+}
+
+// SymbolOrderBookConfig 单个交易对的订单簿配置
+type SymbolOrderBookConfig struct {
+	Symbol     string  `mapstructure:"symbol"`
+	Levels     int     `mapstructure:"levels"`
+	PriceStep  float64 `mapstructure:"price_step"`
+	BaseSize   float64 `mapstructure:"base_size"`
+	SizeGrowth float64 `mapstructure:"size_growth"`
+}
+
+// ForSymbol 返回指定交易对生效的订单簿配置（未配置的字段使用默认值）
+func (c *OrderBookConfig) ForSymbol(symbol string) SymbolOrderBookConfig {
+	result := SymbolOrderBookConfig{
+		Symbol:     symbol,
+		Levels:     c.Levels,
+		PriceStep:  c.PriceStep,
+		BaseSize:   c.BaseSize,
+		SizeGrowth: c.SizeGrowth,
+	}
+
+	for _, sc := range c.Symbols {
+		if sc.Symbol != symbol {
+			continue
+		}
+		if sc.Levels > 0 {
+			result.Levels = sc.Levels
+		}
+		if sc.PriceStep > 0 {
+			result.PriceStep = sc.PriceStep
+		}
+		if sc.BaseSize > 0 {
+			result.BaseSize = sc.BaseSize
+		}
+		if sc.SizeGrowth > 0 {
+			result.SizeGrowth = sc.SizeGrowth
+		}
+		break
+	}
+
+	// 默认值
+	if result.Levels <= 0 {
+		result.Levels = 20
+	}
+	if result.PriceStep <= 0 {
+		result.PriceStep = 0.0005
+	}
+	if result.BaseSize <= 0 {
+		result.BaseSize = 1.0
+	}
+
+	return result
+}
+
+// ForSymbol 返回指定交易对生效的流动性配置（交易对配置覆盖全局默认值）
+func (c *LiquidityConfig) ForSymbol(symbol string) SymbolLiquidityConfig {
+	result := SymbolLiquidityConfig{
+		Symbol:      symbol,
+		Model:       c.Model,
+		Depth:       c.Depth,
+		VolumeRatio: c.VolumeRatio,
+	}
+
+	for _, sc := range c.Symbols {
+		if sc.Symbol != symbol {
+			continue
+		}
+		if sc.Model != "" {
+			result.Model = sc.Model
+		}
+		if sc.Depth > 0 {
+			result.Depth = sc.Depth
+		}
+		if sc.VolumeRatio > 0 {
+			result.VolumeRatio = sc.VolumeRatio
+		}
+		break
+	}
+
+	return result
+}
+
 // GetDSN 返回数据库连接字符串
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf(
@@ -538,3 +538,67 @@ func TestLoggingConfig(t *testing.T) {
 		}
 	})
 }
+
+// TestLiquidityConfigForSymbol 测试按交易对覆盖流动性配置
+func TestLiquidityConfigForSymbol(t *testing.T) {
+	cfg := LiquidityConfig{
+		Model:       "fixed",
+		Depth:       1.0,
+		VolumeRatio: 0.001,
+		Symbols: []SymbolLiquidityConfig{
+			{Symbol: "ETH/USDT", Depth: 10.0},
+			{Symbol: "SOL/USDT", Model: "volume", VolumeRatio: 0.01},
+		},
+	}
+
+	btc := cfg.ForSymbol("BTC/USDT")
+	assert.Equal(t, "fixed", btc.Model)
+	assert.Equal(t, 1.0, btc.Depth)
+
+	eth := cfg.ForSymbol("ETH/USDT")
+	assert.Equal(t, "fixed", eth.Model)
+	assert.Equal(t, 10.0, eth.Depth)
+
+	sol := cfg.ForSymbol("SOL/USDT")
+	assert.Equal(t, "volume", sol.Model)
+	assert.Equal(t, 1.0, sol.Depth)
+	assert.Equal(t, 0.01, sol.VolumeRatio)
+}
+
+// TestMatchingConfigModeForSymbol 测试按交易对选择撮合模式
+func TestMatchingConfigModeForSymbol(t *testing.T) {
+	cfg := MatchingConfig{
+		Symbols: []SymbolMatchingConfig{
+			{Symbol: "BTC/USDT", Mode: "internal"},
+		},
+	}
+
+	assert.Equal(t, "internal", cfg.ModeForSymbol("BTC/USDT"))
+	assert.Equal(t, "external", cfg.ModeForSymbol("ETH/USDT"))
+
+	cfg.Mode = "internal"
+	assert.Equal(t, "internal", cfg.ModeForSymbol("ETH/USDT"))
+}
+
+// TestRealismConfigForSymbol 测试按交易对覆盖执行真实性配置
+func TestRealismConfigForSymbol(t *testing.T) {
+	cfg := RealismConfig{
+		AckLatency: LatencyConfig{Mean: 10},
+		RejectRate: 0.01,
+		Symbols: []SymbolRealismConfig{
+			{Symbol: "ETH/USDT", AckLatency: LatencyConfig{Distribution: "uniform", Min: 5, Max: 50}, OutageRate: 0.1, OutageDuration: "1m"},
+		},
+	}
+
+	btc := cfg.ForSymbol("BTC/USDT")
+	assert.Equal(t, 10.0, btc.AckLatency.Mean)
+	assert.Equal(t, 0.01, btc.RejectRate)
+	assert.Zero(t, btc.OutageRate)
+	assert.Equal(t, "30s", btc.OutageDuration)
+
+	eth := cfg.ForSymbol("ETH/USDT")
+	assert.Equal(t, "uniform", eth.AckLatency.Distribution)
+	assert.Equal(t, 0.01, eth.RejectRate)
+	assert.Equal(t, 0.1, eth.OutageRate)
+	assert.Equal(t, "1m", eth.OutageDuration)
+}
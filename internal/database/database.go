@@ -59,8 +59,18 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.User{},
 		&model.Balance{},
 		&model.Order{},
+		&model.OrderList{},
 		&model.Trade{},
+		&model.UserFeeRate{},
+		&model.Position{},
+		&model.FundingRate{},
+		&model.FundingPayment{},
+		&model.Liquidation{},
+		&model.InsuranceFund{},
+		&model.MarginLoan{},
+		&model.BorrowInterest{},
 		&model.Ticker{},
+		&model.Market{},
 		&model.Kline{},
 	)
 }
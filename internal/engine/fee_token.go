@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// applyFeeToken 用户开启平台币抵扣时，将手续费按折扣换算为平台币并从可用余额扣除（在创建成交记录前调用）
+// 收到的资产就是平台币时（如买入 QSX/USDT）直接按折扣从收到的平台币中扣除
+// 平台币余额不足、没有平台币行情或计价币不一致时仍从收到的资产中全额扣除
+func (m *MatchingEngine) applyFeeToken(tx *gorm.DB, order *model.Order, trade *model.Trade) error {
+	token := &m.cfg.Trading.FeeToken
+	if !token.Enabled() || !trade.Fee.IsPositive() {
+		return nil
+	}
+
+	var user model.User
+	if err := tx.Select("id", "pay_fees_with_token").First(&user, order.UserID).Error; err != nil || !user.PayFeesWithToken {
+		return nil
+	}
+
+	discount := decimal.NewFromInt(1).Sub(decimal.NewFromFloat(token.Discount))
+	if trade.FeeAsset == token.Asset {
+		trade.Fee = CeilAsset(trade.Fee.Mul(discount))
+		return nil
+	}
+
+	// 手续费按计价币估值，再按平台币最新价换算
+	_, quoteCoin := m.splitSymbol(order.Symbol)
+	_, tokenQuote := m.splitSymbol(token.PriceSymbol())
+	if tokenQuote != quoteCoin {
+		return nil
+	}
+	var ticker model.Ticker
+	if err := tx.Where("symbol = ?", token.PriceSymbol()).First(&ticker).Error; err != nil || ticker.LastPrice <= 0 {
+		return nil
+	}
+
+	feeValue := trade.Fee
+	if trade.FeeAsset != quoteCoin {
+		feeValue = trade.Fee.Mul(trade.Price)
+	}
+	tokenFee := CeilAsset(feeValue.Mul(discount).Div(decimal.NewFromFloat(ticker.LastPrice)))
+
+	var balance model.Balance
+	if err := tx.Where("user_id = ? AND asset = ?", order.UserID, token.Asset).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&balance).Error; err != nil || balance.Available.LessThan(tokenFee) {
+		return nil
+	}
+	balance.Available = balance.Available.Sub(tokenFee)
+	if err := tx.Save(&balance).Error; err != nil {
+		return fmt.Errorf("failed to charge fee token: %w", err)
+	}
+
+	trade.Fee = tokenFee
+	trade.FeeAsset = token.Asset
+	return nil
+}
+
+// netOfFee 收到的资产扣除手续费后的数量（手续费以平台币支付时不扣除）
+func netOfFee(trade *model.Trade, asset string, received decimal.Decimal) decimal.Decimal {
+	if trade.FeeAsset != asset {
+		return received
+	}
+	return received.Sub(trade.Fee)
+}
@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// matchInternal 与其他挂单按价格-时间优先撮合（内部撮合模式）
+// 先挂单的一方为 maker，成交价使用 maker 的限价；返回 order 一方的成交记录
+func (m *MatchingEngine) matchInternal(order *model.Order) ([]*model.Trade, error) {
+	var trades []*model.Trade
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		counterparties, err := m.lockCounterparties(tx, order)
+		if err != nil {
+			return err
+		}
+
+		for i := range counterparties {
+			if !isMatchableStatus(order.Status) || !remainingAmount(order).IsPositive() {
+				break
+			}
+
+			counter := &counterparties[i]
+			amount := decimal.Min(remainingAmount(order), remainingAmount(counter))
+			if !amount.IsPositive() {
+				continue
+			}
+
+			// 价格-时间优先：先进入订单簿的一方是 maker，按 maker 价格成交
+			maker, taker := counter, order
+			if isEarlier(order, counter) {
+				maker, taker = order, counter
+			}
+			if maker.Price == nil {
+				continue
+			}
+			price := *maker.Price
+
+			// 同一用户的订单按自成交防护模式处理，不成交
+			if m.isSelfTrade(maker, taker) {
+				if err := m.preventSelfTrade(tx, maker, taker); err != nil {
+					return fmt.Errorf("failed to prevent self-trade: %w", err)
+				}
+				continue
+			}
+
+			// 按金额下单的市价买单不能超出剩余预算
+			amount = m.capToBudget(order, price, amount)
+			if !amount.IsPositive() {
+				break
+			}
+
+			takerTrade, err := m.createTradeRecord(tx, taker, price, amount, false)
+			if err != nil {
+				return fmt.Errorf("failed to create taker trade: %w", err)
+			}
+			if err := m.updateOrderStatus(tx, taker, takerTrade); err != nil {
+				return fmt.Errorf("failed to update taker order: %w", err)
+			}
+
+			makerTrade, err := m.createTradeRecord(tx, maker, price, amount, true)
+			if err != nil {
+				return fmt.Errorf("failed to create maker trade: %w", err)
+			}
+			if err := m.updateOrderStatus(tx, maker, makerTrade); err != nil {
+				return fmt.Errorf("failed to update maker order: %w", err)
+			}
+
+			if taker == order {
+				trades = append(trades, takerTrade)
+			} else {
+				trades = append(trades, makerTrade)
+			}
+
+			m.logger.Info("Orders matched internally",
+				zap.String("symbol", order.Symbol),
+				zap.Uint("maker_order_id", maker.ID),
+				zap.Uint("taker_order_id", taker.ID),
+				zap.Stringer("price", price),
+				zap.Stringer("amount", amount),
+			)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+// lockCounterparties 重新加载订单并锁定订单和可成交的对手方挂单，返回按价格优先、时间优先排序的对手方
+// 先不加锁查询候选对手方，再按 id 升序一次锁定全部行：两个交叉订单同时撮合时加锁顺序一致，不会死锁
+// 订单已不可撮合时返回空列表
+func (m *MatchingEngine) lockCounterparties(tx *gorm.DB, order *model.Order) ([]model.Order, error) {
+	if err := tx.First(order, order.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load order: %w", err)
+	}
+	if !isMatchableStatus(order.Status) {
+		return nil, nil
+	}
+	candidates, err := m.findCounterparties(tx, order, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint{order.ID}
+	for _, c := range candidates {
+		ids = append(ids, c.ID)
+	}
+	var locked []model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id ASC").
+		Find(&locked).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock orders: %w", err)
+	}
+	for _, o := range locked {
+		if o.ID == order.ID {
+			*order = o
+		}
+	}
+	if !isMatchableStatus(order.Status) {
+		return nil, nil
+	}
+
+	// 加锁后按最新状态和价格重新筛选并排序
+	return m.findCounterparties(tx, order, ids[1:])
+}
+
+// findCounterparties 查询可与订单成交的对手方挂单，按价格优先、时间优先排序；ids 不为空时只在其中查找
+func (m *MatchingEngine) findCounterparties(tx *gorm.DB, order *model.Order, ids []uint) ([]model.Order, error) {
+	oppositeSide := "sell"
+	priceOrder := "price ASC"
+	if order.Side == "sell" {
+		oppositeSide = "buy"
+		priceOrder = "price DESC"
+	}
+
+	query := tx.Where("symbol = ? AND side = ? AND type = ? AND status IN ? AND id <> ?",
+		order.Symbol, oppositeSide, "limit", []string{"new", "partially_filled"}, order.ID)
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+
+	// 限价单只与价格交叉的挂单成交；市价买单只与冻结价格能够支付的挂单成交
+	switch {
+	case order.Type == "limit":
+		if order.Price == nil {
+			return nil, fmt.Errorf("limit order must have price")
+		}
+		if order.Side == "buy" {
+			query = query.Where("price <= ?", *order.Price)
+		} else {
+			query = query.Where("price >= ?", *order.Price)
+		}
+	case order.Side == "buy" && order.QuoteOrderQty == nil:
+		if price := ReservationPrice(order); price != nil {
+			query = query.Where("price <= ?", *price)
+		}
+	}
+
+	var counterparties []model.Order
+	if err := query.Order(priceOrder).
+		Order("COALESCE(queued_at, created_at) ASC").
+		Order("id ASC").
+		Find(&counterparties).Error; err != nil {
+		return nil, fmt.Errorf("failed to query counterparties: %w", err)
+	}
+
+	return counterparties, nil
+}
+
+// isEarlier 判断订单 a 是否先于订单 b 进入订单簿
+func isEarlier(a, b *model.Order) bool {
+	if a.Type == "market" {
+		return false
+	}
+	if b.Type == "market" {
+		return true
+	}
+	if queueTime(a).Equal(queueTime(b)) {
+		return a.ID < b.ID
+	}
+	return queueTime(a).Before(queueTime(b))
+}
+
+// queueTime 订单在订单簿中排队的时间（改单失去优先级后为重新排队的时间）
+func queueTime(order *model.Order) time.Time {
+	if order.QueuedAt != nil {
+		return *order.QueuedAt
+	}
+	return order.CreatedAt
+}
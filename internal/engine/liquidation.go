@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// CancelReasonLiquidation 账户被强平时撤销的挂单
+const CancelReasonLiquidation = "liquidation"
+
+// CancelForLiquidation 撤销用户在指定交易对上的未完成订单并解冻保证金（强平前调用）
+func (m *MatchingEngine) CancelForLiquidation(userID uint, symbols []string) error {
+	var orders []model.Order
+	if err := m.db.Where("user_id = ? AND symbol IN ? AND status IN ?", userID, symbols, []string{"new", "partially_filled"}).
+		Find(&orders).Error; err != nil {
+		return fmt.Errorf("failed to query open orders: %w", err)
+	}
+
+	for i := range orders {
+		if err := m.cancelRemaining(&orders[i], "cancelled", CancelReasonLiquidation); err != nil {
+			return fmt.Errorf("failed to cancel order %d: %w", orders[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// Liquidate 以反向市价单平掉持仓并立即撮合，未能成交的部分撤销（下一次检查时重新强平）
+// 撤销挂单后重新锁定持仓，按锁定时的持仓数量下只减仓单；持仓已平掉时返回 nil
+// 强平单不冻结保证金，平仓释放的仓位保证金和盈亏按正常成交结算，全仓亏损超过保证金时可用余额为负
+func (m *MatchingEngine) Liquidate(pos *model.Position) (*model.Order, error) {
+	var order *model.Order
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		locked, err := LockPosition(tx, pos.UserID, pos.Symbol, pos.Leverage)
+		if err != nil {
+			return err
+		}
+		*pos = *locked
+		if !pos.Size.IsPositive() {
+			return nil
+		}
+
+		side := "sell"
+		if pos.Side == PositionShort {
+			side = "buy"
+		}
+		order = &model.Order{
+			UserID:      pos.UserID,
+			Symbol:      pos.Symbol,
+			Side:        side,
+			Type:        "market",
+			TimeInForce: TimeInForceIOC,
+			Status:      "new",
+			Amount:      pos.Size,
+			Leverage:    max(pos.Leverage, 1),
+			ReduceOnly:  true,
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("failed to create liquidation order: %w", err)
+		}
+		return nil
+	})
+	if err != nil || order == nil {
+		return nil, err
+	}
+	if err := m.ExecuteLiquidation(order); err != nil {
+		return nil, err
+	}
+
+	m.logger.Warn("Position liquidated",
+		zap.Uint("user_id", pos.UserID),
+		zap.String("symbol", pos.Symbol),
+		zap.String("side", pos.Side),
+		zap.Stringer("size", pos.Size),
+		zap.Uint("order_id", order.ID),
+		zap.Stringer("filled", order.Filled),
+	)
+
+	return order, nil
+}
+
+// ExecuteLiquidation 立即撮合已创建的强平单，未能成交的部分撤销并解冻剩余冻结资金
+func (m *MatchingEngine) ExecuteLiquidation(order *model.Order) error {
+	if err := m.MatchOrder(order.ID); err != nil {
+		m.logger.Error("Failed to match liquidation order", zap.Uint("order_id", order.ID), zap.Error(err))
+	}
+
+	// 交易对暂停或模拟故障时撮合会直接返回，订单仍未完成
+	return m.cancelRemaining(order, "cancelled", CancelReasonLiquidation)
+}
+
+// LockInsuranceFund 在事务中锁定结算币的保险基金，不存在时按 trading.liquidation.insurance_fund 创建
+func LockInsuranceFund(tx *gorm.DB, cfg *config.Config, asset string) (*model.InsuranceFund, error) {
+	var fund model.InsuranceFund
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("asset = ?", asset).Limit(1).Find(&fund)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to lock insurance fund: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &fund, nil
+	}
+
+	fund = model.InsuranceFund{Asset: asset, Balance: decimal.NewFromFloat(cfg.Trading.Liquidation.InsuranceFund)}
+	if err := tx.Create(&fund).Error; err != nil {
+		return nil, fmt.Errorf("failed to create insurance fund: %w", err)
+	}
+	return &fund, nil
+}
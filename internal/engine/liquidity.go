@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"github.com/shopspring/decimal"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// isMatchableStatus 判断订单状态是否允许继续撮合
+func isMatchableStatus(status string) bool {
+	return status == "new" || status == "partially_filled"
+}
+
+// remainingAmount 订单剩余未成交数量
+func remainingAmount(order *model.Order) decimal.Decimal {
+	return order.Amount.Sub(order.Filled)
+}
+
+// availableLiquidity 计算本次撮合可成交的最大数量
+// limited 为 false 表示不限制（一次全部成交）
+func (m *MatchingEngine) availableLiquidity(ticker *model.Ticker) (amount decimal.Decimal, limited bool) {
+	lc := m.cfg.Trading.Liquidity.ForSymbol(ticker.Symbol)
+
+	switch lc.Model {
+	case "fixed":
+		// 固定盘口数量
+		if lc.Depth <= 0 {
+			return decimal.Zero, false
+		}
+		return decimal.NewFromFloat(lc.Depth), true
+	case "volume":
+		// 盘口数量按 24h 成交量比例估算，缺少成交量数据时使用固定深度
+		if ticker.Volume24hBase != nil && *ticker.Volume24hBase > 0 && lc.VolumeRatio > 0 {
+			return decimal.NewFromFloat(*ticker.Volume24hBase).Mul(decimal.NewFromFloat(lc.VolumeRatio)), true
+		}
+		if lc.Depth > 0 {
+			return decimal.NewFromFloat(lc.Depth), true
+		}
+		return decimal.Zero, false
+	default:
+		return decimal.Zero, false
+	}
+}
+
+// planFills 计算订单本次撮合的成交明细
+// 启用订单簿时逐档吃单（可能产生多笔不同价格的成交），否则按流动性模型以 price 成交
+func (m *MatchingEngine) planFills(order *model.Order, ticker *model.Ticker, price decimal.Decimal) []fill {
+	remaining := remainingAmount(order)
+	if !remaining.IsPositive() {
+		return nil
+	}
+
+	if m.cfg.Trading.OrderBook.Enabled {
+		var limitPrice *decimal.Decimal
+		if order.Type == "limit" {
+			limitPrice = order.Price
+		}
+		bookCfg := m.cfg.Trading.OrderBook.ForSymbol(order.Symbol)
+		return m.books.walk(ticker, bookCfg, m.markets.ForSymbol(order.Symbol), order.Side, remaining, limitPrice, remainingBudget(order))
+	}
+
+	amount := m.capToBudget(order, price, m.fillableAmount(order, ticker))
+	if !amount.IsPositive() {
+		return nil
+	}
+	return []fill{{price: price, amount: amount}}
+}
+
+// fillableAmount 计算订单本次可成交数量：min(剩余数量, 可用流动性)
+func (m *MatchingEngine) fillableAmount(order *model.Order, ticker *model.Ticker) decimal.Decimal {
+	remaining := remainingAmount(order)
+	if !remaining.IsPositive() {
+		return decimal.Zero
+	}
+
+	liquidity, limited := m.availableLiquidity(ticker)
+	if !limited || liquidity.GreaterThanOrEqual(remaining) {
+		return remaining
+	}
+
+	// 按交易对数量步长向下截断，避免产生步长以外的残余数量
+	return m.markets.ForSymbol(order.Symbol).RoundAmount(liquidity)
+}
+
+// remainingBudget 按金额下单的市价买单剩余可花费的计价币，其他订单返回 nil（不限制）
+func remainingBudget(order *model.Order) *decimal.Decimal {
+	if order.QuoteOrderQty == nil {
+		return nil
+	}
+	budget := order.QuoteOrderQty.Sub(order.QuoteFilled)
+	return &budget
+}
+
+// capToBudget 按剩余预算限制以 price 成交的数量（按数量步长向下截断），没有预算的订单原样返回
+func (m *MatchingEngine) capToBudget(order *model.Order, price, amount decimal.Decimal) decimal.Decimal {
+	budget := remainingBudget(order)
+	if budget == nil || !price.IsPositive() {
+		return amount
+	}
+	affordable := m.markets.ForSymbol(order.Symbol).RoundAmount(budget.Div(price))
+	return decimal.Min(amount, affordable)
+}
+
+// marketPrice 将行情价格按交易对价格单位取整为成交价
+func (m *MatchingEngine) marketPrice(symbol string, price float64) decimal.Decimal {
+	return m.markets.ForSymbol(symbol).RoundTick(decimal.NewFromFloat(price))
+}
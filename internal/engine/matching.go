@@ -2,28 +2,49 @@ package engine
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/fee"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
 // MatchingEngine 撮合引擎
 type MatchingEngine struct {
-	db     *gorm.DB
-	cfg    *config.Config
-	logger *zap.Logger
+	db      *gorm.DB
+	cfg     *config.Config
+	logger  *zap.Logger
+	books   *OrderBookStore
+	markets *market.Registry
+
+	execution ExecutionModel // 执行模拟（延迟、拒单、故障），未启用时为 nil
 }
 
 // NewMatchingEngine 创建撮合引擎实例
 func NewMatchingEngine(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *MatchingEngine {
 	return &MatchingEngine{
-		db:     db,
-		cfg:    cfg,
-		logger: logger,
+		db:      db,
+		cfg:     cfg,
+		logger:  logger,
+		books:   defaultOrderBookStore,
+		markets: market.NewRegistry(db, cfg),
+
+		execution: executionModelFor(cfg),
+	}
+}
+
+// Acknowledge 下单确认：按执行模型等待确认延迟，模拟拒单或交易对故障时返回错误
+func (m *MatchingEngine) Acknowledge(symbol string) error {
+	if m.execution == nil {
+		return nil
 	}
+	return m.execution.Acknowledge(symbol)
 }
 
 // MatchOrder 撮合订单
@@ -34,19 +55,109 @@ func (m *MatchingEngine) MatchOrder(orderID uint) error {
 		return fmt.Errorf("order not found: %w", err)
 	}
 
-	// 2. 检查订单状态
-	if order.Status != "new" {
-		return fmt.Errorf("order status is not new: %s", order.Status)
+	// 2. 检查订单状态（部分成交的订单可以继续撮合）
+	if !isMatchableStatus(order.Status) {
+		return fmt.Errorf("order status is not new or partially_filled: %s", order.Status)
 	}
 
-	// 3. 根据订单类型进行撮合
-	if order.Type == "market" {
-		return m.matchMarketOrder(&order)
-	} else if order.Type == "limit" {
-		return m.matchLimitOrder(&order)
+	// 3. 暂停交易或已停用的交易对不撮合，订单保留等待恢复（仍可撤单）
+	mkt, ok, err := m.markets.Get(order.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get market: %w", err)
+	}
+	if ok && !mkt.Active() {
+		m.logger.Debug("Market is not active, skip matching",
+			zap.Uint("order_id", order.ID),
+			zap.String("symbol", order.Symbol),
+			zap.String("status", mkt.Status))
+		return nil
+	}
+
+	// 4. 执行模拟：交易对故障时跳过本次撮合，首次撮合前等待撮合延迟（期间订单可能已撤销，重新读取）
+	if m.execution != nil {
+		if !m.execution.BeforeMatch(&order) {
+			m.logger.Debug("Symbol outage simulated, skip matching",
+				zap.Uint("order_id", order.ID),
+				zap.String("symbol", order.Symbol))
+			return nil
+		}
+		if err := m.db.First(&order, orderID).Error; err != nil {
+			return fmt.Errorf("order not found: %w", err)
+		}
+		if !isMatchableStatus(order.Status) {
+			return nil
+		}
+	}
+
+	// 5. FOK：无法立即全部成交则直接拒绝
+	if order.TimeInForce == TimeInForceFOK {
+		fillable, err := m.immediatelyFillable(&order)
+		if err != nil {
+			return fmt.Errorf("failed to check fill-or-kill: %w", err)
+		}
+		if fillable.LessThan(remainingAmount(&order)) {
+			return m.cancelRemaining(&order, "rejected", CancelReasonFOKUnfillable)
+		}
+	}
+
+	// 6. 撮合
+	if err := m.matchByType(&order); err != nil {
+		return err
+	}
+
+	// 7. IOC/FOK：未能立即成交的剩余部分撤销
+	if isMatchableStatus(order.Status) {
+		switch order.TimeInForce {
+		case TimeInForceIOC:
+			return m.cancelRemaining(&order, "cancelled", CancelReasonIOCRemainder)
+		case TimeInForceFOK:
+			return m.cancelRemaining(&order, "cancelled", CancelReasonFOKUnfillable)
+		}
 	}
 
-	return fmt.Errorf("unsupported order type: %s", order.Type)
+	// 8. 首次撮合后未全部成交的限价单进入订单簿，之后的成交为 maker
+	return m.markRested(&order)
+}
+
+// markRested 标记限价单已进入订单簿（只在首次撮合后标记一次）
+func (m *MatchingEngine) markRested(order *model.Order) error {
+	if order.Type != "limit" || order.RestedAt != nil || !isMatchableStatus(order.Status) {
+		return nil
+	}
+	now := time.Now()
+	if err := m.db.Model(&model.Order{}).
+		Where("id = ? AND rested_at IS NULL", order.ID).
+		Update("rested_at", now).Error; err != nil {
+		return fmt.Errorf("failed to mark order as resting: %w", err)
+	}
+	order.RestedAt = &now
+	return nil
+}
+
+// isResting 订单是否以挂单身份成交（已进入订单簿的限价单，或只做 maker 的订单）
+func isResting(order *model.Order) bool {
+	return order.Type == "limit" && (order.RestedAt != nil || order.PostOnly)
+}
+
+// matchByType 按订单类型撮合（内部撮合模式下优先与其他用户的挂单成交，剩余部分再按外部行情撮合）
+func (m *MatchingEngine) matchByType(order *model.Order) error {
+	if order.Type != "market" && order.Type != "limit" {
+		return fmt.Errorf("unsupported order type: %s", order.Type)
+	}
+
+	if m.cfg.Trading.Matching.ModeForSymbol(order.Symbol) == "internal" {
+		if _, err := m.matchInternal(order); err != nil {
+			return fmt.Errorf("failed to match internally: %w", err)
+		}
+		if !isMatchableStatus(order.Status) {
+			return nil
+		}
+	}
+
+	if order.Type == "market" {
+		return m.matchMarketOrder(order)
+	}
+	return m.matchLimitOrder(order)
 }
 
 // matchMarketOrder 撮合市价单
@@ -58,39 +169,44 @@ func (m *MatchingEngine) matchMarketOrder(order *model.Order) error {
 	}
 
 	// 2. 确定成交价格
-	var price float64
+	var price decimal.Decimal
 	if order.Side == "buy" {
 		// 买单使用 ask 价格
 		if ticker.AskPrice == nil {
 			return fmt.Errorf("ask price not available for %s", order.Symbol)
 		}
-		price = *ticker.AskPrice
+		price = m.marketPrice(order.Symbol, *ticker.AskPrice)
 	} else if order.Side == "sell" {
 		// 卖单使用 bid 价格
 		if ticker.BidPrice == nil {
 			return fmt.Errorf("bid price not available for %s", order.Symbol)
 		}
-		price = *ticker.BidPrice
+		price = m.marketPrice(order.Symbol, *ticker.BidPrice)
 	} else {
 		return fmt.Errorf("invalid order side: %s", order.Side)
 	}
 
-	// 3. 创建成交记录
-	if err := m.createTradeRecord(order, price); err != nil {
-		return fmt.Errorf("failed to create trade record: %w", err)
+	// 3. 按可用流动性成交（可能只成交一部分）
+	trades, err := m.executeFill(order, &ticker, price)
+	if err != nil {
+		return fmt.Errorf("failed to fill order: %w", err)
 	}
-
-	// 4. 更新订单状态
-	if err := m.updateOrderStatus(order, order.Amount); err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+	if len(trades) == 0 {
+		m.logger.Debug("No liquidity available for market order",
+			zap.Uint("order_id", order.ID),
+			zap.String("symbol", order.Symbol),
+		)
+		return nil
 	}
 
 	m.logger.Info("Market order matched",
 		zap.Uint("order_id", order.ID),
 		zap.String("symbol", order.Symbol),
 		zap.String("side", order.Side),
-		zap.Float64("price", price),
-		zap.Float64("amount", order.Amount),
+		zap.Stringer("average_price", order.AveragePrice),
+		zap.Int("trades", len(trades)),
+		zap.Stringer("filled", order.Filled),
+		zap.String("status", order.Status),
 	)
 
 	return nil
@@ -111,19 +227,25 @@ func (m *MatchingEngine) matchLimitOrder(order *model.Order) error {
 
 	limitPrice := *order.Price
 	canMatch := false
-	var matchPrice float64
+	var matchPrice decimal.Decimal
 
 	if order.Side == "buy" {
 		// 买单：限价 >= ask 时可以成交
-		if ticker.AskPrice != nil && limitPrice >= *ticker.AskPrice {
-			canMatch = true
-			matchPrice = *ticker.AskPrice // 实际成交价使用市场价
+		if ticker.AskPrice != nil {
+			ask := m.marketPrice(order.Symbol, *ticker.AskPrice)
+			if limitPrice.GreaterThanOrEqual(ask) {
+				canMatch = true
+				matchPrice = ask // 实际成交价使用市场价
+			}
 		}
 	} else if order.Side == "sell" {
 		// 卖单：限价 <= bid 时可以成交
-		if ticker.BidPrice != nil && limitPrice <= *ticker.BidPrice {
-			canMatch = true
-			matchPrice = *ticker.BidPrice
+		if ticker.BidPrice != nil {
+			bid := m.marketPrice(order.Symbol, *ticker.BidPrice)
+			if limitPrice.LessThanOrEqual(bid) {
+				canMatch = true
+				matchPrice = bid
+			}
 		}
 	}
 
@@ -131,92 +253,168 @@ func (m *MatchingEngine) matchLimitOrder(order *model.Order) error {
 	if !canMatch {
 		m.logger.Debug("Limit order cannot be matched yet",
 			zap.Uint("order_id", order.ID),
-			zap.Float64("limit_price", limitPrice),
+			zap.Stringer("limit_price", limitPrice),
 			zap.Float64p("ask_price", ticker.AskPrice),
 			zap.Float64p("bid_price", ticker.BidPrice),
 		)
 		return nil // 不是错误，只是暂时无法成交
 	}
 
-	// 4. 创建成交记录
-	if err := m.createTradeRecord(order, matchPrice); err != nil {
-		return fmt.Errorf("failed to create trade record: %w", err)
+	// 4. 按可用流动性成交（可能只成交一部分）
+	trades, err := m.executeFill(order, &ticker, matchPrice)
+	if err != nil {
+		return fmt.Errorf("failed to fill order: %w", err)
 	}
-
-	// 5. 更新订单状态
-	if err := m.updateOrderStatus(order, order.Amount); err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+	if len(trades) == 0 {
+		m.logger.Debug("No liquidity available for limit order",
+			zap.Uint("order_id", order.ID),
+			zap.String("symbol", order.Symbol),
+		)
+		return nil
 	}
 
 	m.logger.Info("Limit order matched",
 		zap.Uint("order_id", order.ID),
 		zap.String("symbol", order.Symbol),
 		zap.String("side", order.Side),
-		zap.Float64("limit_price", limitPrice),
-		zap.Float64("match_price", matchPrice),
-		zap.Float64("amount", order.Amount),
+		zap.Stringer("limit_price", limitPrice),
+		zap.Stringer("average_price", order.AveragePrice),
+		zap.Int("trades", len(trades)),
+		zap.Stringer("filled", order.Filled),
+		zap.String("status", order.Status),
 	)
 
 	return nil
 }
 
-// createTradeRecord 创建成交记录并结算余额
-func (m *MatchingEngine) createTradeRecord(order *model.Order, price float64) error {
-	// 1. 计算手续费
-	var feeRate float64
-	if order.Type == "market" {
-		feeRate = m.cfg.Trading.TakerFeeRate
-	} else {
-		feeRate = m.cfg.Trading.MakerFeeRate
-	}
-
-	fee := m.calculateFee(order.Amount, feeRate)
-
-	// 2. 在事务中创建成交记录和结算余额
-	return m.db.Transaction(func(tx *gorm.DB) error {
-		// 创建成交记录
-		trade := &model.Trade{
-			OrderID:  order.ID,
-			UserID:   order.UserID,
-			Symbol:   order.Symbol,
-			Side:     order.Side,
-			Price:    price,
-			Amount:   order.Amount,
-			Fee:      fee,
-			FeeAsset: m.getFeeAsset(order),
+// executeFill 在事务中完成撮合：锁定订单、确定成交明细、逐笔创建成交记录、结算余额并更新订单
+// 没有可用流动性时返回空列表
+func (m *MatchingEngine) executeFill(order *model.Order, ticker *model.Ticker, price decimal.Decimal) ([]*model.Trade, error) {
+	var trades []*model.Trade
+	var fills []fill
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		// 重新加载并锁定订单，避免并发撮合重复成交
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(order, order.ID).Error; err != nil {
+			return fmt.Errorf("failed to lock order: %w", err)
 		}
 
-		if err := tx.Create(trade).Error; err != nil {
-			return fmt.Errorf("failed to create trade: %w", err)
+		if !isMatchableStatus(order.Status) {
+			return fmt.Errorf("order status is not new or partially_filled: %s", order.Status)
 		}
 
-		// 结算余额
-		if err := m.settleBalance(tx, order, trade); err != nil {
-			return fmt.Errorf("failed to settle balance: %w", err)
+		fills = m.planFills(order, ticker, price)
+		if order.ReduceOnly {
+			var err error
+			if fills, err = capReduceOnly(tx, order, fills); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range fills {
+			trade, err := m.createTradeRecord(tx, order, f.price, f.amount, isResting(order))
+			if err != nil {
+				return fmt.Errorf("failed to create trade record: %w", err)
+			}
+
+			if err := m.updateOrderStatus(tx, order, trade); err != nil {
+				return fmt.Errorf("failed to update order status: %w", err)
+			}
+
+			trades = append(trades, trade)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 成交提交后再从合成订单簿中扣除流动性，事务回滚时订单簿不受影响
+	if m.cfg.Trading.OrderBook.Enabled && len(fills) > 0 {
+		m.books.consume(ticker, m.markets.ForSymbol(order.Symbol), order.Side, fills)
+	}
+
+	return trades, nil
+}
+
+// createTradeRecord 创建成交记录并结算余额
+// 手续费按 maker/taker 费率从收到的资产中扣除：买单扣基础币，卖单扣计价币；永续合约按成交金额收取结算币
+func (m *MatchingEngine) createTradeRecord(tx *gorm.DB, order *model.Order, price, amount decimal.Decimal, isMaker bool) (*model.Trade, error) {
+	// 1. 按用户当前的手续费等级确定费率
+	rates, err := fee.NewSchedule(tx, m.cfg).Rates(order.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee rates: %w", err)
+	}
+	feeRate := rates.Taker
+	if isMaker {
+		feeRate = rates.Maker
+	}
+
+	// 2. 创建成交记录（成交金额四舍五入到资金精度）
+	quoteAmount := amount.Mul(price).Round(AssetScale)
+	received := amount
+	if order.Side == "sell" || IsPerpetual(order) {
+		received = quoteAmount
+	}
+
+	trade := &model.Trade{
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		Symbol:      order.Symbol,
+		Side:        order.Side,
+		Price:       price,
+		Amount:      amount,
+		QuoteAmount: quoteAmount,
+		Fee:         m.calculateFee(received, feeRate),
+		FeeAsset:    m.getFeeAsset(order),
+		IsMaker:     isMaker,
+	}
+
+	// 开启平台币抵扣时改为以平台币支付
+	if err := m.applyFeeToken(tx, order, trade); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(trade).Error; err != nil {
+		return nil, fmt.Errorf("failed to create trade: %w", err)
+	}
+
+	// 3. 结算余额
+	if err := m.settleBalance(tx, order, trade); err != nil {
+		return nil, fmt.Errorf("failed to settle balance: %w", err)
+	}
+
+	return trade, nil
 }
 
-// settleBalance 结算余额
+// settleBalance 结算余额（在更新订单成交量之前调用）
+// 冻结资金按订单冻结价格逐笔释放，冻结余额不足时返回错误而不是截断为 0
 func (m *MatchingEngine) settleBalance(tx *gorm.DB, order *model.Order, trade *model.Trade) error {
+	if IsPerpetual(order) {
+		return m.settlePosition(tx, order, trade)
+	}
+
 	baseCoin, quoteCoin := m.splitSymbol(order.Symbol)
 
 	if order.Side == "buy" {
-		// 买单：扣除冻结的 USDT，增加 BTC
-		cost := trade.Amount * trade.Price * (1 + m.cfg.Trading.TakerFeeRate)
+		// 买单：释放本次成交对应的冻结 USDT，按实际成交金额扣款，差额（价格改善、滑点缓冲）退回可用余额
+		reserved := fillReservation(order, trade)
 
-		// 扣除冻结的 USDT
 		var quoteBalance model.Balance
 		if err := tx.Where("user_id = ? AND asset = ?", order.UserID, quoteCoin).
 			First(&quoteBalance).Error; err != nil {
 			return fmt.Errorf("quote balance not found: %w", err)
 		}
 
-		quoteBalance.Locked -= cost
-		if quoteBalance.Locked < 0 {
-			quoteBalance.Locked = 0
+		if quoteBalance.Locked.LessThan(reserved) {
+			return fmt.Errorf("insufficient locked balance: locked %s, required %s", quoteBalance.Locked, reserved)
+		}
+		quoteBalance.Locked = quoteBalance.Locked.Sub(reserved)
+		quoteBalance.Available = quoteBalance.Available.Add(reserved).Sub(trade.QuoteAmount)
+		if quoteBalance.Available.IsNegative() {
+			return fmt.Errorf("insufficient balance to settle trade: cost %s exceeds reserved %s", trade.QuoteAmount, reserved)
 		}
 
 		if err := tx.Save(&quoteBalance).Error; err != nil {
@@ -224,104 +422,138 @@ func (m *MatchingEngine) settleBalance(tx *gorm.DB, order *model.Order, trade *m
 		}
 
 		// 增加 BTC (扣除手续费)
-		receivedAmount := trade.Amount - trade.Fee
-		var baseBalance model.Balance
-		err := tx.Where("user_id = ? AND asset = ?", order.UserID, baseCoin).
-			First(&baseBalance).Error
-		if err != nil {
-			// 如果不存在则创建
-			baseBalance = model.Balance{
-				UserID:    order.UserID,
-				Asset:     baseCoin,
-				Available: receivedAmount,
-				Locked:    0,
-			}
-			if err := tx.Create(&baseBalance).Error; err != nil {
-				return fmt.Errorf("failed to create base balance: %w", err)
-			}
-		} else {
-			baseBalance.Available += receivedAmount
-			if err := tx.Save(&baseBalance).Error; err != nil {
-				return fmt.Errorf("failed to update base balance: %w", err)
-			}
+		if err := m.creditBalance(tx, order.UserID, baseCoin, netOfFee(trade, baseCoin, trade.Amount)); err != nil {
+			return fmt.Errorf("failed to update base balance: %w", err)
 		}
 
 	} else if order.Side == "sell" {
 		// 卖单：扣除冻结的 BTC，增加 USDT (扣除手续费)
-		// 扣除冻结的 BTC
 		var baseBalance model.Balance
 		if err := tx.Where("user_id = ? AND asset = ?", order.UserID, baseCoin).
 			First(&baseBalance).Error; err != nil {
 			return fmt.Errorf("base balance not found: %w", err)
 		}
 
-		baseBalance.Locked -= trade.Amount
-		if baseBalance.Locked < 0 {
-			baseBalance.Locked = 0
+		if baseBalance.Locked.LessThan(trade.Amount) {
+			return fmt.Errorf("insufficient locked balance: locked %s, required %s", baseBalance.Locked, trade.Amount)
 		}
+		baseBalance.Locked = baseBalance.Locked.Sub(trade.Amount)
 
 		if err := tx.Save(&baseBalance).Error; err != nil {
 			return fmt.Errorf("failed to update base balance: %w", err)
 		}
 
 		// 增加 USDT (扣除手续费)
-		receivedUSDT := trade.Amount * trade.Price * (1 - m.cfg.Trading.TakerFeeRate)
-		var quoteBalance model.Balance
-		err := tx.Where("user_id = ? AND asset = ?", order.UserID, quoteCoin).
-			First(&quoteBalance).Error
-		if err != nil {
-			// 如果不存在则创建
-			quoteBalance = model.Balance{
-				UserID:    order.UserID,
-				Asset:     quoteCoin,
-				Available: receivedUSDT,
-				Locked:    0,
-			}
-			if err := tx.Create(&quoteBalance).Error; err != nil {
-				return fmt.Errorf("failed to create quote balance: %w", err)
-			}
-		} else {
-			quoteBalance.Available += receivedUSDT
-			if err := tx.Save(&quoteBalance).Error; err != nil {
-				return fmt.Errorf("failed to update quote balance: %w", err)
-			}
+		if err := m.creditBalance(tx, order.UserID, quoteCoin, netOfFee(trade, quoteCoin, trade.QuoteAmount)); err != nil {
+			return fmt.Errorf("failed to update quote balance: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// updateOrderStatus 更新订单状态
-func (m *MatchingEngine) updateOrderStatus(order *model.Order, filledAmount float64) error {
-	order.Filled = filledAmount
-	order.Status = "filled"
+// creditBalance 增加可用余额，余额记录不存在时创建
+func (m *MatchingEngine) creditBalance(tx *gorm.DB, userID uint, asset string, amount decimal.Decimal) error {
+	var balance model.Balance
+	if err := tx.Where("user_id = ? AND asset = ?", userID, asset).First(&balance).Error; err != nil {
+		balance = model.Balance{
+			UserID:    userID,
+			Asset:     asset,
+			Available: amount,
+		}
+		return tx.Create(&balance).Error
+	}
+
+	balance.Available = balance.Available.Add(amount)
+	return tx.Save(&balance).Error
+}
+
+// updateOrderStatus 根据本次成交更新订单的成交量、均价和状态
+func (m *MatchingEngine) updateOrderStatus(tx *gorm.DB, order *model.Order, trade *model.Trade) error {
+	prevFilled := order.Filled
+	order.Filled = prevFilled.Add(trade.Amount)
+
+	// 成交均价按成交量加权
+	averagePrice := trade.Price
+	if order.AveragePrice != nil && prevFilled.IsPositive() {
+		averagePrice = order.AveragePrice.Mul(prevFilled).
+			Add(trade.Price.Mul(trade.Amount)).
+			Div(order.Filled).
+			Round(AssetScale)
+	}
+	order.AveragePrice = &averagePrice
+
+	order.QuoteFilled = order.QuoteFilled.Add(trade.QuoteAmount)
+	if trade.FeeAsset == m.getFeeAsset(order) {
+		order.Fee = order.Fee.Add(trade.Fee)
+		order.FeeAsset = trade.FeeAsset
+	} else {
+		order.TokenFee = order.TokenFee.Add(trade.Fee)
+		order.TokenFeeAsset = trade.FeeAsset
+	}
 
-	if err := m.db.Save(order).Error; err != nil {
+	if !remainingAmount(order).IsPositive() || m.budgetExhausted(order, trade.Price) {
+		now := time.Now()
+		order.Status = "filled"
+		order.FilledAt = &now
+
+		// 按金额下单的订单以实际成交数量为准，退还未花完的预算
+		if order.QuoteOrderQty != nil {
+			order.Amount = order.Filled
+			if err := m.releaseRemainingLock(tx, order); err != nil {
+				return fmt.Errorf("failed to release unused budget: %w", err)
+			}
+		}
+	} else {
+		order.Status = "partially_filled"
+	}
+
+	if err := tx.Save(order).Error; err != nil {
 		return fmt.Errorf("failed to update order: %w", err)
 	}
 
+	// 订单组：首次成交时撤销同组其他腿，全部成交后激活 bracket 止盈止损腿
+	if order.OrderListID != nil {
+		if prevFilled.IsZero() {
+			if err := m.ResolveOrderList(tx, order); err != nil {
+				return err
+			}
+		}
+		if order.Status == "filled" {
+			if err := m.finalizeBracketEntry(tx, order); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
-// calculateFee 计算手续费
-func (m *MatchingEngine) calculateFee(amount float64, feeRate float64) float64 {
-	return amount * feeRate
+// budgetExhausted 按金额下单的市价买单剩余预算是否已不足以按 price 再成交一个数量步长
+func (m *MatchingEngine) budgetExhausted(order *model.Order, price decimal.Decimal) bool {
+	if order.QuoteOrderQty == nil {
+		return false
+	}
+	return !m.capToBudget(order, price, remainingAmount(order)).IsPositive()
+}
+
+// calculateFee 计算手续费（向上取整到资金精度）
+func (m *MatchingEngine) calculateFee(amount decimal.Decimal, feeRate float64) decimal.Decimal {
+	return CeilAsset(amount.Mul(decimal.NewFromFloat(feeRate)))
 }
 
-// getFeeAsset 获取手续费资产
+// getFeeAsset 获取手续费资产（收到的资产：买单为基础币，卖单为计价币；永续合约为结算币）
 func (m *MatchingEngine) getFeeAsset(order *model.Order) string {
-	baseCoin, _ := m.splitSymbol(order.Symbol)
-	// 手续费使用交易的基础币种
+	baseCoin, quoteCoin := m.splitSymbol(order.Symbol)
+	if order.Side == "sell" || IsPerpetual(order) {
+		return quoteCoin
+	}
 	return baseCoin
 }
 
-// splitSymbol 分割交易对符号
+// splitSymbol 分割交易对符号（永续合约的计价币即结算币）
 func (m *MatchingEngine) splitSymbol(symbol string) (base string, quote string) {
-	// BTC/USDT -> BTC, USDT
-	for i := 0; i < len(symbol); i++ {
-		if symbol[i] == '/' {
-			return symbol[:i], symbol[i+1:]
-		}
-	}
-	return symbol, "USDT" // 默认计价币种
+	// BTC/USDT -> BTC, USDT；BTC/USDT:USDT -> BTC, USDT
+	base, quote, _ = market.SplitSymbol(symbol)
+	return base, quote
 }
@@ -3,8 +3,10 @@ package engine
 import (
 	"testing"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/talkincode/quicksilver/internal/config"
 	"github.com/talkincode/quicksilver/internal/model"
 	"github.com/talkincode/quicksilver/internal/testutil"
 )
@@ -30,7 +32,7 @@ func TestMatchMarketBuyOrder(t *testing.T) {
 	t.Run("Match market buy order successfully", func(t *testing.T) {
 		// Given: 用户有足够的 USDT 余额和冻结资金
 		user := testutil.SeedUser(t, db)
-		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 5500.0) // available: 10000, locked: 5500
+		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 5000.0) // available: 10000, locked: 0.1 * 50000
 		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0)
 
 		// 创建市场价格
@@ -45,17 +47,17 @@ func TestMatchMarketBuyOrder(t *testing.T) {
 		err := db.Save(ticker).Error // 使用 Save 而非 Create
 		require.NoError(t, err)
 
-		// 创建市价买单 (已冻结资金)
+		// 创建市价买单 (已按下单时价格 50000 冻结资金)
 		amount := 0.1
 		price := 50010.0 // ask price
 		order := &model.Order{
-			UserID: user.ID,
-			Symbol: "BTC/USDT",
-			Side:   "buy",
-			Type:   "market",
-			Amount: amount,
-			Filled: 0,
-			Status: "new",
+			UserID:       user.ID,
+			Symbol:       "BTC/USDT",
+			Side:         "buy",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(amount),
+			ReservePrice: decimalPtr(50000.0),
+			Status:       "new",
 		}
 		err = db.Create(order).Error
 		require.NoError(t, err)
@@ -72,7 +74,7 @@ func TestMatchMarketBuyOrder(t *testing.T) {
 		err = db.First(&updatedOrder, order.ID).Error
 		require.NoError(t, err)
 		assert.Equal(t, "filled", updatedOrder.Status)
-		assert.Equal(t, amount, updatedOrder.Filled)
+		assert.Equal(t, amount, updatedOrder.Filled.InexactFloat64())
 
 		// 验证成交记录
 		var trade model.Trade
@@ -81,25 +83,24 @@ func TestMatchMarketBuyOrder(t *testing.T) {
 		assert.Equal(t, user.ID, trade.UserID)
 		assert.Equal(t, "BTC/USDT", trade.Symbol)
 		assert.Equal(t, "buy", trade.Side)
-		assert.Equal(t, price, trade.Price)
-		assert.Equal(t, amount, trade.Amount)
-		assert.Greater(t, trade.Fee, 0.0) // 应该有手续费
+		assert.Equal(t, price, trade.Price.InexactFloat64())
+		assert.Equal(t, amount, trade.Amount.InexactFloat64())
+		assert.Greater(t, trade.Fee.InexactFloat64(), 0.0) // 应该有手续费
 
 		// 验证余额变化
 		var btcBalance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btcBalance).Error
 		require.NoError(t, err)
 		// 买入后应该增加 BTC (扣除手续费)
-		expectedBTC := amount - trade.Fee // 0.1 - 手续费
-		assert.InDelta(t, expectedBTC, btcBalance.Available, 0.00001)
+		expectedBTC := amount - trade.Fee.InexactFloat64() // 0.1 - 手续费
+		assert.InDelta(t, expectedBTC, btcBalance.Available.InexactFloat64(), 0.00001)
 
 		var usdtBalance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdtBalance).Error
 		require.NoError(t, err)
-		// 冻结的 USDT 应该被扣除
-		expectedCost := amount * price * (1 + cfg.Trading.TakerFeeRate)
-		expectedLocked := 5500.0 - expectedCost
-		assert.InDelta(t, expectedLocked, usdtBalance.Locked, 0.01)
+		// 冻结的 USDT 全部释放，成交金额超出冻结的部分从可用余额扣除
+		assert.True(t, usdtBalance.Locked.IsZero())
+		assert.Equal(t, "9999", usdtBalance.Available.String())
 	})
 
 	t.Run("Match market buy order with ticker not found", func(t *testing.T) {
@@ -112,7 +113,7 @@ func TestMatchMarketBuyOrder(t *testing.T) {
 			Symbol: "ETH/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 1.0,
+			Amount: decimal.NewFromFloat(1.0),
 			Status: "new",
 		}
 		err := db.Create(order).Error
@@ -159,8 +160,8 @@ func TestMatchMarketSellOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "sell",
 			Type:   "market",
-			Amount: amount,
-			Filled: 0,
+			Amount: decimal.NewFromFloat(amount),
+			Filled: decimal.NewFromFloat(0),
 			Status: "new",
 		}
 		err = db.Create(order).Error
@@ -178,7 +179,7 @@ func TestMatchMarketSellOrder(t *testing.T) {
 		err = db.First(&updatedOrder, order.ID).Error
 		require.NoError(t, err)
 		assert.Equal(t, "filled", updatedOrder.Status)
-		assert.Equal(t, amount, updatedOrder.Filled)
+		assert.Equal(t, amount, updatedOrder.Filled.InexactFloat64())
 
 		// 验证成交记录
 		var trade model.Trade
@@ -187,23 +188,59 @@ func TestMatchMarketSellOrder(t *testing.T) {
 		assert.Equal(t, user.ID, trade.UserID)
 		assert.Equal(t, "BTC/USDT", trade.Symbol)
 		assert.Equal(t, "sell", trade.Side)
-		assert.Equal(t, price, trade.Price)
-		assert.Equal(t, amount, trade.Amount)
-		assert.Greater(t, trade.Fee, 0.0)
+		assert.Equal(t, price, trade.Price.InexactFloat64())
+		assert.Equal(t, amount, trade.Amount.InexactFloat64())
+		assert.False(t, trade.IsMaker)
+		// 卖单手续费从收到的 USDT 中按 taker 费率扣除
+		assert.Equal(t, "USDT", trade.FeeAsset)
+		assert.InDelta(t, amount*price*cfg.Trading.TakerFeeRate, trade.Fee.InexactFloat64(), 1e-8)
 
 		// 验证余额变化
 		var btcBalance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btcBalance).Error
 		require.NoError(t, err)
 		// 冻结的 BTC 应该被扣除
-		assert.InDelta(t, 0.0, btcBalance.Locked, 0.00001)
+		assert.InDelta(t, 0.0, btcBalance.Locked.InexactFloat64(), 0.00001)
 
 		var usdtBalance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdtBalance).Error
 		require.NoError(t, err)
 		// 应该收到 USDT (扣除手续费)
 		expectedUSDT := amount * price * (1 - cfg.Trading.TakerFeeRate)
-		assert.InDelta(t, expectedUSDT, usdtBalance.Available, 0.01)
+		assert.InDelta(t, expectedUSDT, usdtBalance.Available.InexactFloat64(), 0.01)
+	})
+
+	t.Run("Settlement fails instead of clamping insufficient locked balance", func(t *testing.T) {
+		// Given: 冻结的 BTC 少于订单数量（账目不一致）
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 1.0, 0.05)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0)
+
+		order := &model.Order{
+			UserID: user.ID,
+			Symbol: "BTC/USDT",
+			Side:   "sell",
+			Type:   "market",
+			Amount: decimal.NewFromFloat(0.1),
+			Status: "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		// When: 撮合订单
+		engine := NewMatchingEngine(db, cfg, logger)
+		err := engine.MatchOrder(order.ID)
+
+		// Then: 返回错误，余额和订单保持不变
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "insufficient locked balance")
+
+		var btcBalance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btcBalance).Error)
+		assert.Equal(t, "0.05", btcBalance.Locked.String())
+
+		var updatedOrder model.Order
+		require.NoError(t, db.First(&updatedOrder, order.ID).Error)
+		assert.True(t, updatedOrder.Filled.IsZero())
 	})
 }
 
@@ -246,8 +283,8 @@ func TestCalculateFee(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			fee := engine.calculateFee(tt.amount, tt.feeRate)
-			assert.InDelta(t, tt.expected, fee, 0.00000001)
+			fee := engine.calculateFee(decimal.NewFromFloat(tt.amount), tt.feeRate)
+			assert.InDelta(t, tt.expected, fee.InexactFloat64(), 0.00000001)
 		})
 	}
 }
@@ -282,9 +319,9 @@ func TestMatchLimitOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Price:  &limitPrice,
-			Amount: amount,
-			Filled: 0,
+			Price:  decimalPtr(limitPrice),
+			Amount: decimal.NewFromFloat(amount),
+			Filled: decimal.NewFromFloat(0),
 			Status: "new",
 		}
 		err = db.Create(order).Error
@@ -301,6 +338,50 @@ func TestMatchLimitOrder(t *testing.T) {
 		err = db.First(&updatedOrder, order.ID).Error
 		require.NoError(t, err)
 		assert.Equal(t, "filled", updatedOrder.Status)
+
+		// 立即成交的限价单是 taker
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.False(t, trade.IsMaker)
+		assert.Equal(t, "BTC", trade.FeeAsset)
+		assert.InDelta(t, amount*cfg.Trading.TakerFeeRate, trade.Fee.InexactFloat64(), 1e-8)
+	})
+
+	t.Run("Resting limit order fills as maker", func(t *testing.T) {
+		// Given: 限价买单低于卖一价，首次撮合未成交，进入订单簿
+		testutil.CleanupDB(t, db)
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 4900.0)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0)
+
+		bidPrice, askPrice := 49990.0, 50010.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+
+		limitPrice := 49000.0
+		order := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "buy", "limit", 0.1, &limitPrice)
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		var rested model.Order
+		require.NoError(t, db.First(&rested, order.ID).Error)
+		assert.Equal(t, "new", rested.Status)
+		require.NotNil(t, rested.RestedAt)
+
+		// When: 价格下跌到限价以下
+		bidPrice, askPrice = 48890.0, 48900.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 48900.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 按 maker 费率从收到的 BTC 中扣除手续费
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.True(t, trade.IsMaker)
+		assert.Equal(t, "BTC", trade.FeeAsset)
+		assert.InDelta(t, 0.1*cfg.Trading.MakerFeeRate, trade.Fee.InexactFloat64(), 1e-8)
+
+		var btc model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btc).Error)
+		assert.InDelta(t, 0.1*(1-cfg.Trading.MakerFeeRate), btc.Available.InexactFloat64(), 1e-8)
 	})
 
 	t.Run("Limit buy order not matched when price too low", func(t *testing.T) {
@@ -327,9 +408,9 @@ func TestMatchLimitOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Price:  &limitPrice,
-			Amount: amount,
-			Filled: 0,
+			Price:  decimalPtr(limitPrice),
+			Amount: decimal.NewFromFloat(amount),
+			Filled: decimal.NewFromFloat(0),
 			Status: "new",
 		}
 		err = db.Create(order).Error
@@ -346,7 +427,7 @@ func TestMatchLimitOrder(t *testing.T) {
 		err = db.First(&updatedOrder, order.ID).Error
 		require.NoError(t, err)
 		assert.Equal(t, "new", updatedOrder.Status)
-		assert.Equal(t, 0.0, updatedOrder.Filled)
+		assert.Equal(t, 0.0, updatedOrder.Filled.InexactFloat64())
 	})
 }
 
@@ -377,7 +458,7 @@ func TestMatchOrder_InvalidOrderStatus(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "cancelled",
 		}
 		err := db.Create(order).Error
@@ -397,8 +478,8 @@ func TestMatchOrder_InvalidOrderStatus(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
-			Filled: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
+			Filled: decimal.NewFromFloat(0.1),
 			Status: "filled",
 		}
 		err := db.Create(order).Error
@@ -411,3 +492,938 @@ func TestMatchOrder_InvalidOrderStatus(t *testing.T) {
 		assert.Contains(t, err.Error(), "order status is not new")
 	})
 }
+
+func TestMatchOrder_PartialFillWithLimitedLiquidity(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	cfg.Trading.Liquidity = config.LiquidityConfig{
+		Model: "fixed",
+		Depth: 0.1,
+	}
+	logger := testutil.NewTestLogger()
+
+	t.Run("Market buy order fills over successive ticks", func(t *testing.T) {
+		// Given: 每次撮合最多成交 0.1 BTC
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0.25*51000.0)
+
+		bidPrice := 49990.0
+		askPrice := 50010.0
+		ticker := &model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 50000.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}
+		require.NoError(t, db.Save(ticker).Error)
+
+		order := &model.Order{
+			UserID:       user.ID,
+			Symbol:       "BTC/USDT",
+			Side:         "buy",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(0.25),
+			ReservePrice: decimalPtr(51000.0),
+			Status:       "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+
+		// When: 第一次撮合
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 部分成交
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "partially_filled", updated.Status)
+		assert.InDelta(t, 0.1, updated.Filled.InexactFloat64(), 1e-9)
+		require.NotNil(t, updated.AveragePrice)
+		assert.InDelta(t, askPrice, updated.AveragePrice.InexactFloat64(), 1e-6)
+		assert.Nil(t, updated.FilledAt)
+
+		// When: 价格变化后继续撮合两次
+		newAsk := 50110.0
+		ticker.AskPrice = &newAsk
+		require.NoError(t, db.Save(ticker).Error)
+		require.NoError(t, engine.MatchOrder(order.ID))
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 全部成交，均价按成交量加权
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "filled", updated.Status)
+		assert.Equal(t, 0.25, updated.Filled.InexactFloat64())
+		assert.NotNil(t, updated.FilledAt)
+		expectedAvg := (askPrice*0.1 + newAsk*0.15) / 0.25
+		assert.InDelta(t, expectedAvg, updated.AveragePrice.InexactFloat64(), 1e-6)
+
+		var trades []model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).Order("id ASC").Find(&trades).Error)
+		require.Len(t, trades, 3)
+		assert.InDelta(t, 0.1, trades[0].Amount.InexactFloat64(), 1e-9)
+		assert.InDelta(t, 0.1, trades[1].Amount.InexactFloat64(), 1e-9)
+		assert.InDelta(t, 0.05, trades[2].Amount.InexactFloat64(), 1e-9)
+		assert.True(t, trades[0].Amount.Mul(trades[0].Price).Equal(trades[0].QuoteAmount))
+
+		// And: 冻结资金全部结清，未用完的部分精确退回
+		var usdt model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+		assert.True(t, usdt.Locked.IsZero())
+		spent := trades[0].QuoteAmount.Add(trades[1].QuoteAmount).Add(trades[2].QuoteAmount)
+		assert.True(t, decimal.NewFromFloat(0.25*51000.0).Sub(spent).Equal(usdt.Available))
+
+		// And: 已成交订单不能再撮合
+		err := engine.MatchOrder(order.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("Volume model falls back to depth without volume data", func(t *testing.T) {
+		engine := NewMatchingEngine(db, &config.Config{Trading: config.TradingConfig{
+			Liquidity: config.LiquidityConfig{Model: "volume", VolumeRatio: 0.01, Depth: 0.5},
+		}}, logger)
+
+		ticker := &model.Ticker{Symbol: "BTC/USDT"}
+		amount, limited := engine.availableLiquidity(ticker)
+		assert.True(t, limited)
+		assert.Equal(t, 0.5, amount.InexactFloat64())
+
+		volume := 1000.0
+		ticker.Volume24hBase = &volume
+		amount, limited = engine.availableLiquidity(ticker)
+		assert.True(t, limited)
+		assert.InDelta(t, 10.0, amount.InexactFloat64(), 1e-9)
+	})
+}
+
+func TestMatchOrder_WalkOrderBook(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	cfg.Trading.OrderBook = config.OrderBookConfig{
+		Enabled:   true,
+		Levels:    3,
+		PriceStep: 0.001,
+		BaseSize:  0.1,
+	}
+	logger := testutil.NewTestLogger()
+
+	t.Run("Market sell order walks bids with slippage", func(t *testing.T) {
+		// Given: 订单簿每档 0.1 BTC
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0.5)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0)
+
+		bidPrice := 50000.0
+		askPrice := 50010.0
+		ticker := &model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 50005.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}
+		require.NoError(t, db.Save(ticker).Error)
+
+		order := &model.Order{
+			UserID: user.ID,
+			Symbol: "BTC/USDT",
+			Side:   "sell",
+			Type:   "market",
+			Amount: decimal.NewFromFloat(0.25),
+			Status: "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		// When: 撮合
+		engine := NewMatchingEngine(db, cfg, logger)
+		engine.books = NewOrderBookStore()
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 逐档成交 3 笔，价格依次变差
+		var trades []model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).Order("id ASC").Find(&trades).Error)
+		require.Len(t, trades, 3)
+		assert.Equal(t, 50000.0, trades[0].Price.InexactFloat64())
+		assert.InDelta(t, 49950.0, trades[1].Price.InexactFloat64(), 1e-6)
+		assert.InDelta(t, 49900.0, trades[2].Price.InexactFloat64(), 1e-6)
+		assert.InDelta(t, 0.05, trades[2].Amount.InexactFloat64(), 1e-9)
+
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "filled", updated.Status)
+		expectedAvg := (50000.0*0.1 + 49950.0*0.1 + 49900.0*0.05) / 0.25
+		assert.InDelta(t, expectedAvg, updated.AveragePrice.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("Market order partially fills when book is exhausted", func(t *testing.T) {
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0.5*60000.0)
+
+		bidPrice := 49990.0
+		askPrice := 50010.0
+		ticker := &model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 50000.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}
+		require.NoError(t, db.Save(ticker).Error)
+
+		order := &model.Order{
+			UserID:       user.ID,
+			Symbol:       "BTC/USDT",
+			Side:         "buy",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(0.5),
+			ReservePrice: decimalPtr(60000.0),
+			Status:       "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		engine.books = NewOrderBookStore()
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 三档共 0.3 BTC 被吃完，剩余等待下次行情
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "partially_filled", updated.Status)
+		assert.InDelta(t, 0.3, updated.Filled.InexactFloat64(), 1e-9)
+	})
+
+	t.Run("Rolled back fill does not consume book liquidity", func(t *testing.T) {
+		// Given: 冻结资金不足以结算，撮合事务回滚
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 100.0)
+
+		bidPrice := 49990.0
+		askPrice := 50010.0
+		ticker := &model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 50000.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}
+		require.NoError(t, db.Save(ticker).Error)
+
+		order := &model.Order{
+			UserID:       user.ID,
+			Symbol:       "BTC/USDT",
+			Side:         "buy",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(0.1),
+			ReservePrice: decimalPtr(50010.0),
+			Status:       "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		engine.books = NewOrderBookStore()
+		require.Error(t, engine.MatchOrder(order.ID))
+
+		// Then: 订单簿第一档数量不变
+		var stored model.Ticker
+		require.NoError(t, db.Where("symbol = ?", "BTC/USDT").First(&stored).Error)
+		bookCfg := cfg.Trading.OrderBook.ForSymbol("BTC/USDT")
+		book := engine.books.Snapshot(&stored, bookCfg, 0)
+		require.Len(t, book.Asks, 3)
+		assert.Equal(t, 0.1, book.Asks[0].Amount)
+
+		// When: 补足冻结资金后重新撮合
+		require.NoError(t, db.Model(&model.Balance{}).Where("user_id = ? AND asset = ?", user.ID, "USDT").
+			Update("locked", decimal.NewFromInt(5001)).Error)
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 成交提交后第一档被吃完
+		book = engine.books.Snapshot(&stored, bookCfg, 0)
+		assert.Len(t, book.Asks, 2)
+	})
+
+	t.Run("Cost-based market buy stops at the budget", func(t *testing.T) {
+		// Given: 预算 7000 USDT，按下单时价格估算数量 0.14
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 7000.0)
+
+		bidPrice := 49990.0
+		askPrice := 50000.0
+		ticker := &model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 50000.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}
+		require.NoError(t, db.Save(ticker).Error)
+
+		order := &model.Order{
+			UserID:        user.ID,
+			Symbol:        "BTC/USDT",
+			Side:          "buy",
+			Type:          "market",
+			Amount:        decimal.NewFromFloat(0.14),
+			QuoteOrderQty: decimalPtr(7000.0),
+			Status:        "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		// When: 逐档吃单，第二档价格更高
+		engine := NewMatchingEngine(db, cfg, logger)
+		engine.books = NewOrderBookStore()
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 成交金额不超过预算，订单以实际成交数量完成
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "filled", updated.Status)
+		assert.True(t, updated.QuoteFilled.LessThanOrEqual(decimal.NewFromInt(7000)))
+		assert.True(t, updated.Amount.Equal(updated.Filled))
+		assert.True(t, updated.Filled.LessThan(decimal.NewFromFloat(0.14)))
+
+		// And: 未花完的预算退回可用余额
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.True(t, balance.Locked.IsZero())
+		assert.Equal(t, decimal.NewFromInt(7000).Sub(updated.QuoteFilled).String(), balance.Available.String())
+	})
+}
+
+func TestMatchOrder_InternalMatching(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	cfg.Trading.Matching = config.MatchingConfig{
+		Mode: "external",
+		Symbols: []config.SymbolMatchingConfig{
+			{Symbol: "BTC/USDT", Mode: "internal"},
+		},
+	}
+	logger := testutil.NewTestLogger()
+
+	// 外部行情远离双方限价，只能内部成交
+	bidPrice := 40000.0
+	askPrice := 60000.0
+	ticker := &model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 50000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}
+	require.NoError(t, db.Save(ticker).Error)
+
+	t.Run("Crossing limit orders match with price-time priority", func(t *testing.T) {
+		seller := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, seller.ID, "BTC", 0, 0.3)
+		testutil.SeedBalance(t, db, seller.ID, "USDT", 0, 0)
+		buyer := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, buyer.ID, "USDT", 0, 20000.0)
+		testutil.SeedBalance(t, db, buyer.ID, "BTC", 0, 0)
+
+		// 两笔卖单挂在簿上：价格更优的 50100 应先成交
+		ask1 := 50200.0
+		sell1 := testutil.CreateTestOrder(t, db, seller.ID, "BTC/USDT", "sell", "limit", 0.1, &ask1)
+		ask2 := 50100.0
+		sell2 := testutil.CreateTestOrder(t, db, seller.ID, "BTC/USDT", "sell", "limit", 0.1, &ask2)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(sell1.ID))
+		require.NoError(t, engine.MatchOrder(sell2.ID))
+
+		// When: 买单限价 50300，数量 0.15
+		bid := 50300.0
+		buy := testutil.CreateTestOrder(t, db, buyer.ID, "BTC/USDT", "buy", "limit", 0.15, &bid)
+		require.NoError(t, engine.MatchOrder(buy.ID))
+
+		// Then: 买单全部内部成交，按 maker 价格
+		var buyTrades []model.Trade
+		require.NoError(t, db.Where("order_id = ?", buy.ID).Order("id ASC").Find(&buyTrades).Error)
+		require.Len(t, buyTrades, 2)
+		assert.Equal(t, 50100.0, buyTrades[0].Price.InexactFloat64())
+		assert.InDelta(t, 0.1, buyTrades[0].Amount.InexactFloat64(), 1e-9)
+		assert.Equal(t, 50200.0, buyTrades[1].Price.InexactFloat64())
+		assert.InDelta(t, 0.05, buyTrades[1].Amount.InexactFloat64(), 1e-9)
+		assert.False(t, buyTrades[0].IsMaker)
+
+		var updatedBuy model.Order
+		require.NoError(t, db.First(&updatedBuy, buy.ID).Error)
+		assert.Equal(t, "filled", updatedBuy.Status)
+
+		// And: 卖方挂单成为 maker
+		var sell2Trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", sell2.ID).First(&sell2Trade).Error)
+		assert.True(t, sell2Trade.IsMaker)
+		assert.Equal(t, seller.ID, sell2Trade.UserID)
+
+		var updatedSell1, updatedSell2 model.Order
+		require.NoError(t, db.First(&updatedSell1, sell1.ID).Error)
+		require.NoError(t, db.First(&updatedSell2, sell2.ID).Error)
+		assert.Equal(t, "filled", updatedSell2.Status)
+		assert.Equal(t, "partially_filled", updatedSell1.Status)
+		assert.InDelta(t, 0.05, updatedSell1.Filled.InexactFloat64(), 1e-9)
+
+		// And: 双方余额结算
+		var sellerUSDT model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", seller.ID, "USDT").First(&sellerUSDT).Error)
+		assert.Greater(t, sellerUSDT.Available.InexactFloat64(), 0.0)
+		var buyerBTC model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", buyer.ID, "BTC").First(&buyerBTC).Error)
+		assert.Greater(t, buyerBTC.Available.InexactFloat64(), 0.0)
+	})
+
+	t.Run("Market buy skips resting asks above its reservation price", func(t *testing.T) {
+		testutil.CleanupDB(t, db)
+		bid, ask := 50000.0, 50150.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50100.0, BidPrice: &bid, AskPrice: &ask}).Error)
+
+		seller := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, seller.ID, "BTC", 0, 0.2)
+		testutil.SeedBalance(t, db, seller.ID, "USDT", 0, 0)
+		buyer := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, buyer.ID, "USDT", 0, 10040.0)
+		testutil.SeedBalance(t, db, buyer.ID, "BTC", 0, 0)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		low, high := 50100.0, 50500.0
+		cheap := testutil.CreateTestOrder(t, db, seller.ID, "BTC/USDT", "sell", "limit", 0.1, &low)
+		expensive := testutil.CreateTestOrder(t, db, seller.ID, "BTC/USDT", "sell", "limit", 0.1, &high)
+		require.NoError(t, engine.MatchOrder(cheap.ID))
+		require.NoError(t, engine.MatchOrder(expensive.ID))
+
+		// When: 市价买单 0.2 按 50200 冻结 10040
+		buy := &model.Order{
+			UserID:       buyer.ID,
+			Symbol:       "BTC/USDT",
+			Side:         "buy",
+			Type:         "market",
+			Status:       "new",
+			Amount:       decimal.NewFromFloat(0.2),
+			ReservePrice: decimalPtr(50200),
+		}
+		require.NoError(t, db.Create(buy).Error)
+		require.NoError(t, engine.MatchOrder(buy.ID))
+
+		// Then: 内部只吃 50100 的挂单，剩余部分按外部 ask 50150 成交，50500 的挂单不动
+		var trades []model.Trade
+		require.NoError(t, db.Where("order_id = ?", buy.ID).Order("id ASC").Find(&trades).Error)
+		require.Len(t, trades, 2)
+		assert.Equal(t, "50100", trades[0].Price.String())
+		assert.Equal(t, "50150", trades[1].Price.String())
+
+		require.NoError(t, db.First(buy, buy.ID).Error)
+		assert.Equal(t, "filled", buy.Status)
+		require.NoError(t, db.First(expensive, expensive.ID).Error)
+		assert.Equal(t, "new", expensive.Status)
+
+		var buyerUSDT model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", buyer.ID, "USDT").First(&buyerUSDT).Error)
+		assert.True(t, buyerUSDT.Locked.IsZero())
+		assert.Equal(t, "15", buyerUSDT.Available.String())
+	})
+
+	t.Run("Non-crossing orders rest on the book", func(t *testing.T) {
+		testutil.CleanupDB(t, db)
+		require.NoError(t, db.Save(ticker).Error)
+
+		user1 := testutil.SeedUser(t, db)
+		user2 := testutil.SeedUser(t, db)
+		ask := 50500.0
+		sell := testutil.CreateTestOrder(t, db, user1.ID, "BTC/USDT", "sell", "limit", 0.1, &ask)
+		bid := 50400.0
+		buy := testutil.CreateTestOrder(t, db, user2.ID, "BTC/USDT", "buy", "limit", 0.1, &bid)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(sell.ID))
+		require.NoError(t, engine.MatchOrder(buy.ID))
+
+		var count int64
+		db.Model(&model.Trade{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("External mode ignores resting orders", func(t *testing.T) {
+		testutil.CleanupDB(t, db)
+		require.NoError(t, db.Save(ticker).Error)
+
+		user1 := testutil.SeedUser(t, db)
+		user2 := testutil.SeedUser(t, db)
+		ask := 50000.0
+		sell := testutil.CreateTestOrder(t, db, user1.ID, "ETH/USDT", "sell", "limit", 1, &ask)
+		bid := 50000.0
+		buy := testutil.CreateTestOrder(t, db, user2.ID, "ETH/USDT", "buy", "limit", 1, &bid)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		// ETH/USDT 没有行情，按外部模式撮合会失败，说明没有走内部撮合
+		require.Error(t, engine.MatchOrder(buy.ID))
+
+		var updated model.Order
+		require.NoError(t, db.First(&updated, sell.ID).Error)
+		assert.Equal(t, "new", updated.Status)
+	})
+}
+
+func TestMatchOrder_SelfTradePrevention(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	logger := testutil.NewTestLogger()
+
+	bidPrice := 40000.0
+	askPrice := 60000.0
+	ticker := &model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 50000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}
+
+	tests := []struct {
+		mode        string
+		trades      int64
+		buyStatus   string
+		sellStatus  string
+		sellAmount  float64
+		lockedBTC   float64
+		lockedUSDT  float64
+		cancelledBy []string // 带自成交原因的订单：buy/sell
+	}{
+		{mode: STPNone, trades: 2, buyStatus: "filled", sellStatus: "partially_filled", sellAmount: 0.3, lockedBTC: 0.1, lockedUSDT: 0},
+		{mode: STPCancelNewest, buyStatus: "cancelled", sellStatus: "new", sellAmount: 0.3, lockedBTC: 0.3, lockedUSDT: 0, cancelledBy: []string{"buy"}},
+		{mode: STPCancelOldest, buyStatus: "new", sellStatus: "cancelled", sellAmount: 0.3, lockedBTC: 0, lockedUSDT: 10000, cancelledBy: []string{"sell"}},
+		{mode: STPCancelBoth, buyStatus: "cancelled", sellStatus: "cancelled", sellAmount: 0.3, lockedBTC: 0, lockedUSDT: 0, cancelledBy: []string{"buy", "sell"}},
+		{mode: STPDecrement, buyStatus: "cancelled", sellStatus: "new", sellAmount: 0.1, lockedBTC: 0.1, lockedUSDT: 0, cancelledBy: []string{"buy"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			testutil.CleanupDB(t, db)
+			require.NoError(t, db.Save(ticker).Error)
+			cfg.Trading.Matching = config.MatchingConfig{Mode: "internal", SelfTradePrevention: tt.mode}
+
+			// Given: 同一用户先挂出卖单 0.3 @ 50000
+			user := testutil.SeedUser(t, db)
+			testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0.3)
+			testutil.SeedBalance(t, db, user.ID, "USDT", 0, 10000.0)
+			price := 50000.0
+			sell := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "sell", "limit", 0.3, &price)
+
+			engine := NewMatchingEngine(db, cfg, logger)
+			require.NoError(t, engine.MatchOrder(sell.ID))
+
+			// When: 再下买单 0.2 @ 50000
+			buy := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "buy", "limit", 0.2, &price)
+			require.NoError(t, engine.MatchOrder(buy.ID))
+
+			// Then: 按模式处理双方订单
+			var count int64
+			db.Model(&model.Trade{}).Count(&count)
+			assert.Equal(t, tt.trades, count)
+
+			var updatedBuy, updatedSell model.Order
+			require.NoError(t, db.First(&updatedBuy, buy.ID).Error)
+			require.NoError(t, db.First(&updatedSell, sell.ID).Error)
+			assert.Equal(t, tt.buyStatus, updatedBuy.Status)
+			assert.Equal(t, tt.sellStatus, updatedSell.Status)
+			assert.InDelta(t, tt.sellAmount, updatedSell.Amount.InexactFloat64(), 1e-9)
+
+			reasons := map[string]string{"buy": updatedBuy.CancelReason, "sell": updatedSell.CancelReason}
+			for _, side := range tt.cancelledBy {
+				assert.Equal(t, CancelReasonSelfTrade, reasons[side])
+			}
+
+			// And: 撤销或减少的部分解冻
+			var btc, usdt model.Balance
+			require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btc).Error)
+			require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+			assert.InDelta(t, tt.lockedBTC, btc.Locked.InexactFloat64(), 1e-9)
+			assert.InDelta(t, tt.lockedUSDT, usdt.Locked.InexactFloat64(), 1e-9)
+		})
+	}
+
+	t.Run("Other users' orders still match after the own order is cancelled", func(t *testing.T) {
+		testutil.CleanupDB(t, db)
+		require.NoError(t, db.Save(ticker).Error)
+		cfg.Trading.Matching = config.MatchingConfig{Mode: "internal", SelfTradePrevention: STPCancelOldest}
+
+		// Given: 自己 50000 的卖单在前，其他用户 50100 的卖单在后
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0.1)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 10020.0)
+		other := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, other.ID, "BTC", 0, 0.2)
+		own, otherAsk := 50000.0, 50100.0
+		ownSell := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "sell", "limit", 0.1, &own)
+		otherSell := testutil.CreateTestOrder(t, db, other.ID, "BTC/USDT", "sell", "limit", 0.2, &otherAsk)
+
+		// When: 买单 0.2 @ 50100
+		buy := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "buy", "limit", 0.2, &otherAsk)
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(buy.ID))
+
+		// Then: 自己的卖单被撤销，买单与其他用户成交
+		var updatedOwn, updatedOther, updatedBuy model.Order
+		require.NoError(t, db.First(&updatedOwn, ownSell.ID).Error)
+		require.NoError(t, db.First(&updatedOther, otherSell.ID).Error)
+		require.NoError(t, db.First(&updatedBuy, buy.ID).Error)
+		assert.Equal(t, "cancelled", updatedOwn.Status)
+		assert.Equal(t, "filled", updatedOther.Status)
+		assert.Equal(t, "filled", updatedBuy.Status)
+	})
+}
+
+func TestMatchOrder_FeeToken(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	cfg.Trading.FeeToken = config.FeeTokenConfig{Asset: "QSX", Discount: 0.25}
+	logger := testutil.NewTestLogger()
+
+	price := 50000.0
+	setup := func(t *testing.T, tokenBalance float64) *model.User {
+		testutil.CleanupDB(t, db)
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: price, BidPrice: &price, AskPrice: &price}).Error)
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "QSX/USDT", LastPrice: 2.0}).Error)
+
+		user := testutil.SeedUser(t, db)
+		require.NoError(t, db.Model(user).Update("pay_fees_with_token", true).Error)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 5000.0)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0.1)
+		testutil.SeedBalance(t, db, user.ID, "QSX", tokenBalance)
+		return user
+	}
+	balanceOf := func(t *testing.T, userID uint, asset string) decimal.Decimal {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, asset).First(&balance).Error)
+		return balance.Available
+	}
+
+	t.Run("Buy fee is paid in token at a discount", func(t *testing.T) {
+		user := setup(t, 100)
+		order := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "buy", "limit", 0.1, &price)
+
+		require.NoError(t, NewMatchingEngine(db, cfg, logger).MatchOrder(order.ID))
+
+		// Then: taker 手续费 0.0001 BTC = 5 USDT，打 75 折后按 2 USDT 换算为 1.875 QSX
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.Equal(t, "QSX", trade.FeeAsset)
+		assert.Equal(t, "1.875", trade.Fee.String())
+		assert.Equal(t, "98.125", balanceOf(t, user.ID, "QSX").String())
+		assert.Equal(t, "0.1", balanceOf(t, user.ID, "BTC").String())
+
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.True(t, updated.Fee.IsZero())
+		assert.Equal(t, "1.875", updated.TokenFee.String())
+		assert.Equal(t, "QSX", updated.TokenFeeAsset)
+	})
+
+	t.Run("Sell fee is paid in token at a discount", func(t *testing.T) {
+		user := setup(t, 100)
+		order := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "sell", "limit", 0.1, &price)
+
+		require.NoError(t, NewMatchingEngine(db, cfg, logger).MatchOrder(order.ID))
+
+		// Then: 手续费 5 USDT 以 1.875 QSX 支付，收到全部 5000 USDT
+		assert.Equal(t, "98.125", balanceOf(t, user.ID, "QSX").String())
+		assert.Equal(t, "5000", balanceOf(t, user.ID, "USDT").String())
+	})
+
+	t.Run("Buying the fee token itself gets the discount", func(t *testing.T) {
+		user := setup(t, 0)
+		tokenPrice := 2.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "QSX/USDT", LastPrice: tokenPrice, BidPrice: &tokenPrice, AskPrice: &tokenPrice}).Error)
+		order := testutil.CreateTestOrder(t, db, user.ID, "QSX/USDT", "buy", "limit", 1000, &tokenPrice)
+
+		require.NoError(t, NewMatchingEngine(db, cfg, logger).MatchOrder(order.ID))
+
+		// Then: taker 手续费 1 QSX 打 75 折，从收到的 QSX 中扣除 0.75
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.Equal(t, "QSX", trade.FeeAsset)
+		assert.Equal(t, "0.75", trade.Fee.String())
+		assert.Equal(t, "999.25", balanceOf(t, user.ID, "QSX").String())
+	})
+
+	t.Run("Insufficient token balance falls back to the received asset", func(t *testing.T) {
+		user := setup(t, 1)
+		order := testutil.CreateTestOrder(t, db, user.ID, "BTC/USDT", "buy", "limit", 0.1, &price)
+
+		require.NoError(t, NewMatchingEngine(db, cfg, logger).MatchOrder(order.ID))
+
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.Equal(t, "BTC", trade.FeeAsset)
+		assert.Equal(t, "1", balanceOf(t, user.ID, "QSX").String())
+		assert.Equal(t, "0.0999", balanceOf(t, user.ID, "BTC").String())
+	})
+}
+
+func TestMatchOrder_TimeInForce(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	cfg.Trading.Liquidity = config.LiquidityConfig{
+		Model: "fixed",
+		Depth: 0.1,
+	}
+	logger := testutil.NewTestLogger()
+
+	bidPrice := 49990.0
+	askPrice := 50010.0
+	ticker := &model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 50000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}
+	require.NoError(t, db.Save(ticker).Error)
+
+	t.Run("IOC remainder is cancelled and funds released", func(t *testing.T) {
+		// Given: 卖出 0.25 BTC，每次最多成交 0.1
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0.25)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0)
+
+		order := &model.Order{
+			UserID:      user.ID,
+			Symbol:      "BTC/USDT",
+			Side:        "sell",
+			Type:        "market",
+			TimeInForce: TimeInForceIOC,
+			Amount:      decimal.NewFromFloat(0.25),
+			Status:      "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		// When: 撮合
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 成交 0.1，剩余部分撤销
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "cancelled", updated.Status)
+		assert.Equal(t, CancelReasonIOCRemainder, updated.CancelReason)
+		assert.InDelta(t, 0.1, updated.Filled.InexactFloat64(), 1e-9)
+		assert.NotNil(t, updated.CanceledAt)
+
+		var btc model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btc).Error)
+		assert.InDelta(t, 0.15, btc.Available.InexactFloat64(), 1e-9)
+		assert.InDelta(t, 0.0, btc.Locked.InexactFloat64(), 1e-9)
+	})
+
+	t.Run("FOK is rejected without enough liquidity", func(t *testing.T) {
+		// Given: 买入 0.25 BTC，流动性只有 0.1
+		user := testutil.SeedUser(t, db)
+		price := 50100.0
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0.25*price)
+
+		order := &model.Order{
+			UserID:      user.ID,
+			Symbol:      "BTC/USDT",
+			Side:        "buy",
+			Type:        "limit",
+			TimeInForce: TimeInForceFOK,
+			Amount:      decimal.NewFromFloat(0.25),
+			Price:       decimalPtr(price),
+			Status:      "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		// When: 撮合
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 整单拒绝，没有任何成交，冻结资金全部退回
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "rejected", updated.Status)
+		assert.Equal(t, CancelReasonFOKUnfillable, updated.CancelReason)
+		assert.Equal(t, 0.0, updated.Filled.InexactFloat64())
+
+		var count int64
+		db.Model(&model.Trade{}).Where("order_id = ?", order.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		var usdt model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+		assert.InDelta(t, 0.25*price, usdt.Available.InexactFloat64(), 1e-6)
+		assert.InDelta(t, 0.0, usdt.Locked.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("FOK fills completely when liquidity is sufficient", func(t *testing.T) {
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0, 0.05)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0)
+
+		order := &model.Order{
+			UserID:      user.ID,
+			Symbol:      "BTC/USDT",
+			Side:        "sell",
+			Type:        "market",
+			TimeInForce: TimeInForceFOK,
+			Amount:      decimal.NewFromFloat(0.05),
+			Status:      "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "filled", updated.Status)
+		assert.Empty(t, updated.CancelReason)
+	})
+}
+
+func TestCheckPostOnly(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	logger := testutil.NewTestLogger()
+
+	bidPrice := 49990.0
+	askPrice := 50010.0
+	require.NoError(t, db.Save(&model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 50000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}).Error)
+
+	engine := NewMatchingEngine(db, cfg, logger)
+
+	tests := []struct {
+		name    string
+		side    string
+		price   float64
+		wantErr bool
+	}{
+		{"buy below ask rests", "buy", 50000.0, false},
+		{"buy at ask crosses", "buy", 50010.0, true},
+		{"sell above bid rests", "sell", 50000.0, false},
+		{"sell at bid crosses", "sell", 49990.0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price := tt.price
+			err := engine.CheckPostOnly(&model.Order{
+				Symbol: "BTC/USDT",
+				Side:   tt.side,
+				Type:   "limit",
+				Price:  decimalPtr(price),
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMatchOrder_OrderList(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	logger := testutil.NewTestLogger()
+
+	bidPrice := 49990.0
+	askPrice := 50010.0
+	require.NoError(t, db.Save(&model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 50000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}).Error)
+
+	t.Run("OCO limit leg fill cancels stop leg and releases extra lock", func(t *testing.T) {
+		// Given: 买入 OCO，限价腿 50100（可立即成交），止损腿 51000，按较高的 51000 冻结
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0.1*51000.0)
+
+		list := &model.OrderList{UserID: user.ID, Symbol: "BTC/USDT", Type: "oco"}
+		require.NoError(t, db.Create(list).Error)
+
+		limitPrice := 50100.0
+		stopPrice := 51000.0
+		limitLeg := &model.Order{
+			UserID: user.ID, Symbol: "BTC/USDT", Side: "buy", Type: "limit",
+			Amount: decimal.NewFromFloat(0.1), Price: decimalPtr(limitPrice), Status: "new", OrderListID: &list.ID,
+		}
+		stopLeg := &model.Order{
+			UserID: user.ID, Symbol: "BTC/USDT", Side: "buy", Type: "stop_loss",
+			Amount: decimal.NewFromFloat(0.1), StopPrice: decimalPtr(stopPrice), TriggerCondition: ">=", Status: "new", OrderListID: &list.ID,
+		}
+		require.NoError(t, db.Create(limitLeg).Error)
+		require.NoError(t, db.Create(stopLeg).Error)
+
+		// When: 撮合限价腿
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(limitLeg.ID))
+
+		// Then: 限价腿成交，止损腿被撤销
+		var updatedStop model.Order
+		require.NoError(t, db.First(&updatedStop, stopLeg.ID).Error)
+		assert.Equal(t, "cancelled", updatedStop.Status)
+		assert.Equal(t, CancelReasonOCOSibling, updatedStop.CancelReason)
+
+		// And: 止损腿多冻结的部分 0.1*(51000-50100) 被解冻，按 ask 50010 成交的价格改善退回
+		var usdt model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+		assert.InDelta(t, 0.1*(stopPrice-limitPrice)+0.1*(limitPrice-askPrice), usdt.Available.InexactFloat64(), 1e-6)
+		assert.True(t, usdt.Locked.IsZero())
+	})
+
+	t.Run("Bracket legs are activated after entry fills", func(t *testing.T) {
+		// Given: 市价买入入场单及未激活的止盈止损腿
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 0, 0.1*50010.0)
+		testutil.SeedBalance(t, db, user.ID, "BTC", 0)
+
+		list := &model.OrderList{UserID: user.ID, Symbol: "BTC/USDT", Type: "bracket"}
+		require.NoError(t, db.Create(list).Error)
+
+		entry := &model.Order{
+			UserID: user.ID, Symbol: "BTC/USDT", Side: "buy", Type: "market",
+			Amount: decimal.NewFromFloat(0.1), ReservePrice: decimalPtr(50010.0), Status: "new", OrderListID: &list.ID,
+		}
+		require.NoError(t, db.Create(entry).Error)
+
+		stopLossPrice := 48000.0
+		takeProfitPrice := 53000.0
+		stopLeg := &model.Order{
+			UserID: user.ID, Symbol: "BTC/USDT", Side: "sell", Type: "stop_loss",
+			Amount: decimal.NewFromFloat(0.1), StopPrice: decimalPtr(stopLossPrice), TriggerCondition: "<=", Status: "pending",
+			OrderListID: &list.ID, ParentOrderID: &entry.ID,
+		}
+		takeLeg := &model.Order{
+			UserID: user.ID, Symbol: "BTC/USDT", Side: "sell", Type: "take_profit",
+			Amount: decimal.NewFromFloat(0.1), StopPrice: decimalPtr(takeProfitPrice), TriggerCondition: ">=", Status: "pending",
+			OrderListID: &list.ID, ParentOrderID: &entry.ID,
+		}
+		require.NoError(t, db.Create(stopLeg).Error)
+		require.NoError(t, db.Create(takeLeg).Error)
+
+		// When: 入场单成交
+		engine := NewMatchingEngine(db, cfg, logger)
+		require.NoError(t, engine.MatchOrder(entry.ID))
+
+		// Then: 两条腿按到手数量激活，并共用一份冻结
+		var updatedEntry model.Order
+		require.NoError(t, db.First(&updatedEntry, entry.ID).Error)
+		require.Equal(t, "filled", updatedEntry.Status)
+		netAmount := updatedEntry.Filled.Sub(updatedEntry.Fee).InexactFloat64()
+
+		for _, id := range []uint{stopLeg.ID, takeLeg.ID} {
+			var leg model.Order
+			require.NoError(t, db.First(&leg, id).Error)
+			assert.Equal(t, "new", leg.Status)
+			assert.InDelta(t, netAmount, leg.Amount.InexactFloat64(), 1e-8)
+		}
+
+		var btc model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btc).Error)
+		assert.InDelta(t, netAmount, btc.Locked.InexactFloat64(), 1e-8)
+		assert.InDelta(t, 0.0, btc.Available.InexactFloat64(), 1e-8)
+	})
+}
+
+// decimalPtr 测试辅助：构造精确小数指针
+func decimalPtr(v float64) *decimal.Decimal {
+	d := decimal.NewFromFloat(v)
+	return &d
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 订单组撤销/拒绝原因
+const (
+	CancelReasonOCOSibling          = "oco_sibling"          // 同组另一腿已成交或触发
+	CancelReasonParentCancelled     = "parent_cancelled"     // bracket 入场单未成交即被撤销
+	CancelReasonInsufficientBalance = "insufficient_balance" // bracket 腿激活时余额不足
+)
+
+// ResolveOrderList 订单组中的一腿开始成交或被触发时，撤销同组其他腿（OCO）
+// 同组的腿共用一份冻结资金（各腿所需的最大值），激活腿只保留自己所需的部分，多余部分解冻
+func (m *MatchingEngine) ResolveOrderList(tx *gorm.DB, order *model.Order) error {
+	if order.OrderListID == nil {
+		return nil
+	}
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_list_id = ? AND id <> ? AND status IN ?",
+			*order.OrderListID, order.ID, []string{"new", "partially_filled"})
+	if order.ParentOrderID != nil {
+		query = query.Where("parent_order_id = ?", *order.ParentOrderID)
+	} else {
+		query = query.Where("parent_order_id IS NULL")
+	}
+
+	var siblings []model.Order
+	if err := query.Find(&siblings).Error; err != nil {
+		return fmt.Errorf("failed to query order list siblings: %w", err)
+	}
+	if len(siblings) == 0 {
+		return nil
+	}
+
+	activeReserve := legReservation(order)
+	maxReserve := activeReserve
+	now := time.Now()
+
+	for i := range siblings {
+		sibling := &siblings[i]
+		maxReserve = decimal.Max(maxReserve, legReservation(sibling))
+
+		sibling.Status = "cancelled"
+		sibling.CancelReason = CancelReasonOCOSibling
+		sibling.CanceledAt = &now
+		if err := tx.Save(sibling).Error; err != nil {
+			return fmt.Errorf("failed to cancel sibling order: %w", err)
+		}
+	}
+
+	if release := maxReserve.Sub(activeReserve); release.IsPositive() {
+		if err := m.unlockBalance(tx, order.UserID, m.reservationAsset(order), release); err != nil {
+			return err
+		}
+	}
+
+	m.logger.Info("Order list resolved",
+		zap.Uint("order_list_id", *order.OrderListID),
+		zap.Uint("active_order_id", order.ID),
+		zap.Int("cancelled_siblings", len(siblings)),
+	)
+
+	return nil
+}
+
+// finalizeBracketEntry bracket 入场单结束（全部成交或剩余部分被撤销）后处理止盈止损腿
+// 有成交时按成交数量激活，完全未成交时撤销
+func (m *MatchingEngine) finalizeBracketEntry(tx *gorm.DB, entry *model.Order) error {
+	if entry.OrderListID == nil {
+		return nil
+	}
+
+	var legs []model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_list_id = ? AND parent_order_id = ? AND status = ?",
+			*entry.OrderListID, entry.ID, "pending").
+		Find(&legs).Error; err != nil {
+		return fmt.Errorf("failed to query bracket legs: %w", err)
+	}
+	if len(legs) == 0 {
+		return nil
+	}
+
+	if entry.Filled.IsZero() {
+		return m.closeLegs(tx, legs, "cancelled", CancelReasonParentCancelled)
+	}
+
+	// 腿的数量为入场单实际到手的基础币数量（买入手续费以基础币收取）
+	baseCoin, _ := m.splitSymbol(entry.Symbol)
+	amount := entry.Filled
+	if entry.Side == "buy" && entry.FeeAsset == baseCoin {
+		amount = amount.Sub(entry.Fee)
+	}
+	amount = m.markets.ForSymbol(entry.Symbol).RoundAmount(amount)
+
+	reserve := decimal.Zero
+	for i := range legs {
+		legs[i].Amount = amount
+		reserve = decimal.Max(reserve, legReservation(&legs[i]))
+	}
+	asset := m.reservationAsset(&legs[0])
+
+	var balance model.Balance
+	if err := tx.Where("user_id = ? AND asset = ?", entry.UserID, asset).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&balance).Error; err != nil || balance.Available.LessThan(reserve) {
+		m.logger.Warn("Insufficient balance to activate bracket legs",
+			zap.Uint("entry_order_id", entry.ID),
+			zap.String("asset", asset),
+			zap.Stringer("required", reserve),
+		)
+		return m.closeLegs(tx, legs, "rejected", CancelReasonInsufficientBalance)
+	}
+
+	balance.Available = balance.Available.Sub(reserve)
+	balance.Locked = balance.Locked.Add(reserve)
+	if err := tx.Save(&balance).Error; err != nil {
+		return fmt.Errorf("failed to freeze balance for bracket legs: %w", err)
+	}
+
+	for i := range legs {
+		legs[i].Status = "new"
+		if err := tx.Save(&legs[i]).Error; err != nil {
+			return fmt.Errorf("failed to activate bracket leg: %w", err)
+		}
+	}
+
+	m.logger.Info("Bracket legs activated",
+		zap.Uint("entry_order_id", entry.ID),
+		zap.Stringer("amount", amount),
+		zap.Int("legs", len(legs)),
+	)
+
+	return nil
+}
+
+// closeLegs 将未激活的腿置为终态
+func (m *MatchingEngine) closeLegs(tx *gorm.DB, legs []model.Order, status, reason string) error {
+	now := time.Now()
+	for i := range legs {
+		legs[i].Status = status
+		legs[i].CancelReason = reason
+		legs[i].CanceledAt = &now
+		if err := tx.Save(&legs[i]).Error; err != nil {
+			return fmt.Errorf("failed to close bracket leg: %w", err)
+		}
+	}
+	return nil
+}
+
+// unlockBalance 将冻结资金转回可用余额，冻结余额不足时返回错误
+func (m *MatchingEngine) unlockBalance(tx *gorm.DB, userID uint, asset string, amount decimal.Decimal) error {
+	var balance model.Balance
+	if err := tx.Where("user_id = ? AND asset = ?", userID, asset).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&balance).Error; err != nil {
+		return fmt.Errorf("balance not found: %w", err)
+	}
+
+	if balance.Locked.LessThan(amount) {
+		return fmt.Errorf("insufficient locked balance: locked %s, required %s", balance.Locked, amount)
+	}
+	balance.Locked = balance.Locked.Sub(amount)
+	balance.Available = balance.Available.Add(amount)
+
+	if err := tx.Save(&balance).Error; err != nil {
+		return fmt.Errorf("failed to unfreeze balance: %w", err)
+	}
+	return nil
+}
+
+// legReservation 订单组中一腿单独所需的冻结数量
+func legReservation(order *model.Order) decimal.Decimal {
+	return ReservedFor(order, order.Amount)
+}
+
+// reservationAsset 订单冻结的资产（买单为计价币，卖单为基础币；永续合约为结算币）
+func (m *MatchingEngine) reservationAsset(order *model.Order) string {
+	baseCoin, quoteCoin := m.splitSymbol(order.Symbol)
+	if order.Side == "buy" || IsPerpetual(order) {
+		return quoteCoin
+	}
+	return baseCoin
+}
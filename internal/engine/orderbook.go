@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// OrderBookLevel 订单簿档位
+type OrderBookLevel struct {
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+// OrderBook 合成 L2 订单簿
+type OrderBook struct {
+	Symbol    string           `json:"symbol"`
+	Bids      []OrderBookLevel `json:"bids"` // 价格从高到低
+	Asks      []OrderBookLevel `json:"asks"` // 价格从低到高
+	Timestamp time.Time        `json:"timestamp"`
+
+	tickerUpdatedAt time.Time
+	lastPrice       float64
+}
+
+// fill 单笔成交（成交价 + 成交数量）
+type fill struct {
+	price  decimal.Decimal
+	amount decimal.Decimal
+}
+
+// OrderBookStore 内存订单簿存储
+// 每个交易对一个订单簿，行情更新后按最新行情重建（相当于补充流动性），
+// 同一行情周期内被吃掉的档位不会恢复
+type OrderBookStore struct {
+	mu    sync.Mutex
+	books map[string]*OrderBook
+}
+
+// defaultOrderBookStore 所有撮合引擎实例共享的订单簿存储
+var defaultOrderBookStore = NewOrderBookStore()
+
+// NewOrderBookStore 创建订单簿存储
+func NewOrderBookStore() *OrderBookStore {
+	return &OrderBookStore{
+		books: make(map[string]*OrderBook),
+	}
+}
+
+// DefaultOrderBookStore 返回共享的订单簿存储
+func DefaultOrderBookStore() *OrderBookStore {
+	return defaultOrderBookStore
+}
+
+// BuildOrderBook 根据行情生成合成订单簿
+// 第一档为行情的 bid/ask，之后每档按 PriceStep 远离，挂单数量按 SizeGrowth 递增
+func BuildOrderBook(ticker *model.Ticker, cfg config.SymbolOrderBookConfig) *OrderBook {
+	bestBid := ticker.LastPrice
+	if ticker.BidPrice != nil {
+		bestBid = *ticker.BidPrice
+	}
+	bestAsk := ticker.LastPrice
+	if ticker.AskPrice != nil {
+		bestAsk = *ticker.AskPrice
+	}
+
+	book := &OrderBook{
+		Symbol:          ticker.Symbol,
+		Bids:            make([]OrderBookLevel, 0, cfg.Levels),
+		Asks:            make([]OrderBookLevel, 0, cfg.Levels),
+		Timestamp:       ticker.UpdatedAt,
+		tickerUpdatedAt: ticker.UpdatedAt,
+		lastPrice:       ticker.LastPrice,
+	}
+
+	for i := 0; i < cfg.Levels; i++ {
+		size := roundDown8(cfg.BaseSize * (1 + float64(i)*cfg.SizeGrowth))
+		book.Asks = append(book.Asks, OrderBookLevel{
+			Price:  round8(bestAsk * (1 + float64(i)*cfg.PriceStep)),
+			Amount: size,
+		})
+
+		bidPrice := round8(bestBid * (1 - float64(i)*cfg.PriceStep))
+		if bidPrice > 0 {
+			book.Bids = append(book.Bids, OrderBookLevel{
+				Price:  bidPrice,
+				Amount: size,
+			})
+		}
+	}
+
+	return book
+}
+
+// Snapshot 返回交易对当前订单簿的副本，depth > 0 时只返回前 depth 档
+func (s *OrderBookStore) Snapshot(ticker *model.Ticker, cfg config.SymbolOrderBookConfig, depth int) *OrderBook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book := s.current(ticker, cfg)
+
+	bids := book.Bids
+	asks := book.Asks
+	if depth > 0 {
+		if len(bids) > depth {
+			bids = bids[:depth]
+		}
+		if len(asks) > depth {
+			asks = asks[:depth]
+		}
+	}
+
+	return &OrderBook{
+		Symbol:    book.Symbol,
+		Bids:      append([]OrderBookLevel(nil), bids...),
+		Asks:      append([]OrderBookLevel(nil), asks...),
+		Timestamp: book.Timestamp,
+	}
+}
+
+// walk 按价格优先逐档计算成交明细，不修改订单簿（成交提交后由 consume 扣除）
+// 买单吃 asks，卖单吃 bids；limitPrice 不为空时只成交价格不劣于限价的档位
+// 成交价按交易对价格单位取整，成交数量按数量步长向下截断
+// budget 不为空时累计成交金额不超过 budget（按金额下单的市价买单）
+func (s *OrderBookStore) walk(ticker *model.Ticker, cfg config.SymbolOrderBookConfig, mkt *market.Market, side string, amount decimal.Decimal, limitPrice, budget *decimal.Decimal) []fill {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book := s.current(ticker, cfg)
+
+	levels := book.Asks
+	if side == "sell" {
+		levels = book.Bids
+	}
+
+	var fills []fill
+	remaining := amount
+
+	for _, level := range levels {
+		if !remaining.IsPositive() {
+			break
+		}
+
+		price := mkt.RoundTick(decimal.NewFromFloat(level.Price))
+		if !priceWithinLimit(side, price, limitPrice) {
+			break
+		}
+
+		take := mkt.RoundAmount(decimal.Min(remaining, decimal.NewFromFloat(level.Amount)))
+		if budget != nil {
+			take = decimal.Min(take, mkt.RoundAmount(budget.Div(price)))
+			if !take.IsPositive() {
+				break
+			}
+		}
+		if !take.IsPositive() {
+			continue
+		}
+
+		fills = append(fills, fill{price: price, amount: take})
+		remaining = remaining.Sub(take)
+		if budget != nil {
+			spent := budget.Sub(take.Mul(price).Round(AssetScale))
+			budget = &spent
+		}
+	}
+
+	return fills
+}
+
+// consume 从订单簿中扣除已提交的成交数量，移除剩余不足一个数量步长的档位
+// 订单簿已按新行情重建时不再扣除（新行情已补充流动性）
+func (s *OrderBookStore) consume(ticker *model.Ticker, mkt *market.Market, side string, fills []fill) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book, ok := s.books[ticker.Symbol]
+	if !ok || !book.tickerUpdatedAt.Equal(ticker.UpdatedAt) || book.lastPrice != ticker.LastPrice {
+		return
+	}
+
+	levels := &book.Asks
+	if side == "sell" {
+		levels = &book.Bids
+	}
+
+	for _, f := range fills {
+		remaining := f.amount
+		for i := range *levels {
+			level := &(*levels)[i]
+			if !remaining.IsPositive() {
+				break
+			}
+			if !mkt.RoundTick(decimal.NewFromFloat(level.Price)).Equal(f.price) {
+				continue
+			}
+			levelAmount := decimal.NewFromFloat(level.Amount)
+			take := decimal.Min(remaining, levelAmount)
+			level.Amount = levelAmount.Sub(take).InexactFloat64()
+			remaining = remaining.Sub(take)
+		}
+	}
+
+	kept := make([]OrderBookLevel, 0, len(*levels))
+	for _, level := range *levels {
+		if mkt.RoundAmount(decimal.NewFromFloat(level.Amount)).IsPositive() {
+			kept = append(kept, level)
+		}
+	}
+	*levels = kept
+}
+
+// available 统计不劣于限价的档位上可成交的总数量（不消耗流动性）
+func (s *OrderBookStore) available(ticker *model.Ticker, cfg config.SymbolOrderBookConfig, mkt *market.Market, side string, limitPrice *decimal.Decimal) decimal.Decimal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book := s.current(ticker, cfg)
+
+	levels := book.Asks
+	if side == "sell" {
+		levels = book.Bids
+	}
+
+	total := decimal.Zero
+	for _, level := range levels {
+		price := mkt.RoundTick(decimal.NewFromFloat(level.Price))
+		if !priceWithinLimit(side, price, limitPrice) {
+			break
+		}
+		total = total.Add(mkt.RoundAmount(decimal.NewFromFloat(level.Amount)))
+	}
+
+	return total
+}
+
+// priceWithinLimit 判断档位价格是否不劣于限价（买单不高于限价，卖单不低于限价）
+func priceWithinLimit(side string, price decimal.Decimal, limitPrice *decimal.Decimal) bool {
+	if limitPrice == nil {
+		return true
+	}
+	if side == "buy" {
+		return price.LessThanOrEqual(*limitPrice)
+	}
+	return price.GreaterThanOrEqual(*limitPrice)
+}
+
+// current 返回交易对当前订单簿，行情更新后重建（调用方需持有锁）
+func (s *OrderBookStore) current(ticker *model.Ticker, cfg config.SymbolOrderBookConfig) *OrderBook {
+	book, ok := s.books[ticker.Symbol]
+	if ok && book.tickerUpdatedAt.Equal(ticker.UpdatedAt) && book.lastPrice == ticker.LastPrice {
+		return book
+	}
+
+	book = BuildOrderBook(ticker, cfg)
+	s.books[ticker.Symbol] = book
+	return book
+}
+
+// round8 四舍五入到 8 位小数
+func round8(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
+
+// roundDown8 向下截断到 8 位小数
+func roundDown8(v float64) float64 {
+	return math.Floor(v*1e8+1e-6) / 1e8
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+func newBookTicker(bid, ask float64) *model.Ticker {
+	return &model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: (bid + ask) / 2,
+		BidPrice:  &bid,
+		AskPrice:  &ask,
+		UpdatedAt: time.Now(),
+	}
+}
+
+func TestBuildOrderBook(t *testing.T) {
+	ticker := newBookTicker(49990.0, 50010.0)
+	cfg := config.SymbolOrderBookConfig{Levels: 5, PriceStep: 0.001, BaseSize: 1.0, SizeGrowth: 0.5}
+
+	book := BuildOrderBook(ticker, cfg)
+
+	require.Len(t, book.Asks, 5)
+	require.Len(t, book.Bids, 5)
+
+	// 第一档等于行情 bid/ask
+	assert.Equal(t, 50010.0, book.Asks[0].Price)
+	assert.Equal(t, 49990.0, book.Bids[0].Price)
+
+	// 档位按价格排序，挂单量递增
+	for i := 1; i < 5; i++ {
+		assert.Greater(t, book.Asks[i].Price, book.Asks[i-1].Price)
+		assert.Less(t, book.Bids[i].Price, book.Bids[i-1].Price)
+		assert.Greater(t, book.Asks[i].Amount, book.Asks[i-1].Amount)
+	}
+	assert.InDelta(t, 50010.0*1.004, book.Asks[4].Price, 1e-6)
+	assert.InDelta(t, 3.0, book.Asks[4].Amount, 1e-9)
+}
+
+func TestOrderBookStoreWalk(t *testing.T) {
+	cfg := config.SymbolOrderBookConfig{Levels: 3, PriceStep: 0.001, BaseSize: 1.0}
+	mkt := &market.Market{AmountStep: market.DefaultStep, PriceTick: decimal.New(1, -2)}
+
+	t.Run("Walk multiple levels and consume liquidity", func(t *testing.T) {
+		store := NewOrderBookStore()
+		ticker := newBookTicker(49990.0, 50000.0)
+
+		fills := store.walk(ticker, cfg, mkt, "buy", decimal.NewFromFloat(1.5), nil, nil)
+
+		require.Len(t, fills, 2)
+		assert.Equal(t, "50000", fills[0].price.String())
+		assert.Equal(t, "1", fills[0].amount.String())
+		assert.Equal(t, "50050", fills[1].price.String())
+		assert.Equal(t, "0.5", fills[1].amount.String())
+
+		// 只计算成交明细，提交前不扣除流动性
+		book := store.Snapshot(ticker, cfg, 0)
+		require.Len(t, book.Asks, 3)
+
+		// 同一行情周期内被吃掉的档位不会恢复
+		store.consume(ticker, mkt, "buy", fills)
+		book = store.Snapshot(ticker, cfg, 0)
+		require.Len(t, book.Asks, 2)
+		assert.InDelta(t, 0.5, book.Asks[0].Amount, 1e-9)
+
+		// 行情更新后订单簿重建
+		updated := newBookTicker(49990.0, 50000.0)
+		updated.UpdatedAt = ticker.UpdatedAt.Add(time.Second)
+		book = store.Snapshot(updated, cfg, 0)
+		assert.Len(t, book.Asks, 3)
+		assert.Equal(t, 1.0, book.Asks[0].Amount)
+	})
+
+	t.Run("Walk stops at limit price", func(t *testing.T) {
+		store := NewOrderBookStore()
+		ticker := newBookTicker(50000.0, 50010.0)
+		limit := decimal.NewFromFloat(49960.0)
+
+		fills := store.walk(ticker, cfg, mkt, "sell", decimal.NewFromFloat(5.0), &limit, nil)
+
+		require.Len(t, fills, 1)
+		assert.Equal(t, "50000", fills[0].price.String())
+	})
+
+	t.Run("Snapshot respects depth", func(t *testing.T) {
+		store := NewOrderBookStore()
+		book := store.Snapshot(newBookTicker(50000.0, 50010.0), cfg, 2)
+		assert.Len(t, book.Bids, 2)
+		assert.Len(t, book.Asks, 2)
+	})
+}
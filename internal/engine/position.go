@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 持仓方向
+const (
+	PositionLong  = "long"
+	PositionShort = "short"
+)
+
+// 保证金模式
+const (
+	MarginModeCross    = "cross"
+	MarginModeIsolated = "isolated"
+)
+
+// IsPerpetual 是否为永续合约订单（按杠杆冻结保证金，成交后更新持仓）
+func IsPerpetual(order *model.Order) bool {
+	return order.Leverage > 0
+}
+
+// InitialMargin 按杠杆计算 qty 数量在 price 价格下的初始保证金（向上取整到资金精度）
+func InitialMargin(qty, price decimal.Decimal, leverage int) decimal.Decimal {
+	if leverage <= 0 {
+		leverage = 1
+	}
+	return CeilAsset(qty.Mul(price).Div(decimal.NewFromInt(int64(leverage))))
+}
+
+// UnrealizedPnl 持仓按 price 计算的未实现盈亏
+func UnrealizedPnl(pos *model.Position, price decimal.Decimal) decimal.Decimal {
+	pnl := price.Sub(pos.EntryPrice).Mul(pos.Size)
+	if pos.Side == PositionShort {
+		pnl = pnl.Neg()
+	}
+	return pnl.Round(AssetScale)
+}
+
+// MarkPrice 按行情计算标记价格：买一卖一的中间价，缺少盘口时使用最新价
+func MarkPrice(ticker *model.Ticker) decimal.Decimal {
+	if ticker.BidPrice != nil && ticker.AskPrice != nil && *ticker.BidPrice > 0 && *ticker.AskPrice > 0 {
+		return decimal.NewFromFloat(*ticker.BidPrice).Add(decimal.NewFromFloat(*ticker.AskPrice)).Div(decimal.NewFromInt(2)).Round(AssetScale)
+	}
+	return decimal.NewFromFloat(ticker.LastPrice)
+}
+
+// positionFill 一笔成交对持仓的影响
+type positionFill struct {
+	closed   decimal.Decimal // 平仓数量
+	opened   decimal.Decimal // 开仓数量（同向加仓或反向开仓）
+	pnl      decimal.Decimal // 平仓盈亏
+	released decimal.Decimal // 平仓释放的保证金
+	margin   decimal.Decimal // 开仓占用的保证金
+}
+
+// applyFill 按成交更新持仓：反向成交先平仓（按开仓均价计算盈亏、按比例释放保证金），剩余数量按成交方向开仓
+func applyFill(pos *model.Position, side string, qty, price decimal.Decimal, leverage int) positionFill {
+	var f positionFill
+	direction := PositionLong
+	if side == "sell" {
+		direction = PositionShort
+	}
+
+	if pos.Size.IsPositive() && pos.Side != direction {
+		f.closed = decimal.Min(qty, pos.Size)
+		f.pnl = UnrealizedPnl(&model.Position{Side: pos.Side, Size: f.closed, EntryPrice: pos.EntryPrice}, price)
+		f.released = pos.Margin
+		if f.closed.LessThan(pos.Size) {
+			f.released = FloorAsset(pos.Margin.Mul(f.closed).Div(pos.Size))
+		}
+
+		pos.Size = pos.Size.Sub(f.closed)
+		pos.Margin = pos.Margin.Sub(f.released)
+		pos.RealizedPnl = pos.RealizedPnl.Add(f.pnl)
+		if pos.Size.IsZero() {
+			pos.Side = ""
+			pos.EntryPrice = decimal.Zero
+		}
+	}
+
+	f.opened = qty.Sub(f.closed)
+	if f.opened.IsPositive() {
+		f.margin = InitialMargin(f.opened, price, leverage)
+		size := pos.Size.Add(f.opened)
+		pos.EntryPrice = pos.EntryPrice.Mul(pos.Size).Add(price.Mul(f.opened)).Div(size).Round(AssetScale)
+		pos.Size = size
+		pos.Side = direction
+		pos.Margin = pos.Margin.Add(f.margin)
+	}
+
+	return f
+}
+
+// LockPosition 在事务中锁定用户在交易对的持仓，不存在时按 leverage 创建空仓位
+func LockPosition(tx *gorm.DB, userID uint, symbol string, leverage int) (*model.Position, error) {
+	var pos model.Position
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND symbol = ?", userID, symbol).
+		Limit(1).
+		Find(&pos)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to lock position: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &pos, nil
+	}
+
+	pos = model.Position{
+		UserID:     userID,
+		Symbol:     symbol,
+		Leverage:   leverage,
+		MarginMode: MarginModeCross,
+	}
+	if err := tx.Create(&pos).Error; err != nil {
+		return nil, fmt.Errorf("failed to create position: %w", err)
+	}
+	return &pos, nil
+}
+
+// capReduceOnly 锁定持仓并把只减仓订单的成交明细截断到反向持仓数量，没有可平的持仓时不成交
+func capReduceOnly(tx *gorm.DB, order *model.Order, fills []fill) ([]fill, error) {
+	var pos model.Position
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND symbol = ?", order.UserID, order.Symbol).
+		Limit(1).
+		Find(&pos).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock position: %w", err)
+	}
+
+	closable := decimal.Zero
+	if (order.Side == "sell" && pos.Side == PositionLong) || (order.Side == "buy" && pos.Side == PositionShort) {
+		closable = pos.Size
+	}
+
+	capped := make([]fill, 0, len(fills))
+	for _, f := range fills {
+		if !closable.IsPositive() {
+			break
+		}
+		f.amount = decimal.Min(f.amount, closable)
+		closable = closable.Sub(f.amount)
+		capped = append(capped, f)
+	}
+	return capped, nil
+}
+
+// settlePosition 永续合约成交结算（在更新订单成交量之前调用）
+// 释放本次成交对应的冻结保证金；平仓部分释放仓位保证金并结算盈亏，开仓部分占用仓位保证金；手续费从结算币扣除
+// 仓位保证金计入冻结余额，全仓亏损超过保证金时可用余额可能为负；逐仓亏损以仓位保证金为限，不足部分由保险基金承担
+func (m *MatchingEngine) settlePosition(tx *gorm.DB, order *model.Order, trade *model.Trade) error {
+	_, settle := m.splitSymbol(order.Symbol)
+	reserved := fillReservation(order, trade)
+
+	pos, err := LockPosition(tx, order.UserID, order.Symbol, order.Leverage)
+	if err != nil {
+		return err
+	}
+	f := applyFill(pos, order.Side, trade.Amount, trade.Price, order.Leverage)
+	consumed, err := m.capIsolatedLoss(tx, pos, &f, settle)
+	if err != nil {
+		return err
+	}
+	if err := tx.Save(pos).Error; err != nil {
+		return fmt.Errorf("failed to update position: %w", err)
+	}
+
+	var balance model.Balance
+	if err := tx.Where("user_id = ? AND asset = ?", order.UserID, settle).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&balance).Error; err != nil {
+		return fmt.Errorf("settle balance not found: %w", err)
+	}
+	if balance.Locked.LessThan(reserved) {
+		return fmt.Errorf("insufficient locked balance: locked %s, required %s", balance.Locked, reserved)
+	}
+
+	balance.Locked = balance.Locked.Sub(reserved).Add(f.margin).Sub(f.released).Sub(consumed)
+	balance.Available = balance.Available.Add(netOfFee(trade, settle, reserved.Sub(f.margin).Add(f.released).Add(f.pnl)))
+	if err := tx.Save(&balance).Error; err != nil {
+		return fmt.Errorf("failed to update settle balance: %w", err)
+	}
+
+	return nil
+}
+
+// capIsolatedLoss 逐仓平仓亏损超过释放的保证金时，先用剩余仓位保证金承担，仍不足的部分由保险基金承担
+// 调整 f.pnl 使亏损不超过释放的保证金，返回从剩余仓位保证金中扣除的金额（需同时从冻结余额中扣除）
+func (m *MatchingEngine) capIsolatedLoss(tx *gorm.DB, pos *model.Position, f *positionFill, settle string) (decimal.Decimal, error) {
+	excess := f.pnl.Add(f.released).Neg()
+	if pos.MarginMode != MarginModeIsolated || !excess.IsPositive() {
+		return decimal.Zero, nil
+	}
+
+	// 反向开仓的保证金属于新仓位，不承担平仓亏损
+	consumed := decimal.Min(excess, pos.Margin.Sub(f.margin))
+	pos.Margin = pos.Margin.Sub(consumed)
+	f.pnl = f.released.Neg()
+
+	shortfall := excess.Sub(consumed)
+	if !shortfall.IsPositive() {
+		return consumed, nil
+	}
+	pos.RealizedPnl = pos.RealizedPnl.Add(shortfall)
+
+	fund, err := LockInsuranceFund(tx, m.cfg, settle)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	fund.Balance = fund.Balance.Sub(shortfall)
+	if err := tx.Save(fund).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to update insurance fund: %w", err)
+	}
+
+	m.logger.Warn("Isolated position loss exceeds margin, covered by insurance fund",
+		zap.Uint("user_id", pos.UserID),
+		zap.String("symbol", pos.Symbol),
+		zap.Stringer("shortfall", shortfall),
+		zap.Stringer("insurance_fund", fund.Balance))
+	return consumed, nil
+}
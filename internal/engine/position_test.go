@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+func TestMatchOrder_Perpetual(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	logger := testutil.NewTestLogger()
+	engine := NewMatchingEngine(db, cfg, logger)
+
+	const symbol = "BTC/USDT:USDT"
+	setTicker := func(bid, ask float64) {
+		require.NoError(t, db.Save(&model.Ticker{Symbol: symbol, LastPrice: (bid + ask) / 2, BidPrice: &bid, AskPrice: &ask}).Error)
+	}
+
+	// Given: 10 倍杠杆，USDT 可用 10000，已为市价买单冻结保证金 0.1 * 50100 / 10 = 501
+	user := testutil.SeedUser(t, db)
+	testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 501.0)
+
+	balanceOf := func() model.Balance {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		return balance
+	}
+	positionOf := func() model.Position {
+		var pos model.Position
+		require.NoError(t, db.Where("user_id = ? AND symbol = ?", user.ID, symbol).First(&pos).Error)
+		return pos
+	}
+	placeMarket := func(side string, amount, reservePrice float64) *model.Order {
+		order := &model.Order{
+			UserID:       user.ID,
+			Symbol:       symbol,
+			Side:         side,
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(amount),
+			ReservePrice: decimalPtr(reservePrice),
+			Leverage:     10,
+			Status:       "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+		return order
+	}
+
+	t.Run("Buy opens a long position", func(t *testing.T) {
+		setTicker(49990, 50010)
+		order := placeMarket("buy", 0.1, 50100)
+
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 多仓 0.1 @ 50010，占用保证金 500.1，手续费 5.001 USDT
+		pos := positionOf()
+		assert.Equal(t, PositionLong, pos.Side)
+		assert.Equal(t, "0.1", pos.Size.String())
+		assert.Equal(t, "50010", pos.EntryPrice.String())
+		assert.Equal(t, "500.1", pos.Margin.String())
+
+		balance := balanceOf()
+		assert.Equal(t, "500.1", balance.Locked.String())
+		assert.Equal(t, "9995.899", balance.Available.String())
+
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.Equal(t, "5.001", trade.Fee.String())
+		assert.Equal(t, "USDT", trade.FeeAsset)
+	})
+
+	t.Run("Larger sell closes the long with profit and opens a short", func(t *testing.T) {
+		// Given: 为 0.15 的卖单冻结保证金 0.15 * 51000 / 10 = 765
+		setTicker(51000, 51010)
+		require.NoError(t, db.Model(&model.Balance{}).Where("user_id = ? AND asset = ?", user.ID, "USDT").
+			Updates(map[string]interface{}{"available": "9230.899", "locked": "1265.1"}).Error)
+		order := placeMarket("sell", 0.15, 51000)
+
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 平多 0.1 盈利 (51000 - 50010) * 0.1 = 99，剩余 0.05 开空
+		pos := positionOf()
+		assert.Equal(t, PositionShort, pos.Side)
+		assert.Equal(t, "0.05", pos.Size.String())
+		assert.Equal(t, "51000", pos.EntryPrice.String())
+		assert.Equal(t, "255", pos.Margin.String())
+		assert.Equal(t, "99", pos.RealizedPnl.String())
+
+		// 可用 = 9230.899 + 765（释放冻结）- 255（空仓保证金）+ 500.1（释放多仓保证金）+ 99 - 7.65（手续费）
+		balance := balanceOf()
+		assert.Equal(t, "255", balance.Locked.String())
+		assert.Equal(t, "10332.349", balance.Available.String())
+	})
+
+	t.Run("Reduce-only order is capped at the opposite position and never flips it", func(t *testing.T) {
+		// Given: 空仓 0.05，只减仓 IOC 买单 0.2（强平单不冻结保证金）
+		setTicker(50990, 51000)
+		order := &model.Order{
+			UserID:      user.ID,
+			Symbol:      symbol,
+			Side:        "buy",
+			Type:        "market",
+			TimeInForce: TimeInForceIOC,
+			Amount:      decimal.NewFromFloat(0.2),
+			Leverage:    10,
+			ReduceOnly:  true,
+			Status:      "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 只成交 0.05 平掉空仓，剩余部分撤销，不开多仓
+		require.NoError(t, db.First(order, order.ID).Error)
+		assert.Equal(t, "0.05", order.Filled.String())
+		assert.Equal(t, "cancelled", order.Status)
+
+		pos := positionOf()
+		assert.True(t, pos.Size.IsZero())
+		assert.Empty(t, pos.Side)
+
+		// 可用 = 10332.349 + 255（释放空仓保证金）- 2.55（手续费）
+		balance := balanceOf()
+		assert.True(t, balance.Locked.IsZero())
+		assert.Equal(t, "10584.799", balance.Available.String())
+	})
+}
+
+func TestSettlePosition_IsolatedLoss(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	cfg := testutil.LoadTestConfig(t)
+	cfg.Trading.Liquidation.InsuranceFund = 1000
+	logger := testutil.NewTestLogger()
+	engine := NewMatchingEngine(db, cfg, logger)
+
+	const symbol = "BTC/USDT:USDT"
+	bid, ask := 44490.0, 44510.0
+	require.NoError(t, db.Save(&model.Ticker{Symbol: symbol, LastPrice: 44500, BidPrice: &bid, AskPrice: &ask}).Error)
+
+	// openIsolated 逐仓多仓 1 BTC @ 50000，仓位保证金 5000，另有可用余额 1000
+	openIsolated := func(t *testing.T) *model.User {
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 1000.0, 5000.0)
+		require.NoError(t, db.Create(&model.Position{
+			UserID:     user.ID,
+			Symbol:     symbol,
+			Side:       PositionLong,
+			Size:       decimal.NewFromInt(1),
+			EntryPrice: decimal.NewFromInt(50000),
+			Margin:     decimal.NewFromInt(5000),
+			Leverage:   10,
+			MarginMode: MarginModeIsolated,
+		}).Error)
+		return user
+	}
+	closeLong := func(t *testing.T, userID uint, amount float64) {
+		order := &model.Order{
+			UserID:      userID,
+			Symbol:      symbol,
+			Side:        "sell",
+			Type:        "market",
+			TimeInForce: TimeInForceIOC,
+			Amount:      decimal.NewFromFloat(amount),
+			Leverage:    10,
+			ReduceOnly:  true,
+			Status:      "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+		require.NoError(t, engine.MatchOrder(order.ID))
+	}
+	stateOf := func(t *testing.T, userID uint) (model.Position, model.Balance) {
+		var pos model.Position
+		require.NoError(t, db.Where("user_id = ? AND symbol = ?", userID, symbol).First(&pos).Error)
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, "USDT").First(&balance).Error)
+		return pos, balance
+	}
+	fundBalance := func(t *testing.T) string {
+		var fund model.InsuranceFund
+		require.NoError(t, db.Where("asset = ?", "USDT").First(&fund).Error)
+		return fund.Balance.String()
+	}
+
+	t.Run("Partial close takes the excess loss from the remaining margin", func(t *testing.T) {
+		user := openIsolated(t)
+
+		// When: 按 bid 44490 平仓 0.5，亏损 2755 超过释放的保证金 2500
+		closeLong(t, user.ID, 0.5)
+
+		// Then: 超出的 255 从剩余仓位保证金中扣除，可用余额只扣手续费 22.245
+		pos, balance := stateOf(t, user.ID)
+		assert.Equal(t, "0.5", pos.Size.String())
+		assert.Equal(t, "2245", pos.Margin.String())
+		assert.Equal(t, "2245", balance.Locked.String())
+		assert.Equal(t, "977.755", balance.Available.String())
+	})
+
+	t.Run("Loss beyond the position margin never touches other available funds", func(t *testing.T) {
+		user := openIsolated(t)
+
+		// When: 按 bid 44490 平掉全部 1 BTC，亏损 5510 超过仓位保证金 5000
+		closeLong(t, user.ID, 1)
+
+		// Then: 超出的 510 由保险基金承担，可用余额只扣手续费 44.49
+		pos, balance := stateOf(t, user.ID)
+		assert.True(t, pos.Size.IsZero())
+		assert.True(t, pos.Margin.IsZero())
+		assert.Equal(t, "-5000", pos.RealizedPnl.String())
+		assert.True(t, balance.Locked.IsZero())
+		assert.Equal(t, "955.51", balance.Available.String())
+		assert.Equal(t, "490", fundBalance(t))
+	})
+}
+
+func TestUnrealizedPnl(t *testing.T) {
+	long := &model.Position{Side: PositionLong, Size: decimal.NewFromFloat(0.5), EntryPrice: decimal.NewFromInt(40000)}
+	assert.Equal(t, "500", UnrealizedPnl(long, decimal.NewFromInt(41000)).String())
+
+	short := &model.Position{Side: PositionShort, Size: decimal.NewFromFloat(0.5), EntryPrice: decimal.NewFromInt(40000)}
+	assert.Equal(t, "-500", UnrealizedPnl(short, decimal.NewFromInt(41000)).String())
+}
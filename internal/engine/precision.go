@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"github.com/shopspring/decimal"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// AssetScale 资金精度：余额、冻结、成交金额和手续费统一保留 8 位小数（与数据库 decimal(20,8) 一致）
+const AssetScale int32 = 8
+
+// CeilAsset 用户应付/需冻结的金额向上取整到资金精度
+func CeilAsset(d decimal.Decimal) decimal.Decimal {
+	return d.RoundCeil(AssetScale)
+}
+
+// FloorAsset 用户应收的金额向下截断到资金精度
+func FloorAsset(d decimal.Decimal) decimal.Decimal {
+	return d.RoundFloor(AssetScale)
+}
+
+// ReservationPrice 买单冻结资金所按的价格：ReservePrice > Price > StopPrice，都没有时返回 nil
+func ReservationPrice(order *model.Order) *decimal.Decimal {
+	switch {
+	case order.ReservePrice != nil:
+		return order.ReservePrice
+	case order.Price != nil:
+		return order.Price
+	default:
+		return order.StopPrice
+	}
+}
+
+// ReservedFor 订单成交 qty 数量所对应的冻结数量
+// 卖单冻结基础币（即数量本身），买单冻结 qty*冻结价格（向上取整）；永续合约订单两个方向都冻结保证金
+// 部分成交时按 ReservedFor(成交后) - ReservedFor(成交前) 扣减，累计结果与整单冻结完全一致
+func ReservedFor(order *model.Order, qty decimal.Decimal) decimal.Decimal {
+	if order.Side == "sell" && !IsPerpetual(order) {
+		return qty
+	}
+	price := ReservationPrice(order)
+	if price == nil {
+		return decimal.Zero
+	}
+	if IsPerpetual(order) {
+		return InitialMargin(qty, *price, order.Leverage)
+	}
+	return CeilAsset(qty.Mul(*price))
+}
+
+// RemainingReservation 订单未成交部分仍冻结的数量（按金额下单的市价买单为未花完的预算）
+func RemainingReservation(order *model.Order) decimal.Decimal {
+	if order.QuoteOrderQty != nil {
+		return order.QuoteOrderQty.Sub(order.QuoteFilled)
+	}
+	return ReservedFor(order, order.Amount).Sub(ReservedFor(order, order.Filled))
+}
+
+// fillReservation 本次成交应从冻结中扣减的数量
+// 按金额下单的市价买单冻结的就是预算，按成交金额扣减；其余订单按 ReservedFor 差额扣减
+func fillReservation(order *model.Order, trade *model.Trade) decimal.Decimal {
+	if order.QuoteOrderQty != nil {
+		return trade.QuoteAmount
+	}
+	return ReservedFor(order, order.Filled.Add(trade.Amount)).Sub(ReservedFor(order, order.Filled))
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+func TestRounding(t *testing.T) {
+	t.Run("Payables round up and receivables round down", func(t *testing.T) {
+		d := decimal.RequireFromString("0.000000011")
+		assert.Equal(t, "0.00000002", CeilAsset(d).String())
+		assert.Equal(t, "0.00000001", FloorAsset(d).String())
+	})
+}
+
+func TestReservedFor(t *testing.T) {
+	t.Run("Partial fills consume exactly the full reservation", func(t *testing.T) {
+		// Given: 限价买单 1 BTC @ 33333.33333333（整单冻结需要向上取整）
+		price := decimal.RequireFromString("33333.33333333")
+		order := &model.Order{Side: "buy", Type: "limit", Price: &price, Amount: decimal.NewFromInt(1)}
+		total := ReservedFor(order, order.Amount)
+
+		// When: 分三次成交
+		consumed := decimal.Zero
+		for _, qty := range []string{"0.33333333", "0.33333333", "0.33333334"} {
+			next := order.Filled.Add(decimal.RequireFromString(qty))
+			consumed = consumed.Add(ReservedFor(order, next).Sub(ReservedFor(order, order.Filled)))
+			order.Filled = next
+		}
+
+		// Then: 累计扣减与整单冻结完全一致，剩余冻结为 0
+		assert.True(t, consumed.Equal(total))
+		assert.True(t, RemainingReservation(order).IsZero())
+	})
+
+	t.Run("Reservation price priority", func(t *testing.T) {
+		reserve := decimal.NewFromInt(51000)
+		stop := decimal.NewFromInt(48000)
+		order := &model.Order{Side: "buy", StopPrice: &stop, Amount: decimal.NewFromInt(1)}
+		assert.Equal(t, "48000", ReservedFor(order, order.Amount).String())
+
+		order.ReservePrice = &reserve
+		assert.Equal(t, "51000", ReservedFor(order, order.Amount).String())
+
+		order.Side = "sell"
+		assert.Equal(t, "1", ReservedFor(order, order.Amount).String())
+	})
+}
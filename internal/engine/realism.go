@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 执行模拟产生的拒单错误
+var (
+	ErrSimulatedReject = errors.New("order rejected by the exchange, please retry")
+	ErrSymbolOutage    = errors.New("symbol is temporarily unavailable")
+)
+
+// ExecutionModel 撮合前的执行模型：决定下单确认和撮合前的延迟、拒单和故障
+type ExecutionModel interface {
+	// Acknowledge 下单确认（下单请求返回前调用），返回错误时拒绝订单
+	Acknowledge(symbol string) error
+	// BeforeMatch 撮合前调用，返回 false 时跳过本次撮合（订单保留，等待下次撮合）
+	BeforeMatch(order *model.Order) bool
+}
+
+// executionModels 每份配置对应的执行模型，同一配置的所有撮合引擎共享随机数和故障状态
+var executionModels sync.Map // *config.Config -> ExecutionModel
+
+// RegisterExecutionModel 为配置注册自定义执行模型，取代 trading.realism 的模拟器
+func RegisterExecutionModel(cfg *config.Config, em ExecutionModel) {
+	executionModels.Store(cfg, em)
+}
+
+// executionModelFor 返回配置对应的执行模型，未注册且未启用 trading.realism 时返回 nil
+func executionModelFor(cfg *config.Config) ExecutionModel {
+	if em, ok := executionModels.Load(cfg); ok {
+		return em.(ExecutionModel)
+	}
+	if !cfg.Trading.Realism.Enabled {
+		return nil
+	}
+	em, _ := executionModels.LoadOrStore(cfg, NewSimulator(&cfg.Trading.Realism))
+	return em.(ExecutionModel)
+}
+
+// Simulator 按 trading.realism 配置模拟延迟、随机拒单和交易对故障
+// 随机数由配置的种子初始化，相同种子和相同的调用顺序得到相同的结果
+type Simulator struct {
+	cfg     *config.RealismConfig
+	mu      sync.Mutex
+	rng     *rand.Rand
+	outages map[string]time.Time // 交易对故障结束时间
+}
+
+// NewSimulator 创建执行模拟器
+func NewSimulator(cfg *config.RealismConfig) *Simulator {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Simulator{
+		cfg:     cfg,
+		rng:     rand.New(rand.NewSource(seed)),
+		outages: make(map[string]time.Time),
+	}
+}
+
+// Acknowledge 等待下单确认延迟，故障中的交易对和随机命中的订单被拒绝
+func (s *Simulator) Acknowledge(symbol string) error {
+	sc := s.cfg.ForSymbol(symbol)
+
+	s.mu.Lock()
+	delay := s.sampleLatency(sc.AckLatency)
+	rejected := sc.RejectRate > 0 && s.rng.Float64() < sc.RejectRate
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+
+	if s.inOutage(symbol) {
+		return ErrSymbolOutage
+	}
+	if rejected {
+		return ErrSimulatedReject
+	}
+	return nil
+}
+
+// BeforeMatch 按故障概率使交易对进入故障；订单首次撮合前等待撮合延迟（从下单时间算起）
+func (s *Simulator) BeforeMatch(order *model.Order) bool {
+	sc := s.cfg.ForSymbol(order.Symbol)
+
+	s.mu.Lock()
+	if until, ok := s.outages[order.Symbol]; ok && time.Now().Before(until) {
+		s.mu.Unlock()
+		return false
+	}
+	if sc.OutageRate > 0 && s.rng.Float64() < sc.OutageRate {
+		duration, err := time.ParseDuration(sc.OutageDuration)
+		if err != nil {
+			duration = 30 * time.Second
+		}
+		s.outages[order.Symbol] = time.Now().Add(duration)
+		s.mu.Unlock()
+		return false
+	}
+	var delay time.Duration
+	if order.RestedAt == nil && order.Filled.IsZero() {
+		delay = s.sampleLatency(sc.FillLatency) - time.Since(order.CreatedAt)
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return true
+}
+
+// inOutage 交易对当前是否处于故障中
+func (s *Simulator) inOutage(symbol string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.outages[symbol]
+	return ok && time.Now().Before(until)
+}
+
+// sampleLatency 按延迟分布采样（调用方持有锁）
+func (s *Simulator) sampleLatency(lc config.LatencyConfig) time.Duration {
+	var ms float64
+	switch lc.Distribution {
+	case "uniform":
+		ms = lc.Min
+		if lc.Max > lc.Min {
+			ms += s.rng.Float64() * (lc.Max - lc.Min)
+		}
+	case "normal":
+		ms = lc.Mean + s.rng.NormFloat64()*lc.StdDev
+	case "exponential":
+		ms = s.rng.ExpFloat64() * lc.Mean
+	default:
+		ms = lc.Mean
+	}
+
+	if ms < lc.Min {
+		ms = lc.Min
+	}
+	if lc.Max > 0 && ms > lc.Max {
+		ms = lc.Max
+	}
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
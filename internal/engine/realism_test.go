@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+func TestSimulator(t *testing.T) {
+	rejections := func(seed int64) []bool {
+		sim := NewSimulator(&config.RealismConfig{Enabled: true, Seed: seed, RejectRate: 0.5})
+		result := make([]bool, 32)
+		for i := range result {
+			result[i] = sim.Acknowledge("BTC/USDT") != nil
+		}
+		return result
+	}
+
+	t.Run("Same seed reproduces the same rejections", func(t *testing.T) {
+		first := rejections(7)
+		assert.Equal(t, first, rejections(7))
+		assert.NotEqual(t, first, rejections(8))
+		assert.Contains(t, first, true)
+		assert.Contains(t, first, false)
+	})
+
+	t.Run("Latency distributions respect bounds", func(t *testing.T) {
+		sim := NewSimulator(&config.RealismConfig{Seed: 1})
+		for _, lc := range []config.LatencyConfig{
+			{Distribution: "uniform", Min: 5, Max: 10},
+			{Distribution: "normal", Mean: 8, StdDev: 20, Min: 5, Max: 10},
+			{Distribution: "exponential", Mean: 7, Min: 5, Max: 10},
+		} {
+			for i := 0; i < 100; i++ {
+				d := sim.sampleLatency(lc)
+				assert.GreaterOrEqual(t, d, 5*time.Millisecond)
+				assert.LessOrEqual(t, d, 10*time.Millisecond)
+			}
+		}
+		assert.Equal(t, 3*time.Millisecond, sim.sampleLatency(config.LatencyConfig{Mean: 3}))
+	})
+
+	t.Run("Acknowledge waits for the ack latency", func(t *testing.T) {
+		sim := NewSimulator(&config.RealismConfig{Seed: 1, AckLatency: config.LatencyConfig{Mean: 30}})
+		start := time.Now()
+		require.NoError(t, sim.Acknowledge("BTC/USDT"))
+		assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	})
+}
+
+func TestMatchOrder_Realism(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	logger := testutil.NewTestLogger()
+
+	bidPrice, askPrice := 49990.0, 50010.0
+	require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+
+	newOrder := func(t *testing.T) *model.Order {
+		user := testutil.SeedUser(t, db)
+		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0, 5001.0)
+		order := &model.Order{
+			UserID: user.ID,
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(50010),
+			Status: "new",
+		}
+		require.NoError(t, db.Create(order).Error)
+		return order
+	}
+
+	t.Run("Symbol outage pauses matching and rejects new orders", func(t *testing.T) {
+		// Given: BTC/USDT 每次撮合都会进入故障
+		cfg := testutil.LoadTestConfig(t)
+		cfg.Trading.Realism = config.RealismConfig{
+			Enabled: true,
+			Seed:    1,
+			Symbols: []config.SymbolRealismConfig{{Symbol: "BTC/USDT", OutageRate: 1, OutageDuration: "1m"}},
+		}
+		engine := NewMatchingEngine(db, cfg, logger)
+		order := newOrder(t)
+
+		// When: 撮合可立即成交的订单
+		require.NoError(t, engine.MatchOrder(order.ID))
+
+		// Then: 订单保留未成交，BTC/USDT 的新订单被拒绝，其他交易对不受影响
+		var reloaded model.Order
+		require.NoError(t, db.First(&reloaded, order.ID).Error)
+		assert.Equal(t, "new", reloaded.Status)
+		assert.ErrorIs(t, engine.Acknowledge("BTC/USDT"), ErrSymbolOutage)
+		assert.NoError(t, NewMatchingEngine(db, cfg, logger).Acknowledge("ETH/USDT"))
+	})
+
+	t.Run("Fill latency delays the first match", func(t *testing.T) {
+		cfg := testutil.LoadTestConfig(t)
+		cfg.Trading.Realism = config.RealismConfig{
+			Enabled:     true,
+			Seed:        1,
+			FillLatency: config.LatencyConfig{Mean: 50},
+		}
+		order := newOrder(t)
+
+		start := time.Now()
+		require.NoError(t, NewMatchingEngine(db, cfg, logger).MatchOrder(order.ID))
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+		var reloaded model.Order
+		require.NoError(t, db.First(&reloaded, order.ID).Error)
+		assert.Equal(t, "filled", reloaded.Status)
+	})
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 自成交防护模式（trading.matching.self_trade_prevention）
+const (
+	STPNone         = "none"          // 允许自成交（默认）
+	STPCancelNewest = "cancel_newest" // 撤销后进入订单簿的订单（taker）
+	STPCancelOldest = "cancel_oldest" // 撤销先挂出的订单（maker）
+	STPCancelBoth   = "cancel_both"   // 双方都撤销
+	STPDecrement    = "decrement"     // 双方减少重叠数量，剩余为零的订单撤销
+)
+
+// CancelReasonSelfTrade 因自成交防护撤销
+const CancelReasonSelfTrade = "self_trade_prevention"
+
+// selfTradeMode 生效的自成交防护模式，未配置或无效时为 none
+func (m *MatchingEngine) selfTradeMode() string {
+	switch mode := m.cfg.Trading.Matching.SelfTradePrevention; mode {
+	case STPCancelNewest, STPCancelOldest, STPCancelBoth, STPDecrement:
+		return mode
+	default:
+		return STPNone
+	}
+}
+
+// isSelfTrade 两笔订单属于同一用户且启用了自成交防护
+func (m *MatchingEngine) isSelfTrade(a, b *model.Order) bool {
+	return a.UserID == b.UserID && m.selfTradeMode() != STPNone
+}
+
+// preventSelfTrade 按自成交防护模式处理同一用户相互交叉的 maker/taker 订单（订单均已锁定）
+func (m *MatchingEngine) preventSelfTrade(tx *gorm.DB, maker, taker *model.Order) error {
+	mode := m.selfTradeMode()
+	var err error
+	switch mode {
+	case STPCancelNewest:
+		err = m.closeRemaining(tx, taker, "cancelled", CancelReasonSelfTrade)
+	case STPCancelOldest:
+		err = m.closeRemaining(tx, maker, "cancelled", CancelReasonSelfTrade)
+	case STPCancelBoth:
+		if err = m.closeRemaining(tx, maker, "cancelled", CancelReasonSelfTrade); err == nil {
+			err = m.closeRemaining(tx, taker, "cancelled", CancelReasonSelfTrade)
+		}
+	case STPDecrement:
+		overlap := decimal.Min(remainingAmount(maker), remainingAmount(taker))
+		if err = m.decrementOrder(tx, maker, overlap); err == nil {
+			err = m.decrementOrder(tx, taker, overlap)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info("Self-trade prevented",
+		zap.String("mode", mode),
+		zap.Uint("user_id", taker.UserID),
+		zap.Uint("maker_order_id", maker.ID),
+		zap.Uint("taker_order_id", taker.ID),
+	)
+	return nil
+}
+
+// decrementOrder 减少订单数量并解冻对应资金，剩余数量减为零时撤销订单
+func (m *MatchingEngine) decrementOrder(tx *gorm.DB, order *model.Order, amount decimal.Decimal) error {
+	if !remainingAmount(order).GreaterThan(amount) {
+		return m.closeRemaining(tx, order, "cancelled", CancelReasonSelfTrade)
+	}
+
+	reservedBefore := RemainingReservation(order)
+	order.Amount = order.Amount.Sub(amount)
+	if released := reservedBefore.Sub(RemainingReservation(order)); released.IsPositive() {
+		if err := m.unlockBalance(tx, order.UserID, m.reservationAsset(order), released); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Save(order).Error; err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 订单有效方式
+const (
+	TimeInForceGTC = "GTC" // 一直有效直到成交或撤销
+	TimeInForceIOC = "IOC" // 立即成交，未成交部分撤销
+	TimeInForceFOK = "FOK" // 全部立即成交，否则拒绝
+)
+
+// 撤销/拒绝原因
+const (
+	CancelReasonIOCRemainder  = "ioc_remainder"
+	CancelReasonFOKUnfillable = "fok_unfillable"
+)
+
+// CheckPostOnly 检查只做 maker 的订单是否会立即成交（下单时调用）
+// 会与外部行情或内部挂单交叉时返回错误
+func (m *MatchingEngine) CheckPostOnly(order *model.Order) error {
+	if order.Price == nil {
+		return fmt.Errorf("post-only order must have price")
+	}
+	price := *order.Price
+
+	var ticker model.Ticker
+	if err := m.db.Where("symbol = ?", order.Symbol).First(&ticker).Error; err == nil {
+		if order.Side == "buy" && ticker.AskPrice != nil && price.GreaterThanOrEqual(m.marketPrice(order.Symbol, *ticker.AskPrice)) {
+			return fmt.Errorf("post-only order would immediately match at ask price %.8f", *ticker.AskPrice)
+		}
+		if order.Side == "sell" && ticker.BidPrice != nil && price.LessThanOrEqual(m.marketPrice(order.Symbol, *ticker.BidPrice)) {
+			return fmt.Errorf("post-only order would immediately match at bid price %.8f", *ticker.BidPrice)
+		}
+	}
+
+	if m.cfg.Trading.Matching.ModeForSymbol(order.Symbol) == "internal" {
+		counterparties, err := m.findCounterparties(m.db, order, nil)
+		if err != nil {
+			return err
+		}
+		if len(counterparties) > 0 {
+			return fmt.Errorf("post-only order would immediately match resting order at %s", counterparties[0].Price)
+		}
+	}
+
+	return nil
+}
+
+// immediatelyFillable 估算订单当前可立即成交的数量（内部挂单 + 外部流动性），用于 FOK 检查
+func (m *MatchingEngine) immediatelyFillable(order *model.Order) (decimal.Decimal, error) {
+	total := decimal.Zero
+
+	if m.cfg.Trading.Matching.ModeForSymbol(order.Symbol) == "internal" {
+		counterparties, err := m.findCounterparties(m.db, order, nil)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		for i := range counterparties {
+			// 自成交防护下自己的挂单不会成交
+			if m.isSelfTrade(order, &counterparties[i]) {
+				continue
+			}
+			total = total.Add(remainingAmount(&counterparties[i]))
+		}
+	}
+
+	var ticker model.Ticker
+	if err := m.db.Where("symbol = ?", order.Symbol).First(&ticker).Error; err != nil {
+		return total, nil
+	}
+
+	var limitPrice *decimal.Decimal
+	if order.Type == "limit" {
+		limitPrice = order.Price
+	}
+
+	if m.cfg.Trading.OrderBook.Enabled {
+		bookCfg := m.cfg.Trading.OrderBook.ForSymbol(order.Symbol)
+		return total.Add(m.books.available(&ticker, bookCfg, m.markets.ForSymbol(order.Symbol), order.Side, limitPrice)), nil
+	}
+
+	// 外部行情是否可成交
+	if order.Side == "buy" {
+		if ticker.AskPrice == nil || !priceWithinLimit(order.Side, m.marketPrice(order.Symbol, *ticker.AskPrice), limitPrice) {
+			return total, nil
+		}
+	} else {
+		if ticker.BidPrice == nil || !priceWithinLimit(order.Side, m.marketPrice(order.Symbol, *ticker.BidPrice), limitPrice) {
+			return total, nil
+		}
+	}
+
+	return total.Add(m.fillableAmount(order, &ticker)), nil
+}
+
+// cancelRemaining 撤销订单未成交部分并解冻对应资金
+func (m *MatchingEngine) cancelRemaining(order *model.Order, status, reason string) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(order, order.ID).Error; err != nil {
+			return fmt.Errorf("failed to lock order: %w", err)
+		}
+
+		if !isMatchableStatus(order.Status) {
+			return nil
+		}
+
+		return m.closeRemaining(tx, order, status, reason)
+	})
+}
+
+// closeRemaining 在事务中将已锁定的订单置为终态，解冻未成交部分对应的资金
+func (m *MatchingEngine) closeRemaining(tx *gorm.DB, order *model.Order, status, reason string) error {
+	if err := m.releaseRemainingLock(tx, order); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	order.Status = status
+	order.CancelReason = reason
+	order.CanceledAt = &now
+	if err := tx.Save(order).Error; err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if err := m.finalizeBracketEntry(tx, order); err != nil {
+		return err
+	}
+
+	m.logger.Info("Order remainder cancelled",
+		zap.Uint("order_id", order.ID),
+		zap.String("status", status),
+		zap.String("reason", reason),
+		zap.Stringer("filled", order.Filled),
+	)
+
+	return nil
+}
+
+// releaseRemainingLock 将订单未成交部分对应的冻结资金转回可用余额
+func (m *MatchingEngine) releaseRemainingLock(tx *gorm.DB, order *model.Order) error {
+	amount := RemainingReservation(order)
+	if !amount.IsPositive() {
+		return nil
+	}
+	return m.unlockBalance(tx, order.UserID, m.reservationAsset(order), amount)
+}
@@ -0,0 +1,93 @@
+package fee
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// VolumeWindow 计算手续费等级的滚动成交额窗口
+const VolumeWindow = 30 * 24 * time.Hour
+
+// Rates 用户当前生效的手续费率
+type Rates struct {
+	Maker    float64
+	Taker    float64
+	Volume   decimal.Decimal // 最近 30 天成交额（计价币）
+	Tier     int             // 命中的成交额等级（trading.fee_tiers 中的序号，从 1 开始），0 为基础费率
+	Override bool            // 是否为管理员设置的用户费率
+}
+
+// Schedule 手续费表：基础费率、按 30 天成交额划分的等级和管理员设置的用户费率（优先级最高）
+type Schedule struct {
+	db  *gorm.DB
+	cfg *config.TradingConfig
+}
+
+// NewSchedule 创建手续费表
+func NewSchedule(db *gorm.DB, cfg *config.Config) *Schedule {
+	return &Schedule{db: db, cfg: &cfg.Trading}
+}
+
+// Rates 查询用户当前生效的手续费率
+func (s *Schedule) Rates(userID uint) (*Rates, error) {
+	volume, err := s.Volume(userID)
+	if err != nil {
+		return nil, err
+	}
+	rates := s.TierRates(volume)
+
+	var override model.UserFeeRate
+	result := s.db.Where("user_id = ?", userID).Limit(1).Find(&override)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query user fee rate: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		rates.Maker = override.MakerFeeRate.InexactFloat64()
+		rates.Taker = override.TakerFeeRate.InexactFloat64()
+		rates.Override = true
+	}
+
+	return rates, nil
+}
+
+// Volume 用户最近 30 天的成交额（计价币）
+func (s *Schedule) Volume(userID uint) (decimal.Decimal, error) {
+	var volume decimal.Decimal
+	err := s.db.Model(&model.Trade{}).
+		Select("COALESCE(SUM(quote_amount), 0)").
+		Where("user_id = ? AND created_at >= ?", userID, time.Now().Add(-VolumeWindow)).
+		Row().Scan(&volume)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to query trading volume: %w", err)
+	}
+	return volume, nil
+}
+
+// TierRates 按成交额查找等级费率：取成交额门槛不超过 volume 的最高等级，未达到任何等级时使用基础费率
+func (s *Schedule) TierRates(volume decimal.Decimal) *Rates {
+	rates := &Rates{
+		Maker:  s.cfg.MakerFeeRate,
+		Taker:  s.cfg.TakerFeeRate,
+		Volume: volume,
+	}
+
+	threshold := decimal.Zero
+	for i, tier := range s.cfg.FeeTiers {
+		minVolume := decimal.NewFromFloat(tier.MinVolume)
+		if volume.LessThan(minVolume) || (rates.Tier > 0 && minVolume.LessThan(threshold)) {
+			continue
+		}
+		threshold = minVolume
+		rates.Maker = tier.MakerFeeRate
+		rates.Taker = tier.TakerFeeRate
+		rates.Tier = i + 1
+	}
+
+	return rates
+}
@@ -0,0 +1,113 @@
+package fee
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+func TestTierRates(t *testing.T) {
+	cfg := &config.Config{Trading: config.TradingConfig{
+		MakerFeeRate: 0.0005,
+		TakerFeeRate: 0.001,
+		// 等级不要求按顺序配置
+		FeeTiers: []config.FeeTierConfig{
+			{MinVolume: 10000000, MakerFeeRate: 0.0002, TakerFeeRate: 0.0006},
+			{MinVolume: 1000000, MakerFeeRate: 0.0004, TakerFeeRate: 0.0008},
+		},
+	}}
+	schedule := NewSchedule(nil, cfg)
+
+	tests := []struct {
+		name   string
+		volume float64
+		tier   int
+		maker  float64
+		taker  float64
+	}{
+		{"Below all tiers uses base rates", 999999, 0, 0.0005, 0.001},
+		{"Exactly at tier threshold", 1000000, 2, 0.0004, 0.0008},
+		{"Highest tier reached", 25000000, 1, 0.0002, 0.0006},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates := schedule.TierRates(decimal.NewFromFloat(tt.volume))
+			assert.Equal(t, tt.tier, rates.Tier)
+			assert.Equal(t, tt.maker, rates.Maker)
+			assert.Equal(t, tt.taker, rates.Taker)
+		})
+	}
+}
+
+func TestScheduleRates(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	cfg.Trading.FeeTiers = []config.FeeTierConfig{
+		{MinVolume: 100000, MakerFeeRate: 0.0003, TakerFeeRate: 0.0007},
+	}
+	schedule := NewSchedule(db, cfg)
+	user := testutil.CreateTestUser(t, db)
+
+	trade := func(quote float64, at time.Time) {
+		require.NoError(t, db.Create(&model.Trade{
+			OrderID:     1,
+			UserID:      user.ID,
+			Symbol:      "BTC/USDT",
+			Side:        "buy",
+			Price:       decimal.NewFromInt(50000),
+			Amount:      decimal.NewFromFloat(quote / 50000),
+			QuoteAmount: decimal.NewFromFloat(quote),
+			CreatedAt:   at,
+		}).Error)
+	}
+
+	t.Run("No trades uses base rates", func(t *testing.T) {
+		rates, err := schedule.Rates(user.ID)
+		require.NoError(t, err)
+		assert.True(t, rates.Volume.IsZero())
+		assert.Equal(t, 0, rates.Tier)
+		assert.Equal(t, cfg.Trading.TakerFeeRate, rates.Taker)
+	})
+
+	t.Run("Only trades within 30 days count", func(t *testing.T) {
+		// Given: 30 天内 6 万、31 天前 5 万
+		trade(60000, time.Now().Add(-24*time.Hour))
+		trade(50000, time.Now().Add(-31*24*time.Hour))
+
+		rates, err := schedule.Rates(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "60000", rates.Volume.String())
+		assert.Equal(t, 0, rates.Tier)
+
+		// When: 再成交 4 万，达到等级门槛
+		trade(40000, time.Now())
+		rates, err = schedule.Rates(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, rates.Tier)
+		assert.Equal(t, 0.0003, rates.Maker)
+		assert.Equal(t, 0.0007, rates.Taker)
+	})
+
+	t.Run("User override takes precedence", func(t *testing.T) {
+		require.NoError(t, db.Create(&model.UserFeeRate{
+			UserID:       user.ID,
+			MakerFeeRate: decimal.Zero,
+			TakerFeeRate: decimal.NewFromFloat(0.0002),
+		}).Error)
+
+		rates, err := schedule.Rates(user.ID)
+		require.NoError(t, err)
+		assert.True(t, rates.Override)
+		assert.Equal(t, 0.0, rates.Maker)
+		assert.Equal(t, 0.0002, rates.Taker)
+		assert.Equal(t, "100000", rates.Volume.String())
+	})
+}
@@ -0,0 +1,326 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 交易对状态
+const (
+	StatusActive   = "active"   // 正常交易
+	StatusHalted   = "halted"   // 暂停交易，只允许撤单（行情继续更新，挂单不撮合）
+	StatusInactive = "inactive" // 已停用（拒绝新订单，不再更新行情）
+)
+
+// DefaultStep 未配置时的数量步长和价格最小变动单位（与数据库 decimal(20,8) 一致）
+var DefaultStep = decimal.New(1, -8)
+
+// Market 交易对定义：基础币/计价币、步长、限额和状态
+// 永续合约使用 CCXT 统一符号 BASE/QUOTE:SETTLE（如 BTC/USDT:USDT），以结算币计算保证金和盈亏
+type Market struct {
+	Symbol      string
+	Base        string
+	Quote       string
+	Settle      string          // 结算币，非空时为永续合约
+	AmountStep  decimal.Decimal // 数量步长
+	PriceTick   decimal.Decimal // 价格最小变动单位
+	MinAmount   decimal.Decimal // 最小数量
+	MaxAmount   decimal.Decimal // 最大数量，0 表示不限
+	MinNotional decimal.Decimal // 最小名义价值（数量 × 价格，计价币），0 表示不限
+	MaxLeverage int             // 永续合约最大杠杆，0 表示使用 trading.perpetual.max_leverage
+	Status      string
+
+	MaintenanceMarginRate decimal.Decimal // 永续合约维持保证金率，0 表示使用 trading.perpetual.maintenance_margin_rate
+}
+
+// IsSwap 是否为永续合约
+func (m *Market) IsSwap() bool {
+	return m.Settle != ""
+}
+
+// Type 交易对类型（CCXT market type）：spot | swap
+func (m *Market) Type() string {
+	if m.IsSwap() {
+		return "swap"
+	}
+	return "spot"
+}
+
+// Active 交易对是否接受新订单并撮合
+func (m *Market) Active() bool {
+	return m.Status == StatusActive
+}
+
+// Enabled 交易对是否未停用（停用的交易对不再更新行情和 K 线）
+func (m *Market) Enabled() bool {
+	return m.Status != StatusInactive
+}
+
+// AmountPrecision 数量精度（步长的小数位数）
+func (m *Market) AmountPrecision() int32 {
+	return decimalPlaces(m.AmountStep)
+}
+
+// PricePrecision 价格精度（价格最小变动单位的小数位数）
+func (m *Market) PricePrecision() int32 {
+	return decimalPlaces(m.PriceTick)
+}
+
+// RoundAmount 数量按步长向下截断
+func (m *Market) RoundAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.Div(m.AmountStep).Floor().Mul(m.AmountStep)
+}
+
+// RoundPrice 限价按价格单位向保守方向取整（买单向下、卖单向上）
+func (m *Market) RoundPrice(side string, price decimal.Decimal) decimal.Decimal {
+	if side == "sell" {
+		return m.CeilPrice(price)
+	}
+	return price.Div(m.PriceTick).Floor().Mul(m.PriceTick)
+}
+
+// CeilPrice 价格按价格单位向上取整
+func (m *Market) CeilPrice(price decimal.Decimal) decimal.Decimal {
+	return price.Div(m.PriceTick).Ceil().Mul(m.PriceTick)
+}
+
+// RoundTick 价格按价格单位四舍五入（用于行情价格和触发价）
+func (m *Market) RoundTick(price decimal.Decimal) decimal.Decimal {
+	return price.Div(m.PriceTick).Round(0).Mul(m.PriceTick)
+}
+
+// Registry 交易对注册表
+// 配置中的交易对作为初始定义，markets 表中的记录覆盖同名配置，管理员新增的交易对只存在于表中
+type Registry struct {
+	db       *gorm.DB
+	markets  map[string]*Market
+	symbols  []string
+	defaults Market
+}
+
+// NewRegistry 创建交易对注册表，db 为 nil 时只使用配置
+// market.symbols 中的交易对使用默认规则，market.definitions 中的配置覆盖对应字段
+func NewRegistry(db *gorm.DB, cfg *config.Config) *Registry {
+	r := &Registry{
+		db:      db,
+		markets: make(map[string]*Market),
+		defaults: Market{
+			AmountStep: DefaultStep,
+			PriceTick:  DefaultStep,
+			MinAmount:  decimal.NewFromFloat(cfg.Trading.MinOrderAmount),
+			Status:     StatusActive,
+		},
+	}
+
+	for _, symbol := range cfg.Market.Symbols {
+		r.add(r.newMarket(symbol))
+	}
+
+	for _, def := range cfg.Market.Definitions {
+		m, ok := r.markets[def.Symbol]
+		if !ok {
+			m = r.newMarket(def.Symbol)
+			r.add(m)
+		}
+		applyDefinition(m, def)
+	}
+
+	return r
+}
+
+// Get 查询交易对定义（数据库记录优先于配置）
+func (r *Registry) Get(symbol string) (*Market, bool, error) {
+	if r.db != nil {
+		var row model.Market
+		result := r.db.Where("symbol = ?", symbol).Limit(1).Find(&row)
+		if result.Error != nil {
+			return nil, false, fmt.Errorf("failed to query market: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return FromModel(&row), true, nil
+		}
+	}
+
+	m, ok := r.markets[symbol]
+	if !ok {
+		return nil, false, nil
+	}
+	configured := *m
+	return &configured, true, nil
+}
+
+// ForSymbol 查询交易对规则，未注册或查询失败时返回默认规则（用于撮合时的取整）
+func (r *Registry) ForSymbol(symbol string) *Market {
+	if m, ok, err := r.Get(symbol); err == nil && ok {
+		return m
+	}
+	return r.newMarket(symbol)
+}
+
+// List 返回全部交易对：先按配置顺序，再按创建顺序列出表中新增的交易对
+func (r *Registry) List() ([]*Market, error) {
+	var rows []model.Market
+	if r.db != nil {
+		if err := r.db.Order("id ASC").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to list markets: %w", err)
+		}
+	}
+
+	stored := make(map[string]*Market, len(rows))
+	for i := range rows {
+		stored[rows[i].Symbol] = FromModel(&rows[i])
+	}
+
+	markets := make([]*Market, 0, len(r.symbols)+len(rows))
+	for _, symbol := range r.symbols {
+		if m, ok := stored[symbol]; ok {
+			markets = append(markets, m)
+			continue
+		}
+		configured := *r.markets[symbol]
+		markets = append(markets, &configured)
+	}
+	for i := range rows {
+		if _, ok := r.markets[rows[i].Symbol]; !ok {
+			markets = append(markets, stored[rows[i].Symbol])
+		}
+	}
+	return markets, nil
+}
+
+// Enabled 返回未停用的交易对（行情和 K 线更新范围）
+func (r *Registry) Enabled() ([]*Market, error) {
+	markets, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make([]*Market, 0, len(markets))
+	for _, m := range markets {
+		if m.Enabled() {
+			enabled = append(enabled, m)
+		}
+	}
+	return enabled, nil
+}
+
+// Default 返回未配置交易对的默认规则
+func (r *Registry) Default(symbol string) *Market {
+	return r.newMarket(symbol)
+}
+
+// FromModel 将数据库记录转换为交易对定义
+func FromModel(row *model.Market) *Market {
+	return &Market{
+		Symbol:      row.Symbol,
+		Base:        row.Base,
+		Quote:       row.Quote,
+		Settle:      row.Settle,
+		AmountStep:  row.AmountStep,
+		PriceTick:   row.PriceTick,
+		MinAmount:   row.MinAmount,
+		MaxAmount:   row.MaxAmount,
+		MinNotional: row.MinNotional,
+		MaxLeverage: row.MaxLeverage,
+		Status:      row.Status,
+
+		MaintenanceMarginRate: row.MaintenanceMarginRate,
+	}
+}
+
+// ToModel 将交易对定义转换为数据库记录
+func (m *Market) ToModel() *model.Market {
+	return &model.Market{
+		Symbol:      m.Symbol,
+		Base:        m.Base,
+		Quote:       m.Quote,
+		Settle:      m.Settle,
+		AmountStep:  m.AmountStep,
+		PriceTick:   m.PriceTick,
+		MinAmount:   m.MinAmount,
+		MaxAmount:   m.MaxAmount,
+		MinNotional: m.MinNotional,
+		MaxLeverage: m.MaxLeverage,
+		Status:      m.Status,
+
+		MaintenanceMarginRate: m.MaintenanceMarginRate,
+	}
+}
+
+// add 注册交易对（重复的交易对保留先注册的）
+func (r *Registry) add(m *Market) {
+	if _, ok := r.markets[m.Symbol]; ok {
+		return
+	}
+	r.markets[m.Symbol] = m
+	r.symbols = append(r.symbols, m.Symbol)
+}
+
+// newMarket 以默认规则创建交易对定义
+func (r *Registry) newMarket(symbol string) *Market {
+	m := r.defaults
+	m.Symbol = symbol
+	m.Base, m.Quote, m.Settle = SplitSymbol(symbol)
+	return &m
+}
+
+// applyDefinition 用配置覆盖交易对规则（零值字段保持默认）
+func applyDefinition(m *Market, def config.MarketDefinitionConfig) {
+	if def.Base != "" {
+		m.Base = def.Base
+	}
+	if def.Quote != "" {
+		m.Quote = def.Quote
+	}
+	if def.AmountStep > 0 {
+		m.AmountStep = decimal.NewFromFloat(def.AmountStep)
+	}
+	if def.PriceTick > 0 {
+		m.PriceTick = decimal.NewFromFloat(def.PriceTick)
+	}
+	if def.MinAmount > 0 {
+		m.MinAmount = decimal.NewFromFloat(def.MinAmount)
+	}
+	if def.MaxAmount > 0 {
+		m.MaxAmount = decimal.NewFromFloat(def.MaxAmount)
+	}
+	if def.MinNotional > 0 {
+		m.MinNotional = decimal.NewFromFloat(def.MinNotional)
+	}
+	if def.MaxLeverage > 0 {
+		m.MaxLeverage = def.MaxLeverage
+	}
+	if def.MaintenanceMarginRate > 0 {
+		m.MaintenanceMarginRate = decimal.NewFromFloat(def.MaintenanceMarginRate)
+	}
+	if def.Status != "" {
+		m.Status = def.Status
+	}
+}
+
+// SplitSymbol 从交易对拆分基础币、计价币和结算币（永续合约 BASE/QUOTE:SETTLE），默认计价币为 USDT
+func SplitSymbol(symbol string) (base, quote, settle string) {
+	if i := strings.Index(symbol, ":"); i >= 0 {
+		symbol, settle = symbol[:i], symbol[i+1:]
+	}
+	parts := strings.Split(symbol, "/")
+	if len(parts) == 2 {
+		return parts[0], parts[1], settle
+	}
+	return symbol, "USDT", settle
+}
+
+// decimalPlaces 步长的有效小数位数
+func decimalPlaces(step decimal.Decimal) int32 {
+	places := int32(0)
+	for !step.Round(places).Equal(step) {
+		places++
+	}
+	return places
+}
@@ -0,0 +1,162 @@
+package market
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+func TestNewRegistry(t *testing.T) {
+	cfg := &config.Config{
+		Market: config.MarketConfig{
+			Symbols: []string{"BTC/USDT", "ETH/USDT"},
+			Definitions: []config.MarketDefinitionConfig{
+				{Symbol: "BTC/USDT", AmountStep: 0.00001, PriceTick: 0.01, MinNotional: 5},
+				{Symbol: "SOL/USDC", Status: StatusInactive},
+			},
+		},
+		Trading: config.TradingConfig{MinOrderAmount: 0.0001},
+	}
+
+	// When: 根据配置构建注册表
+	registry := NewRegistry(nil, cfg)
+
+	t.Run("Symbols and definitions are listed in order", func(t *testing.T) {
+		markets, err := registry.List()
+		require.NoError(t, err)
+
+		var symbols []string
+		for _, m := range markets {
+			symbols = append(symbols, m.Symbol)
+		}
+		assert.Equal(t, []string{"BTC/USDT", "ETH/USDT", "SOL/USDC"}, symbols)
+	})
+
+	t.Run("Definitions override defaults", func(t *testing.T) {
+		btc, ok, err := registry.Get("BTC/USDT")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "0.00001", btc.AmountStep.String())
+		assert.Equal(t, "0.01", btc.PriceTick.String())
+		assert.Equal(t, "0.0001", btc.MinAmount.String())
+		assert.Equal(t, "5", btc.MinNotional.String())
+		assert.Equal(t, int32(5), btc.AmountPrecision())
+		assert.Equal(t, int32(2), btc.PricePrecision())
+		assert.True(t, btc.Active())
+
+		sol, ok, err := registry.Get("SOL/USDC")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "SOL", sol.Base)
+		assert.Equal(t, "USDC", sol.Quote)
+		assert.False(t, sol.Active())
+	})
+
+	t.Run("Unknown symbols fall back to default rules", func(t *testing.T) {
+		_, ok, err := registry.Get("DOGE/USDT")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		m := registry.ForSymbol("DOGE/USDT")
+		assert.Equal(t, "DOGE", m.Base)
+		assert.Equal(t, int32(8), m.AmountPrecision())
+		assert.Equal(t, int32(8), m.PricePrecision())
+	})
+
+	t.Run("Perpetual symbols carry the settle currency", func(t *testing.T) {
+		m := registry.ForSymbol("BTC/USDT:USDT")
+		assert.Equal(t, "BTC", m.Base)
+		assert.Equal(t, "USDT", m.Quote)
+		assert.Equal(t, "USDT", m.Settle)
+		assert.True(t, m.IsSwap())
+		assert.Equal(t, "swap", m.Type())
+		assert.False(t, registry.ForSymbol("BTC/USDT").IsSwap())
+	})
+}
+
+func TestRegistryWithDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Market{}))
+
+	cfg := &config.Config{
+		Market:  config.MarketConfig{Symbols: []string{"BTC/USDT", "ETH/USDT"}},
+		Trading: config.TradingConfig{MinOrderAmount: 0.0001},
+	}
+	registry := NewRegistry(db, cfg)
+
+	// Given: 数据库中暂停了 BTC/USDT，并新增了 SOL/USDT
+	halted := registry.Default("BTC/USDT")
+	halted.Status = StatusHalted
+	require.NoError(t, db.Create(halted.ToModel()).Error)
+
+	added := registry.Default("SOL/USDT")
+	added.PriceTick = decimal.RequireFromString("0.001")
+	added.Status = StatusInactive
+	require.NoError(t, db.Create(added.ToModel()).Error)
+
+	t.Run("Database records override configured markets", func(t *testing.T) {
+		btc, ok, err := registry.Get("BTC/USDT")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, StatusHalted, btc.Status)
+		assert.False(t, btc.Active())
+		assert.True(t, btc.Enabled())
+
+		sol, ok, err := registry.Get("SOL/USDT")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "0.001", sol.PriceTick.String())
+	})
+
+	t.Run("List merges configured and added markets", func(t *testing.T) {
+		markets, err := registry.List()
+		require.NoError(t, err)
+		require.Len(t, markets, 3)
+		assert.Equal(t, "BTC/USDT", markets[0].Symbol)
+		assert.Equal(t, StatusHalted, markets[0].Status)
+		assert.Equal(t, "ETH/USDT", markets[1].Symbol)
+		assert.Equal(t, "SOL/USDT", markets[2].Symbol)
+	})
+
+	t.Run("Disabled markets are excluded from enabled list", func(t *testing.T) {
+		markets, err := registry.Enabled()
+		require.NoError(t, err)
+		require.Len(t, markets, 2)
+		assert.Equal(t, "BTC/USDT", markets[0].Symbol)
+		assert.Equal(t, "ETH/USDT", markets[1].Symbol)
+	})
+}
+
+func TestMarketRounding(t *testing.T) {
+	m := &Market{AmountStep: decimal.RequireFromString("0.00001"), PriceTick: decimal.RequireFromString("0.01")}
+
+	t.Run("Amount is truncated to the step", func(t *testing.T) {
+		assert.Equal(t, "0.12345", m.RoundAmount(decimal.RequireFromString("0.123459")).String())
+	})
+
+	t.Run("Limit price rounds toward the conservative side", func(t *testing.T) {
+		price := decimal.RequireFromString("50000.005")
+		assert.Equal(t, "50000", m.RoundPrice("buy", price).String())
+		assert.Equal(t, "50000.01", m.RoundPrice("sell", price).String())
+	})
+
+	t.Run("Trigger prices round to the nearest tick", func(t *testing.T) {
+		assert.Equal(t, "50000.01", m.RoundTick(decimal.RequireFromString("50000.005")).String())
+		assert.Equal(t, "50000", m.RoundTick(decimal.RequireFromString("50000.004")).String())
+	})
+
+	t.Run("Non-decimal tick sizes", func(t *testing.T) {
+		m := &Market{AmountStep: decimal.RequireFromString("0.5"), PriceTick: decimal.RequireFromString("0.05")}
+		assert.Equal(t, "1.5", m.RoundAmount(decimal.RequireFromString("1.9")).String())
+		assert.Equal(t, "100.05", m.RoundPrice("buy", decimal.RequireFromString("100.07")).String())
+		assert.Equal(t, "100.1", m.CeilPrice(decimal.RequireFromString("100.07")).String())
+	})
+}
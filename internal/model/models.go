@@ -2,8 +2,15 @@ package model
 
 import (
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
+func init() {
+	// 金额字段在 JSON 中仍输出为数字，与原有接口保持兼容（解析时数字和字符串均可）
+	decimal.MarshalJSONWithoutQuotes = true
+}
+
 // User 用户模型
 type User struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
@@ -16,68 +23,220 @@ type User struct {
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	LastLogin *time.Time `json:"last_login,omitempty"`
+
+	PayFeesWithToken bool `gorm:"default:false" json:"pay_fees_with_token"` // 以平台币（trading.fee_token）按折扣支付手续费
 }
 
 // Balance 余额模型
 type Balance struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	Asset     string    `gorm:"size:10;not null" json:"asset"`
-	Available float64   `gorm:"type:decimal(20,8);default:0" json:"available"`
-	Locked    float64   `gorm:"type:decimal(20,8);default:0" json:"locked"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"not null;index" json:"user_id"`
+	Asset     string          `gorm:"size:10;not null" json:"asset"`
+	Available decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"available"`
+	Locked    decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"locked"`
+	Borrowed  decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"borrowed"` // 现货杠杆借款本金（已计入可用余额）
+	Interest  decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"interest"` // 已计提未归还的借款利息
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
 
 	User *User `gorm:"foreignKey:UserID" json:"-"`
 }
 
 // Order 订单模型
+// 同一用户的 ClientOrderID 唯一（空值除外），重复提交时返回原订单
 type Order struct {
-	ID               uint       `gorm:"primaryKey" json:"id"`
-	UserID           uint       `gorm:"not null;index" json:"user_id"`
-	Symbol           string     `gorm:"size:20;not null;index" json:"symbol"`
-	Side             string     `gorm:"size:4;not null" json:"side"`
-	Type             string     `gorm:"size:10;not null;index:idx_status_type" json:"type"`               // market/limit/stop_loss/take_profit
-	Status           string     `gorm:"size:20;not null;default:new;index:idx_status_type" json:"status"` // new/open/filled/canceled/triggered
-	Price            *float64   `gorm:"type:decimal(20,8)" json:"price,omitempty"`
-	StopPrice        *float64   `gorm:"type:decimal(20,8)" json:"stop_price,omitempty"` // 止盈止损触发价格
-	TriggerCondition string     `gorm:"size:10" json:"trigger_condition,omitempty"`     // ">=" 或 "<="
-	Amount           float64    `gorm:"type:decimal(20,8);not null" json:"amount"`
-	Filled           float64    `gorm:"type:decimal(20,8);default:0" json:"filled"`
-	AveragePrice     *float64   `gorm:"type:decimal(20,8)" json:"average_price,omitempty"`
-	Fee              float64    `gorm:"type:decimal(20,8);default:0" json:"fee"`
-	FeeAsset         string     `gorm:"size:10" json:"fee_asset,omitempty"`
-	ClientOrderID    string     `gorm:"size:64;index" json:"client_order_id,omitempty"`
-	ParentOrderID    *uint      `gorm:"index" json:"parent_order_id,omitempty"` // 关联的父订单ID（用于止盈止损）
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-	FilledAt         *time.Time `json:"filled_at,omitempty"`
-	CanceledAt       *time.Time `json:"canceled_at,omitempty"`
-	TriggeredAt      *time.Time `json:"triggered_at,omitempty"` // 止盈止损触发时间
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	UserID           uint             `gorm:"not null;index;uniqueIndex:idx_orders_user_client_order_id" json:"user_id"`
+	Symbol           string           `gorm:"size:20;not null;index" json:"symbol"`
+	Side             string           `gorm:"size:4;not null" json:"side"`
+	Type             string           `gorm:"size:20;not null;index:idx_status_type" json:"type"`               // market/limit/stop_loss/take_profit/stop_loss_limit/take_profit_limit/trailing_stop/twap/iceberg
+	Status           string           `gorm:"size:20;not null;default:new;index:idx_status_type" json:"status"` // new/partially_filled/filled/cancelled/rejected/triggered/pending
+	TimeInForce      string           `gorm:"size:3;default:GTC" json:"time_in_force,omitempty"`                // GTC/IOC/FOK
+	PostOnly         bool             `gorm:"default:false" json:"post_only"`                                   // 只做 maker，会立即成交时拒绝
+	CancelReason     string           `gorm:"size:50" json:"cancel_reason,omitempty"`                           // 撤销/拒绝原因
+	Price            *decimal.Decimal `gorm:"type:decimal(20,8)" json:"price,omitempty"`
+	StopPrice        *decimal.Decimal `gorm:"type:decimal(20,8)" json:"stop_price,omitempty"`       // 止盈止损触发价格（*_limit 类型触发后按 Price 下限价单）
+	TriggerCondition string           `gorm:"size:10" json:"trigger_condition,omitempty"`           // ">=" 或 "<="
+	TrailingDelta    *decimal.Decimal `gorm:"type:decimal(20,8)" json:"trailing_delta,omitempty"`   // 跟踪止损固定回撤距离
+	TrailingPercent  *decimal.Decimal `gorm:"type:decimal(10,4)" json:"trailing_percent,omitempty"` // 跟踪止损回撤百分比（1 表示 1%）
+	Watermark        *decimal.Decimal `gorm:"type:decimal(20,8)" json:"watermark,omitempty"`        // 跟踪止损最优价（卖单为最高价，买单为最低价）
+	ReservePrice     *decimal.Decimal `gorm:"type:decimal(20,8)" json:"reserve_price,omitempty"`    // 买单冻结资金所按的价格（限价单为限价，市价单为下单时价格加滑点缓冲，条件单子订单为触发价）
+	QuoteOrderQty    *decimal.Decimal `gorm:"type:decimal(20,8)" json:"quote_order_qty,omitempty"`  // 按金额下单的市价买单预算（计价币），冻结资金即为预算
+	VisibleAmount    *decimal.Decimal `gorm:"type:decimal(20,8)" json:"visible_amount,omitempty"`   // 冰山单每次显示的数量
+	AlgoSlices       int              `gorm:"default:0" json:"algo_slices,omitempty"`               // TWAP 切片数量
+	AlgoSlicesSent   int              `gorm:"default:0" json:"algo_slices_sent,omitempty"`          // 算法单已下发的子订单数量
+	AlgoInterval     int              `gorm:"default:0" json:"algo_interval,omitempty"`             // TWAP 切片间隔（秒）
+	NextSliceAt      *time.Time       `json:"next_slice_at,omitempty"`                              // TWAP 下一个切片的下发时间
+	Amount           decimal.Decimal  `gorm:"type:decimal(20,8);not null" json:"amount"`
+	Filled           decimal.Decimal  `gorm:"type:decimal(20,8);default:0" json:"filled"`
+	QuoteFilled      decimal.Decimal  `gorm:"type:decimal(20,8);default:0" json:"quote_filled"` // 累计成交金额（计价币）
+	AveragePrice     *decimal.Decimal `gorm:"type:decimal(20,8)" json:"average_price,omitempty"`
+	Fee              decimal.Decimal  `gorm:"type:decimal(20,8);default:0" json:"fee"`
+	FeeAsset         string           `gorm:"size:10" json:"fee_asset,omitempty"`
+	TokenFee         decimal.Decimal  `gorm:"type:decimal(20,8);default:0" json:"token_fee"` // 以平台币支付的手续费（Fee 为从收到的资产中扣除的部分）
+	TokenFeeAsset    string           `gorm:"size:10" json:"token_fee_asset,omitempty"`
+	Leverage         int              `gorm:"default:0" json:"leverage,omitempty"`        // 永续合约订单的杠杆（按名义价值/杠杆冻结保证金），现货订单为 0
+	ReduceOnly       bool             `gorm:"default:false" json:"reduce_only,omitempty"` // 只减仓：成交数量不超过反向持仓，不会开仓或反手
+	ClientOrderID    string           `gorm:"size:64;uniqueIndex:idx_orders_user_client_order_id,where:client_order_id <> ''" json:"client_order_id,omitempty"`
+	ParentOrderID    *uint            `gorm:"index" json:"parent_order_id,omitempty"` // 关联的父订单ID（用于止盈止损、bracket 的止盈止损腿）
+	OrderListID      *uint            `gorm:"index" json:"order_list_id,omitempty"`   // 所属订单组ID（OCO/bracket）
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	QueuedAt         *time.Time       `json:"queued_at,omitempty"` // 改单失去时间优先级后重新排队的时间，为空时按创建时间排队
+	RestedAt         *time.Time       `json:"rested_at,omitempty"` // 首次撮合后仍未全部成交、进入订单簿的时间；此后的成交按 maker 计费
+	FilledAt         *time.Time       `json:"filled_at,omitempty"`
+	CanceledAt       *time.Time       `json:"canceled_at,omitempty"`
+	TriggeredAt      *time.Time       `json:"triggered_at,omitempty"` // 止盈止损触发时间
 
 	User   *User   `gorm:"foreignKey:UserID" json:"-"`
 	Trades []Trade `gorm:"foreignKey:OrderID" json:"trades,omitempty"`
 }
 
+// OrderList 订单组模型（OCO / bracket）
+// OCO：同组订单一腿成交或触发后撤销其他腿；bracket：入场单成交后激活止盈止损腿（二者互为 OCO）
+type OrderList struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	Symbol       string    `gorm:"size:20;not null" json:"symbol"`
+	Type         string    `gorm:"size:10;not null" json:"type"` // oco/bracket
+	ClientListID string    `gorm:"size:64" json:"client_list_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	User   *User   `gorm:"foreignKey:UserID" json:"-"`
+	Orders []Order `gorm:"foreignKey:OrderListID" json:"orders,omitempty"`
+}
+
 // Trade 成交模型
 type Trade struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	OrderID     uint      `gorm:"not null;index" json:"order_id"`
-	UserID      uint      `gorm:"not null;index" json:"user_id"`
-	Symbol      string    `gorm:"size:20;not null" json:"symbol"`
-	Side        string    `gorm:"size:4;not null" json:"side"`
-	Price       float64   `gorm:"type:decimal(20,8);not null" json:"price"`
-	Amount      float64   `gorm:"type:decimal(20,8);not null" json:"amount"`
-	QuoteAmount float64   `gorm:"type:decimal(20,8);not null" json:"quote_amount"`
-	Fee         float64   `gorm:"type:decimal(20,8);default:0" json:"fee"`
-	FeeAsset    string    `gorm:"size:10" json:"fee_asset,omitempty"`
-	IsMaker     bool      `gorm:"default:false" json:"is_maker"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	OrderID     uint            `gorm:"not null;index" json:"order_id"`
+	UserID      uint            `gorm:"not null;index" json:"user_id"`
+	Symbol      string          `gorm:"size:20;not null" json:"symbol"`
+	Side        string          `gorm:"size:4;not null" json:"side"`
+	Price       decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"price"`
+	Amount      decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"amount"`
+	QuoteAmount decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"quote_amount"`
+	Fee         decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"fee"`
+	FeeAsset    string          `gorm:"size:10" json:"fee_asset,omitempty"`
+	IsMaker     bool            `gorm:"default:false" json:"is_maker"`
+	CreatedAt   time.Time       `json:"created_at"`
 
 	Order *Order `gorm:"foreignKey:OrderID" json:"-"`
 	User  *User  `gorm:"foreignKey:UserID" json:"-"`
 }
 
+// Position 永续合约持仓（单向持仓：每个用户每个交易对一个净持仓）
+// 仓位保证金计入结算币的冻结余额，平仓盈亏计入可用余额
+type Position struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	UserID      uint            `gorm:"not null;uniqueIndex:idx_positions_user_symbol" json:"user_id"`
+	Symbol      string          `gorm:"size:20;not null;uniqueIndex:idx_positions_user_symbol" json:"symbol"`
+	Side        string          `gorm:"size:5" json:"side,omitempty"`                      // long/short，无持仓时为空
+	Size        decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"size"`          // 持仓数量（基础币）
+	EntryPrice  decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"entry_price"`   // 开仓均价
+	Leverage    int             `gorm:"not null;default:1" json:"leverage"`                // 杠杆倍数
+	MarginMode  string          `gorm:"size:10;not null;default:cross" json:"margin_mode"` // cross/isolated
+	Margin      decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"margin"`        // 仓位占用的保证金（结算币）
+	RealizedPnl decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"realized_pnl"`  // 累计已实现盈亏（不含手续费）
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// FundingRate 永续合约资金费率（每个交易对每个结算周期一条）
+type FundingRate struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	Symbol      string          `gorm:"size:20;not null;uniqueIndex:idx_funding_rates_symbol_time" json:"symbol"`
+	Rate        decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"rate"`
+	MarkPrice   decimal.Decimal `gorm:"type:decimal(20,8)" json:"mark_price"`
+	IndexPrice  decimal.Decimal `gorm:"type:decimal(20,8)" json:"index_price"`
+	FundingTime time.Time       `gorm:"not null;uniqueIndex:idx_funding_rates_symbol_time" json:"funding_time"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// FundingPayment 用户资金费收支记录
+type FundingPayment struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	UserID      uint            `gorm:"not null;index:idx_funding_payments_user_time" json:"user_id"`
+	Symbol      string          `gorm:"size:20;not null" json:"symbol"`
+	Side        string          `gorm:"size:5;not null" json:"side"` // 结算时的持仓方向
+	Size        decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"size"`
+	Rate        decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"rate"`
+	MarkPrice   decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"mark_price"`
+	Amount      decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"amount"` // 正数为收取，负数为支付（结算币）
+	FundingTime time.Time       `gorm:"not null;index:idx_funding_payments_user_time" json:"funding_time"`
+	CreatedAt   time.Time       `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Liquidation 强平记录（每个被强平的持仓一条）
+type Liquidation struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	UserID            uint            `gorm:"not null;index" json:"user_id"`
+	Symbol            string          `gorm:"size:20;not null" json:"symbol"`
+	Side              string          `gorm:"size:5;not null" json:"side"`                           // 被强平的持仓方向
+	OrderID           uint            `gorm:"not null" json:"order_id"`                              // 强平市价单
+	Size              decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"size"`               // 平仓数量
+	Price             decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"price"`              // 平仓成交均价
+	MarkPrice         decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"mark_price"`         // 触发强平时的标记价格
+	Equity            decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"equity"`             // 触发强平时的账户权益
+	MaintenanceMargin decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"maintenance_margin"` // 触发强平时的维持保证金
+	Fee               decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"fee"`               // 强平手续费（计入保险基金）
+	InsuranceCovered  decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"insurance_covered"` // 保险基金承担的穿仓亏损
+	CreatedAt         time.Time       `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// MarginLoan 现货杠杆借款、还款记录
+type MarginLoan struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"not null;index" json:"user_id"`
+	Asset     string          `gorm:"size:10;not null" json:"asset"`
+	Type      string          `gorm:"size:20;not null" json:"type"`                 // borrow | repay | liquidation
+	Amount    decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"amount"`    // 借入或归还的本金
+	Interest  decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"interest"` // 本次归还的利息
+	CreatedAt time.Time       `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// BorrowInterest 借款利息计提记录（每个用户每个资产每小时一条）
+type BorrowInterest struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"not null;uniqueIndex:idx_borrow_interests_user_asset_time" json:"user_id"`
+	Asset     string          `gorm:"size:10;not null;uniqueIndex:idx_borrow_interests_user_asset_time" json:"asset"`
+	Borrowed  decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"borrowed"` // 计息本金
+	Rate      decimal.Decimal `gorm:"type:decimal(20,10);not null" json:"rate"`    // 小时利率
+	Interest  decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"interest"` // 本小时利息
+	AccruedAt time.Time       `gorm:"not null;uniqueIndex:idx_borrow_interests_user_asset_time" json:"accrued_at"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// InsuranceFund 保险基金（每个结算币一条）：收取强平手续费，承担穿仓亏损
+type InsuranceFund struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Asset     string          `gorm:"uniqueIndex;size:10;not null" json:"asset"`
+	Balance   decimal.Decimal `gorm:"type:decimal(20,8);default:0" json:"balance"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// UserFeeRate 管理员为用户设置的手续费率（覆盖按成交额计算的等级费率）
+type UserFeeRate struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	UserID       uint            `gorm:"uniqueIndex;not null" json:"user_id"`
+	MakerFeeRate decimal.Decimal `gorm:"type:decimal(10,6);not null" json:"maker_fee_rate"`
+	TakerFeeRate decimal.Decimal `gorm:"type:decimal(10,6);not null" json:"taker_fee_rate"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
 // Ticker 行情模型
 type Ticker struct {
 	Symbol                string    `gorm:"primaryKey;size:20" json:"symbol"`
@@ -94,6 +253,26 @@ type Ticker struct {
 	Source                string    `gorm:"size:20;default:binance" json:"source"`
 }
 
+// Market 交易对定义（管理员运行时维护，覆盖配置中的同名交易对）
+type Market struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	Symbol      string          `gorm:"uniqueIndex;size:20;not null" json:"symbol"`
+	Base        string          `gorm:"size:10;not null" json:"base"`
+	Quote       string          `gorm:"size:10;not null" json:"quote"`
+	Settle      string          `gorm:"size:10" json:"settle,omitempty"` // 结算币，非空时为永续合约
+	AmountStep  decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"amount_step"`
+	PriceTick   decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"price_tick"`
+	MinAmount   decimal.Decimal `gorm:"type:decimal(20,8);not null;default:0" json:"min_amount"`
+	MaxAmount   decimal.Decimal `gorm:"type:decimal(20,8);not null;default:0" json:"max_amount"`   // 0 表示不限
+	MinNotional decimal.Decimal `gorm:"type:decimal(20,8);not null;default:0" json:"min_notional"` // 0 表示不限
+	MaxLeverage int             `gorm:"default:0" json:"max_leverage,omitempty"`                   // 永续合约最大杠杆，0 表示使用默认配置
+	Status      string          `gorm:"size:20;not null;default:active;index" json:"status"`       // active | halted | inactive
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	MaintenanceMarginRate decimal.Decimal `gorm:"type:decimal(10,6);not null;default:0" json:"maintenance_margin_rate"` // 永续合约维持保证金率，0 表示使用默认配置
+}
+
 // Kline K线/蜡烛图数据模型
 type Kline struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -123,14 +302,54 @@ func (Order) TableName() string {
 	return "orders"
 }
 
+func (OrderList) TableName() string {
+	return "order_lists"
+}
+
 func (Trade) TableName() string {
 	return "trades"
 }
 
+func (Position) TableName() string {
+	return "positions"
+}
+
+func (FundingRate) TableName() string {
+	return "funding_rates"
+}
+
+func (FundingPayment) TableName() string {
+	return "funding_payments"
+}
+
+func (Liquidation) TableName() string {
+	return "liquidations"
+}
+
+func (MarginLoan) TableName() string {
+	return "margin_loans"
+}
+
+func (BorrowInterest) TableName() string {
+	return "borrow_interests"
+}
+
+func (InsuranceFund) TableName() string {
+	return "insurance_funds"
+}
+
+func (UserFeeRate) TableName() string {
+	return "user_fee_rates"
+}
+
 func (Ticker) TableName() string {
 	return "tickers"
 }
 
+func (Market) TableName() string {
+	return "markets"
+}
+
 func (Kline) TableName() string {
 	return "klines"
 }
@@ -1,10 +1,12 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
@@ -16,7 +18,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&User{}, &Balance{}, &Order{}, &Trade{}, &Ticker{})
+	err = db.AutoMigrate(&User{}, &Balance{}, &Order{}, &OrderList{}, &Trade{}, &UserFeeRate{}, &Position{}, &FundingRate{}, &FundingPayment{}, &Liquidation{}, &InsuranceFund{}, &MarginLoan{}, &BorrowInterest{}, &Ticker{}, &Market{})
 	require.NoError(t, err)
 
 	return db
@@ -93,24 +95,24 @@ func TestBalanceModel(t *testing.T) {
 		balance := &Balance{
 			UserID:    user.ID,
 			Asset:     "BTC",
-			Available: 1.5,
-			Locked:    0.5,
+			Available: decimal.NewFromFloat(1.5),
+			Locked:    decimal.NewFromFloat(0.5),
 		}
 
 		err := db.Create(balance).Error
 		require.NoError(t, err)
 		assert.NotZero(t, balance.ID)
 		assert.Equal(t, user.ID, balance.UserID)
-		assert.Equal(t, 1.5, balance.Available)
-		assert.Equal(t, 0.5, balance.Locked)
+		assert.Equal(t, 1.5, balance.Available.InexactFloat64())
+		assert.Equal(t, 0.5, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Multiple assets for same user", func(t *testing.T) {
 		btcBalance := &Balance{
 			UserID:    user.ID,
 			Asset:     "BTC",
-			Available: 1.0,
-			Locked:    0.0,
+			Available: decimal.NewFromFloat(1.0),
+			Locked:    decimal.NewFromFloat(0.0),
 		}
 		err := db.Create(btcBalance).Error
 		require.NoError(t, err)
@@ -118,8 +120,8 @@ func TestBalanceModel(t *testing.T) {
 		ethBalance := &Balance{
 			UserID:    user.ID,
 			Asset:     "ETH",
-			Available: 10.0,
-			Locked:    0.0,
+			Available: decimal.NewFromFloat(10.0),
+			Locked:    decimal.NewFromFloat(0.0),
 		}
 		err = db.Create(ethBalance).Error
 		require.NoError(t, err)
@@ -128,6 +130,23 @@ func TestBalanceModel(t *testing.T) {
 		db.Where("user_id = ?", user.ID).Find(&balances)
 		assert.GreaterOrEqual(t, len(balances), 2)
 	})
+
+	t.Run("Decimal amounts are exact and serialize as numbers", func(t *testing.T) {
+		balance := &Balance{
+			UserID:    user.ID,
+			Asset:     "USDT",
+			Available: decimal.RequireFromString("0.1").Add(decimal.RequireFromString("0.2")),
+		}
+		require.NoError(t, db.Create(balance).Error)
+
+		var found Balance
+		require.NoError(t, db.First(&found, balance.ID).Error)
+		assert.True(t, found.Available.Equal(decimal.RequireFromString("0.3")))
+
+		data, err := json.Marshal(found)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"available":0.3`)
+	})
 }
 
 func TestOrderModel(t *testing.T) {
@@ -148,7 +167,7 @@ func TestOrderModel(t *testing.T) {
 			Side:   "buy",
 			Type:   "market",
 			Status: "new",
-			Amount: 0.5,
+			Amount: decimal.NewFromFloat(0.5),
 		}
 
 		err := db.Create(order).Error
@@ -159,14 +178,14 @@ func TestOrderModel(t *testing.T) {
 	})
 
 	t.Run("Create limit order", func(t *testing.T) {
-		price := 50000.0
+		price := decimal.NewFromFloat(50000.0)
 		order := &Order{
 			UserID: user.ID,
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
 			Status: "new",
-			Amount: 0.5,
+			Amount: decimal.NewFromFloat(0.5),
 			Price:  &price,
 		}
 
@@ -174,7 +193,7 @@ func TestOrderModel(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotZero(t, order.ID)
 		assert.NotNil(t, order.Price)
-		assert.Equal(t, 50000.0, *order.Price)
+		assert.Equal(t, 50000.0, order.Price.InexactFloat64())
 	})
 
 	t.Run("Order with client order ID", func(t *testing.T) {
@@ -184,7 +203,7 @@ func TestOrderModel(t *testing.T) {
 			Side:          "sell",
 			Type:          "market",
 			Status:        "new",
-			Amount:        0.1,
+			Amount:        decimal.NewFromFloat(0.1),
 			ClientOrderID: "client-order-123",
 		}
 
@@ -215,7 +234,7 @@ func TestTradeModel(t *testing.T) {
 		Side:   "buy",
 		Type:   "market",
 		Status: "new",
-		Amount: 1.0,
+		Amount: decimal.NewFromFloat(1.0),
 	}
 	err = db.Create(order).Error
 	require.NoError(t, err)
@@ -226,10 +245,10 @@ func TestTradeModel(t *testing.T) {
 			UserID:      user.ID,
 			Symbol:      "BTC/USDT",
 			Side:        "buy",
-			Price:       50000.0,
-			Amount:      1.0,
-			QuoteAmount: 50000.0,
-			Fee:         50.0,
+			Price:       decimal.NewFromFloat(50000.0),
+			Amount:      decimal.NewFromFloat(1.0),
+			QuoteAmount: decimal.NewFromFloat(50000.0),
+			Fee:         decimal.NewFromFloat(50.0),
 			FeeAsset:    "USDT",
 			IsMaker:     false,
 		}
@@ -238,7 +257,7 @@ func TestTradeModel(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotZero(t, trade.ID)
 		assert.Equal(t, order.ID, trade.OrderID)
-		assert.Equal(t, 50000.0, trade.Price)
+		assert.Equal(t, 50000.0, trade.Price.InexactFloat64())
 	})
 
 	t.Run("Query trades by order", func(t *testing.T) {
@@ -249,10 +268,10 @@ func TestTradeModel(t *testing.T) {
 				UserID:      user.ID,
 				Symbol:      "BTC/USDT",
 				Side:        "buy",
-				Price:       50000.0,
-				Amount:      0.1,
-				QuoteAmount: 5000.0,
-				Fee:         5.0,
+				Price:       decimal.NewFromFloat(50000.0),
+				Amount:      decimal.NewFromFloat(0.1),
+				QuoteAmount: decimal.NewFromFloat(5000.0),
+				Fee:         decimal.NewFromFloat(5.0),
 				FeeAsset:    "USDT",
 			}
 			db.Create(trade)
@@ -359,6 +378,46 @@ func TestTableNames(t *testing.T) {
 		var ticker Ticker
 		assert.Equal(t, "tickers", ticker.TableName())
 	})
+
+	t.Run("Position table name", func(t *testing.T) {
+		var position Position
+		assert.Equal(t, "positions", position.TableName())
+	})
+
+	t.Run("FundingRate table name", func(t *testing.T) {
+		var rate FundingRate
+		assert.Equal(t, "funding_rates", rate.TableName())
+	})
+
+	t.Run("FundingPayment table name", func(t *testing.T) {
+		var payment FundingPayment
+		assert.Equal(t, "funding_payments", payment.TableName())
+	})
+
+	t.Run("Liquidation table name", func(t *testing.T) {
+		var liquidation Liquidation
+		assert.Equal(t, "liquidations", liquidation.TableName())
+	})
+
+	t.Run("MarginLoan table name", func(t *testing.T) {
+		var loan MarginLoan
+		assert.Equal(t, "margin_loans", loan.TableName())
+	})
+
+	t.Run("BorrowInterest table name", func(t *testing.T) {
+		var interest BorrowInterest
+		assert.Equal(t, "borrow_interests", interest.TableName())
+	})
+
+	t.Run("InsuranceFund table name", func(t *testing.T) {
+		var fund InsuranceFund
+		assert.Equal(t, "insurance_funds", fund.TableName())
+	})
+
+	t.Run("UserFeeRate table name", func(t *testing.T) {
+		var feeRate UserFeeRate
+		assert.Equal(t, "user_fee_rates", feeRate.TableName())
+	})
 }
 
 func TestTimestamps(t *testing.T) {
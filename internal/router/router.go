@@ -7,6 +7,7 @@ import (
 
 	"github.com/talkincode/quicksilver/internal/api"
 	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/middleware"
 	"github.com/talkincode/quicksilver/internal/service"
 )
@@ -18,6 +19,12 @@ func SetupRoutes(e *echo.Echo, db *gorm.DB, cfg *config.Config, logger *zap.Logg
 	orderService := service.NewOrderService(db, cfg, logger, balanceService)
 	userService := service.NewUserService(db, cfg, logger)
 	klineService := service.NewKlineService(db, cfg, logger)
+	marketService := service.NewMarketService(db, cfg, logger)
+	feeService := service.NewFeeService(db, cfg, logger)
+	positionService := service.NewPositionService(db, cfg, logger)
+	fundingService := service.NewFundingService(db, cfg, logger, balanceService)
+	liquidationService := service.NewLiquidationService(db, cfg, logger)
+	spotMarginService := service.NewSpotMarginService(db, cfg, logger, balanceService)
 
 	// 健康检查
 	e.GET("/health", func(c echo.Context) error {
@@ -32,10 +39,12 @@ func SetupRoutes(e *echo.Echo, db *gorm.DB, cfg *config.Config, logger *zap.Logg
 	{
 		public.GET("/ping", api.Ping)
 		public.GET("/time", api.ServerTime)
-		public.GET("/markets", api.GetMarkets(cfg))
+		public.GET("/markets", api.GetMarkets(marketService))
 		public.GET("/ticker/:symbol", api.GetTicker(db))
+		public.GET("/orderbook/:symbol", api.GetOrderBook(db, cfg)) // 合成 L2 订单簿
 		public.GET("/trades/:symbol", api.GetTrades(db))
-		public.GET("/ohlcv/:symbol", api.GetOHLCV(klineService)) // K线数据
+		public.GET("/ohlcv/:symbol", api.GetOHLCV(klineService))               // K线数据
+		public.GET("/fundingRate/:symbol", api.GetFundingRate(fundingService)) // 永续合约资金费率（CCXT fetchFundingRate）
 	}
 
 	// 私有接口（需要认证）
@@ -44,11 +53,31 @@ func SetupRoutes(e *echo.Echo, db *gorm.DB, cfg *config.Config, logger *zap.Logg
 	{
 		private.GET("/balance", api.GetBalance(db))
 		private.POST("/order", api.CreateOrder(orderService))
+		private.GET("/order", api.GetOrderByClientOrderID(orderService))       // 按 clientOrderId 查询订单
+		private.DELETE("/order", api.CancelOrderByClientOrderID(orderService)) // 按 clientOrderId 撤单
 		private.GET("/order/:id", api.GetOrder(orderService))
+		private.PUT("/order/:id", api.EditOrder(orderService)) // 改单（CCXT editOrder）
 		private.DELETE("/order/:id", api.CancelOrder(orderService))
 		private.GET("/orders", api.GetOrders(orderService))
+		private.DELETE("/orders", api.CancelAllOrders(orderService)) // 撤销全部未完成订单（可按 symbol/side 过滤）
+		private.POST("/batchOrders", api.CreateOrders(orderService)) // 批量下单
 		private.GET("/orders/open", api.GetOpenOrders(orderService))
+		private.POST("/orderList", api.CreateOrderList(orderService))       // OCO / bracket 订单组
+		private.GET("/orderList/:id", api.GetOrderList(orderService))       // 订单组详情
+		private.DELETE("/orderList/:id", api.CancelOrderList(orderService)) // 撤销订单组
 		private.GET("/myTrades", api.GetMyTrades(db))
+		private.GET("/tradingFees", api.GetTradingFees(feeService))               // 当前手续费率（CCXT fetchTradingFees）
+		private.PUT("/account/feeToken", api.SetFeeTokenPayment(feeService, cfg)) // 开启/关闭平台币抵扣手续费
+		private.GET("/positions", api.GetPositions(positionService))              // 永续合约持仓（CCXT fetchPositions）
+		private.POST("/leverage", api.SetLeverage(positionService))               // 设置杠杆（CCXT setLeverage）
+		private.POST("/marginMode", api.SetMarginMode(positionService))           // 设置保证金模式（CCXT setMarginMode）
+		private.POST("/positionMargin/add", api.AddMargin(positionService))       // 追加逐仓保证金（CCXT addMargin）
+		private.POST("/positionMargin/reduce", api.ReduceMargin(positionService)) // 减少逐仓保证金（CCXT reduceMargin）
+		private.GET("/fundingHistory", api.GetFundingHistory(fundingService))     // 资金费收支记录（CCXT fetchFundingHistory）
+		private.GET("/liquidations", api.GetMyLiquidations(liquidationService))   // 强平记录（CCXT fetchMyLiquidations）
+		private.POST("/margin/borrow", api.BorrowMargin(spotMarginService))       // 现货杠杆借币（CCXT borrowMargin）
+		private.POST("/margin/repay", api.RepayMargin(spotMarginService))         // 现货杠杆还币（CCXT repayMargin）
+		private.GET("/borrowInterest", api.GetBorrowInterest(spotMarginService))  // 借款利息记录（CCXT fetchBorrowInterest）
 	}
 
 	// 管理员接口（需要认证 + 管理员权限）
@@ -67,5 +96,19 @@ func SetupRoutes(e *echo.Echo, db *gorm.DB, cfg *config.Config, logger *zap.Logg
 		admin.GET("/users/:id/balances", api.AdminGetUserBalances(balanceService))
 		admin.GET("/balances", api.AdminGetAllBalances(balanceService))
 		admin.POST("/users/:id/balance/adjust", api.AdminAdjustBalance(balanceService))
+
+		// 手续费管理（用户费率覆盖按成交额计算的等级费率）
+		admin.PUT("/users/:id/fees", api.AdminSetUserFeeRate(feeService))
+		admin.DELETE("/users/:id/fees", api.AdminDeleteUserFeeRate(feeService))
+
+		// 交易对管理（:symbol 格式为 BTC-USDT）
+		admin.GET("/markets", api.AdminListMarkets(marketService))
+		admin.POST("/markets", api.AdminAddMarket(marketService))
+		admin.POST("/markets/:symbol/disable", api.AdminSetMarketStatus(marketService, market.StatusInactive))
+		admin.POST("/markets/:symbol/halt", api.AdminSetMarketStatus(marketService, market.StatusHalted)) // 只允许撤单
+		admin.POST("/markets/:symbol/resume", api.AdminSetMarketStatus(marketService, market.StatusActive))
+
+		// 永续合约保险基金
+		admin.GET("/insuranceFund", api.AdminGetInsuranceFunds(liquidationService))
 	}
 }
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// algoOrderTypes 算法单（父订单）类型
+var algoOrderTypes = []string{"twap", "iceberg"}
+
+// isAlgoOrderType 判断是否为算法单类型
+func isAlgoOrderType(orderType string) bool {
+	for _, t := range algoOrderTypes {
+		if t == orderType {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAlgoOrder 根据下单参数确定算法单类型并验证参数，未设置时返回空类型
+func (s *OrderService) resolveAlgoOrder(req CreateOrderRequest) (string, error) {
+	isTWAP := req.TwapDuration != 0 || req.TwapSlices != 0
+	isIceberg := req.IcebergQty != nil
+
+	switch {
+	case isTWAP && isIceberg:
+		return "", fmt.Errorf("twap and iceberg parameters cannot be combined")
+	case !isTWAP && !isIceberg:
+		return "", nil
+	}
+
+	if req.TimeInForce != "" && req.TimeInForce != engine.TimeInForceGTC {
+		return "", fmt.Errorf("algo orders must be GTC")
+	}
+	if req.PostOnly {
+		return "", fmt.Errorf("postOnly is not supported for algo orders")
+	}
+
+	if isIceberg {
+		if req.Type != "limit" {
+			return "", fmt.Errorf("iceberg orders must be limit orders")
+		}
+		if !req.IcebergQty.IsPositive() || req.IcebergQty.GreaterThanOrEqual(req.Amount) {
+			return "", fmt.Errorf("icebergQty must be positive and less than amount")
+		}
+		if minAmount := s.markets.ForSymbol(req.Symbol).MinAmount; req.IcebergQty.LessThan(minAmount) {
+			return "", fmt.Errorf("icebergQty is too small, minimum is %s", minAmount)
+		}
+		return "iceberg", nil
+	}
+
+	if req.TwapDuration <= 0 {
+		return "", fmt.Errorf("twapDuration must be positive")
+	}
+	if req.TwapSlices < 2 {
+		return "", fmt.Errorf("twapSlices must be at least 2")
+	}
+	if minAmount := s.markets.ForSymbol(req.Symbol).MinAmount; req.Amount.Div(decimal.NewFromInt(int64(req.TwapSlices))).LessThan(minAmount) {
+		return "", fmt.Errorf("twap slice amount is too small, minimum is %s", minAmount)
+	}
+	return "twap", nil
+}
+
+// applyAlgoParams 将算法参数写入父订单
+func applyAlgoParams(order *model.Order, req CreateOrderRequest, algoType string) {
+	order.Type = algoType
+	if algoType == "iceberg" {
+		order.VisibleAmount = req.IcebergQty
+		return
+	}
+
+	now := time.Now()
+	order.AlgoSlices = req.TwapSlices
+	order.AlgoInterval = req.TwapDuration / req.TwapSlices
+	order.NextSliceAt = &now
+}
+
+// StartAlgoScheduler 启动算法单调度器，定期同步进度并下发子订单
+func (s *OrderService) StartAlgoScheduler() {
+	interval, err := time.ParseDuration(s.cfg.Trading.Algo.SchedulerInterval)
+	if err != nil || interval <= 0 {
+		interval = 1 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := s.ProcessAlgoOrders(); err != nil {
+				s.logger.Error("Failed to process algo orders", zap.Error(err))
+			}
+		}
+	}()
+
+	s.logger.Info("Algo order scheduler started", zap.Duration("interval", interval))
+}
+
+// ProcessAlgoOrders 处理所有未完成的算法单
+func (s *OrderService) ProcessAlgoOrders() error {
+	var ids []uint
+	if err := s.db.Model(&model.Order{}).
+		Where("type IN ? AND status IN ?", algoOrderTypes, openOrderStatuses).
+		Order("created_at ASC").
+		Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to query algo orders: %w", err)
+	}
+
+	for _, id := range ids {
+		s.processAlgoOrder(id)
+	}
+
+	return nil
+}
+
+// processAlgoOrder 同步算法单进度，按需下发下一个子订单
+// TWAP 按时间间隔下发切片；冰山单在上一个显示部分全部成交后补充
+func (s *OrderService) processAlgoOrder(orderID uint) {
+	var child *model.Order
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var parent model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&parent, orderID).Error; err != nil {
+			return fmt.Errorf("failed to lock algo order: %w", err)
+		}
+		if parent.Status != "new" && parent.Status != "partially_filled" {
+			return nil
+		}
+
+		// 暂停交易或已停用的交易对不下发子订单
+		if !market.NewRegistry(tx, s.cfg).ForSymbol(parent.Symbol).Active() {
+			return nil
+		}
+
+		children, err := s.syncAlgoProgress(tx, &parent)
+		if err != nil {
+			return err
+		}
+		if parent.Status == "filled" {
+			return nil
+		}
+
+		// 已下发数量：未撤销的子订单按数量计，撤销的子订单按已成交数量计
+		sent := decimal.Zero
+		hasOpenChild := false
+		for _, c := range children {
+			switch c.Status {
+			case "cancelled", "rejected":
+				sent = sent.Add(c.Filled)
+			default:
+				sent = sent.Add(c.Amount)
+			}
+			if c.Status == "new" || c.Status == "partially_filled" {
+				hasOpenChild = true
+			}
+		}
+		unsent := parent.Amount.Sub(sent)
+		if !unsent.IsPositive() {
+			return nil
+		}
+
+		var amount decimal.Decimal
+		switch parent.Type {
+		case "twap":
+			now := time.Now()
+			if parent.AlgoSlicesSent >= parent.AlgoSlices || (parent.NextSliceAt != nil && now.Before(*parent.NextSliceAt)) {
+				return nil
+			}
+			amount = unsent.Div(decimal.NewFromInt(int64(parent.AlgoSlices - parent.AlgoSlicesSent)))
+			next := now.Add(time.Duration(parent.AlgoInterval) * time.Second)
+			parent.NextSliceAt = &next
+		case "iceberg":
+			if hasOpenChild {
+				return nil
+			}
+			amount = decimal.Min(*parent.VisibleAmount, unsent)
+		default:
+			return nil
+		}
+
+		// 切片按数量精度截断，最后一个切片下发剩余全部数量，避免精度残留
+		mkt := s.markets.ForSymbol(parent.Symbol)
+		amount = mkt.RoundAmount(amount)
+		if unsent.Sub(amount).LessThan(mkt.MinAmount) {
+			amount = unsent
+		}
+
+		childType := "market"
+		if parent.Price != nil {
+			childType = "limit"
+		}
+		child = &model.Order{
+			UserID:        parent.UserID,
+			Symbol:        parent.Symbol,
+			Side:          parent.Side,
+			Type:          childType,
+			TimeInForce:   engine.TimeInForceGTC,
+			Status:        "new",
+			Amount:        amount,
+			Price:         parent.Price,
+			ReservePrice:  parent.ReservePrice,
+			ParentOrderID: &parent.ID,
+		}
+		if err := tx.Create(child).Error; err != nil {
+			return fmt.Errorf("failed to create algo slice: %w", err)
+		}
+
+		parent.AlgoSlicesSent++
+		if err := tx.Save(&parent).Error; err != nil {
+			return fmt.Errorf("failed to update algo order: %w", err)
+		}
+
+		s.logger.Info("Algo slice placed",
+			zap.Uint("parent_order_id", parent.ID),
+			zap.Uint("child_order_id", child.ID),
+			zap.String("algo", parent.Type),
+			zap.Int("slice", parent.AlgoSlicesSent),
+			zap.Stringer("amount", amount),
+		)
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to process algo order",
+			zap.Uint("order_id", orderID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	// 子订单冻结资金由父订单承担，直接进入撮合
+	if child != nil {
+		if err := s.createMatchingEngine().MatchOrder(child.ID); err != nil {
+			s.logger.Error("Failed to match algo slice",
+				zap.Uint("order_id", child.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// syncAlgoProgress 汇总子订单成交情况到父订单（成交量、均价、手续费、状态）
+func (s *OrderService) syncAlgoProgress(tx *gorm.DB, parent *model.Order) ([]model.Order, error) {
+	var children []model.Order
+	if err := tx.Where("parent_order_id = ?", parent.ID).
+		Order("id ASC").
+		Find(&children).Error; err != nil {
+		return nil, fmt.Errorf("failed to query algo slices: %w", err)
+	}
+
+	filled, cost, fee, tokenFee := decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero
+	for _, c := range children {
+		filled = filled.Add(c.Filled)
+		if c.AveragePrice != nil {
+			cost = cost.Add(c.Filled.Mul(*c.AveragePrice))
+		}
+		fee = fee.Add(c.Fee)
+		if c.FeeAsset != "" {
+			parent.FeeAsset = c.FeeAsset
+		}
+		tokenFee = tokenFee.Add(c.TokenFee)
+		if c.TokenFeeAsset != "" {
+			parent.TokenFeeAsset = c.TokenFeeAsset
+		}
+	}
+
+	parent.Filled = filled
+	parent.Fee = fee
+	parent.TokenFee = tokenFee
+	if filled.IsPositive() {
+		averagePrice := cost.DivRound(filled, engine.AssetScale)
+		parent.AveragePrice = &averagePrice
+		parent.Status = "partially_filled"
+	}
+	if !parent.Amount.Sub(filled).IsPositive() {
+		now := time.Now()
+		parent.Status = "filled"
+		parent.FilledAt = &now
+	}
+
+	if err := tx.Save(parent).Error; err != nil {
+		return nil, fmt.Errorf("failed to update algo order: %w", err)
+	}
+
+	return children, nil
+}
+
+// cancelAlgoOrder 撤销算法单：撤销未完成的子订单，按父订单未成交部分解冻资金
+func (s *OrderService) cancelAlgoOrder(order *model.Order) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(order, order.ID).Error; err != nil {
+			return fmt.Errorf("failed to lock algo order: %w", err)
+		}
+		if order.Status != "new" && order.Status != "partially_filled" {
+			return fmt.Errorf("cannot cancel order with status: %s", order.Status)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&model.Order{}).
+			Where("parent_order_id = ? AND status IN ?", order.ID, openOrderStatuses).
+			Updates(map[string]interface{}{
+				"status":      "cancelled",
+				"canceled_at": now,
+			}).Error; err != nil {
+			return fmt.Errorf("failed to cancel algo slices: %w", err)
+		}
+
+		children, err := s.syncAlgoProgress(tx, order)
+		if err != nil {
+			return err
+		}
+		if order.Status == "filled" {
+			return fmt.Errorf("cannot cancel order with status: filled")
+		}
+
+		// 子订单成交已按各自的成交量从父订单冻结中扣减
+		consumed := decimal.Zero
+		for i := range children {
+			consumed = consumed.Add(engine.ReservedFor(&children[i], children[i].Filled))
+		}
+
+		order.Status = "cancelled"
+		order.CanceledAt = &now
+		order.FilledAt = nil
+		if err := tx.Save(order).Error; err != nil {
+			return fmt.Errorf("failed to update order status: %w", err)
+		}
+
+		// 解冻 = 父订单整单冻结 - 子订单已扣减部分，与状态更新在同一事务中提交
+		_, frozenAsset := s.remainingReservation(order)
+		frozenAmount := engine.ReservedFor(order, order.Amount).Sub(consumed)
+		if !frozenAmount.IsPositive() {
+			return nil
+		}
+		if err := s.balanceService.WithTx(tx).UnfreezeBalance(order.UserID, frozenAsset, frozenAmount); err != nil {
+			return fmt.Errorf("failed to unfreeze balance: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Algo order cancelled",
+		zap.Uint("order_id", order.ID),
+		zap.Uint("user_id", order.UserID),
+		zap.Stringer("filled", order.Filled),
+	)
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// TestTWAPOrder 测试 TWAP 算法单按时间切片下发
+func TestTWAPOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	t.Run("Market TWAP sells in equal slices over time", func(t *testing.T) {
+		// Given: 1 BTC，分 3 片、60 秒内卖出
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+		createTestBalance(t, db, user.ID, "USDT", 0, 0)
+		bidPrice, askPrice := 50000.0, 50000.0
+		require.NoError(t, db.Save(&model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 50000.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}).Error)
+
+		// When: 创建 TWAP 单
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:       "BTC/USDT",
+			Side:         "sell",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(0.9),
+			TwapDuration: 60,
+			TwapSlices:   3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "twap", order.Type)
+		assert.Equal(t, 3, order.AlgoSlices)
+		assert.Equal(t, 20, order.AlgoInterval)
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 立即下发第一片并成交，父订单部分成交
+		var children []model.Order
+		require.NoError(t, db.Where("parent_order_id = ?", order.ID).Find(&children).Error)
+		require.Len(t, children, 1)
+		assert.InDelta(t, 0.3, children[0].Amount.InexactFloat64(), 1e-8)
+		assert.Equal(t, "filled", children[0].Status)
+
+		// When: 间隔未到时再次调度
+		require.NoError(t, orderService.ProcessAlgoOrders())
+
+		// Then: 不下发新切片，父订单进度已同步
+		var parent model.Order
+		require.NoError(t, db.First(&parent, order.ID).Error)
+		assert.Equal(t, 1, parent.AlgoSlicesSent)
+		assert.Equal(t, "partially_filled", parent.Status)
+		assert.InDelta(t, 0.3, parent.Filled.InexactFloat64(), 1e-8)
+
+		// When: 依次到达下一个切片时间
+		for i := 0; i < 2; i++ {
+			require.NoError(t, db.Model(&model.Order{}).Where("id = ?", order.ID).
+				Update("next_slice_at", time.Now().Add(-time.Second)).Error)
+			require.NoError(t, orderService.ProcessAlgoOrders())
+		}
+		require.NoError(t, orderService.ProcessAlgoOrders())
+
+		// Then: 三片全部成交，父订单完成
+		require.NoError(t, db.First(&parent, order.ID).Error)
+		assert.Equal(t, 3, parent.AlgoSlicesSent)
+		assert.Equal(t, "filled", parent.Status)
+		assert.InDelta(t, 0.9, parent.Filled.InexactFloat64(), 1e-8)
+		assert.InDelta(t, 50000.0, parent.AveragePrice.InexactFloat64(), 1e-6)
+
+		var btc model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btc).Error)
+		assert.InDelta(t, 0.1, btc.Available.InexactFloat64(), 1e-8)
+		assert.InDelta(t, 0.0, btc.Locked.InexactFloat64(), 1e-8)
+	})
+
+	t.Run("Cancel TWAP releases unsent amount", func(t *testing.T) {
+		// Given: 限价 TWAP 卖单，价格高于市价不会成交
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+		createTestBalance(t, db, user.ID, "USDT", 0, 0)
+		createTestTicker(t, db, "BTC/USDT", 50000.0)
+
+		price := 60000.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:       "BTC/USDT",
+			Side:         "sell",
+			Type:         "limit",
+			Price:        decimalPtr(price),
+			Amount:       decimal.NewFromFloat(1.0),
+			TwapDuration: 100,
+			TwapSlices:   4,
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		var child model.Order
+		require.NoError(t, db.Where("parent_order_id = ?", order.ID).First(&child).Error)
+		assert.Equal(t, "limit", child.Type)
+
+		// When: 直接撤销子订单
+		err = orderService.CancelOrder(user.ID, child.ID)
+
+		// Then: 拒绝，需撤销父订单
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cancel the parent instead")
+
+		// When: 撤销父订单
+		require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+
+		// Then: 子订单撤销，全部冻结资金释放
+		require.NoError(t, db.First(&child, child.ID).Error)
+		assert.Equal(t, "cancelled", child.Status)
+
+		var parent model.Order
+		require.NoError(t, db.First(&parent, order.ID).Error)
+		assert.Equal(t, "cancelled", parent.Status)
+
+		var btc model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&btc).Error)
+		assert.InDelta(t, 1.0, btc.Available.InexactFloat64(), 1e-8)
+		assert.InDelta(t, 0.0, btc.Locked.InexactFloat64(), 1e-8)
+	})
+}
+
+// TestIcebergOrder 测试冰山单在显示部分成交后补充
+func TestIcebergOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	// Given: 冰山卖单 1 BTC，每次显示 0.4
+	user := createTestUser(t, db)
+	createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+	createTestBalance(t, db, user.ID, "USDT", 0, 0)
+	createTestTicker(t, db, "BTC/USDT", 50000.0)
+
+	price := 51000.0
+	visible := 0.4
+	order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+		Symbol:     "BTC/USDT",
+		Side:       "sell",
+		Type:       "limit",
+		Price:      decimalPtr(price),
+		Amount:     decimal.NewFromFloat(1.0),
+		IcebergQty: decimalPtr(visible),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "iceberg", order.Type)
+	time.Sleep(100 * time.Millisecond)
+
+	// When: 显示部分未成交时调度
+	require.NoError(t, orderService.ProcessAlgoOrders())
+
+	// Then: 只有一个显示子订单
+	var children []model.Order
+	require.NoError(t, db.Where("parent_order_id = ?", order.ID).Find(&children).Error)
+	require.Len(t, children, 1)
+	assert.InDelta(t, 0.4, children[0].Amount.InexactFloat64(), 1e-8)
+
+	// When: 行情上涨，显示部分成交后再次调度
+	bidPrice, askPrice := 52000.0, 52000.0
+	require.NoError(t, db.Save(&model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 52000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}).Error)
+	require.NoError(t, orderService.createMatchingEngine().MatchOrder(children[0].ID))
+	require.NoError(t, orderService.ProcessAlgoOrders())
+
+	// Then: 补充下一个显示部分
+	require.NoError(t, db.Where("parent_order_id = ?", order.ID).Order("id ASC").Find(&children).Error)
+	require.Len(t, children, 2)
+	assert.Equal(t, "filled", children[0].Status)
+	assert.InDelta(t, 0.4, children[1].Amount.InexactFloat64(), 1e-8)
+
+	var parent model.Order
+	require.NoError(t, db.First(&parent, order.ID).Error)
+	assert.Equal(t, "partially_filled", parent.Status)
+	assert.Equal(t, 2, parent.AlgoSlicesSent)
+}
+
+// TestResolveAlgoOrder 测试算法单参数验证
+func TestResolveAlgoOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+	cfg := setupTestConfig(t)
+	orderService := NewOrderService(db, cfg, zap.NewNop(), NewBalanceService(db, cfg, zap.NewNop()))
+
+	price := 50000.0
+	qty := decimalPtr
+
+	tests := []struct {
+		name    string
+		req     CreateOrderRequest
+		want    string
+		wantErr string
+	}{
+		{"plain order", CreateOrderRequest{Type: "limit", Amount: decimal.NewFromFloat(1), Price: decimalPtr(price)}, "", ""},
+		{"valid iceberg", CreateOrderRequest{Type: "limit", Amount: decimal.NewFromFloat(1), Price: decimalPtr(price), IcebergQty: qty(0.2)}, "iceberg", ""},
+		{"iceberg market", CreateOrderRequest{Type: "market", Amount: decimal.NewFromFloat(1), IcebergQty: qty(0.2)}, "", "must be limit"},
+		{"iceberg qty too large", CreateOrderRequest{Type: "limit", Amount: decimal.NewFromFloat(1), Price: decimalPtr(price), IcebergQty: qty(1)}, "", "less than amount"},
+		{"valid twap", CreateOrderRequest{Type: "market", Amount: decimal.NewFromFloat(1), TwapDuration: 60, TwapSlices: 5}, "twap", ""},
+		{"twap single slice", CreateOrderRequest{Type: "market", Amount: decimal.NewFromFloat(1), TwapDuration: 60, TwapSlices: 1}, "", "at least 2"},
+		{"twap without duration", CreateOrderRequest{Type: "market", Amount: decimal.NewFromFloat(1), TwapSlices: 5}, "", "twapDuration"},
+		{"twap with IOC", CreateOrderRequest{Type: "market", Amount: decimal.NewFromFloat(1), TwapDuration: 60, TwapSlices: 5, TimeInForce: "IOC"}, "", "GTC"},
+		{"twap and iceberg", CreateOrderRequest{Type: "limit", Amount: decimal.NewFromFloat(1), Price: decimalPtr(price), TwapDuration: 60, TwapSlices: 5, IcebergQty: qty(0.2)}, "", "cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderService.resolveAlgoOrder(tt.req)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
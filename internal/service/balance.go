@@ -2,15 +2,25 @@ package service
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
+// 现货杠杆借还款记录类型
+const (
+	LoanTypeBorrow      = "borrow"
+	LoanTypeRepay       = "repay"
+	LoanTypeLiquidation = "liquidation" // 强平时用账户资产归还或由保险基金核销
+)
+
 // BalanceService 余额管理服务
 type BalanceService struct {
 	db     *gorm.DB
@@ -27,6 +37,15 @@ func NewBalanceService(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *Bal
 	}
 }
 
+// WithTx 返回在指定事务中操作余额的服务，余额变动与调用方的其他修改一起提交或回滚
+func (s *BalanceService) WithTx(tx *gorm.DB) *BalanceService {
+	return &BalanceService{
+		db:     tx,
+		cfg:    s.cfg,
+		logger: s.logger,
+	}
+}
+
 // GetBalance 获取用户指定资产的余额
 func (s *BalanceService) GetBalance(userID uint, asset string) (*model.Balance, error) {
 	var balance model.Balance
@@ -51,7 +70,7 @@ func (s *BalanceService) GetAllBalances(userID uint) ([]model.Balance, error) {
 }
 
 // CheckBalance 检查用户是否有足够的可用余额
-func (s *BalanceService) CheckBalance(userID uint, asset string, amount float64) error {
+func (s *BalanceService) CheckBalance(userID uint, asset string, amount decimal.Decimal) error {
 	var balance model.Balance
 	if err := s.db.Where("user_id = ? AND asset = ?", userID, asset).First(&balance).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -60,17 +79,17 @@ func (s *BalanceService) CheckBalance(userID uint, asset string, amount float64)
 		return fmt.Errorf("failed to get balance: %w", err)
 	}
 
-	if balance.Available < amount {
-		return fmt.Errorf("insufficient balance: available %.8f, required %.8f", balance.Available, amount)
+	if balance.Available.LessThan(amount) {
+		return fmt.Errorf("insufficient balance: available %s, required %s", balance.Available, amount)
 	}
 
 	return nil
 }
 
 // FreezeBalance 冻结余额（从可用余额转到冻结余额）
-func (s *BalanceService) FreezeBalance(userID uint, asset string, amount float64) error {
+func (s *BalanceService) FreezeBalance(userID uint, asset string, amount decimal.Decimal) error {
 	// 1. 参数验证
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
 
@@ -88,13 +107,13 @@ func (s *BalanceService) FreezeBalance(userID uint, asset string, amount float64
 		}
 
 		// 检查可用余额是否足够
-		if balance.Available < amount {
-			return fmt.Errorf("insufficient balance: available %.8f, required %.8f", balance.Available, amount)
+		if balance.Available.LessThan(amount) {
+			return fmt.Errorf("insufficient balance: available %s, required %s", balance.Available, amount)
 		}
 
 		// 更新余额
-		balance.Available -= amount
-		balance.Locked += amount
+		balance.Available = balance.Available.Sub(amount)
+		balance.Locked = balance.Locked.Add(amount)
 
 		if err := tx.Save(&balance).Error; err != nil {
 			return fmt.Errorf("failed to freeze balance: %w", err)
@@ -103,7 +122,7 @@ func (s *BalanceService) FreezeBalance(userID uint, asset string, amount float64
 		s.logger.Info("Balance frozen",
 			zap.Uint("user_id", userID),
 			zap.String("asset", asset),
-			zap.Float64("amount", amount),
+			zap.Stringer("amount", amount),
 		)
 
 		return nil
@@ -111,9 +130,9 @@ func (s *BalanceService) FreezeBalance(userID uint, asset string, amount float64
 }
 
 // UnfreezeBalance 解冻余额（从冻结余额转回可用余额）
-func (s *BalanceService) UnfreezeBalance(userID uint, asset string, amount float64) error {
+func (s *BalanceService) UnfreezeBalance(userID uint, asset string, amount decimal.Decimal) error {
 	// 1. 参数验证
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
 
@@ -131,13 +150,13 @@ func (s *BalanceService) UnfreezeBalance(userID uint, asset string, amount float
 		}
 
 		// 检查冻结余额是否足够
-		if balance.Locked < amount {
-			return fmt.Errorf("insufficient locked balance: locked %.8f, required %.8f", balance.Locked, amount)
+		if balance.Locked.LessThan(amount) {
+			return fmt.Errorf("insufficient locked balance: locked %s, required %s", balance.Locked, amount)
 		}
 
 		// 更新余额
-		balance.Locked -= amount
-		balance.Available += amount
+		balance.Locked = balance.Locked.Sub(amount)
+		balance.Available = balance.Available.Add(amount)
 
 		if err := tx.Save(&balance).Error; err != nil {
 			return fmt.Errorf("failed to unfreeze balance: %w", err)
@@ -146,17 +165,29 @@ func (s *BalanceService) UnfreezeBalance(userID uint, asset string, amount float
 		s.logger.Info("Balance unfrozen",
 			zap.Uint("user_id", userID),
 			zap.String("asset", asset),
-			zap.Float64("amount", amount),
+			zap.Stringer("amount", amount),
 		)
 
 		return nil
 	})
 }
 
+// AdjustFrozenBalance 按差额调整冻结余额：delta 为正时从可用余额冻结，为负时解冻
+func (s *BalanceService) AdjustFrozenBalance(userID uint, asset string, delta decimal.Decimal) error {
+	switch {
+	case delta.IsPositive():
+		return s.FreezeBalance(userID, asset, delta)
+	case delta.IsNegative():
+		return s.UnfreezeBalance(userID, asset, delta.Neg())
+	default:
+		return nil
+	}
+}
+
 // DeductBalance 从冻结余额中扣除（通常用于订单成交）
-func (s *BalanceService) DeductBalance(userID uint, asset string, amount float64) error {
+func (s *BalanceService) DeductBalance(userID uint, asset string, amount decimal.Decimal) error {
 	// 1. 参数验证
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
 
@@ -174,12 +205,12 @@ func (s *BalanceService) DeductBalance(userID uint, asset string, amount float64
 		}
 
 		// 检查冻结余额是否足够
-		if balance.Locked < amount {
-			return fmt.Errorf("insufficient locked balance: locked %.8f, required %.8f", balance.Locked, amount)
+		if balance.Locked.LessThan(amount) {
+			return fmt.Errorf("insufficient locked balance: locked %s, required %s", balance.Locked, amount)
 		}
 
 		// 从冻结余额扣除
-		balance.Locked -= amount
+		balance.Locked = balance.Locked.Sub(amount)
 
 		if err := tx.Save(&balance).Error; err != nil {
 			return fmt.Errorf("failed to deduct balance: %w", err)
@@ -188,7 +219,7 @@ func (s *BalanceService) DeductBalance(userID uint, asset string, amount float64
 		s.logger.Info("Balance deducted",
 			zap.Uint("user_id", userID),
 			zap.String("asset", asset),
-			zap.Float64("amount", amount),
+			zap.Stringer("amount", amount),
 		)
 
 		return nil
@@ -196,9 +227,9 @@ func (s *BalanceService) DeductBalance(userID uint, asset string, amount float64
 }
 
 // AddBalance 增加可用余额（通常用于充值或订单成交收款）
-func (s *BalanceService) AddBalance(userID uint, asset string, amount float64) error {
+func (s *BalanceService) AddBalance(userID uint, asset string, amount decimal.Decimal) error {
 	// 1. 参数验证
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
 
@@ -214,7 +245,6 @@ func (s *BalanceService) AddBalance(userID uint, asset string, amount float64) e
 				UserID:    userID,
 				Asset:     asset,
 				Available: amount,
-				Locked:    0,
 			}
 			if err := tx.Create(&balance).Error; err != nil {
 				return fmt.Errorf("failed to create balance: %w", err)
@@ -223,13 +253,13 @@ func (s *BalanceService) AddBalance(userID uint, asset string, amount float64) e
 			s.logger.Info("Balance created and added",
 				zap.Uint("user_id", userID),
 				zap.String("asset", asset),
-				zap.Float64("amount", amount),
+				zap.Stringer("amount", amount),
 			)
 		} else if err != nil {
 			return fmt.Errorf("failed to get balance: %w", err)
 		} else {
 			// 余额存在，增加金额
-			balance.Available += amount
+			balance.Available = balance.Available.Add(amount)
 			if err := tx.Save(&balance).Error; err != nil {
 				return fmt.Errorf("failed to add balance: %w", err)
 			}
@@ -237,7 +267,7 @@ func (s *BalanceService) AddBalance(userID uint, asset string, amount float64) e
 			s.logger.Info("Balance added",
 				zap.Uint("user_id", userID),
 				zap.String("asset", asset),
-				zap.Float64("amount", amount),
+				zap.Stringer("amount", amount),
 			)
 		}
 
@@ -245,10 +275,42 @@ func (s *BalanceService) AddBalance(userID uint, asset string, amount float64) e
 	})
 }
 
+// DebitBalance 从可用余额扣除，允许扣为负数（用于永续合约资金费等按持仓结算的支出）
+func (s *BalanceService) DebitBalance(userID uint, asset string, amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var balance model.Balance
+		if err := tx.Where("user_id = ? AND asset = ?", userID, asset).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&balance).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("balance not found")
+			}
+			return fmt.Errorf("failed to lock balance: %w", err)
+		}
+
+		balance.Available = balance.Available.Sub(amount)
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed to debit balance: %w", err)
+		}
+
+		s.logger.Info("Balance debited",
+			zap.Uint("user_id", userID),
+			zap.String("asset", asset),
+			zap.Stringer("amount", amount),
+		)
+
+		return nil
+	})
+}
+
 // TransferBalance 在两个用户之间转账
-func (s *BalanceService) TransferBalance(fromUserID, toUserID uint, asset string, amount float64) error {
+func (s *BalanceService) TransferBalance(fromUserID, toUserID uint, asset string, amount decimal.Decimal) error {
 	// 1. 参数验证
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
 
@@ -302,12 +364,12 @@ func (s *BalanceService) TransferBalance(fromUserID, toUserID uint, asset string
 		if fromBalance.ID == 0 {
 			return fmt.Errorf("sender balance not found")
 		}
-		if fromBalance.Available < amount {
-			return fmt.Errorf("insufficient balance: available %.8f, required %.8f", fromBalance.Available, amount)
+		if fromBalance.Available.LessThan(amount) {
+			return fmt.Errorf("insufficient balance: available %s, required %s", fromBalance.Available, amount)
 		}
 
 		// 扣除发送方余额
-		fromBalance.Available -= amount
+		fromBalance.Available = fromBalance.Available.Sub(amount)
 		if err := tx.Save(&fromBalance).Error; err != nil {
 			return fmt.Errorf("failed to deduct sender balance: %w", err)
 		}
@@ -319,14 +381,13 @@ func (s *BalanceService) TransferBalance(fromUserID, toUserID uint, asset string
 				UserID:    toUserID,
 				Asset:     asset,
 				Available: amount,
-				Locked:    0,
 			}
 			if err := tx.Create(&toBalance).Error; err != nil {
 				return fmt.Errorf("failed to create receiver balance: %w", err)
 			}
 		} else {
 			// 接收方余额存在，增加金额
-			toBalance.Available += amount
+			toBalance.Available = toBalance.Available.Add(amount)
 			if err := tx.Save(&toBalance).Error; err != nil {
 				return fmt.Errorf("failed to add receiver balance: %w", err)
 			}
@@ -336,7 +397,7 @@ func (s *BalanceService) TransferBalance(fromUserID, toUserID uint, asset string
 			zap.Uint("from_user_id", fromUserID),
 			zap.Uint("to_user_id", toUserID),
 			zap.String("asset", asset),
-			zap.Float64("amount", amount),
+			zap.Stringer("amount", amount),
 		)
 
 		return nil
@@ -365,8 +426,8 @@ func (s *BalanceService) GetAllBalancesPaginated(page, limit int) ([]model.Balan
 }
 
 // DeductBalanceFromAvailable 从可用余额中直接扣除（管理员操作）
-func (s *BalanceService) DeductBalanceFromAvailable(userID uint, asset string, amount float64) (*model.Balance, error) {
-	if amount <= 0 {
+func (s *BalanceService) DeductBalanceFromAvailable(userID uint, asset string, amount decimal.Decimal) (*model.Balance, error) {
+	if !amount.IsPositive() {
 		return nil, fmt.Errorf("amount must be positive")
 	}
 
@@ -384,12 +445,12 @@ func (s *BalanceService) DeductBalanceFromAvailable(userID uint, asset string, a
 		}
 
 		// 检查可用余额是否足够
-		if balance.Available < amount {
-			return fmt.Errorf("insufficient balance: available %.8f, required %.8f", balance.Available, amount)
+		if balance.Available.LessThan(amount) {
+			return fmt.Errorf("insufficient balance: available %s, required %s", balance.Available, amount)
 		}
 
 		// 扣除可用余额
-		balance.Available -= amount
+		balance.Available = balance.Available.Sub(amount)
 
 		if err := tx.Save(&balance).Error; err != nil {
 			return fmt.Errorf("failed to deduct balance: %w", err)
@@ -398,7 +459,7 @@ func (s *BalanceService) DeductBalanceFromAvailable(userID uint, asset string, a
 		s.logger.Info("Balance deducted from available (admin)",
 			zap.Uint("user_id", userID),
 			zap.String("asset", asset),
-			zap.Float64("amount", amount),
+			zap.Stringer("amount", amount),
 		)
 
 		return nil
@@ -410,3 +471,153 @@ func (s *BalanceService) DeductBalanceFromAvailable(userID uint, asset string, a
 
 	return &balance, nil
 }
+
+// Borrow 借入资产：本金计入可用余额，同时记为借款
+func (s *BalanceService) Borrow(userID uint, asset string, amount decimal.Decimal) (*model.MarginLoan, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	loan := &model.MarginLoan{UserID: userID, Asset: asset, Type: LoanTypeBorrow, Amount: amount}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var balance model.Balance
+		result := tx.Where("user_id = ? AND asset = ?", userID, asset).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Limit(1).Find(&balance)
+		if result.Error != nil {
+			return fmt.Errorf("failed to lock balance: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			balance = model.Balance{UserID: userID, Asset: asset}
+		}
+
+		balance.Available = balance.Available.Add(amount)
+		balance.Borrowed = balance.Borrowed.Add(amount)
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed to borrow: %w", err)
+		}
+		if err := tx.Create(loan).Error; err != nil {
+			return fmt.Errorf("failed to record loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Margin borrowed",
+		zap.Uint("user_id", userID),
+		zap.String("asset", asset),
+		zap.Stringer("amount", amount),
+	)
+	return loan, nil
+}
+
+// Repay 用可用余额归还借款：先还利息再还本金，超过负债的部分不扣除
+func (s *BalanceService) Repay(userID uint, asset string, amount decimal.Decimal) (*model.MarginLoan, error) {
+	return s.repay(userID, asset, amount, LoanTypeRepay)
+}
+
+// repay 归还借款并按 loanType 记录
+func (s *BalanceService) repay(userID uint, asset string, amount decimal.Decimal, loanType string) (*model.MarginLoan, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	var loan *model.MarginLoan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var balance model.Balance
+		if err := tx.Where("user_id = ? AND asset = ?", userID, asset).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&balance).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("balance not found")
+			}
+			return fmt.Errorf("failed to lock balance: %w", err)
+		}
+
+		debt := balance.Borrowed.Add(balance.Interest)
+		if !debt.IsPositive() {
+			return fmt.Errorf("no outstanding %s loan", asset)
+		}
+		pay := decimal.Min(amount, debt)
+		if balance.Available.LessThan(pay) {
+			return fmt.Errorf("insufficient balance: available %s, required %s", balance.Available, pay)
+		}
+
+		interest := decimal.Min(pay, balance.Interest)
+		principal := pay.Sub(interest)
+		balance.Available = balance.Available.Sub(pay)
+		balance.Interest = balance.Interest.Sub(interest)
+		balance.Borrowed = balance.Borrowed.Sub(principal)
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed to repay: %w", err)
+		}
+
+		loan = &model.MarginLoan{UserID: userID, Asset: asset, Type: loanType, Amount: principal, Interest: interest}
+		if err := tx.Create(loan).Error; err != nil {
+			return fmt.Errorf("failed to record loan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Margin repaid",
+		zap.Uint("user_id", userID),
+		zap.String("asset", asset),
+		zap.String("type", loanType),
+		zap.Stringer("principal", loan.Amount),
+		zap.Stringer("interest", loan.Interest),
+	)
+	return loan, nil
+}
+
+// AccrueInterest 按小时利率计提 accruedAt 这一小时的借款利息（向上取整），
+// 同一小时重复调用或没有借款时返回 nil
+func (s *BalanceService) AccrueInterest(userID uint, asset string, rate decimal.Decimal, accruedAt time.Time) (*model.BorrowInterest, error) {
+	var record *model.BorrowInterest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var balance model.Balance
+		if err := tx.Where("user_id = ? AND asset = ?", userID, asset).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&balance).Error; err != nil {
+			return fmt.Errorf("failed to lock balance: %w", err)
+		}
+		if !balance.Borrowed.IsPositive() {
+			return nil
+		}
+
+		var accrued int64
+		if err := tx.Model(&model.BorrowInterest{}).
+			Where("user_id = ? AND asset = ? AND accrued_at = ?", userID, asset, accruedAt).
+			Count(&accrued).Error; err != nil {
+			return fmt.Errorf("failed to check borrow interest: %w", err)
+		}
+		if accrued > 0 {
+			return nil
+		}
+
+		record = &model.BorrowInterest{
+			UserID:    userID,
+			Asset:     asset,
+			Borrowed:  balance.Borrowed,
+			Rate:      rate,
+			Interest:  engine.CeilAsset(balance.Borrowed.Mul(rate)),
+			AccruedAt: accruedAt,
+		}
+		balance.Interest = balance.Interest.Add(record.Interest)
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed to accrue interest: %w", err)
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("failed to record borrow interest: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -27,7 +28,16 @@ func setupBenchDB() *gorm.DB {
 		&model.User{},
 		&model.Balance{},
 		&model.Order{},
+		&model.OrderList{},
 		&model.Trade{},
+		&model.UserFeeRate{},
+		&model.Position{},
+		&model.FundingRate{},
+		&model.FundingPayment{},
+		&model.Liquidation{},
+		&model.InsuranceFund{},
+		&model.MarginLoan{},
+		&model.BorrowInterest{},
 		&model.Ticker{},
 	)
 
@@ -52,8 +62,8 @@ func BenchmarkFreezeBalance(b *testing.B) {
 	balance := &model.Balance{
 		UserID:    user.ID,
 		Asset:     "USDT",
-		Available: 1000000.0,
-		Locked:    0,
+		Available: decimal.NewFromFloat(1000000.0),
+		Locked:    decimal.NewFromFloat(0),
 	}
 	db.Create(balance)
 
@@ -61,7 +71,7 @@ func BenchmarkFreezeBalance(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.FreezeBalance(user.ID, "USDT", 1.0)
+		service.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(1.0))
 	}
 }
 
@@ -82,8 +92,8 @@ func BenchmarkUnfreezeBalance(b *testing.B) {
 	balance := &model.Balance{
 		UserID:    user.ID,
 		Asset:     "USDT",
-		Available: 0,
-		Locked:    1000000.0,
+		Available: decimal.NewFromFloat(0),
+		Locked:    decimal.NewFromFloat(1000000.0),
 	}
 	db.Create(balance)
 
@@ -91,7 +101,7 @@ func BenchmarkUnfreezeBalance(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.UnfreezeBalance(user.ID, "USDT", 1.0)
+		service.UnfreezeBalance(user.ID, "USDT", decimal.NewFromFloat(1.0))
 	}
 }
 
@@ -112,8 +122,8 @@ func BenchmarkGetBalance(b *testing.B) {
 	balance := &model.Balance{
 		UserID:    user.ID,
 		Asset:     "USDT",
-		Available: 1000.0,
-		Locked:    0,
+		Available: decimal.NewFromFloat(1000.0),
+		Locked:    decimal.NewFromFloat(0),
 	}
 	db.Create(balance)
 
@@ -147,14 +157,14 @@ func BenchmarkTransferBalance(b *testing.B) {
 	}
 	db.Create(user2)
 
-	db.Create(&model.Balance{UserID: user1.ID, Asset: "USDT", Available: 1000000.0, Locked: 0})
-	db.Create(&model.Balance{UserID: user2.ID, Asset: "USDT", Available: 0, Locked: 0})
+	db.Create(&model.Balance{UserID: user1.ID, Asset: "USDT", Available: decimal.NewFromFloat(1000000.0), Locked: decimal.NewFromFloat(0)})
+	db.Create(&model.Balance{UserID: user2.ID, Asset: "USDT", Available: decimal.NewFromFloat(0), Locked: decimal.NewFromFloat(0)})
 
 	service := NewBalanceService(db, cfg, logger)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.TransferBalance(user1.ID, user2.ID, "USDT", 1.0)
+		service.TransferBalance(user1.ID, user2.ID, "USDT", decimal.NewFromFloat(1.0))
 	}
 }
 
@@ -175,8 +185,8 @@ func BenchmarkDeductBalance(b *testing.B) {
 	balance := &model.Balance{
 		UserID:    user.ID,
 		Asset:     "USDT",
-		Available: 0,
-		Locked:    1000000.0,
+		Available: decimal.NewFromFloat(0),
+		Locked:    decimal.NewFromFloat(1000000.0),
 	}
 	db.Create(balance)
 
@@ -184,7 +194,7 @@ func BenchmarkDeductBalance(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.DeductBalance(user.ID, "USDT", 1.0)
+		service.DeductBalance(user.ID, "USDT", decimal.NewFromFloat(1.0))
 	}
 }
 
@@ -205,8 +215,8 @@ func BenchmarkAddBalance(b *testing.B) {
 	balance := &model.Balance{
 		UserID:    user.ID,
 		Asset:     "USDT",
-		Available: 0,
-		Locked:    0,
+		Available: decimal.NewFromFloat(0),
+		Locked:    decimal.NewFromFloat(0),
 	}
 	db.Create(balance)
 
@@ -214,6 +224,6 @@ func BenchmarkAddBalance(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.AddBalance(user.ID, "USDT", 1.0)
+		service.AddBalance(user.ID, "USDT", decimal.NewFromFloat(1.0))
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/talkincode/quicksilver/internal/model"
@@ -41,7 +42,7 @@ func TestFreezeBalanceConcurrency(t *testing.T) {
 				defer wg.Done()
 				// 添加小延迟避免 SQLite 锁竞争
 				time.Sleep(time.Millisecond * 5)
-				err := service.FreezeBalance(user.ID, "USDT", 200.0)
+				err := service.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(200.0))
 				successChan <- (err == nil)
 			}()
 		}
@@ -64,11 +65,11 @@ func TestFreezeBalanceConcurrency(t *testing.T) {
 		// 验证最终余额状态：可用余额 + 冻结余额 = 1000
 		balance, err := service.GetBalance(user.ID, "USDT")
 		require.NoError(t, err)
-		total := balance.Available + balance.Locked
-		assert.Equal(t, 1000.0, total, "总余额应该保持 1000")
+		total := balance.Available.Add(balance.Locked)
+		assert.Equal(t, 1000.0, total.InexactFloat64(), "总余额应该保持 1000")
 
 		// 验证冻结金额是成功操作的倍数
-		assert.Equal(t, float64(successCount)*200.0, balance.Locked)
+		assert.Equal(t, float64(successCount)*200.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Concurrent freeze and unfreeze operations", func(t *testing.T) {
@@ -93,10 +94,10 @@ func TestFreezeBalanceConcurrency(t *testing.T) {
 				defer wg.Done()
 				if index%2 == 0 {
 					// 冻结 10
-					service.FreezeBalance(user.ID, "USDT", 10.0)
+					service.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(10.0))
 				} else {
 					// 解冻 10
-					service.UnfreezeBalance(user.ID, "USDT", 10.0)
+					service.UnfreezeBalance(user.ID, "USDT", decimal.NewFromFloat(10.0))
 				}
 			}(i)
 		}
@@ -106,8 +107,8 @@ func TestFreezeBalanceConcurrency(t *testing.T) {
 		// Then: 总余额应该不变
 		balance, err := service.GetBalance(user.ID, "USDT")
 		require.NoError(t, err)
-		total := balance.Available + balance.Locked
-		assert.Equal(t, 1000.0, total, "总余额应该保持 1000")
+		total := balance.Available.Add(balance.Locked)
+		assert.Equal(t, 1000.0, total.InexactFloat64(), "总余额应该保持 1000")
 	})
 
 	t.Run("Concurrent transfer operations", func(t *testing.T) {
@@ -134,13 +135,13 @@ func TestFreezeBalanceConcurrency(t *testing.T) {
 			// user1 -> user2
 			go func() {
 				defer wg.Done()
-				service.TransferBalance(user1.ID, user2.ID, "USDT", 5.0)
+				service.TransferBalance(user1.ID, user2.ID, "USDT", decimal.NewFromFloat(5.0))
 			}()
 
 			// user2 -> user1
 			go func() {
 				defer wg.Done()
-				service.TransferBalance(user2.ID, user1.ID, "USDT", 5.0)
+				service.TransferBalance(user2.ID, user1.ID, "USDT", decimal.NewFromFloat(5.0))
 			}()
 		}
 
@@ -152,8 +153,8 @@ func TestFreezeBalanceConcurrency(t *testing.T) {
 		balance2, err := service.GetBalance(user2.ID, "USDT")
 		require.NoError(t, err)
 
-		totalBalance := balance1.Available + balance2.Available
-		assert.InDelta(t, 1000.0, totalBalance, 0.01, "总余额应该保持不变")
+		totalBalance := balance1.Available.Add(balance2.Available)
+		assert.InDelta(t, 1000.0, totalBalance.InexactFloat64(), 0.01, "总余额应该保持不变")
 	})
 }
 
@@ -188,17 +189,17 @@ func TestDeadlockPrevention(t *testing.T) {
 
 			go func() {
 				defer wg.Done()
-				service.TransferBalance(users[0].ID, users[1].ID, "USDT", 10.0)
+				service.TransferBalance(users[0].ID, users[1].ID, "USDT", decimal.NewFromFloat(10.0))
 			}()
 
 			go func() {
 				defer wg.Done()
-				service.TransferBalance(users[1].ID, users[2].ID, "USDT", 10.0)
+				service.TransferBalance(users[1].ID, users[2].ID, "USDT", decimal.NewFromFloat(10.0))
 			}()
 
 			go func() {
 				defer wg.Done()
-				service.TransferBalance(users[2].ID, users[0].ID, "USDT", 10.0)
+				service.TransferBalance(users[2].ID, users[0].ID, "USDT", decimal.NewFromFloat(10.0))
 			}()
 		}
 
@@ -218,12 +219,12 @@ func TestDeadlockPrevention(t *testing.T) {
 		}
 
 		// Then: 总余额应该保持 3000
-		total := 0.0
+		total := decimal.Zero
 		for _, user := range users {
 			balance, err := service.GetBalance(user.ID, "USDT")
 			require.NoError(t, err)
-			total += balance.Available
+			total = total.Add(balance.Available)
 		}
-		assert.InDelta(t, 3000.0, total, 0.01)
+		assert.InDelta(t, 3000.0, total.InexactFloat64(), 0.01)
 	})
 }
@@ -3,6 +3,7 @@ package service
 import (
 	"testing"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -46,8 +47,8 @@ func TestGetBalance(t *testing.T) {
 		assert.NotNil(t, balance)
 		assert.Equal(t, user.ID, balance.UserID)
 		assert.Equal(t, "USDT", balance.Asset)
-		assert.Equal(t, 10000.0, balance.Available)
-		assert.Equal(t, 0.0, balance.Locked)
+		assert.Equal(t, 10000.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 0.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Get non-existent balance", func(t *testing.T) {
@@ -144,15 +145,15 @@ func TestFreezeBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When: 冻结 500 USDT
-		err := balanceService.FreezeBalance(user.ID, "USDT", 500.0)
+		err := balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// Then
 		require.NoError(t, err)
 
 		// 验证余额状态
 		balance, _ := balanceService.GetBalance(user.ID, "USDT")
-		assert.Equal(t, 9500.0, balance.Available)
-		assert.Equal(t, 500.0, balance.Locked)
+		assert.Equal(t, 9500.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 500.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Freeze balance with insufficient funds", func(t *testing.T) {
@@ -166,7 +167,7 @@ func TestFreezeBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 100.0)
 
 		// When: 尝试冻结超过可用余额
-		err := balanceService.FreezeBalance(user.ID, "USDT", 500.0)
+		err := balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// Then
 		require.Error(t, err)
@@ -174,8 +175,8 @@ func TestFreezeBalance(t *testing.T) {
 
 		// 验证余额未改变
 		balance, _ := balanceService.GetBalance(user.ID, "USDT")
-		assert.Equal(t, 100.0, balance.Available)
-		assert.Equal(t, 0.0, balance.Locked)
+		assert.Equal(t, 100.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 0.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Freeze negative amount", func(t *testing.T) {
@@ -189,7 +190,7 @@ func TestFreezeBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When: 尝试冻结负数金额
-		err := balanceService.FreezeBalance(user.ID, "USDT", -100.0)
+		err := balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(-100.0))
 
 		// Then
 		require.Error(t, err)
@@ -207,7 +208,7 @@ func TestFreezeBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When
-		err := balanceService.FreezeBalance(user.ID, "USDT", 0.0)
+		err := balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(0.0))
 
 		// Then
 		require.Error(t, err)
@@ -227,18 +228,18 @@ func TestUnfreezeBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 9500.0)
 
 		// 先冻结一些资金
-		balanceService.FreezeBalance(user.ID, "USDT", 500.0)
+		balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// When: 解冻 300 USDT
-		err := balanceService.UnfreezeBalance(user.ID, "USDT", 300.0)
+		err := balanceService.UnfreezeBalance(user.ID, "USDT", decimal.NewFromFloat(300.0))
 
 		// Then
 		require.NoError(t, err)
 
 		// 验证余额状态
 		balance, _ := balanceService.GetBalance(user.ID, "USDT")
-		assert.Equal(t, 9300.0, balance.Available)
-		assert.Equal(t, 200.0, balance.Locked)
+		assert.Equal(t, 9300.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 200.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Unfreeze more than locked", func(t *testing.T) {
@@ -250,10 +251,10 @@ func TestUnfreezeBalance(t *testing.T) {
 
 		user := testutil.SeedUser(t, db)
 		testutil.SeedBalance(t, db, user.ID, "USDT", 9500.0)
-		balanceService.FreezeBalance(user.ID, "USDT", 500.0)
+		balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// When: 尝试解冻超过已冻结金额
-		err := balanceService.UnfreezeBalance(user.ID, "USDT", 1000.0)
+		err := balanceService.UnfreezeBalance(user.ID, "USDT", decimal.NewFromFloat(1000.0))
 
 		// Then
 		require.Error(t, err)
@@ -261,8 +262,8 @@ func TestUnfreezeBalance(t *testing.T) {
 
 		// 验证余额未改变
 		balance, _ := balanceService.GetBalance(user.ID, "USDT")
-		assert.Equal(t, 9000.0, balance.Available)
-		assert.Equal(t, 500.0, balance.Locked)
+		assert.Equal(t, 9000.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 500.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Unfreeze negative amount", func(t *testing.T) {
@@ -276,7 +277,7 @@ func TestUnfreezeBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When
-		err := balanceService.UnfreezeBalance(user.ID, "USDT", -100.0)
+		err := balanceService.UnfreezeBalance(user.ID, "USDT", decimal.NewFromFloat(-100.0))
 
 		// Then
 		require.Error(t, err)
@@ -294,18 +295,18 @@ func TestDeductBalance(t *testing.T) {
 
 		user := testutil.SeedUser(t, db)
 		testutil.SeedBalance(t, db, user.ID, "USDT", 9500.0)
-		balanceService.FreezeBalance(user.ID, "USDT", 500.0)
+		balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// When: 从冻结余额扣除 300
-		err := balanceService.DeductBalance(user.ID, "USDT", 300.0)
+		err := balanceService.DeductBalance(user.ID, "USDT", decimal.NewFromFloat(300.0))
 
 		// Then
 		require.NoError(t, err)
 
 		// 验证余额状态
 		balance, _ := balanceService.GetBalance(user.ID, "USDT")
-		assert.Equal(t, 9000.0, balance.Available)
-		assert.Equal(t, 200.0, balance.Locked)
+		assert.Equal(t, 9000.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 200.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Deduct more than locked", func(t *testing.T) {
@@ -317,10 +318,10 @@ func TestDeductBalance(t *testing.T) {
 
 		user := testutil.SeedUser(t, db)
 		testutil.SeedBalance(t, db, user.ID, "USDT", 9500.0)
-		balanceService.FreezeBalance(user.ID, "USDT", 500.0)
+		balanceService.FreezeBalance(user.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// When: 尝试扣除超过冻结金额
-		err := balanceService.DeductBalance(user.ID, "USDT", 1000.0)
+		err := balanceService.DeductBalance(user.ID, "USDT", decimal.NewFromFloat(1000.0))
 
 		// Then
 		require.Error(t, err)
@@ -338,7 +339,7 @@ func TestDeductBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When
-		err := balanceService.DeductBalance(user.ID, "USDT", -100.0)
+		err := balanceService.DeductBalance(user.ID, "USDT", decimal.NewFromFloat(-100.0))
 
 		// Then
 		require.Error(t, err)
@@ -358,15 +359,15 @@ func TestAddBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When: 增加 5000 USDT
-		err := balanceService.AddBalance(user.ID, "USDT", 5000.0)
+		err := balanceService.AddBalance(user.ID, "USDT", decimal.NewFromFloat(5000.0))
 
 		// Then
 		require.NoError(t, err)
 
 		// 验证余额状态
 		balance, _ := balanceService.GetBalance(user.ID, "USDT")
-		assert.Equal(t, 15000.0, balance.Available)
-		assert.Equal(t, 0.0, balance.Locked)
+		assert.Equal(t, 15000.0, balance.Available.InexactFloat64())
+		assert.Equal(t, 0.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Add balance creates new record if not exists", func(t *testing.T) {
@@ -379,7 +380,7 @@ func TestAddBalance(t *testing.T) {
 		user := testutil.SeedUser(t, db)
 
 		// When: 为新资产添加余额
-		err := balanceService.AddBalance(user.ID, "BTC", 0.5)
+		err := balanceService.AddBalance(user.ID, "BTC", decimal.NewFromFloat(0.5))
 
 		// Then
 		require.NoError(t, err)
@@ -387,8 +388,8 @@ func TestAddBalance(t *testing.T) {
 		// 验证余额已创建
 		balance, err := balanceService.GetBalance(user.ID, "BTC")
 		require.NoError(t, err)
-		assert.Equal(t, 0.5, balance.Available)
-		assert.Equal(t, 0.0, balance.Locked)
+		assert.Equal(t, 0.5, balance.Available.InexactFloat64())
+		assert.Equal(t, 0.0, balance.Locked.InexactFloat64())
 	})
 
 	t.Run("Add negative amount", func(t *testing.T) {
@@ -402,7 +403,7 @@ func TestAddBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When
-		err := balanceService.AddBalance(user.ID, "USDT", -100.0)
+		err := balanceService.AddBalance(user.ID, "USDT", decimal.NewFromFloat(-100.0))
 
 		// Then
 		require.Error(t, err)
@@ -434,17 +435,17 @@ func TestTransferBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user2.ID, "USDT", 5000.0)
 
 		// When: 从 user1 转账 1000 给 user2
-		err := balanceService.TransferBalance(user1.ID, user2.ID, "USDT", 1000.0)
+		err := balanceService.TransferBalance(user1.ID, user2.ID, "USDT", decimal.NewFromFloat(1000.0))
 
 		// Then
 		require.NoError(t, err)
 
 		// 验证余额
 		balance1, _ := balanceService.GetBalance(user1.ID, "USDT")
-		assert.Equal(t, 9000.0, balance1.Available)
+		assert.Equal(t, 9000.0, balance1.Available.InexactFloat64())
 
 		balance2, _ := balanceService.GetBalance(user2.ID, "USDT")
-		assert.Equal(t, 6000.0, balance2.Available)
+		assert.Equal(t, 6000.0, balance2.Available.InexactFloat64())
 	})
 
 	t.Run("Transfer with insufficient balance", func(t *testing.T) {
@@ -468,7 +469,7 @@ func TestTransferBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user2.ID, "USDT", 0.0)
 
 		// When: 尝试转账超过余额
-		err := balanceService.TransferBalance(user1.ID, user2.ID, "USDT", 500.0)
+		err := balanceService.TransferBalance(user1.ID, user2.ID, "USDT", decimal.NewFromFloat(500.0))
 
 		// Then
 		require.Error(t, err)
@@ -476,7 +477,7 @@ func TestTransferBalance(t *testing.T) {
 
 		// 验证余额未改变
 		balance1, _ := balanceService.GetBalance(user1.ID, "USDT")
-		assert.Equal(t, 100.0, balance1.Available)
+		assert.Equal(t, 100.0, balance1.Available.InexactFloat64())
 	})
 
 	t.Run("Transfer to same user", func(t *testing.T) {
@@ -490,7 +491,7 @@ func TestTransferBalance(t *testing.T) {
 		testutil.SeedBalance(t, db, user.ID, "USDT", 10000.0)
 
 		// When: 尝试转账给自己
-		err := balanceService.TransferBalance(user.ID, user.ID, "USDT", 1000.0)
+		err := balanceService.TransferBalance(user.ID, user.ID, "USDT", decimal.NewFromFloat(1000.0))
 
 		// Then
 		require.Error(t, err)
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/fee"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// FeeService 手续费服务：查询用户当前费率，管理员设置用户费率
+type FeeService struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	logger  *zap.Logger
+	markets *market.Registry
+}
+
+// NewFeeService 创建手续费服务
+func NewFeeService(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *FeeService {
+	return &FeeService{
+		db:      db,
+		cfg:     cfg,
+		logger:  logger,
+		markets: market.NewRegistry(db, cfg),
+	}
+}
+
+// TradingFees 用户在各交易对上的手续费率（所有交易对使用同一费率）
+type TradingFees struct {
+	Rates   *fee.Rates
+	Symbols []string
+}
+
+// SetFeeRateRequest 设置用户手续费率请求
+type SetFeeRateRequest struct {
+	MakerFeeRate *decimal.Decimal `json:"maker_fee_rate"`
+	TakerFeeRate *decimal.Decimal `json:"taker_fee_rate"`
+}
+
+// maxFeeRate 手续费率上限（不含）
+var maxFeeRate = decimal.NewFromFloat(0.1)
+
+// GetTradingFees 查询用户当前生效的手续费率和适用的交易对（未停用的交易对）
+func (s *FeeService) GetTradingFees(userID uint) (*TradingFees, error) {
+	rates, err := fee.NewSchedule(s.db, s.cfg).Rates(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	markets, err := s.markets.Enabled()
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(markets))
+	for _, m := range markets {
+		symbols = append(symbols, m.Symbol)
+	}
+
+	return &TradingFees{Rates: rates, Symbols: symbols}, nil
+}
+
+// SetUserFeeRate 设置用户手续费率，覆盖按成交额计算的等级费率
+func (s *FeeService) SetUserFeeRate(userID uint, req SetFeeRateRequest) (*model.UserFeeRate, error) {
+	if req.MakerFeeRate == nil || req.TakerFeeRate == nil {
+		return nil, fmt.Errorf("maker_fee_rate and taker_fee_rate are required")
+	}
+	for _, rate := range []decimal.Decimal{*req.MakerFeeRate, *req.TakerFeeRate} {
+		if rate.IsNegative() || rate.GreaterThanOrEqual(maxFeeRate) {
+			return nil, fmt.Errorf("fee rates must be between 0 and %s", maxFeeRate)
+		}
+	}
+
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	var override model.UserFeeRate
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&override).Error; err != nil {
+		return nil, fmt.Errorf("failed to query user fee rate: %w", err)
+	}
+	override.UserID = userID
+	override.MakerFeeRate = *req.MakerFeeRate
+	override.TakerFeeRate = *req.TakerFeeRate
+	if err := s.db.Save(&override).Error; err != nil {
+		return nil, fmt.Errorf("failed to save user fee rate: %w", err)
+	}
+
+	s.logger.Info("User fee rate set",
+		zap.Uint("user_id", userID),
+		zap.Stringer("maker_fee_rate", override.MakerFeeRate),
+		zap.Stringer("taker_fee_rate", override.TakerFeeRate),
+	)
+
+	return &override, nil
+}
+
+// DeleteUserFeeRate 删除用户手续费率，恢复按成交额计算的等级费率
+func (s *FeeService) DeleteUserFeeRate(userID uint) error {
+	result := s.db.Where("user_id = ?", userID).Delete(&model.UserFeeRate{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete user fee rate: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("user fee rate not found")
+	}
+
+	s.logger.Info("User fee rate deleted", zap.Uint("user_id", userID))
+	return nil
+}
+
+// SetFeeTokenPayment 开启或关闭以平台币支付手续费
+func (s *FeeService) SetFeeTokenPayment(userID uint, enabled bool) (*model.User, error) {
+	if enabled && !s.cfg.Trading.FeeToken.Enabled() {
+		return nil, fmt.Errorf("fee token payment is not enabled")
+	}
+
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err := s.db.Model(&user).Update("pay_fees_with_token", enabled).Error; err != nil {
+		return nil, fmt.Errorf("failed to update fee token payment: %w", err)
+	}
+	user.PayFeesWithToken = enabled
+
+	s.logger.Info("Fee token payment updated",
+		zap.Uint("user_id", userID),
+		zap.Bool("enabled", enabled),
+	)
+
+	return &user, nil
+}
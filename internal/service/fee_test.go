@@ -0,0 +1,133 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestUserFeeRate 测试管理员设置用户手续费率及其对成交手续费的影响
+func TestUserFeeRate(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	feeService := NewFeeService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+	bidPrice, askPrice := 50000.0, 50000.0
+	require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+
+	t.Run("Default rates apply to every market", func(t *testing.T) {
+		fees, err := feeService.GetTradingFees(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"BTC/USDT", "ETH/USDT"}, fees.Symbols)
+		assert.Equal(t, cfg.Trading.MakerFeeRate, fees.Rates.Maker)
+		assert.Equal(t, cfg.Trading.TakerFeeRate, fees.Rates.Taker)
+		assert.False(t, fees.Rates.Override)
+	})
+
+	t.Run("Override rate is charged on fills", func(t *testing.T) {
+		// Given: 管理员将 taker 费率设为 0.02%
+		_, err := feeService.SetUserFeeRate(user.ID, SetFeeRateRequest{
+			MakerFeeRate: decimalPtr(0),
+			TakerFeeRate: decimalPtr(0.0002),
+		})
+		require.NoError(t, err)
+
+		// When: 市价买入 0.1 BTC
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "market",
+			Amount: decimal.NewFromFloat(0.1),
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 按覆盖费率收取手续费
+		var trade model.Trade
+		require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+		assert.Equal(t, "0.00002", trade.Fee.String())
+
+		fees, err := feeService.GetTradingFees(user.ID)
+		require.NoError(t, err)
+		assert.True(t, fees.Rates.Override)
+		assert.Equal(t, 0.0002, fees.Rates.Taker)
+		assert.True(t, fees.Rates.Volume.IsPositive())
+	})
+
+	t.Run("Updating and deleting the override", func(t *testing.T) {
+		rate, err := feeService.SetUserFeeRate(user.ID, SetFeeRateRequest{
+			MakerFeeRate: decimalPtr(0.0001),
+			TakerFeeRate: decimalPtr(0.0003),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "0.0003", rate.TakerFeeRate.String())
+
+		var count int64
+		db.Model(&model.UserFeeRate{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		// When: 删除覆盖费率
+		require.NoError(t, feeService.DeleteUserFeeRate(user.ID))
+
+		// Then: 恢复基础费率
+		fees, err := feeService.GetTradingFees(user.ID)
+		require.NoError(t, err)
+		assert.False(t, fees.Rates.Override)
+		assert.Equal(t, cfg.Trading.TakerFeeRate, fees.Rates.Taker)
+
+		assert.Error(t, feeService.DeleteUserFeeRate(user.ID))
+	})
+
+	t.Run("Invalid requests are rejected", func(t *testing.T) {
+		_, err := feeService.SetUserFeeRate(user.ID, SetFeeRateRequest{TakerFeeRate: decimalPtr(0.001)})
+		assert.Error(t, err)
+
+		_, err = feeService.SetUserFeeRate(user.ID, SetFeeRateRequest{MakerFeeRate: decimalPtr(-0.001), TakerFeeRate: decimalPtr(0.001)})
+		assert.Error(t, err)
+
+		_, err = feeService.SetUserFeeRate(user.ID+100, SetFeeRateRequest{MakerFeeRate: decimalPtr(0), TakerFeeRate: decimalPtr(0)})
+		require.Error(t, err)
+		assert.Equal(t, "user not found", err.Error())
+	})
+}
+
+// TestSetFeeTokenPayment 测试开启/关闭平台币抵扣手续费
+func TestSetFeeTokenPayment(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	feeService := NewFeeService(db, cfg, logger)
+	user := testutil.CreateTestUser(t, db)
+
+	t.Run("Rejected when no fee token is configured", func(t *testing.T) {
+		_, err := feeService.SetFeeTokenPayment(user.ID, true)
+		assert.Error(t, err)
+	})
+
+	t.Run("Enable and disable", func(t *testing.T) {
+		cfg.Trading.FeeToken = config.FeeTokenConfig{Asset: "QSX", Discount: 0.25}
+
+		updated, err := feeService.SetFeeTokenPayment(user.ID, true)
+		require.NoError(t, err)
+		assert.True(t, updated.PayFeesWithToken)
+
+		var reloaded model.User
+		require.NoError(t, db.First(&reloaded, user.ID).Error)
+		assert.True(t, reloaded.PayFeesWithToken)
+
+		updated, err = feeService.SetFeeTokenPayment(user.ID, false)
+		require.NoError(t, err)
+		assert.False(t, updated.PayFeesWithToken)
+	})
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 资金费率计算模式
+const (
+	FundingModeFixed   = "fixed"
+	FundingModePremium = "premium"
+	FundingModeReplay  = "replay"
+)
+
+// fundingRateScale 资金费率保留的小数位数（与数据库 decimal(20,8) 一致）
+const fundingRateScale = 8
+
+// FundingService 永续合约资金费服务：按周期计算资金费率，在多空持仓之间结算资金费
+type FundingService struct {
+	db             *gorm.DB
+	cfg            *config.Config
+	logger         *zap.Logger
+	markets        *market.Registry
+	balanceService *BalanceService
+
+	replayOnce  sync.Once
+	replayRates map[string][]decimal.Decimal
+	replayErr   error
+}
+
+// NewFundingService 创建资金费服务
+func NewFundingService(db *gorm.DB, cfg *config.Config, logger *zap.Logger, balanceService *BalanceService) *FundingService {
+	return &FundingService{
+		db:             db,
+		cfg:            cfg,
+		logger:         logger,
+		markets:        market.NewRegistry(db, cfg),
+		balanceService: balanceService,
+	}
+}
+
+// FundingRateInfo 交易对下一期的预测资金费率和上一期的结算记录
+type FundingRateInfo struct {
+	Current  *model.FundingRate // 按当前行情计算的下一期费率（未结算）
+	Previous *model.FundingRate // 最近一次结算的费率，尚未结算过时为 nil
+	Interval time.Duration
+}
+
+// interval 资金费结算周期，默认 8h
+func (s *FundingService) interval() time.Duration {
+	interval, err := time.ParseDuration(s.cfg.Trading.Funding.Interval)
+	if err != nil || interval <= 0 {
+		return 8 * time.Hour
+	}
+	return interval
+}
+
+// nextFundingTime 下一个结算时间（按周期对齐）
+func (s *FundingService) nextFundingTime(now time.Time) time.Time {
+	interval := s.interval()
+	return now.UTC().Truncate(interval).Add(interval)
+}
+
+// StartScheduler 启动资金费结算调度，在每个周期边界结算所有永续合约
+func (s *FundingService) StartScheduler() {
+	if !s.cfg.Trading.Funding.Enabled {
+		return
+	}
+
+	go func() {
+		for {
+			next := s.nextFundingTime(time.Now())
+			time.Sleep(time.Until(next))
+			if err := s.SettleFunding(next); err != nil {
+				s.logger.Error("Failed to settle funding", zap.Time("funding_time", next), zap.Error(err))
+			}
+		}
+	}()
+
+	s.logger.Info("Funding scheduler started",
+		zap.Duration("interval", s.interval()),
+		zap.String("mode", s.cfg.Trading.Funding.Mode),
+	)
+}
+
+// SettleFunding 结算 fundingTime 这一期所有永续合约的资金费，同一期重复调用不会重复结算
+func (s *FundingService) SettleFunding(fundingTime time.Time) error {
+	markets, err := s.markets.Enabled()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, mkt := range markets {
+		if !mkt.IsSwap() {
+			continue
+		}
+		if err := s.settleSymbol(mkt.Symbol, fundingTime.UTC()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mkt.Symbol, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// settleSymbol 记录交易对本期费率并结算所有持仓：费率为正时多头支付、空头收取，费率为负时相反
+func (s *FundingService) settleSymbol(symbol string, fundingTime time.Time) error {
+	rate, err := s.estimateRate(symbol, fundingTime)
+	if err != nil {
+		return err
+	}
+	_, _, settle := market.SplitSymbol(symbol)
+
+	var payments, failed int
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var settled int64
+		if err := tx.Model(&model.FundingRate{}).
+			Where("symbol = ? AND funding_time = ?", symbol, fundingTime).
+			Count(&settled).Error; err != nil {
+			return fmt.Errorf("failed to check funding rate: %w", err)
+		}
+		if settled > 0 {
+			return nil
+		}
+		if err := tx.Create(rate).Error; err != nil {
+			return fmt.Errorf("failed to save funding rate: %w", err)
+		}
+
+		var positions []model.Position
+		if err := tx.Where("symbol = ? AND size > 0", symbol).Order("id ASC").Find(&positions).Error; err != nil {
+			return fmt.Errorf("failed to query positions: %w", err)
+		}
+
+		// 每个持仓在独立的保存点中结算，单个持仓失败只跳过该持仓，不影响本期费率和其他持仓
+		for _, pos := range positions {
+			var paid bool
+			err := tx.Transaction(func(ptx *gorm.DB) error {
+				var err error
+				paid, err = s.settlePayment(ptx, pos, rate, settle)
+				return err
+			})
+			if err != nil {
+				s.logger.Error("Failed to settle funding for position, skipped",
+					zap.Uint("user_id", pos.UserID),
+					zap.String("symbol", symbol),
+					zap.Time("funding_time", fundingTime),
+					zap.Error(err),
+				)
+				failed++
+				continue
+			}
+			if paid {
+				payments++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Funding settled",
+		zap.String("symbol", symbol),
+		zap.Time("funding_time", fundingTime),
+		zap.Stringer("rate", rate.Rate),
+		zap.Int("payments", payments),
+		zap.Int("failed", failed),
+	)
+	return nil
+}
+
+// settlePayment 结算单个持仓本期的资金费并记录，资金费为 0 时返回 false
+func (s *FundingService) settlePayment(tx *gorm.DB, pos model.Position, rate *model.FundingRate, settle string) (bool, error) {
+	amount := pos.Size.Mul(rate.MarkPrice).Mul(rate.Rate).Round(engine.AssetScale)
+	if pos.Side == engine.PositionLong {
+		amount = amount.Neg()
+	}
+
+	var err error
+	balances := s.balanceService.WithTx(tx)
+	switch {
+	case amount.IsZero():
+		return false, nil
+	case pos.MarginMode == engine.MarginModeIsolated:
+		amount, err = settleIsolatedFunding(tx, &pos, settle, amount)
+	case amount.IsPositive():
+		err = balances.AddBalance(pos.UserID, settle, amount)
+	default:
+		err = balances.DebitBalance(pos.UserID, settle, amount.Neg())
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to settle funding for user %d: %w", pos.UserID, err)
+	}
+
+	if err := tx.Create(&model.FundingPayment{
+		UserID:      pos.UserID,
+		Symbol:      rate.Symbol,
+		Side:        pos.Side,
+		Size:        pos.Size,
+		Rate:        rate.Rate,
+		MarkPrice:   rate.MarkPrice,
+		Amount:      amount,
+		FundingTime: rate.FundingTime,
+	}).Error; err != nil {
+		return false, fmt.Errorf("failed to record funding payment: %w", err)
+	}
+	return true, nil
+}
+
+// settleIsolatedFunding 逐仓持仓的资金费计入仓位保证金并同步调整结算币冻结余额，不影响可用余额
+// 支出以仓位保证金为限，返回实际结算的金额
+func settleIsolatedFunding(tx *gorm.DB, pos *model.Position, settle string, amount decimal.Decimal) (decimal.Decimal, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(pos, pos.ID).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to lock position: %w", err)
+	}
+	var balance model.Balance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND asset = ?", pos.UserID, settle).
+		First(&balance).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("settle balance not found: %w", err)
+	}
+
+	amount = decimal.Max(amount, pos.Margin.Neg())
+	pos.Margin = pos.Margin.Add(amount)
+	balance.Locked = balance.Locked.Add(amount)
+	if err := tx.Save(pos).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to update position margin: %w", err)
+	}
+	if err := tx.Save(&balance).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to update settle balance: %w", err)
+	}
+	return amount, nil
+}
+
+// estimateRate 按配置的模式计算交易对在 fundingTime 的资金费率
+// premium 模式的溢价指数 = (盘口中间价 - 指数价格) / 指数价格，资金费按标记价格计算
+func (s *FundingService) estimateRate(symbol string, fundingTime time.Time) (*model.FundingRate, error) {
+	midPrice, indexPrice, err := perpetualPrices(s.db, symbol)
+	if err != nil {
+		return nil, err
+	}
+	markPrice := midPrice
+	if s.cfg.Trading.Perpetual.MarkPrice == MarkPriceIndex {
+		markPrice = indexPrice
+	}
+
+	cfg := &s.cfg.Trading.Funding
+	var rate decimal.Decimal
+	switch cfg.Mode {
+	case FundingModePremium:
+		premium := midPrice.Sub(indexPrice).Div(indexPrice)
+		rate = premium.Add(decimal.NewFromFloat(cfg.InterestRate))
+	case FundingModeReplay:
+		if rate, err = s.replayRate(symbol); err != nil {
+			return nil, err
+		}
+	case FundingModeFixed, "":
+		rate = decimal.NewFromFloat(cfg.Rate)
+	default:
+		return nil, fmt.Errorf("unknown funding mode %q", cfg.Mode)
+	}
+
+	if cfg.Cap > 0 {
+		limit := decimal.NewFromFloat(cfg.Cap)
+		rate = decimal.Max(decimal.Min(rate, limit), limit.Neg())
+	}
+
+	return &model.FundingRate{
+		Symbol:      symbol,
+		Rate:        rate.Round(fundingRateScale),
+		MarkPrice:   markPrice,
+		IndexPrice:  indexPrice,
+		FundingTime: fundingTime,
+	}, nil
+}
+
+// replayRate 回放模式：按交易对已结算的期数依次取费率文件中的下一条，用完后重复最后一条
+func (s *FundingService) replayRate(symbol string) (decimal.Decimal, error) {
+	s.replayOnce.Do(func() {
+		s.replayRates, s.replayErr = loadFundingReplay(s.cfg.Trading.Funding.ReplayFile)
+	})
+	if s.replayErr != nil {
+		return decimal.Zero, s.replayErr
+	}
+
+	rates := s.replayRates[symbol]
+	if len(rates) == 0 {
+		return decimal.Zero, fmt.Errorf("no replay funding rates for %s", symbol)
+	}
+
+	var settled int64
+	if err := s.db.Model(&model.FundingRate{}).Where("symbol = ?", symbol).Count(&settled).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to count funding rates: %w", err)
+	}
+	return rates[min(int(settled), len(rates)-1)], nil
+}
+
+// loadFundingReplay 读取资金费率回放文件：每行 symbol,rate，忽略空行和 # 开头的注释
+func loadFundingReplay(path string) (map[string][]decimal.Decimal, error) {
+	if path == "" {
+		return nil, fmt.Errorf("funding replay file not configured")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open funding replay file: %w", err)
+	}
+	defer file.Close()
+
+	rates := make(map[string][]decimal.Decimal)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		symbol, value, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid funding replay line %d: %q", line, text)
+		}
+		rate, err := decimal.NewFromString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid funding rate on line %d: %w", line, err)
+		}
+		symbol = strings.TrimSpace(symbol)
+		rates[symbol] = append(rates[symbol], rate)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read funding replay file: %w", err)
+	}
+	return rates, nil
+}
+
+// GetFundingRate 查询交易对的预测资金费率（CCXT fetchFundingRate）
+func (s *FundingService) GetFundingRate(symbol string) (*FundingRateInfo, error) {
+	mkt, ok, err := s.markets.Get(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !mkt.IsSwap() {
+		return nil, ccxt.NewError(ccxt.ErrBadSymbol, "%s is not a perpetual market", symbol)
+	}
+
+	now := time.Now()
+	current, err := s.estimateRate(symbol, s.nextFundingTime(now))
+	if err != nil {
+		return nil, err
+	}
+	current.CreatedAt = now
+
+	info := &FundingRateInfo{Current: current, Interval: s.interval()}
+	var previous model.FundingRate
+	result := s.db.Where("symbol = ?", symbol).Order("funding_time DESC").Limit(1).Find(&previous)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query funding rate: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		info.Previous = &previous
+	}
+	return info, nil
+}
+
+// GetFundingHistory 查询用户的资金费收支记录（CCXT fetchFundingHistory），按结算时间升序
+// 指定 since 时返回该时间之后的前 limit 条，否则返回最近的 limit 条
+func (s *FundingService) GetFundingHistory(userID uint, symbol string, since *time.Time, limit int) ([]model.FundingPayment, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := s.db.Where("user_id = ?", userID)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+
+	var payments []model.FundingPayment
+	if since != nil {
+		if err := query.Where("funding_time >= ?", since.UTC()).
+			Order("funding_time ASC, id ASC").
+			Limit(limit).
+			Find(&payments).Error; err != nil {
+			return nil, fmt.Errorf("failed to query funding history: %w", err)
+		}
+		return payments, nil
+	}
+
+	if err := query.Order("funding_time DESC, id DESC").Limit(limit).Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to query funding history: %w", err)
+	}
+	for i, j := 0, len(payments)-1; i < j; i, j = i+1, j-1 {
+		payments[i], payments[j] = payments[j], payments[i]
+	}
+	return payments, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestFundingSettlement 测试资金费率计算和多空持仓之间的资金费结算
+func TestFundingSettlement(t *testing.T) {
+	const symbol = "BTC/USDT:USDT"
+	fundingTime := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	setup := func(t *testing.T, funding config.FundingConfig) (*gorm.DB, *FundingService) {
+		db := testutil.NewTestDB(t)
+		cfg := testutil.NewTestConfig()
+		cfg.Market.Symbols = append(cfg.Market.Symbols, symbol)
+		cfg.Trading.Funding = funding
+		logger := testutil.NewTestLogger()
+
+		bidPrice, askPrice := 50000.0, 50010.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: symbol, LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+		return db, NewFundingService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+	}
+
+	openPosition := func(t *testing.T, db *gorm.DB, side string, size float64) *model.User {
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 1000.0, 500.0)
+		require.NoError(t, db.Create(&model.Position{
+			UserID:     user.ID,
+			Symbol:     symbol,
+			Side:       side,
+			Size:       decimal.NewFromFloat(size),
+			EntryPrice: decimal.NewFromInt(50000),
+			Leverage:   10,
+			MarginMode: "cross",
+			Margin:     decimal.NewFromInt(500),
+		}).Error)
+		return user
+	}
+
+	available := func(t *testing.T, db *gorm.DB, userID uint) string {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, "USDT").First(&balance).Error)
+		return balance.Available.String()
+	}
+
+	t.Run("Fixed rate: longs pay shorts once per funding time", func(t *testing.T) {
+		db, funding := setup(t, config.FundingConfig{Mode: "fixed", Rate: 0.0001})
+		long := openPosition(t, db, "long", 0.2)
+		short := openPosition(t, db, "short", 0.1)
+
+		// When: 按标记价格 50005 结算两次同一期
+		require.NoError(t, funding.SettleFunding(fundingTime))
+		require.NoError(t, funding.SettleFunding(fundingTime))
+
+		// Then: 多头支付 0.2 * 50005 * 0.0001 = 1.0001，空头收取 0.50005，只结算一次
+		assert.Equal(t, "998.9999", available(t, db, long.ID))
+		assert.Equal(t, "1000.50005", available(t, db, short.ID))
+
+		history, err := funding.GetFundingHistory(long.ID, symbol, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "-1.0001", history[0].Amount.String())
+		assert.Equal(t, "50005", history[0].MarkPrice.String())
+
+		var rates int64
+		require.NoError(t, db.Model(&model.FundingRate{}).Count(&rates).Error)
+		assert.Equal(t, int64(1), rates)
+	})
+
+	t.Run("A failed position does not roll back the funding period", func(t *testing.T) {
+		db, funding := setup(t, config.FundingConfig{Mode: "fixed", Rate: 0.0001})
+		long := openPosition(t, db, "long", 0.2)
+		short := openPosition(t, db, "short", 0.1)
+
+		// Given: 多头的结算币余额不存在，扣除资金费失败
+		require.NoError(t, db.Where("user_id = ?", long.ID).Delete(&model.Balance{}).Error)
+
+		// When: 结算本期资金费
+		require.NoError(t, funding.SettleFunding(fundingTime))
+
+		// Then: 多头被跳过，空头照常收取，本期费率已记录
+		history, err := funding.GetFundingHistory(long.ID, symbol, nil, 0)
+		require.NoError(t, err)
+		assert.Empty(t, history)
+		assert.Equal(t, "1000.50005", available(t, db, short.ID))
+
+		var rates int64
+		require.NoError(t, db.Model(&model.FundingRate{}).Count(&rates).Error)
+		assert.Equal(t, int64(1), rates)
+	})
+
+	t.Run("Isolated positions pay funding from the position margin", func(t *testing.T) {
+		db, funding := setup(t, config.FundingConfig{Mode: "fixed", Rate: 0.0001})
+		long := openPosition(t, db, "long", 0.2)
+		require.NoError(t, db.Model(&model.Position{}).Where("user_id = ?", long.ID).
+			Update("margin_mode", "isolated").Error)
+
+		// When: 按标记价格 50005 结算
+		require.NoError(t, funding.SettleFunding(fundingTime))
+
+		// Then: 资金费 1.0001 从仓位保证金和冻结余额中扣除，可用余额不变
+		var pos model.Position
+		require.NoError(t, db.Where("user_id = ?", long.ID).First(&pos).Error)
+		assert.Equal(t, "498.9999", pos.Margin.String())
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", long.ID, "USDT").First(&balance).Error)
+		assert.Equal(t, "1000", balance.Available.String())
+		assert.Equal(t, "498.9999", balance.Locked.String())
+
+		history, err := funding.GetFundingHistory(long.ID, symbol, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "-1.0001", history[0].Amount.String())
+	})
+
+	t.Run("Premium rate follows the spot index and is capped", func(t *testing.T) {
+		db, funding := setup(t, config.FundingConfig{Mode: "premium", InterestRate: 0.0001, Cap: 0.0005})
+
+		// Given: 现货指数 49995，溢价 (50005 - 49995) / 49995 ≈ 0.0002
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 49995.0}).Error)
+
+		info, err := funding.GetFundingRate(symbol)
+		require.NoError(t, err)
+		assert.Equal(t, "0.00030002", info.Current.Rate.String())
+		assert.Equal(t, "49995", info.Current.IndexPrice.String())
+		assert.True(t, info.Current.FundingTime.After(time.Now()))
+		assert.Nil(t, info.Previous)
+
+		// When: 永续价格大幅高于现货
+		require.NoError(t, db.Model(&model.Ticker{}).Where("symbol = ?", "BTC/USDT").Update("last_price", 49000.0).Error)
+
+		// Then: 费率被限制在 0.0005
+		info, err = funding.GetFundingRate(symbol)
+		require.NoError(t, err)
+		assert.Equal(t, "0.0005", info.Current.Rate.String())
+
+		_, err = funding.GetFundingRate("BTC/USDT")
+		assert.Error(t, err)
+	})
+
+	t.Run("Replay file rates are used in order and the last one repeats", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "funding.csv")
+		require.NoError(t, os.WriteFile(path, []byte("# symbol,rate\nBTC/USDT:USDT,0.0002\nBTC/USDT:USDT,-0.0001\n"), 0o600))
+		db, funding := setup(t, config.FundingConfig{Mode: "replay", ReplayFile: path})
+		short := openPosition(t, db, "short", 1)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, funding.SettleFunding(fundingTime.Add(time.Duration(i)*8*time.Hour)))
+		}
+
+		// Then: 空头收取 10.001，再两次各支付 5.0005
+		history, err := funding.GetFundingHistory(short.ID, "", nil, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, "10.001", history[0].Amount.String())
+		assert.Equal(t, "-5.0005", history[1].Amount.String())
+		assert.Equal(t, "-5.0005", history[2].Amount.String())
+		assert.Equal(t, "1000", available(t, db, short.ID))
+
+		since := fundingTime.Add(8 * time.Hour)
+		history, err = funding.GetFundingHistory(short.ID, symbol, &since, 1)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.True(t, history[0].FundingTime.Equal(since))
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"gorm.io/gorm/clause"
 
 	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
@@ -35,10 +36,11 @@ func (r *jsonReader) Read(p []byte) (n int, err error) {
 
 // KlineService K线数据服务
 type KlineService struct {
-	db     *gorm.DB
-	cfg    *config.Config
-	logger *zap.Logger
-	client *http.Client
+	db      *gorm.DB
+	cfg     *config.Config
+	logger  *zap.Logger
+	client  *http.Client
+	markets *market.Registry
 
 	ensureIndexesOnce sync.Once
 }
@@ -46,10 +48,11 @@ type KlineService struct {
 // NewKlineService 创建K线服务
 func NewKlineService(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *KlineService {
 	return &KlineService{
-		db:     db,
-		cfg:    cfg,
-		logger: logger,
-		client: &http.Client{Timeout: 10 * time.Second},
+		db:      db,
+		cfg:     cfg,
+		logger:  logger,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		markets: market.NewRegistry(db, cfg),
 	}
 }
 
@@ -98,10 +101,16 @@ type hyperliquidCandle struct {
 func (s *KlineService) updateHyperliquidKlines() error {
 	s.ensureKlineIndexes()
 
-	// 使用配置中的 symbols
+	// 使用交易对注册表中未停用的交易对
 	intervals := []string{"1m", "5m", "15m", "1h", "4h", "1d"}
 
-	for _, symbol := range s.cfg.Market.Symbols {
+	markets, err := s.markets.Enabled()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range markets {
+		symbol := m.Symbol
 		for _, interval := range intervals {
 			coin := s.convertSymbolToCoin(symbol)
 
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// LiquidationService 永续合约强平服务：按标记价格检查账户维持保证金，强平保证金不足的账户
+type LiquidationService struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	logger  *zap.Logger
+	markets *market.Registry
+	running sync.Mutex // 行情更新频繁触发检查，上一次检查未结束时跳过
+}
+
+// NewLiquidationService 创建强平服务
+func NewLiquidationService(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *LiquidationService {
+	return &LiquidationService{
+		db:      db,
+		cfg:     cfg,
+		logger:  logger,
+		markets: market.NewRegistry(db, cfg),
+	}
+}
+
+// MarginAccount 强平检查的保证金账户：用户在一个结算币下的全部全仓持仓，或单个逐仓持仓
+type MarginAccount struct {
+	UserID            uint
+	Settle            string
+	MarginMode        string
+	Positions         []model.Position
+	MarkPrices        map[string]decimal.Decimal
+	Equity            decimal.Decimal // 全仓：结算币余额（可用 + 冻结，不含逐仓保证金）+ 未实现盈亏；逐仓：仓位保证金 + 未实现盈亏
+	MaintenanceMargin decimal.Decimal // 持仓名义价值 * 维持保证金率之和
+	Available         decimal.Decimal // 逐仓：强平前（撤单后）的结算币可用余额，作为亏损结算的底线
+}
+
+// Liquidatable 账户权益是否已低于维持保证金
+func (a *MarginAccount) Liquidatable() bool {
+	return a.Equity.LessThan(a.MaintenanceMargin)
+}
+
+// CheckLiquidations 检查所有持仓账户，权益低于维持保证金时强平该结算币下的全部持仓
+func (s *LiquidationService) CheckLiquidations() error {
+	if !s.running.TryLock() {
+		return nil
+	}
+	defer s.running.Unlock()
+
+	accounts, err := s.MarginAccounts()
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		if !account.Liquidatable() {
+			continue
+		}
+		if err := s.liquidate(account); err != nil {
+			s.logger.Error("Failed to liquidate account",
+				zap.Uint("user_id", account.UserID),
+				zap.String("settle", account.Settle),
+				zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// MarginAccounts 按用户和结算币汇总全仓持仓，逐仓持仓各自作为一个账户，计算账户权益和维持保证金
+// 有持仓缺少标记价格的账户无法准确计算权益，跳过本次检查
+func (s *LiquidationService) MarginAccounts() ([]*MarginAccount, error) {
+	var positions []model.Position
+	if err := s.db.Where("size > 0").Order("user_id ASC, margin_mode ASC, symbol ASC").Find(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query positions: %w", err)
+	}
+
+	var accounts []*MarginAccount
+	for _, pos := range positions {
+		_, _, settle := market.SplitSymbol(pos.Symbol)
+		n := len(accounts)
+		if n == 0 || pos.MarginMode == engine.MarginModeIsolated || accounts[n-1].MarginMode == engine.MarginModeIsolated ||
+			accounts[n-1].UserID != pos.UserID || accounts[n-1].Settle != settle {
+			accounts = append(accounts, &MarginAccount{
+				UserID:     pos.UserID,
+				Settle:     settle,
+				MarginMode: pos.MarginMode,
+				MarkPrices: make(map[string]decimal.Decimal),
+			})
+		}
+		account := accounts[len(accounts)-1]
+		account.Positions = append(account.Positions, pos)
+	}
+
+	prices := make(map[string]decimal.Decimal)
+	result := make([]*MarginAccount, 0, len(accounts))
+	for _, account := range accounts {
+		if err := s.evaluate(account, prices); err != nil {
+			s.logger.Debug("Skip margin check",
+				zap.Uint("user_id", account.UserID),
+				zap.String("settle", account.Settle),
+				zap.Error(err))
+			continue
+		}
+		result = append(result, account)
+	}
+	return result, nil
+}
+
+// evaluate 按标记价格计算账户权益和维持保证金，prices 缓存本次检查已查询的标记价格
+func (s *LiquidationService) evaluate(account *MarginAccount, prices map[string]decimal.Decimal) error {
+	for i := range account.Positions {
+		pos := &account.Positions[i]
+		price, ok := prices[pos.Symbol]
+		if !ok {
+			var err error
+			if price, err = markPrice(s.db, s.cfg, pos.Symbol); err != nil {
+				return err
+			}
+			prices[pos.Symbol] = price
+		}
+
+		rate := s.cfg.Trading.Perpetual.MaintenanceRate(s.markets.ForSymbol(pos.Symbol).MaintenanceMarginRate.InexactFloat64())
+		account.MarkPrices[pos.Symbol] = price
+		account.Equity = account.Equity.Add(engine.UnrealizedPnl(pos, price))
+		account.MaintenanceMargin = account.MaintenanceMargin.Add(
+			pos.Size.Mul(price).Mul(decimal.NewFromFloat(rate)).Round(engine.AssetScale))
+	}
+
+	if account.MarginMode == engine.MarginModeIsolated {
+		account.Equity = account.Equity.Add(account.Positions[0].Margin)
+		return nil
+	}
+
+	var balance model.Balance
+	if err := s.db.Where("user_id = ? AND asset = ?", account.UserID, account.Settle).Limit(1).Find(&balance).Error; err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	// 逐仓保证金只承担对应持仓的亏损，不计入全仓权益
+	var isolated []model.Position
+	if err := s.db.Where("user_id = ? AND margin_mode = ? AND size > 0", account.UserID, engine.MarginModeIsolated).
+		Find(&isolated).Error; err != nil {
+		return fmt.Errorf("failed to query isolated positions: %w", err)
+	}
+	account.Equity = account.Equity.Add(balance.Available).Add(balance.Locked)
+	for _, pos := range isolated {
+		if _, _, settle := market.SplitSymbol(pos.Symbol); settle == account.Settle {
+			account.Equity = account.Equity.Sub(pos.Margin)
+		}
+	}
+	return nil
+}
+
+// liquidate 强平账户：撤销相关挂单，以市价单平掉全部持仓，结算强平手续费和穿仓亏损
+func (s *LiquidationService) liquidate(account *MarginAccount) error {
+	s.logger.Warn("Account below maintenance margin, liquidating",
+		zap.Uint("user_id", account.UserID),
+		zap.String("settle", account.Settle),
+		zap.Stringer("equity", account.Equity),
+		zap.Stringer("maintenance_margin", account.MaintenanceMargin))
+
+	matchEngine := engine.NewMatchingEngine(s.db, s.cfg, s.logger)
+	symbols := make([]string, 0, len(account.Positions))
+	for _, pos := range account.Positions {
+		symbols = append(symbols, pos.Symbol)
+	}
+	if err := matchEngine.CancelForLiquidation(account.UserID, symbols); err != nil {
+		return err
+	}
+	if account.MarginMode == engine.MarginModeIsolated {
+		var balance model.Balance
+		if err := s.db.Where("user_id = ? AND asset = ?", account.UserID, account.Settle).First(&balance).Error; err != nil {
+			return fmt.Errorf("settle balance not found: %w", err)
+		}
+		account.Available = balance.Available
+	}
+
+	orders := make([]*model.Order, 0, len(account.Positions))
+	for i := range account.Positions {
+		order, err := matchEngine.Liquidate(&account.Positions[i])
+		if err != nil {
+			return err
+		}
+		orders = append(orders, order)
+	}
+
+	return s.settleLiquidation(account, orders)
+}
+
+// settleLiquidation 记录强平事件：按成交金额收取强平手续费（不超过剩余可用余额）计入保险基金，
+// 全部持仓平仓后可用余额仍为负（穿仓）时由保险基金补足，亏损记录在最后一条强平记录上
+// 逐仓持仓以强平前的可用余额为底线：手续费只从剩余仓位保证金中收取，超出仓位保证金的亏损由保险基金承担
+func (s *LiquidationService) settleLiquidation(account *MarginAccount, orders []*model.Order) error {
+	floor := decimal.Zero
+	if account.MarginMode == engine.MarginModeIsolated {
+		floor = account.Available
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var balance model.Balance
+		if err := tx.Where("user_id = ? AND asset = ?", account.UserID, account.Settle).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&balance).Error; err != nil {
+			return fmt.Errorf("settle balance not found: %w", err)
+		}
+		fund, err := engine.LockInsuranceFund(tx, s.cfg, account.Settle)
+		if err != nil {
+			return err
+		}
+
+		feeRate := decimal.NewFromFloat(s.cfg.Trading.Liquidation.FeeRate)
+		records := make([]*model.Liquidation, 0, len(orders))
+		closed := true
+		for i, order := range orders {
+			// 撤单后持仓已平掉，没有下强平单
+			if order == nil {
+				continue
+			}
+			if order.Filled.LessThan(order.Amount) {
+				closed = false
+			}
+			if !order.Filled.IsPositive() {
+				continue
+			}
+
+			fee := decimal.Min(engine.FloorAsset(order.QuoteFilled.Mul(feeRate)), decimal.Max(balance.Available.Sub(floor), decimal.Zero))
+			balance.Available = balance.Available.Sub(fee)
+			fund.Balance = fund.Balance.Add(fee)
+
+			records = append(records, &model.Liquidation{
+				UserID:            account.UserID,
+				Symbol:            order.Symbol,
+				Side:              account.Positions[i].Side,
+				OrderID:           order.ID,
+				Size:              order.Filled,
+				Price:             *order.AveragePrice,
+				MarkPrice:         account.MarkPrices[order.Symbol],
+				Equity:            account.Equity,
+				MaintenanceMargin: account.MaintenanceMargin,
+				Fee:               fee,
+			})
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		if closed && balance.Available.LessThan(floor) {
+			covered := floor.Sub(balance.Available)
+			balance.Available = floor
+			fund.Balance = fund.Balance.Sub(covered)
+			records[len(records)-1].InsuranceCovered = covered
+
+			if fund.Balance.IsNegative() {
+				s.logger.Warn("Insurance fund depleted",
+					zap.String("asset", fund.Asset),
+					zap.Stringer("balance", fund.Balance))
+			}
+		}
+
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed to update settle balance: %w", err)
+		}
+		if err := tx.Save(fund).Error; err != nil {
+			return fmt.Errorf("failed to update insurance fund: %w", err)
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return fmt.Errorf("failed to record liquidation: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetLiquidations 查询用户的强平记录（CCXT fetchMyLiquidations），按时间升序
+func (s *LiquidationService) GetLiquidations(userID uint, symbol string, since *time.Time, limit int) ([]model.Liquidation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := s.db.Where("user_id = ?", userID)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	if since != nil {
+		query = query.Where("created_at >= ?", *since)
+	}
+
+	var liquidations []model.Liquidation
+	if err := query.Order("created_at ASC, id ASC").Limit(limit).Find(&liquidations).Error; err != nil {
+		return nil, fmt.Errorf("failed to query liquidations: %w", err)
+	}
+	return liquidations, nil
+}
+
+// GetInsuranceFunds 查询各结算币的保险基金余额
+func (s *LiquidationService) GetInsuranceFunds() ([]model.InsuranceFund, error) {
+	var funds []model.InsuranceFund
+	if err := s.db.Order("asset ASC").Find(&funds).Error; err != nil {
+		return nil, fmt.Errorf("failed to query insurance funds: %w", err)
+	}
+	return funds, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestLiquidation 测试按标记价格检查维持保证金、强平持仓和保险基金承担穿仓亏损
+func TestLiquidation(t *testing.T) {
+	const symbol = "BTC/USDT:USDT"
+
+	setup := func(t *testing.T) (*gorm.DB, *LiquidationService) {
+		db := testutil.NewTestDB(t)
+		cfg := testutil.NewTestConfig()
+		cfg.Market.Symbols = append(cfg.Market.Symbols, symbol)
+		cfg.Trading.Liquidation = config.LiquidationConfig{FeeRate: 0.005, InsuranceFund: 1000}
+		return db, NewLiquidationService(db, cfg, testutil.NewTestLogger())
+	}
+
+	setTicker := func(t *testing.T, db *gorm.DB, bid, ask float64) {
+		require.NoError(t, db.Save(&model.Ticker{Symbol: symbol, LastPrice: bid, BidPrice: &bid, AskPrice: &ask}).Error)
+	}
+
+	// openPosition 10 倍杠杆持仓 1 BTC @ 50000，仓位保证金 5000 计入冻结余额
+	openPosition := func(t *testing.T, db *gorm.DB, side string, available, locked float64) *model.User {
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", available, locked)
+		require.NoError(t, db.Create(&model.Position{
+			UserID:     user.ID,
+			Symbol:     symbol,
+			Side:       side,
+			Size:       decimal.NewFromInt(1),
+			EntryPrice: decimal.NewFromInt(50000),
+			Leverage:   10,
+			MarginMode: "cross",
+			Margin:     decimal.NewFromInt(5000),
+		}).Error)
+		return user
+	}
+
+	usdt := func(t *testing.T, db *gorm.DB, userID uint) model.Balance {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, "USDT").First(&balance).Error)
+		return balance
+	}
+
+	t.Run("Bankrupt long is closed and the insurance fund covers the shortfall", func(t *testing.T) {
+		db, liquidations := setup(t)
+
+		// Given: 多仓 1 BTC，另有一笔限价买单冻结保证金 400
+		user := openPosition(t, db, "long", 100, 5400)
+		openOrder := &model.Order{
+			UserID:   user.ID,
+			Symbol:   symbol,
+			Side:     "buy",
+			Type:     "limit",
+			Amount:   decimal.NewFromFloat(0.1),
+			Price:    decimalPtr(40000),
+			Leverage: 10,
+			Status:   "new",
+		}
+		require.NoError(t, db.Create(openOrder).Error)
+
+		// When: 标记价格 45000，权益 5500 - 5000 = 500 高于维持保证金 225
+		setTicker(t, db, 44990, 45010)
+		require.NoError(t, liquidations.CheckLiquidations())
+
+		// Then: 不强平
+		var pos model.Position
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&pos).Error)
+		assert.Equal(t, "1", pos.Size.String())
+
+		// When: 标记价格 44500，权益为 0
+		setTicker(t, db, 44490, 44510)
+		accounts, err := liquidations.MarginAccounts()
+		require.NoError(t, err)
+		require.Len(t, accounts, 1)
+		assert.Equal(t, "0", accounts[0].Equity.String())
+		assert.Equal(t, "222.5", accounts[0].MaintenanceMargin.String())
+		require.NoError(t, liquidations.CheckLiquidations())
+
+		// Then: 挂单被撤销，持仓按 bid 44490 市价平仓
+		require.NoError(t, db.First(openOrder, openOrder.ID).Error)
+		assert.Equal(t, "cancelled", openOrder.Status)
+		assert.Equal(t, engine.CancelReasonLiquidation, openOrder.CancelReason)
+
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&pos).Error)
+		assert.True(t, pos.Size.IsZero())
+
+		// 可用 = 500 + 5000（释放保证金）- 5510（平仓亏损）- 44.49（手续费）= -54.49，由保险基金补足
+		balance := usdt(t, db, user.ID)
+		assert.True(t, balance.Available.IsZero())
+		assert.True(t, balance.Locked.IsZero())
+
+		records, err := liquidations.GetLiquidations(user.ID, symbol, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, "long", records[0].Side)
+		assert.Equal(t, "44490", records[0].Price.String())
+		assert.Equal(t, "44500", records[0].MarkPrice.String())
+		assert.True(t, records[0].Fee.IsZero())
+		assert.Equal(t, "54.49", records[0].InsuranceCovered.String())
+
+		funds, err := liquidations.GetInsuranceFunds()
+		require.NoError(t, err)
+		require.Len(t, funds, 1)
+		assert.Equal(t, "945.51", funds[0].Balance.String())
+	})
+
+	t.Run("Remaining equity pays the liquidation fee", func(t *testing.T) {
+		db, liquidations := setup(t)
+		user := openPosition(t, db, "short", 1000, 5000)
+
+		// When: 标记价格 55900，权益 6000 - 5900 = 100 低于维持保证金 279.5
+		setTicker(t, db, 55890, 55910)
+		require.NoError(t, liquidations.CheckLiquidations())
+
+		// Then: 按 ask 55910 平空，剩余 34.09 全部作为强平手续费计入保险基金
+		records, err := liquidations.GetLiquidations(user.ID, "", nil, 0)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, "short", records[0].Side)
+		assert.Equal(t, "34.09", records[0].Fee.String())
+		assert.True(t, records[0].InsuranceCovered.IsZero())
+		assert.True(t, usdt(t, db, user.ID).Available.IsZero())
+
+		funds, err := liquidations.GetInsuranceFunds()
+		require.NoError(t, err)
+		require.Len(t, funds, 1)
+		assert.Equal(t, "1034.09", funds[0].Balance.String())
+	})
+
+	t.Run("Isolated position loses at most its own margin", func(t *testing.T) {
+		db, liquidations := setup(t)
+
+		// Given: 逐仓多仓 1 BTC，仓位保证金 5000，另有可用余额 1000
+		user := openPosition(t, db, "long", 1000, 5000)
+		require.NoError(t, db.Model(&model.Position{}).Where("user_id = ?", user.ID).
+			Update("margin_mode", engine.MarginModeIsolated).Error)
+
+		// When: 标记价格 45500，仓位权益 5000 - 4500 = 500 高于维持保证金 227.5（可用余额不计入）
+		setTicker(t, db, 45490, 45510)
+		accounts, err := liquidations.MarginAccounts()
+		require.NoError(t, err)
+		require.Len(t, accounts, 1)
+		assert.Equal(t, engine.MarginModeIsolated, accounts[0].MarginMode)
+		assert.Equal(t, "500", accounts[0].Equity.String())
+		require.NoError(t, liquidations.CheckLiquidations())
+
+		var pos model.Position
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&pos).Error)
+		assert.Equal(t, "1", pos.Size.String())
+
+		// When: 标记价格 44500，仓位权益为 0
+		setTicker(t, db, 44490, 44510)
+		require.NoError(t, liquidations.CheckLiquidations())
+
+		// Then: 按 bid 44490 平仓，超出仓位保证金的亏损 5510 - 5000 在成交结算时由保险基金承担，
+		// 手续费 44.49 在强平结算时由保险基金补足，可用余额不受影响
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&pos).Error)
+		assert.True(t, pos.Size.IsZero())
+
+		balance := usdt(t, db, user.ID)
+		assert.Equal(t, "1000", balance.Available.String())
+		assert.True(t, balance.Locked.IsZero())
+
+		records, err := liquidations.GetLiquidations(user.ID, symbol, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.True(t, records[0].Fee.IsZero())
+		assert.Equal(t, "44.49", records[0].InsuranceCovered.String())
+
+		funds, err := liquidations.GetInsuranceFunds()
+		require.NoError(t, err)
+		require.Len(t, funds, 1)
+		assert.Equal(t, "445.51", funds[0].Balance.String())
+	})
+}
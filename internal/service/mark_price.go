@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// 标记价格来源
+const (
+	MarkPriceMid   = "mid"
+	MarkPriceIndex = "index"
+)
+
+// perpetualPrices 查询永续合约的盘口中间价和指数价格
+// 指数价格为同名现货（BASE/QUOTE）的最新价，没有现货行情时等于中间价
+func perpetualPrices(db *gorm.DB, symbol string) (mid, index decimal.Decimal, err error) {
+	var ticker model.Ticker
+	if err := db.Where("symbol = ?", symbol).First(&ticker).Error; err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("ticker not found for %s: %w", symbol, err)
+	}
+	mid = engine.MarkPrice(&ticker)
+	if !mid.IsPositive() {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("no mark price for %s", symbol)
+	}
+
+	index = mid
+	base, quote, _ := market.SplitSymbol(symbol)
+	var spot model.Ticker
+	if err := db.Where("symbol = ?", base+"/"+quote).First(&spot).Error; err == nil && spot.LastPrice > 0 {
+		index = decimal.NewFromFloat(spot.LastPrice)
+	}
+	return mid, index, nil
+}
+
+// markPrice 按 trading.perpetual.mark_price 计算永续合约的标记价格（用于未实现盈亏、资金费和强平）
+func markPrice(db *gorm.DB, cfg *config.Config, symbol string) (decimal.Decimal, error) {
+	mid, index, err := perpetualPrices(db, symbol)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if cfg.Trading.Perpetual.MarkPrice == MarkPriceIndex {
+		return index, nil
+	}
+	return mid, nil
+}
@@ -10,12 +10,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"golang.org/x/sync/semaphore"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/talkincode/quicksilver/internal/config"
 	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
@@ -26,6 +29,9 @@ type MarketService struct {
 	logger            *zap.Logger
 	client            *http.Client
 	matchingSemaphore *semaphore.Weighted // 并发控制信号量
+	markets           *market.Registry
+	liquidations      *LiquidationService
+	spotMargin        *SpotMarginService
 }
 
 // NewMarketService 创建市场数据服务
@@ -38,6 +44,9 @@ func NewMarketService(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *Mark
 			Timeout: 10 * time.Second,
 		},
 		matchingSemaphore: semaphore.NewWeighted(10), // 最多 10 个并发撮合
+		markets:           market.NewRegistry(db, cfg),
+		liquidations:      NewLiquidationService(db, cfg, logger),
+		spotMargin:        NewSpotMarginService(db, cfg, logger, NewBalanceService(db, cfg, logger)),
 	}
 }
 
@@ -98,6 +107,19 @@ func (s *MarketService) UpdateTickers() error {
 		}
 	}()
 
+	// 按最新价格检查永续合约账户的维持保证金和现货杠杆账户的保证金水平，触发强平
+	go func() {
+		if liqErr := s.liquidations.CheckLiquidations(); liqErr != nil {
+			s.logger.Error("Failed to check liquidations", zap.Error(liqErr))
+		}
+		if !s.cfg.Trading.SpotMargin.Enabled() {
+			return
+		}
+		if liqErr := s.spotMargin.CheckMarginLevels(); liqErr != nil {
+			s.logger.Error("Failed to check margin levels", zap.Error(liqErr))
+		}
+	}()
+
 	return nil
 }
 
@@ -162,9 +184,15 @@ func (s *MarketService) updateHyperliquidTickers() error {
 		zap.Int("mids_count", len(midsResp)),
 	)
 
-	// 更新数据库
+	// 更新数据库（停用的交易对不再更新行情）
+	markets, err := s.markets.Enabled()
+	if err != nil {
+		return err
+	}
+
 	updatedCount := 0
-	for _, symbol := range s.cfg.Market.Symbols {
+	for _, m := range markets {
+		symbol := m.Symbol
 		// 转换交易对格式: BTC/USDT -> BTC
 		coin := convertSymbolToCoin(symbol)
 		s.logger.Debug("Processing symbol", zap.String("symbol", symbol), zap.String("coin", coin))
@@ -268,11 +296,12 @@ func convertSymbolToCoin(symbol string) string {
 	return symbol
 }
 
-// TriggerPendingOrdersMatching 触发未成交限价单的撮合
+// TriggerPendingOrdersMatching 触发未成交限价单及部分成交订单的撮合
 func (s *MarketService) TriggerPendingOrdersMatching() error {
-	// 查询所有未成交的限价单（添加索引优化）
+	// 查询所有未完全成交的限价单，以及因流动性不足而部分成交的市价单（添加索引优化）
 	var pendingOrders []model.Order
-	err := s.db.Where("status = ? AND type = ?", "new", "limit").
+	err := s.db.Where("(type = ? AND status IN ?) OR (type = ? AND status = ?)",
+		"limit", openOrderStatuses, "market", "partially_filled").
 		Order("created_at ASC"). // 按创建时间排序，先进先出
 		Find(&pendingOrders).Error
 	if err != nil {
@@ -333,7 +362,7 @@ func (s *MarketService) createMatchingEngine() *engine.MatchingEngine {
 func (s *MarketService) TriggerStopOrders() error {
 	// 查询所有未触发的止盈止损单
 	var stopOrders []model.Order
-	err := s.db.Where("status = ? AND type IN (?)", "new", []string{"stop_loss", "take_profit"}).
+	err := s.db.Where("status = ? AND type IN (?)", "new", stopOrderTypes).
 		Order("created_at ASC").
 		Find(&stopOrders).Error
 	if err != nil {
@@ -374,19 +403,35 @@ func (s *MarketService) checkAndTriggerStopOrder(orderID uint) {
 		return
 	}
 
-	// 检查触发条件
-	currentPrice := ticker.LastPrice
+	// 暂停交易或已停用的交易对不触发条件单
+	mkt := s.markets.ForSymbol(order.Symbol)
+	if !mkt.Active() {
+		return
+	}
+
+	// 检查触发条件（行情价格按交易对价格单位取整）
+	currentPrice := mkt.RoundTick(decimal.NewFromFloat(ticker.LastPrice))
 	if order.StopPrice == nil {
 		s.logger.Error("Stop price is null", zap.Uint("order_id", orderID))
 		return
 	}
 
+	// 跟踪止损：价格向有利方向移动时更新最优价和触发价
+	if order.Type == "trailing_stop" {
+		if err := s.updateTrailingStop(&order, currentPrice, mkt); err != nil {
+			s.logger.Error("Failed to update trailing stop",
+				zap.Uint("order_id", orderID),
+				zap.Error(err))
+			return
+		}
+	}
+
 	triggered := false
 	switch order.TriggerCondition {
 	case ">=":
-		triggered = currentPrice >= *order.StopPrice
+		triggered = currentPrice.GreaterThanOrEqual(*order.StopPrice)
 	case "<=":
-		triggered = currentPrice <= *order.StopPrice
+		triggered = currentPrice.LessThanOrEqual(*order.StopPrice)
 	default:
 		s.logger.Error("Invalid trigger condition",
 			zap.Uint("order_id", orderID),
@@ -397,56 +442,76 @@ func (s *MarketService) checkAndTriggerStopOrder(orderID uint) {
 	if !triggered {
 		s.logger.Debug("Stop order condition not met",
 			zap.Uint("order_id", orderID),
-			zap.Float64("current_price", currentPrice),
-			zap.Float64("stop_price", *order.StopPrice),
+			zap.Stringer("current_price", currentPrice),
+			zap.Stringer("stop_price", order.StopPrice),
 			zap.String("condition", order.TriggerCondition))
 		return
 	}
 
-	// 触发条件满足，创建市价单
+	// 触发条件满足，创建子订单（*_limit 类型为限价单，否则为市价单）
 	s.logger.Info("Stop order triggered",
 		zap.Uint("order_id", orderID),
 		zap.String("type", order.Type),
-		zap.Float64("current_price", currentPrice),
-		zap.Float64("stop_price", *order.StopPrice))
+		zap.Stringer("current_price", currentPrice),
+		zap.Stringer("stop_price", order.StopPrice))
+
+	childType := "market"
+	var childPrice *decimal.Decimal
+	if order.Type == "stop_loss_limit" || order.Type == "take_profit_limit" {
+		childType = "limit"
+		childPrice = order.Price
+	}
 
 	// 使用事务确保原子性
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// 1. 更新止盈止损单状态为 triggered
+		// 1. 更新止盈止损单状态为 triggered（仅限仍未触发的订单，避免与撤单竞争）
 		now := time.Now()
-		if err := tx.Model(&order).Updates(map[string]interface{}{
+		result := tx.Model(&order).Where("status = ?", "new").Updates(map[string]interface{}{
 			"status":       "triggered",
 			"triggered_at": now,
 			"updated_at":   now,
-		}).Error; err != nil {
-			return fmt.Errorf("failed to update stop order status: %w", err)
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update stop order status: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
 		}
 
-		// 2. 创建市价单（继承止盈止损单的参数）
-		marketOrder := &model.Order{
+		// 2. 创建子订单（继承止盈止损单的参数，冻结资金由子订单按父订单的冻结价格继续使用）
+		childOrder := &model.Order{
 			UserID:        order.UserID,
 			Symbol:        order.Symbol,
 			Side:          order.Side,
-			Type:          "market",
+			Type:          childType,
+			TimeInForce:   order.TimeInForce,
 			Status:        "new",
 			Amount:        order.Amount,
+			Price:         childPrice,
+			ReservePrice:  engine.ReservationPrice(&order),
 			ParentOrderID: &order.ID, // 关联父订单
 		}
 
-		if err := tx.Create(marketOrder).Error; err != nil {
-			return fmt.Errorf("failed to create market order: %w", err)
+		if err := tx.Create(childOrder).Error; err != nil {
+			return fmt.Errorf("failed to create %s order: %w", childType, err)
 		}
 
-		s.logger.Info("Market order created from stop order",
+		s.logger.Info("Child order created from stop order",
 			zap.Uint("parent_order_id", order.ID),
-			zap.Uint("market_order_id", marketOrder.ID))
+			zap.Uint("child_order_id", childOrder.ID),
+			zap.String("type", childType))
+
+		// 订单组：止损/止盈腿触发后撤销同组其他腿
+		if err := s.createMatchingEngine().ResolveOrderList(tx, &order); err != nil {
+			return fmt.Errorf("failed to resolve order list: %w", err)
+		}
 
 		// 3. 触发撮合引擎（在事务外异步执行）
 		go func() {
 			matchEngine := s.createMatchingEngine()
-			if err := matchEngine.MatchOrder(marketOrder.ID); err != nil {
-				s.logger.Error("Failed to match market order from stop order",
-					zap.Uint("order_id", marketOrder.ID),
+			if err := matchEngine.MatchOrder(childOrder.ID); err != nil {
+				s.logger.Error("Failed to match child order from stop order",
+					zap.Uint("order_id", childOrder.ID),
 					zap.Error(err))
 			}
 		}()
@@ -460,3 +525,81 @@ func (s *MarketService) checkAndTriggerStopOrder(orderID uint) {
 			zap.Error(err))
 	}
 }
+
+// updateTrailingStop 价格创新高（卖单）或新低（买单）时上移/下移跟踪止损触发价并持久化最优价
+// 买单触发价只会下移，冻结价格随触发价下移并解冻多冻结的计价币，保持冻结金额 = 数量 × 触发价（加滑点缓冲）
+func (s *MarketService) updateTrailingStop(order *model.Order, price decimal.Decimal, mkt *market.Market) error {
+	if order.Watermark != nil {
+		if order.Side == "sell" && price.LessThanOrEqual(*order.Watermark) {
+			return nil
+		}
+		if order.Side == "buy" && price.GreaterThanOrEqual(*order.Watermark) {
+			return nil
+		}
+	}
+
+	oldReserved := engine.ReservedFor(order, order.Amount)
+	newStop := trailingStopPrice(order, price, mkt)
+	moved := *order
+	moved.StopPrice = &newStop
+	if order.ReservePrice != nil {
+		reserveStopMarketBuy(s.cfg, &moved, mkt)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 按旧触发价条件更新，并发的行情协程只有一个能移动触发价并释放差额
+		query := tx.Model(&model.Order{}).Where("id = ? AND status = ?", order.ID, "new")
+		if order.StopPrice != nil {
+			query = query.Where("stop_price = ?", *order.StopPrice)
+		} else {
+			query = query.Where("stop_price IS NULL")
+		}
+		result := query.Updates(map[string]interface{}{
+			"watermark":     price,
+			"stop_price":    newStop,
+			"reserve_price": moved.ReservePrice,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update trailing stop: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		release := oldReserved.Sub(engine.ReservedFor(&moved, moved.Amount))
+		if order.Side == "buy" && release.IsPositive() {
+			_, quoteAsset, _ := market.SplitSymbol(order.Symbol)
+
+			var balance model.Balance
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND asset = ?", order.UserID, quoteAsset).
+				First(&balance).Error; err != nil {
+				return fmt.Errorf("balance not found: %w", err)
+			}
+			if balance.Locked.LessThan(release) {
+				return fmt.Errorf("locked %s balance %s is less than release amount %s", quoteAsset, balance.Locked, release)
+			}
+			balance.Locked = balance.Locked.Sub(release)
+			balance.Available = balance.Available.Add(release)
+			if err := tx.Save(&balance).Error; err != nil {
+				return fmt.Errorf("failed to unfreeze balance: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	order.Watermark = &price
+	order.StopPrice = &newStop
+	order.ReservePrice = moved.ReservePrice
+
+	s.logger.Debug("Trailing stop moved",
+		zap.Uint("order_id", order.ID),
+		zap.Stringer("watermark", price),
+		zap.Stringer("stop_price", newStop))
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// AddMarketRequest 新增交易对请求，未设置的规则使用默认值
+type AddMarketRequest struct {
+	Symbol      string           `json:"symbol"`
+	Base        string           `json:"base,omitempty"`
+	Quote       string           `json:"quote,omitempty"`
+	AmountStep  *decimal.Decimal `json:"amount_step,omitempty"`
+	PriceTick   *decimal.Decimal `json:"price_tick,omitempty"`
+	MinAmount   *decimal.Decimal `json:"min_amount,omitempty"`
+	MaxAmount   *decimal.Decimal `json:"max_amount,omitempty"`
+	MinNotional *decimal.Decimal `json:"min_notional,omitempty"`
+	MaxLeverage int              `json:"max_leverage,omitempty"` // 永续合约（BASE/QUOTE:SETTLE）的最大杠杆
+
+	MaintenanceMarginRate *decimal.Decimal `json:"maintenance_margin_rate,omitempty"` // 永续合约的维持保证金率
+}
+
+// ListMarkets 获取全部交易对（含暂停交易和已停用的交易对）
+func (s *MarketService) ListMarkets() ([]*market.Market, error) {
+	return s.markets.List()
+}
+
+// AddMarket 新增交易对，立即开放交易
+func (s *MarketService) AddMarket(req AddMarketRequest) (*market.Market, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if parts := strings.Split(symbol, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("symbol must be in BASE/QUOTE format")
+	}
+
+	if _, ok, err := s.markets.Get(symbol); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, fmt.Errorf("market already exists")
+	}
+
+	m := s.markets.Default(symbol)
+	if req.Base != "" {
+		m.Base = strings.ToUpper(req.Base)
+	}
+	if req.Quote != "" {
+		m.Quote = strings.ToUpper(req.Quote)
+	}
+	for _, field := range []struct {
+		value    *decimal.Decimal
+		target   *decimal.Decimal
+		positive bool
+	}{
+		{req.AmountStep, &m.AmountStep, true},
+		{req.PriceTick, &m.PriceTick, true},
+		{req.MinAmount, &m.MinAmount, false},
+		{req.MaxAmount, &m.MaxAmount, false},
+		{req.MinNotional, &m.MinNotional, false},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if field.value.IsNegative() || (field.positive && field.value.IsZero()) {
+			return nil, fmt.Errorf("amount_step and price_tick must be positive, limits must not be negative")
+		}
+		*field.target = *field.value
+	}
+	if m.MaxAmount.IsPositive() && m.MaxAmount.LessThan(m.MinAmount) {
+		return nil, fmt.Errorf("max_amount must not be less than min_amount")
+	}
+	if req.MaxLeverage < 0 || (req.MaxLeverage > 0 && !m.IsSwap()) {
+		return nil, fmt.Errorf("max_leverage must be positive and is only supported for perpetual markets")
+	}
+	m.MaxLeverage = req.MaxLeverage
+	if rate := req.MaintenanceMarginRate; rate != nil {
+		if !m.IsSwap() || !rate.IsPositive() || rate.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+			return nil, fmt.Errorf("maintenance_margin_rate must be between 0 and 1 and is only supported for perpetual markets")
+		}
+		m.MaintenanceMarginRate = *rate
+	}
+
+	if err := s.db.Create(m.ToModel()).Error; err != nil {
+		return nil, fmt.Errorf("failed to create market: %w", err)
+	}
+
+	s.logger.Info("Market added", zap.String("symbol", symbol))
+	return m, nil
+}
+
+// SetMarketStatus 修改交易对状态：停用(inactive)、暂停交易(halted，只允许撤单)、恢复(active)
+// 配置中定义的交易对首次修改时写入数据库，之后以数据库记录为准
+func (s *MarketService) SetMarketStatus(symbol, status string) (*market.Market, error) {
+	switch status {
+	case market.StatusActive, market.StatusHalted, market.StatusInactive:
+	default:
+		return nil, fmt.Errorf("invalid market status: %s", status)
+	}
+
+	m, ok, err := s.markets.Get(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("market not found")
+	}
+	m.Status = status
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Market{}).Where("symbol = ?", symbol).Update("status", status)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update market status: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Create(m.ToModel()).Error; err != nil {
+				return fmt.Errorf("failed to create market: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Market status updated",
+		zap.String("symbol", symbol),
+		zap.String("status", status))
+	return m, nil
+}
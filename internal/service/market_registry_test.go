@@ -0,0 +1,193 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestAddMarket 测试运行时新增交易对
+func TestAddMarket(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	marketService := NewMarketService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	t.Run("Add market and trade it immediately", func(t *testing.T) {
+		// When: 新增 SOL/USDT
+		m, err := marketService.AddMarket(AddMarketRequest{
+			Symbol:      "sol/usdt",
+			PriceTick:   decimalPtr(0.01),
+			MinNotional: decimalPtr(5),
+		})
+		require.NoError(t, err)
+
+		// Then: 交易对写入数据库，未设置的规则使用默认值
+		assert.Equal(t, "SOL/USDT", m.Symbol)
+		assert.Equal(t, "SOL", m.Base)
+		assert.Equal(t, "USDT", m.Quote)
+		assert.Equal(t, "0.01", m.PriceTick.String())
+		assert.Equal(t, "0.00000001", m.AmountStep.String())
+		assert.Equal(t, market.StatusActive, m.Status)
+
+		markets, err := marketService.ListMarkets()
+		require.NoError(t, err)
+		require.Len(t, markets, 3)
+		assert.Equal(t, "SOL/USDT", markets[2].Symbol)
+
+		// And: 可以立即下单
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "SOL/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromInt(1),
+			Price:  decimalPtr(100.005),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "100", order.Price.String())
+	})
+
+	t.Run("Duplicate market is rejected", func(t *testing.T) {
+		_, err := marketService.AddMarket(AddMarketRequest{Symbol: "BTC/USDT"})
+		require.Error(t, err)
+		assert.Equal(t, "market already exists", err.Error())
+	})
+
+	t.Run("Invalid definitions are rejected", func(t *testing.T) {
+		_, err := marketService.AddMarket(AddMarketRequest{Symbol: "DOGE"})
+		assert.Error(t, err)
+
+		_, err = marketService.AddMarket(AddMarketRequest{Symbol: "DOGE/USDT", AmountStep: decimalPtr(0)})
+		assert.Error(t, err)
+
+		_, err = marketService.AddMarket(AddMarketRequest{Symbol: "DOGE/USDT", MinAmount: decimalPtr(10), MaxAmount: decimalPtr(1)})
+		assert.Error(t, err)
+	})
+}
+
+// TestSetMarketStatus 测试暂停交易（只允许撤单）、恢复和停用交易对
+func TestSetMarketStatus(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	marketService := NewMarketService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+	testutil.CreateTestTicker(t, db, "BTC/USDT", 50000.0)
+
+	// Given: 低于市价的限价买单（暂不成交）
+	order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+		Symbol: "BTC/USDT",
+		Side:   "buy",
+		Type:   "limit",
+		Amount: decimal.NewFromFloat(0.1),
+		Price:  decimalPtr(49000),
+	})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("Halted market rejects new orders and does not match", func(t *testing.T) {
+		// When: 暂停 BTC/USDT
+		m, err := marketService.SetMarketStatus("BTC/USDT", market.StatusHalted)
+		require.NoError(t, err)
+		assert.Equal(t, market.StatusHalted, m.Status)
+
+		// Then: 新订单返回 MarketClosed
+		_, err = orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(49000),
+		})
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrMarketClosed, ccxt.ErrorCode(err))
+		assert.Contains(t, err.Error(), "halted")
+
+		// And: 价格下跌后挂单也不撮合
+		bidPrice, askPrice := 47990.0, 48000.0
+		require.NoError(t, db.Save(&model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 48000.0,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}).Error)
+		require.NoError(t, orderService.createMatchingEngine().MatchOrder(order.ID))
+
+		var reloaded model.Order
+		require.NoError(t, db.First(&reloaded, order.ID).Error)
+		assert.Equal(t, "new", reloaded.Status)
+	})
+
+	t.Run("Resumed market matches again", func(t *testing.T) {
+		// When: 恢复交易
+		_, err := marketService.SetMarketStatus("BTC/USDT", market.StatusActive)
+		require.NoError(t, err)
+		require.NoError(t, orderService.createMatchingEngine().MatchOrder(order.ID))
+
+		// Then: 挂单成交
+		var reloaded model.Order
+		require.NoError(t, db.First(&reloaded, order.ID).Error)
+		assert.Equal(t, "filled", reloaded.Status)
+	})
+
+	t.Run("Halted market still allows cancels", func(t *testing.T) {
+		// Given: 新的挂单，之后暂停交易
+		open, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(40000),
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+		_, err = marketService.SetMarketStatus("BTC/USDT", market.StatusHalted)
+		require.NoError(t, err)
+
+		// When/Then: 撤单成功
+		require.NoError(t, orderService.CancelOrder(user.ID, open.ID))
+	})
+
+	t.Run("Unknown market", func(t *testing.T) {
+		_, err := marketService.SetMarketStatus("DOGE/USDT", market.StatusHalted)
+		require.Error(t, err)
+		assert.Equal(t, "market not found", err.Error())
+	})
+
+	t.Run("Disabled market is excluded from ticker updates", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(HyperliquidAllMidsResponse{"BTC": "50000", "ETH": "3000"})
+		}))
+		defer server.Close()
+		cfg.Market.APIURL = server.URL
+
+		// When: 停用 ETH/USDT 后更新行情
+		_, err := marketService.SetMarketStatus("ETH/USDT", market.StatusInactive)
+		require.NoError(t, err)
+		require.NoError(t, marketService.UpdateTickers())
+
+		// Then: 只有未停用的交易对更新行情
+		var count int64
+		db.Model(&model.Ticker{}).Where("symbol = ?", "ETH/USDT").Count(&count)
+		assert.Zero(t, count)
+		db.Model(&model.Ticker{}).Where("symbol = ? AND last_price = ?", "BTC/USDT", 50000.0).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
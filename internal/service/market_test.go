@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -284,8 +285,8 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Price:  &limitPrice,
-			Amount: 0.1,
+			Price:  decimalPtr(limitPrice),
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		db.Create(order)
@@ -308,13 +309,13 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 		var updatedOrder model.Order
 		db.First(&updatedOrder, order.ID)
 		assert.Equal(t, "filled", updatedOrder.Status)
-		assert.Equal(t, 0.1, updatedOrder.Filled)
+		assert.Equal(t, 0.1, updatedOrder.Filled.InexactFloat64())
 
 		// 验证成交记录
 		var trade model.Trade
 		err = db.Where("order_id = ?", order.ID).First(&trade).Error
 		require.NoError(t, err)
-		assert.Equal(t, 49000.0, trade.Price)
+		assert.Equal(t, 49000.0, trade.Price.InexactFloat64())
 	})
 
 	t.Run("Do not trigger limit buy order when price is still high", func(t *testing.T) {
@@ -337,8 +338,8 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Price:  &limitPrice,
-			Amount: 0.1,
+			Price:  decimalPtr(limitPrice),
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		db.Create(order)
@@ -354,7 +355,7 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 		var updatedOrder model.Order
 		db.First(&updatedOrder, order.ID)
 		assert.Equal(t, "new", updatedOrder.Status)
-		assert.Equal(t, 0.0, updatedOrder.Filled)
+		assert.Equal(t, 0.0, updatedOrder.Filled.InexactFloat64())
 	})
 
 	t.Run("Trigger limit sell order when price rises", func(t *testing.T) {
@@ -379,8 +380,8 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "sell",
 			Type:   "limit",
-			Price:  &limitPrice,
-			Amount: 0.1,
+			Price:  decimalPtr(limitPrice),
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		db.Create(order)
@@ -401,7 +402,7 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 		var updatedOrder model.Order
 		db.First(&updatedOrder, order.ID)
 		assert.Equal(t, "filled", updatedOrder.Status)
-		assert.Equal(t, 0.1, updatedOrder.Filled)
+		assert.Equal(t, 0.1, updatedOrder.Filled.InexactFloat64())
 	})
 
 	t.Run("Only trigger limit orders, not market orders", func(t *testing.T) {
@@ -423,7 +424,7 @@ func TestTriggerPendingOrdersMatching(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		db.Create(marketOrder)
@@ -464,8 +465,8 @@ func TestUpdateTickersWithMatching(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Price:  &limitPrice,
-			Amount: 0.1,
+			Price:  decimalPtr(limitPrice),
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		db.Create(order)
@@ -496,7 +497,7 @@ func TestUpdateTickersWithMatching(t *testing.T) {
 		err = db.First(&updatedOrder, order.ID).Error
 		require.NoError(t, err, "Order should exist")
 
-		t.Logf("Order status: %s, filled: %.8f", updatedOrder.Status, updatedOrder.Filled)
+		t.Logf("Order status: %s, filled: %s", updatedOrder.Status, updatedOrder.Filled)
 		assert.Equal(t, "filled", updatedOrder.Status)
 	})
 }
@@ -1,35 +1,83 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/talkincode/quicksilver/internal/ccxt"
 	"github.com/talkincode/quicksilver/internal/config"
 	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
+// openOrderStatuses 未完成订单状态（可撤销、仍需撮合）
+var openOrderStatuses = []string{"new", "partially_filled"}
+
+// maxClientOrderIDLength clientOrderId 最大长度（与数据库字段长度一致）
+const maxClientOrderIDLength = 64
+
 // OrderService 订单管理服务
 type OrderService struct {
 	db             *gorm.DB
 	cfg            *config.Config
 	logger         *zap.Logger
 	balanceService *BalanceService
+	markets        *market.Registry
+	deferred       *[]func(*OrderService) // 批量事务中延迟到提交后执行的任务，为空时立即异步执行
 }
 
 // CreateOrderRequest 创建订单请求
+// 数量和价格使用精确小数，JSON 中既可以是数字也可以是字符串
 type CreateOrderRequest struct {
-	Symbol        string   `json:"symbol"`
-	Side          string   `json:"side"`   // buy | sell
-	Type          string   `json:"type"`   // market | limit
-	Amount        float64  `json:"amount"` // 数量
-	Price         *float64 `json:"price"`  // 价格（限价单必填）
-	ClientOrderID string   `json:"client_order_id,omitempty"`
+	Symbol             string           `json:"symbol"`
+	Side               string           `json:"side"`                      // buy | sell
+	Type               string           `json:"type"`                      // market | limit
+	Amount             decimal.Decimal  `json:"amount"`                    // 数量
+	Price              *decimal.Decimal `json:"price"`                     // 价格（限价单必填）
+	Cost               *decimal.Decimal `json:"cost,omitempty"`            // 按金额下单：市价买单花费的计价币数量（CCXT createMarketBuyOrderWithCost），设置时不填 amount
+	ClientOrderID      string           `json:"client_order_id,omitempty"` // 用户自定义订单ID，重复提交时返回原订单
+	ClientOrderIDParam string           `json:"clientOrderId,omitempty"`   // CCXT 参数名，同 client_order_id
+	TimeInForce        string           `json:"timeInForce,omitempty"`     // GTC(默认) | IOC | FOK | PO(等同 postOnly)
+	PostOnly           bool             `json:"postOnly,omitempty"`        // 只做 maker（仅限价单）
+
+	// 条件单参数（CCXT 风格），设置后创建止损/止盈单，type 决定触发后的子订单类型
+	StopPrice       *decimal.Decimal `json:"stopPrice,omitempty"`       // 止损触发价（同 triggerPrice）
+	TriggerPrice    *decimal.Decimal `json:"triggerPrice,omitempty"`    // 止损触发价
+	StopLossPrice   *decimal.Decimal `json:"stopLossPrice,omitempty"`   // 止损触发价
+	TakeProfitPrice *decimal.Decimal `json:"takeProfitPrice,omitempty"` // 止盈触发价
+	TrailingAmount  *decimal.Decimal `json:"trailingAmount,omitempty"`  // 跟踪止损固定回撤距离
+	TrailingPercent *decimal.Decimal `json:"trailingPercent,omitempty"` // 跟踪止损回撤百分比
+
+	// 算法单参数：设置 icebergQty 创建冰山单，设置 twapDuration/twapSlices 创建 TWAP 单
+	IcebergQty   *decimal.Decimal `json:"icebergQty,omitempty"`   // 冰山单每次显示的数量（仅限价单）
+	TwapDuration int              `json:"twapDuration,omitempty"` // TWAP 执行时长（秒）
+	TwapSlices   int              `json:"twapSlices,omitempty"`   // TWAP 切片数量
 }
 
+// acknowledge 执行模拟：下单确认延迟、随机拒单和交易对故障
+// 确认延迟期间会阻塞，必须在事务外调用，避免等待时持有余额和订单的行锁
+func (s *OrderService) acknowledge(symbol string) error {
+	if err := s.createMatchingEngine().Acknowledge(symbol); err != nil {
+		code := ccxt.ErrExchangeError
+		if errors.Is(err, engine.ErrSymbolOutage) {
+			code = ccxt.ErrExchangeNotAvailable
+		}
+		return fmt.Errorf("order rejected: %w", ccxt.WithCode(code, err))
+	}
+	return nil
+}
+
+// stopOrderTypes 条件单类型（*_limit 触发后生成限价单，否则生成市价单）
+var stopOrderTypes = []string{"stop_loss", "take_profit", "stop_loss_limit", "take_profit_limit", "trailing_stop"}
+
 // NewOrderService 创建订单服务
 func NewOrderService(db *gorm.DB, cfg *config.Config, logger *zap.Logger, balanceService *BalanceService) *OrderService {
 	return &OrderService{
@@ -37,18 +85,66 @@ func NewOrderService(db *gorm.DB, cfg *config.Config, logger *zap.Logger, balanc
 		cfg:            cfg,
 		logger:         logger,
 		balanceService: balanceService,
+		markets:        market.NewRegistry(db, cfg),
 	}
 }
 
 // CreateOrder 创建订单
+// 设置了 clientOrderId 且该用户已有相同 clientOrderId 的订单时，直接返回原订单（幂等重试）
 func (s *OrderService) CreateOrder(userID uint, req CreateOrderRequest) (*model.Order, error) {
-	// 1. 参数验证
+	return s.createOrder(userID, req, true)
+}
+
+// createOrder 创建订单，acknowledge 为 false 时调用方已在事务外完成下单确认（批量下单）
+func (s *OrderService) createOrder(userID uint, req CreateOrderRequest, acknowledge bool) (*model.Order, error) {
+	// 幂等：重复提交返回原订单
+	if req.ClientOrderID == "" {
+		req.ClientOrderID = req.ClientOrderIDParam
+	}
+	if len(req.ClientOrderID) > maxClientOrderIDLength {
+		return nil, ccxt.NewError(ccxt.ErrInvalidOrder, "invalid order request: clientOrderId is too long, maximum is %d characters", maxClientOrderIDLength)
+	}
+	if existing, err := s.findByClientOrderID(userID, req.ClientOrderID); err != nil || existing != nil {
+		return existing, err
+	}
+
+	// 1. 参数验证（先按交易对精度取整）
+	req = normalizeTimeInForce(req)
+	req = s.normalizePrecision(req)
 	if err := s.validateOrderRequest(req); err != nil {
-		return nil, fmt.Errorf("invalid order request: %w", err)
+		return nil, fmt.Errorf("invalid order request: %w", ccxt.WithCode(ccxt.ErrInvalidOrder, err))
 	}
 
-	// 2. 获取当前市场价格（用于市价单）
-	var currentPrice float64
+	if acknowledge {
+		if err := s.acknowledge(req.Symbol); err != nil {
+			return nil, err
+		}
+	}
+
+	// 条件单：冻结资金并等待触发，不进入撮合
+	if orderType, triggerPrice, _ := resolveStopOrder(req); orderType == "trailing_stop" {
+		return s.createTrailingStopOrder(userID, req)
+	} else if orderType != "" {
+		return s.createConditionalOrder(newConditionalOrder(userID, req, orderType, triggerPrice))
+	}
+
+	// 只做 maker 的订单会立即成交时直接拒绝
+	if req.PostOnly {
+		candidate := &model.Order{
+			UserID: userID,
+			Symbol: req.Symbol,
+			Side:   req.Side,
+			Type:   req.Type,
+			Price:  req.Price,
+		}
+		if err := s.createMatchingEngine().CheckPostOnly(candidate); err != nil {
+			return nil, fmt.Errorf("order rejected: %w", err)
+		}
+	}
+
+	// 2. 确定冻结价格：限价单使用用户指定价格，市价单使用当前市场价格（按价格精度向上取整），
+	// 市价买单再加上滑点缓冲，成交后按实际成交金额退还差额
+	var currentPrice decimal.Decimal
 	if req.Type == "market" {
 		var ticker model.Ticker
 		if err := s.db.Where("symbol = ?", req.Symbol).First(&ticker).Error; err != nil {
@@ -57,36 +153,75 @@ func (s *OrderService) CreateOrder(userID uint, req CreateOrderRequest) (*model.
 			}
 			return nil, fmt.Errorf("failed to get ticker: %w", err)
 		}
-		currentPrice = ticker.LastPrice
+		mkt := s.markets.ForSymbol(req.Symbol)
+		lastPrice := mkt.CeilPrice(decimal.NewFromFloat(ticker.LastPrice))
+		if req.Cost != nil {
+			// 按金额下单：数量按当前价格估算，实际成交以金额为上限
+			req.Amount = mkt.RoundAmount(req.Cost.Div(lastPrice))
+			if err := checkCostAmount(mkt, req.Amount); err != nil {
+				return nil, fmt.Errorf("invalid order request: %w", ccxt.WithCode(ccxt.ErrInvalidOrder, err))
+			}
+		} else if err := checkNotional(mkt, req.Amount, lastPrice); err != nil {
+			return nil, fmt.Errorf("invalid order request: %w", ccxt.WithCode(ccxt.ErrInvalidOrder, err))
+		}
+		currentPrice = lastPrice
+		if req.Side == "buy" {
+			currentPrice = marketBuyReservePrice(s.cfg, mkt, lastPrice)
+		}
 	} else {
-		// 限价单使用用户指定价格
 		currentPrice = *req.Price
 	}
 
-	// 3. 计算需要冻结的资金
+	// 3. 计算需要冻结的资金（永续合约按杠杆冻结保证金）
 	frozenAmount, frozenAsset := s.calculateFrozenAmount(req, currentPrice)
+	leverage, err := s.orderLeverage(userID, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if leverage > 0 {
+		frozenAmount, frozenAsset = engine.InitialMargin(req.Amount, currentPrice, leverage), s.getQuoteAsset(req.Symbol)
+		if err := s.checkPortfolioMargin(userID, req, frozenAmount); err != nil {
+			return nil, err
+		}
+	}
 
 	// 4. 检查余额并冻结资金
 	if err := s.balanceService.FreezeBalance(userID, frozenAsset, frozenAmount); err != nil {
 		return nil, fmt.Errorf("failed to freeze balance: %w", err)
 	}
 
-	// 5. 创建订单记录
+	// 5. 创建订单记录（算法单为父订单，由调度器下发子订单）
+	algoType, _ := s.resolveAlgoOrder(req)
 	order := &model.Order{
 		UserID:        userID,
 		ClientOrderID: req.ClientOrderID,
 		Symbol:        req.Symbol,
 		Side:          req.Side,
 		Type:          req.Type,
+		TimeInForce:   req.TimeInForce,
+		PostOnly:      req.PostOnly,
 		Amount:        req.Amount,
 		Price:         req.Price,
+		Leverage:      leverage,
 		Status:        "new",
-		Filled:        0,
+	}
+	if req.Cost != nil {
+		order.QuoteOrderQty = req.Cost
+	} else if req.Type == "market" && (req.Side == "buy" || leverage > 0) {
+		order.ReservePrice = &currentPrice
+	}
+	if algoType != "" {
+		applyAlgoParams(order, req, algoType)
 	}
 
-	if err := s.db.Create(order).Error; err != nil {
+	if existing, err := s.insertOrder(order); err != nil {
 		// 创建失败，解冻资金
 		_ = s.balanceService.UnfreezeBalance(userID, frozenAsset, frozenAmount)
+
+		// 并发重复提交：唯一索引冲突时返回先写入的订单
+		if existing != nil {
+			return existing, nil
+		}
 		s.logger.Error("Failed to create order",
 			zap.Uint("user_id", userID),
 			zap.String("symbol", req.Symbol),
@@ -101,20 +236,28 @@ func (s *OrderService) CreateOrder(userID uint, req CreateOrderRequest) (*model.
 		zap.String("symbol", order.Symbol),
 		zap.String("side", order.Side),
 		zap.String("type", order.Type),
-		zap.Float64("amount", order.Amount),
+		zap.Stringer("amount", order.Amount),
 	)
 
+	// 算法单立即下发第一个子订单
+	if algoType != "" {
+		s.afterCommit(func(svc *OrderService) {
+			svc.processAlgoOrder(order.ID)
+		})
+		return order, nil
+	}
+
 	// 触发撮合引擎（异步）
-	go func() {
+	s.afterCommit(func(svc *OrderService) {
 		// 延迟导入以避免循环依赖
-		engine := s.createMatchingEngine()
+		engine := svc.createMatchingEngine()
 		if err := engine.MatchOrder(order.ID); err != nil {
-			s.logger.Error("Failed to match order",
+			svc.logger.Error("Failed to match order",
 				zap.Uint("order_id", order.ID),
 				zap.Error(err),
 			)
 		}
-	}()
+	})
 
 	return order, nil
 }
@@ -132,6 +275,64 @@ func (s *OrderService) GetOrderByID(orderID uint) (*model.Order, error) {
 	return &order, nil
 }
 
+// GetOrderByClientOrderID 根据用户自定义订单ID获取订单
+func (s *OrderService) GetOrderByClientOrderID(userID uint, clientOrderID string) (*model.Order, error) {
+	if clientOrderID == "" {
+		return nil, fmt.Errorf("clientOrderId is required")
+	}
+	order, err := s.findByClientOrderID(userID, clientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ccxt.NewError(ccxt.ErrOrderNotFound, "order not found")
+	}
+	return order, nil
+}
+
+// CancelOrderByClientOrderID 根据用户自定义订单ID撤销订单，返回撤销后的订单
+func (s *OrderService) CancelOrderByClientOrderID(userID uint, clientOrderID string) (*model.Order, error) {
+	order, err := s.GetOrderByClientOrderID(userID, clientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.CancelOrder(userID, order.ID); err != nil {
+		return nil, err
+	}
+	return s.GetOrderByID(order.ID)
+}
+
+// findByClientOrderID 查询用户的自定义订单ID对应的订单，clientOrderID 为空或不存在时返回 nil
+func (s *OrderService) findByClientOrderID(userID uint, clientOrderID string) (*model.Order, error) {
+	if clientOrderID == "" {
+		return nil, nil
+	}
+	var order model.Order
+	result := s.db.Where("user_id = ? AND client_order_id = ?", userID, clientOrderID).Limit(1).Find(&order)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get order: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+// insertOrder 在保存点中写入订单，失败时先回滚保存点再按 clientOrderId 查询已有订单
+// 写入失败会中止所在事务（如 PostgreSQL 上的批量下单），回滚到保存点后事务才能继续查询
+func (s *OrderService) insertOrder(order *model.Order) (*model.Order, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(order).Error
+	})
+	if err == nil {
+		return nil, nil
+	}
+	if existing, findErr := s.findByClientOrderID(order.UserID, order.ClientOrderID); findErr == nil && existing != nil {
+		return existing, err
+	}
+	return nil, err
+}
+
 // GetUserOrders 获取用户订单列表（分页）
 func (s *OrderService) GetUserOrders(userID uint, page, pageSize int) ([]model.Order, int64, error) {
 	var orders []model.Order
@@ -161,7 +362,7 @@ func (s *OrderService) GetUserOrders(userID uint, page, pageSize int) ([]model.O
 func (s *OrderService) GetOpenOrders(userID uint) ([]model.Order, error) {
 	var orders []model.Order
 
-	if err := s.db.Where("user_id = ? AND status = ?", userID, "new").
+	if err := s.db.Where("user_id = ? AND status IN ?", userID, openOrderStatuses).
 		Order("created_at DESC").
 		Find(&orders).Error; err != nil {
 		return nil, fmt.Errorf("failed to get open orders: %w", err)
@@ -183,53 +384,63 @@ func (s *OrderService) CancelOrder(userID, orderID uint) error {
 		return fmt.Errorf("order does not belong to user")
 	}
 
-	// 3. 检查订单状态
-	if order.Status != "new" {
+	// 3. 检查订单状态（未成交、部分成交和未激活的订单组腿可以撤销）
+	if order.Status != "new" && order.Status != "partially_filled" && order.Status != "pending" {
 		return fmt.Errorf("cannot cancel order with status: %s", order.Status)
 	}
 
-	// 4. 计算需要解冻的资金
-	var frozenAmount float64
-	var frozenAsset string
+	// 订单组中的订单撤销整个订单组
+	if order.OrderListID != nil {
+		return s.CancelOrderList(userID, *order.OrderListID)
+	}
 
-	if order.Side == "buy" {
-		// 买单：解冻 USDT
-		frozenAsset = s.getQuoteAsset(order.Symbol)
-		if order.Type == "market" {
-			// 市价单：需要重新计算当时冻结的金额
-			// 这里简化处理，假设按当前价格计算
-			var ticker model.Ticker
-			if err := s.db.Where("symbol = ?", order.Symbol).First(&ticker).Error; err == nil {
-				frozenAmount = order.Amount * ticker.LastPrice
-			}
-		} else {
-			// 限价单：按限价计算
-			frozenAmount = order.Amount * (*order.Price)
+	// 算法单撤销父订单及未完成的子订单；子订单不能单独撤销
+	if isAlgoOrderType(order.Type) {
+		return s.cancelAlgoOrder(order)
+	}
+	if order.ParentOrderID != nil {
+		parent, err := s.GetOrderByID(*order.ParentOrderID)
+		if err == nil && isAlgoOrderType(parent.Type) {
+			return fmt.Errorf("order is a slice of algo order %d, cancel the parent instead", parent.ID)
 		}
-	} else {
-		// 卖单：解冻基础币
-		frozenAsset = s.getBaseAsset(order.Symbol)
-		frozenAmount = order.Amount
 	}
 
-	// 5. 更新订单状态
-	order.Status = "cancelled"
-	if err := s.db.Save(order).Error; err != nil {
-		s.logger.Error("Failed to update order status",
-			zap.Uint("order_id", orderID),
-			zap.Error(err),
-		)
-		return fmt.Errorf("failed to update order status: %w", err)
-	}
+	// 4. 锁定订单，按锁定后的剩余数量解冻资金并更新状态
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var locked model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&locked, orderID).Error; err != nil {
+			return fmt.Errorf("failed to lock order: %w", err)
+		}
+		// 加锁前订单可能已成交或被撤销
+		if locked.Status != "new" && locked.Status != "partially_filled" {
+			return fmt.Errorf("cannot cancel order with status: %s", locked.Status)
+		}
+
+		// 只解冻未成交部分
+		frozenAmount, frozenAsset := s.remainingReservation(&locked)
 
-	// 6. 解冻资金
-	if err := s.balanceService.UnfreezeBalance(userID, frozenAsset, frozenAmount); err != nil {
-		s.logger.Error("Failed to unfreeze balance",
+		now := time.Now()
+		locked.Status = "cancelled"
+		locked.CanceledAt = &now
+		if err := tx.Save(&locked).Error; err != nil {
+			return fmt.Errorf("failed to update order status: %w", err)
+		}
+
+		if frozenAmount.IsPositive() {
+			if err := s.balanceService.WithTx(tx).UnfreezeBalance(userID, frozenAsset, frozenAmount); err != nil {
+				return fmt.Errorf("failed to unfreeze balance: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to cancel order",
 			zap.Uint("order_id", orderID),
 			zap.Uint("user_id", userID),
 			zap.Error(err),
 		)
-		return fmt.Errorf("failed to unfreeze balance: %w", err)
+		return err
 	}
 
 	s.logger.Info("Order cancelled",
@@ -244,7 +455,11 @@ func (s *OrderService) CancelOrder(userID, orderID uint) error {
 func (s *OrderService) validateOrderRequest(req CreateOrderRequest) error {
 	// 1. 验证交易对
 	if req.Symbol == "" {
-		return fmt.Errorf("symbol is required")
+		return ccxt.NewError(ccxt.ErrBadSymbol, "symbol is required")
+	}
+	mkt, err := s.market(req.Symbol)
+	if err != nil {
+		return err
 	}
 
 	// 2. 验证方向
@@ -253,17 +468,22 @@ func (s *OrderService) validateOrderRequest(req CreateOrderRequest) error {
 	}
 
 	// 3. 验证订单类型
-	if req.Type != "market" && req.Type != "limit" {
-		return fmt.Errorf("type must be market or limit")
-	}
-
-	// 4. 验证数量
-	if req.Amount <= 0 {
-		return fmt.Errorf("amount must be positive")
+	if req.Type != "market" && req.Type != "limit" && req.Type != "trailing_stop" {
+		return fmt.Errorf("type must be market or limit, or trailing_stop")
 	}
 
-	if req.Amount < s.cfg.Trading.MinOrderAmount {
-		return fmt.Errorf("amount is too small, minimum is %.8f", s.cfg.Trading.MinOrderAmount)
+	// 4. 验证数量（按金额下单时验证金额，数量在获取行情后换算并验证）
+	if req.Cost != nil {
+		if err := validateCost(mkt, req); err != nil {
+			return err
+		}
+	} else {
+		if !req.Amount.IsPositive() {
+			return fmt.Errorf("amount must be positive")
+		}
+		if err := checkAmount(mkt, req.Amount); err != nil {
+			return err
+		}
 	}
 
 	// 5. 限价单必须提供价格
@@ -272,25 +492,365 @@ func (s *OrderService) validateOrderRequest(req CreateOrderRequest) error {
 	}
 
 	// 6. 限价单价格必须为正
-	if req.Type == "limit" && *req.Price <= 0 {
+	if req.Type == "limit" && !req.Price.IsPositive() {
 		return fmt.Errorf("price must be positive")
 	}
 
+	// 7. 验证有效方式
+	switch req.TimeInForce {
+	case "", engine.TimeInForceGTC, engine.TimeInForceIOC, engine.TimeInForceFOK:
+	default:
+		return fmt.Errorf("timeInForce must be GTC, IOC, FOK or PO")
+	}
+
+	// 8. 只做 maker 仅支持 GTC 限价单
+	if req.PostOnly {
+		if req.Type != "limit" {
+			return fmt.Errorf("postOnly is only supported for limit orders")
+		}
+		if req.TimeInForce == engine.TimeInForceIOC || req.TimeInForce == engine.TimeInForceFOK {
+			return fmt.Errorf("postOnly orders cannot be IOC or FOK")
+		}
+	}
+
+	// 9. 验证条件单参数
+	orderType, triggerPrice, err := resolveStopOrder(req)
+	if err != nil {
+		return err
+	}
+	if orderType != "" && req.PostOnly {
+		return fmt.Errorf("postOnly is not supported for stop orders")
+	}
+	if orderType != "" && req.Cost != nil {
+		return fmt.Errorf("cost is not supported for stop orders")
+	}
+
+	// 10. 验证最小名义价值（限价单按限价，条件市价单按触发价，市价单在获取行情后验证）
+	notionalPrice := triggerPrice
+	if req.Price != nil {
+		notionalPrice = *req.Price
+	}
+	if notionalPrice.IsPositive() {
+		if err := checkNotional(mkt, req.Amount, notionalPrice); err != nil {
+			return err
+		}
+	}
+
+	// 11. 验证算法单参数
+	algoType, err := s.resolveAlgoOrder(req)
+	if err != nil {
+		return err
+	}
+	if algoType != "" && orderType != "" {
+		return fmt.Errorf("algo orders cannot be combined with trigger prices")
+	}
+	if algoType != "" && req.Cost != nil {
+		return fmt.Errorf("cost is not supported for algo orders")
+	}
+
+	// 12. 永续合约只支持市价单和限价单
+	if mkt.IsSwap() && (orderType != "" || algoType != "" || req.Cost != nil) {
+		return fmt.Errorf("perpetual markets only support market and limit orders")
+	}
+
+	return nil
+}
+
+// validateCost 验证按金额下单的参数：仅支持市价买单，不能同时指定数量，金额不低于最小名义价值
+func validateCost(mkt *market.Market, req CreateOrderRequest) error {
+	if req.Type != "market" || req.Side != "buy" {
+		return fmt.Errorf("cost is only supported for market buy orders")
+	}
+	if !req.Amount.IsZero() {
+		return fmt.Errorf("amount and cost cannot both be set")
+	}
+	if req.TimeInForce == engine.TimeInForceFOK {
+		return fmt.Errorf("cost orders cannot be FOK")
+	}
+	if !req.Cost.IsPositive() {
+		return fmt.Errorf("cost must be positive")
+	}
+	if mkt.MinNotional.IsPositive() && req.Cost.LessThan(mkt.MinNotional) {
+		return fmt.Errorf("order notional is too small, minimum is %s %s", mkt.MinNotional, mkt.Quote)
+	}
+	return nil
+}
+
+// checkCostAmount 验证按金额换算出的数量
+func checkCostAmount(mkt *market.Market, amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return fmt.Errorf("cost is too small to buy %s at the current price", mkt.AmountStep)
+	}
+	return checkAmount(mkt, amount)
+}
+
+// marketBuyReservePrice 市价买单冻结价格：当前价格按滑点缓冲上浮，按价格单位向上取整
+func marketBuyReservePrice(cfg *config.Config, mkt *market.Market, price decimal.Decimal) decimal.Decimal {
+	if slippage := decimal.NewFromFloat(cfg.Trading.MarketBuySlippage); slippage.IsPositive() {
+		price = price.Mul(decimal.NewFromInt(1).Add(slippage))
+	}
+	return mkt.CeilPrice(price)
+}
+
+// reserveStopMarketBuy 市价条件买单（止损、止盈、跟踪止损）按触发价加滑点缓冲冻结，触发后子市价单沿用该冻结价格
+func reserveStopMarketBuy(cfg *config.Config, order *model.Order, mkt *market.Market) {
+	if order.Side != "buy" || order.Price != nil || order.StopPrice == nil {
+		return
+	}
+	reservePrice := marketBuyReservePrice(cfg, mkt, *order.StopPrice)
+	order.ReservePrice = &reservePrice
+}
+
+// resolveStopOrder 根据 CCXT 条件单参数确定条件单类型和触发价，未设置时返回空类型
+// stopPrice/triggerPrice/stopLossPrice 为止损，takeProfitPrice 为止盈；限价单对应 *_limit 类型；
+// trailingAmount/trailingPercent 为跟踪止损
+func resolveStopOrder(req CreateOrderRequest) (orderType string, triggerPrice decimal.Decimal, err error) {
+	// 跟踪止损：触发价由下单时的行情决定
+	if req.Type == "trailing_stop" || req.TrailingAmount != nil || req.TrailingPercent != nil {
+		return "trailing_stop", decimal.Zero, validateTrailingStop(req)
+	}
+
+	var stopLoss *decimal.Decimal
+	for _, p := range []*decimal.Decimal{req.StopPrice, req.TriggerPrice, req.StopLossPrice} {
+		if p == nil {
+			continue
+		}
+		if stopLoss != nil && !stopLoss.Equal(*p) {
+			return "", decimal.Zero, fmt.Errorf("conflicting stop prices")
+		}
+		stopLoss = p
+	}
+
+	switch {
+	case stopLoss != nil && req.TakeProfitPrice != nil:
+		return "", decimal.Zero, fmt.Errorf("stopLossPrice and takeProfitPrice cannot be combined in one order")
+	case stopLoss != nil:
+		orderType, triggerPrice = "stop_loss", *stopLoss
+	case req.TakeProfitPrice != nil:
+		orderType, triggerPrice = "take_profit", *req.TakeProfitPrice
+	default:
+		return "", decimal.Zero, nil
+	}
+
+	if !triggerPrice.IsPositive() {
+		return "", decimal.Zero, fmt.Errorf("trigger price must be positive")
+	}
+	if req.Type == "limit" {
+		orderType += "_limit"
+	}
+
+	return orderType, triggerPrice, nil
+}
+
+// validateTrailingStop 验证跟踪止损参数：回撤距离和百分比二选一，触发后下市价单
+func validateTrailingStop(req CreateOrderRequest) error {
+	if req.Type == "limit" {
+		return fmt.Errorf("trailing stop orders cannot be limit orders")
+	}
+	if req.StopPrice != nil || req.TriggerPrice != nil || req.StopLossPrice != nil || req.TakeProfitPrice != nil {
+		return fmt.Errorf("trailing stop cannot be combined with other trigger prices")
+	}
+	if (req.TrailingAmount == nil) == (req.TrailingPercent == nil) {
+		return fmt.Errorf("exactly one of trailingAmount or trailingPercent is required for trailing stop orders")
+	}
+	if req.TrailingAmount != nil && !req.TrailingAmount.IsPositive() {
+		return fmt.Errorf("trailingAmount must be positive")
+	}
+	if req.TrailingPercent != nil && (!req.TrailingPercent.IsPositive() || req.TrailingPercent.GreaterThanOrEqual(decimal.NewFromInt(100))) {
+		return fmt.Errorf("trailingPercent must be between 0 and 100")
+	}
+	return nil
+}
+
+// trailingStopPrice 根据最优价计算跟踪止损触发价（按价格精度四舍五入）
+// 卖单触发价 = 最高价 - 回撤，买单触发价 = 最低价 + 回撤
+func trailingStopPrice(order *model.Order, watermark decimal.Decimal, mkt *market.Market) decimal.Decimal {
+	delta := decimal.Zero
+	if order.TrailingDelta != nil {
+		delta = *order.TrailingDelta
+	} else if order.TrailingPercent != nil {
+		delta = watermark.Mul(*order.TrailingPercent).Div(decimal.NewFromInt(100))
+	}
+
+	if order.Side == "sell" {
+		return mkt.RoundTick(watermark.Sub(delta))
+	}
+	return mkt.RoundTick(watermark.Add(delta))
+}
+
+// triggerConditionFor 返回条件单的触发条件
+// 止损（含跟踪止损）：卖单价格 <= 触发价，买单价格 >= 触发价；止盈相反
+func triggerConditionFor(orderType, side string) string {
+	stopLoss := orderType != "take_profit" && orderType != "take_profit_limit"
+	if stopLoss == (side == "sell") {
+		return "<="
+	}
+	return ">="
+}
+
+// normalizeTimeInForce 规范化有效方式：默认 GTC，PO 转换为 postOnly
+func normalizeTimeInForce(req CreateOrderRequest) CreateOrderRequest {
+	req.TimeInForce = strings.ToUpper(req.TimeInForce)
+	if req.TimeInForce == "PO" {
+		req.PostOnly = true
+		req.TimeInForce = ""
+	}
+	if req.TimeInForce == "" {
+		req.TimeInForce = engine.TimeInForceGTC
+	}
+	return req
+}
+
+// market 查询可下单的交易对：未注册返回 BadSymbol，暂停交易或已停用返回 MarketClosed
+func (s *OrderService) market(symbol string) (*market.Market, error) {
+	mkt, ok, err := s.markets.Get(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ccxt.NewError(ccxt.ErrBadSymbol, "unknown symbol %s", symbol)
+	}
+	switch mkt.Status {
+	case market.StatusActive:
+		return mkt, nil
+	case market.StatusHalted:
+		return nil, ccxt.NewError(ccxt.ErrMarketClosed, "market %s is halted (cancel-only)", symbol)
+	default:
+		return nil, ccxt.NewError(ccxt.ErrMarketClosed, "market %s is disabled", symbol)
+	}
+}
+
+// checkAmount 验证数量是否在交易对的最小/最大数量范围内
+func checkAmount(mkt *market.Market, amount decimal.Decimal) error {
+	if amount.LessThan(mkt.MinAmount) {
+		return ccxt.NewError(ccxt.ErrInvalidOrder, "amount is too small, minimum is %s", mkt.MinAmount)
+	}
+	if mkt.MaxAmount.IsPositive() && amount.GreaterThan(mkt.MaxAmount) {
+		return ccxt.NewError(ccxt.ErrInvalidOrder, "amount is too large, maximum is %s", mkt.MaxAmount)
+	}
+	return nil
+}
+
+// checkNotional 验证名义价值（数量 × 价格）不低于交易对的最小名义价值
+func checkNotional(mkt *market.Market, amount, price decimal.Decimal) error {
+	if amount.Mul(price).LessThan(mkt.MinNotional) {
+		return ccxt.NewError(ccxt.ErrInvalidOrder, "order notional is too small, minimum is %s %s", mkt.MinNotional, mkt.Quote)
+	}
+	return nil
+}
+
+// normalizePrecision 按交易对步长和价格单位规范化下单参数
+// 数量（含冰山显示数量）向下截断，限价向保守方向取整，触发价和跟踪距离四舍五入
+func (s *OrderService) normalizePrecision(req CreateOrderRequest) CreateOrderRequest {
+	mkt := s.markets.ForSymbol(req.Symbol)
+
+	req.Amount = mkt.RoundAmount(req.Amount)
+	if req.Cost != nil {
+		cost := engine.FloorAsset(*req.Cost)
+		req.Cost = &cost
+	}
+	if req.Price != nil {
+		price := mkt.RoundPrice(req.Side, *req.Price)
+		req.Price = &price
+	}
+	if req.IcebergQty != nil {
+		qty := mkt.RoundAmount(*req.IcebergQty)
+		req.IcebergQty = &qty
+	}
+	for _, field := range []**decimal.Decimal{&req.StopPrice, &req.TriggerPrice, &req.StopLossPrice, &req.TakeProfitPrice, &req.TrailingAmount} {
+		if *field != nil {
+			rounded := mkt.RoundTick(**field)
+			*field = &rounded
+		}
+	}
+
+	return req
+}
+
+// remainingReservation 计算订单未成交部分对应的冻结资金
+// 卖单为未成交数量的基础币；买单按冻结价格（限价、下单时市价或触发价）计算的计价币
+func (s *OrderService) remainingReservation(order *model.Order) (amount decimal.Decimal, asset string) {
+	if order.Side == "sell" && !engine.IsPerpetual(order) {
+		return engine.RemainingReservation(order), s.getBaseAsset(order.Symbol)
+	}
+	return engine.RemainingReservation(order), s.getQuoteAsset(order.Symbol)
+}
+
+// orderLeverage 永续合约订单使用用户在该交易对设置的杠杆（未设置时为默认杠杆），现货订单返回 0
+func (s *OrderService) orderLeverage(userID uint, symbol string) (int, error) {
+	mkt := s.markets.ForSymbol(symbol)
+	if !mkt.IsSwap() {
+		return 0, nil
+	}
+
+	var pos model.Position
+	result := s.db.Where("user_id = ? AND symbol = ?", userID, symbol).Limit(1).Find(&pos)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to get position: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return pos.Leverage, nil
+	}
+	perp := &s.cfg.Trading.Perpetual
+	return perp.Leverage(perp.LeverageLimit(mkt.MaxLeverage)), nil
+}
+
+// checkPortfolioMargin 永续合约下单前的组合保证金检查：结算币可用余额加上全仓持仓按标记价格计算的未实现盈亏，
+// 须足以支付新订单的初始保证金（逐仓持仓的盈亏只影响其仓位保证金，不计入）；只减少已有持仓的订单不检查
+func (s *OrderService) checkPortfolioMargin(userID uint, req CreateOrderRequest, margin decimal.Decimal) error {
+	_, _, settle := market.SplitSymbol(req.Symbol)
+
+	var positions []model.Position
+	if err := s.db.Where("user_id = ? AND size > 0", userID).Find(&positions).Error; err != nil {
+		return fmt.Errorf("failed to query positions: %w", err)
+	}
+
+	pnl := decimal.Zero
+	for i := range positions {
+		pos := &positions[i]
+		if pos.Symbol == req.Symbol && reducesPosition(pos, req) {
+			return nil
+		}
+		if pos.MarginMode == engine.MarginModeIsolated {
+			continue
+		}
+		if _, _, posSettle := market.SplitSymbol(pos.Symbol); posSettle != settle {
+			continue
+		}
+		// 缺少行情时按开仓均价计算（未实现盈亏为 0）
+		if price, err := markPrice(s.db, s.cfg, pos.Symbol); err == nil {
+			pnl = pnl.Add(engine.UnrealizedPnl(pos, price))
+		}
+	}
+
+	var balance model.Balance
+	if err := s.db.Where("user_id = ? AND asset = ?", userID, settle).Limit(1).Find(&balance).Error; err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+	if free := balance.Available.Add(pnl); free.LessThan(margin) {
+		return ccxt.NewError(ccxt.ErrInsufficientFunds,
+			"insufficient margin: available %s, unrealized pnl %s, required %s", balance.Available, pnl, margin)
+	}
 	return nil
 }
 
-// calculateFrozenAmount 计算需要冻结的资金数量和币种
-func (s *OrderService) calculateFrozenAmount(req CreateOrderRequest, price float64) (amount float64, asset string) {
+// reducesPosition 订单是否与持仓方向相反且数量不超过持仓（只减仓）
+func reducesPosition(pos *model.Position, req CreateOrderRequest) bool {
+	closing := (pos.Side == engine.PositionLong && req.Side == "sell") || (pos.Side == engine.PositionShort && req.Side == "buy")
+	return closing && req.Amount.LessThanOrEqual(pos.Size)
+}
+
+// calculateFrozenAmount 计算需要冻结的资金数量和币种（买单按 price 冻结，向上取整到资金精度；按金额下单时冻结金额本身）
+func (s *OrderService) calculateFrozenAmount(req CreateOrderRequest, price decimal.Decimal) (amount decimal.Decimal, asset string) {
+	if req.Cost != nil {
+		return *req.Cost, s.getQuoteAsset(req.Symbol)
+	}
 	if req.Side == "buy" {
 		// 买单：冻结计价币（USDT）
-		asset = s.getQuoteAsset(req.Symbol)
-		amount = req.Amount * price
-	} else {
-		// 卖单：冻结基础币（BTC）
-		asset = s.getBaseAsset(req.Symbol)
-		amount = req.Amount
+		return engine.CeilAsset(req.Amount.Mul(price)), s.getQuoteAsset(req.Symbol)
 	}
-	return amount, asset
+	// 卖单：冻结基础币（BTC）
+	return req.Amount, s.getBaseAsset(req.Symbol)
 }
 
 // getBaseAsset 从交易对获取基础币种 (BTC/USDT -> BTC)
@@ -302,13 +862,10 @@ func (s *OrderService) getBaseAsset(symbol string) string {
 	return ""
 }
 
-// getQuoteAsset 从交易对获取计价币种 (BTC/USDT -> USDT)
+// getQuoteAsset 从交易对获取计价币种 (BTC/USDT -> USDT，永续合约 BTC/USDT:USDT -> USDT)
 func (s *OrderService) getQuoteAsset(symbol string) string {
-	parts := strings.Split(symbol, "/")
-	if len(parts) == 2 {
-		return parts[1]
-	}
-	return "USDT" // 默认
+	_, quote, _ := market.SplitSymbol(symbol)
+	return quote
 }
 
 // createMatchingEngine 创建撮合引擎实例
@@ -317,149 +874,151 @@ func (s *OrderService) createMatchingEngine() *engine.MatchingEngine {
 }
 
 // CreateStopLossOrder 创建止损单
-func (s *OrderService) CreateStopLossOrder(userID uint, symbol, side string, amount, stopPrice float64) (*model.Order, error) {
+func (s *OrderService) CreateStopLossOrder(userID uint, symbol, side string, amount, stopPrice decimal.Decimal) (*model.Order, error) {
 	s.logger.Debug("CreateStopLossOrder called",
 		zap.Uint("user_id", userID),
 		zap.String("symbol", symbol),
 		zap.String("side", side),
-		zap.Float64("amount", amount),
-		zap.Float64("stop_price", stopPrice),
+		zap.Stringer("amount", amount),
+		zap.Stringer("stop_price", stopPrice),
 	)
 
 	// 1. 参数验证
 	if side != "sell" && side != "buy" {
 		return nil, fmt.Errorf("invalid side: %s", side)
 	}
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return nil, fmt.Errorf("amount must be positive")
 	}
-	if stopPrice <= 0 {
+	if !stopPrice.IsPositive() {
 		return nil, fmt.Errorf("stop price must be positive")
 	}
 
-	// 2. 检查余额（止损单需要冻结资产）
-	var asset string
-	var frozenAmount float64
-	if side == "sell" {
-		// 卖单止损：冻结基础币
-		asset = s.getBaseAsset(symbol)
-		frozenAmount = amount
-	} else {
-		// 买单止损：冻结计价币（需要估算成本）
-		asset = s.getQuoteAsset(symbol)
-		frozenAmount = amount * stopPrice
-	}
-
-	if err := s.balanceService.CheckBalance(userID, asset, frozenAmount); err != nil {
-		return nil, fmt.Errorf("insufficient balance: %w", err)
-	}
-
-	// 3. 冻结资金
-	if err := s.balanceService.FreezeBalance(userID, asset, frozenAmount); err != nil {
-		return nil, fmt.Errorf("failed to freeze balance: %w", err)
-	}
-
-	// 4. 创建止损单
-	// 止损条件：卖单价格 <= 止损价，买单价格 >= 止损价
-	triggerCondition := "<=" // 默认卖单止损
-	if side == "buy" {
-		triggerCondition = ">="
-	}
-
-	order := &model.Order{
-		UserID:           userID,
-		Symbol:           symbol,
-		Side:             side,
-		Type:             "stop_loss",
-		Status:           "new",
-		StopPrice:        &stopPrice,
-		TriggerCondition: triggerCondition,
-		Amount:           amount,
-	}
-
-	if err := s.db.Create(order).Error; err != nil {
-		// 回滚冻结
-		_ = s.balanceService.UnfreezeBalance(userID, asset, frozenAmount)
-		return nil, fmt.Errorf("failed to create stop loss order: %w", err)
-	}
-
-	s.logger.Info("Stop loss order created",
-		zap.Uint("order_id", order.ID),
-		zap.String("symbol", symbol),
-		zap.Float64("stop_price", stopPrice),
-	)
-
-	return order, nil
+	return s.createConditionalOrder(newConditionalOrder(userID, CreateOrderRequest{
+		Symbol: symbol,
+		Side:   side,
+		Type:   "market",
+		Amount: amount,
+	}, "stop_loss", stopPrice))
 }
 
 // CreateTakeProfitOrder 创建止盈单
-func (s *OrderService) CreateTakeProfitOrder(userID uint, symbol, side string, amount, takeProfitPrice float64) (*model.Order, error) {
+func (s *OrderService) CreateTakeProfitOrder(userID uint, symbol, side string, amount, takeProfitPrice decimal.Decimal) (*model.Order, error) {
 	s.logger.Debug("CreateTakeProfitOrder called",
 		zap.Uint("user_id", userID),
 		zap.String("symbol", symbol),
 		zap.String("side", side),
-		zap.Float64("amount", amount),
-		zap.Float64("take_profit_price", takeProfitPrice),
+		zap.Stringer("amount", amount),
+		zap.Stringer("take_profit_price", takeProfitPrice),
 	)
 
 	// 1. 参数验证
 	if side != "sell" && side != "buy" {
 		return nil, fmt.Errorf("invalid side: %s", side)
 	}
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return nil, fmt.Errorf("amount must be positive")
 	}
-	if takeProfitPrice <= 0 {
+	if !takeProfitPrice.IsPositive() {
 		return nil, fmt.Errorf("take profit price must be positive")
 	}
 
-	// 2. 检查余额
-	var asset string
-	var frozenAmount float64
-	if side == "sell" {
-		asset = s.getBaseAsset(symbol)
-		frozenAmount = amount
-	} else {
-		asset = s.getQuoteAsset(symbol)
-		frozenAmount = amount * takeProfitPrice
-	}
+	return s.createConditionalOrder(newConditionalOrder(userID, CreateOrderRequest{
+		Symbol: symbol,
+		Side:   side,
+		Type:   "market",
+		Amount: amount,
+	}, "take_profit", takeProfitPrice))
+}
 
-	if err := s.balanceService.CheckBalance(userID, asset, frozenAmount); err != nil {
-		return nil, fmt.Errorf("insufficient balance: %w", err)
+// createTrailingStopOrder 创建跟踪止损单，以当前价格作为初始最优价
+func (s *OrderService) createTrailingStopOrder(userID uint, req CreateOrderRequest) (*model.Order, error) {
+	var ticker model.Ticker
+	if err := s.db.Where("symbol = ?", req.Symbol).First(&ticker).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("ticker not found for symbol %s", req.Symbol)
+		}
+		return nil, fmt.Errorf("failed to get ticker: %w", err)
 	}
 
-	// 3. 冻结资金
-	if err := s.balanceService.FreezeBalance(userID, asset, frozenAmount); err != nil {
-		return nil, fmt.Errorf("failed to freeze balance: %w", err)
+	req.Type = "market"
+	req.Price = nil
+	order := newConditionalOrder(userID, req, "trailing_stop", decimal.Zero)
+	order.TrailingDelta = req.TrailingAmount
+	order.TrailingPercent = req.TrailingPercent
+
+	mkt := s.markets.ForSymbol(req.Symbol)
+	watermark := mkt.RoundTick(decimal.NewFromFloat(ticker.LastPrice))
+	stopPrice := trailingStopPrice(order, watermark, mkt)
+	if !stopPrice.IsPositive() {
+		return nil, fmt.Errorf("trailing stop price must be positive")
 	}
+	order.Watermark = &watermark
+	order.StopPrice = &stopPrice
+
+	return s.createConditionalOrder(order)
+}
 
-	// 4. 创建止盈单
-	// 止盈条件：卖单价格 >= 止盈价，买单价格 <= 止盈价
-	triggerCondition := ">=" // 默认卖单止盈
-	if side == "buy" {
-		triggerCondition = "<="
+// newConditionalOrder 根据下单请求生成条件单
+func newConditionalOrder(userID uint, req CreateOrderRequest, orderType string, triggerPrice decimal.Decimal) *model.Order {
+	timeInForce := req.TimeInForce
+	if timeInForce == "" {
+		timeInForce = engine.TimeInForceGTC
 	}
 
-	order := &model.Order{
+	return &model.Order{
 		UserID:           userID,
-		Symbol:           symbol,
-		Side:             side,
-		Type:             "take_profit",
+		ClientOrderID:    req.ClientOrderID,
+		Symbol:           req.Symbol,
+		Side:             req.Side,
+		Type:             orderType,
+		TimeInForce:      timeInForce,
 		Status:           "new",
-		StopPrice:        &takeProfitPrice,
-		TriggerCondition: triggerCondition,
-		Amount:           amount,
+		Price:            req.Price,
+		StopPrice:        &triggerPrice,
+		TriggerCondition: triggerConditionFor(orderType, req.Side),
+		Amount:           req.Amount,
 	}
+}
+
+// createConditionalOrder 冻结资金并保存条件单
+// 冻结的资金在触发后由子订单继续使用，撤单时解冻
+func (s *OrderService) createConditionalOrder(order *model.Order) (*model.Order, error) {
+	mkt := s.markets.ForSymbol(order.Symbol)
+	if mkt.IsSwap() {
+		return nil, ccxt.NewError(ccxt.ErrInvalidOrder, "stop orders are not supported for perpetual markets")
+	}
+
+	// 1. 计算冻结资金：卖单冻结基础币，买单按限价（市价条件单按触发价加滑点缓冲）冻结计价币
+	reserveStopMarketBuy(s.cfg, order, mkt)
+	frozenAmount, frozenAsset := s.remainingReservation(order)
+
+	if err := s.balanceService.CheckBalance(order.UserID, frozenAsset, frozenAmount); err != nil {
+		return nil, fmt.Errorf("insufficient balance: %w", err)
+	}
+
+	// 2. 冻结资金
+	if err := s.balanceService.FreezeBalance(order.UserID, frozenAsset, frozenAmount); err != nil {
+		return nil, fmt.Errorf("failed to freeze balance: %w", err)
+	}
+
+	// 3. 创建条件单
+	if existing, err := s.insertOrder(order); err != nil {
+		// 回滚冻结
+		_ = s.balanceService.UnfreezeBalance(order.UserID, frozenAsset, frozenAmount)
 
-	if err := s.db.Create(order).Error; err != nil {
-		_ = s.balanceService.UnfreezeBalance(userID, asset, frozenAmount)
-		return nil, fmt.Errorf("failed to create take profit order: %w", err)
+		// 并发重复提交：唯一索引冲突时返回先写入的订单
+		if existing != nil {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("failed to create %s order: %w", order.Type, err)
 	}
 
-	s.logger.Info("Take profit order created",
+	s.logger.Info("Conditional order created",
 		zap.Uint("order_id", order.ID),
-		zap.String("symbol", symbol),
-		zap.Float64("take_profit_price", takeProfitPrice),
+		zap.String("symbol", order.Symbol),
+		zap.String("type", order.Type),
+		zap.Stringer("trigger_price", order.StopPrice),
 	)
 
 	return order, nil
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// MaxBatchOrders 单次批量下单的最大订单数
+const MaxBatchOrders = 50
+
+// 批量下单模式
+const (
+	BatchModeBestEffort   = "best_effort"    // 逐笔下单，失败的订单不影响其他订单（默认）
+	BatchModeAllOrNothing = "all_or_nothing" // 任一订单失败则全部不下
+)
+
+// BatchOrdersRequest 批量下单请求（CCXT createOrders）
+type BatchOrdersRequest struct {
+	Orders []CreateOrderRequest `json:"orders"`
+	Mode   string               `json:"mode,omitempty"` // best_effort(默认) | all_or_nothing
+}
+
+// BatchOrderResult 批量下单中单笔订单的结果，Order 与 Error 二选一
+type BatchOrderResult struct {
+	Order *model.Order
+	Error error
+}
+
+// CancelAllOrdersRequest 撤销全部未完成订单的过滤条件，为空时不过滤
+type CancelAllOrdersRequest struct {
+	Symbol string
+	Side   string
+}
+
+// withTx 返回在事务 tx 中下单/撤单的服务副本
+// 下单后的撮合和算法单调度追加到 tasks，由调用方在事务提交后执行
+func (s *OrderService) withTx(tx *gorm.DB, tasks *[]func(*OrderService)) *OrderService {
+	txService := *s
+	txService.db = tx
+	txService.balanceService = s.balanceService.WithTx(tx)
+	txService.markets = market.NewRegistry(tx, s.cfg)
+	txService.deferred = tasks
+	return &txService
+}
+
+// afterCommit 订单写入后异步执行的任务（撮合、算法单调度）
+// 在批量事务中延迟到事务提交后由原服务执行，避免撮合读取未提交的订单
+func (s *OrderService) afterCommit(task func(*OrderService)) {
+	if s.deferred != nil {
+		*s.deferred = append(*s.deferred, task)
+		return
+	}
+	go task(s)
+}
+
+// CreateOrders 批量下单，所有订单在同一事务中冻结资金并写入
+// best_effort 模式下每笔订单使用独立的保存点，失败的订单回滚自身修改；all_or_nothing 模式下任一失败则整批回滚
+func (s *OrderService) CreateOrders(userID uint, req BatchOrdersRequest) ([]BatchOrderResult, error) {
+	// 1. 参数验证
+	if len(req.Orders) == 0 {
+		return nil, fmt.Errorf("orders is required")
+	}
+	if len(req.Orders) > MaxBatchOrders {
+		return nil, fmt.Errorf("too many orders: maximum is %d", MaxBatchOrders)
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = BatchModeBestEffort
+	}
+	if mode != BatchModeBestEffort && mode != BatchModeAllOrNothing {
+		return nil, fmt.Errorf("mode must be best_effort or all_or_nothing")
+	}
+
+	// 2. 在事务外逐笔执行下单确认，确认延迟期间不持有任何行锁
+	acks := make([]error, len(req.Orders))
+	for i, orderReq := range req.Orders {
+		acks[i] = s.acknowledge(orderReq.Symbol)
+	}
+
+	// 3. 在同一事务中逐笔下单，确认被拒的订单按下单失败处理
+	results := make([]BatchOrderResult, len(req.Orders))
+	var tasks []func(*OrderService)
+	failed := -1
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i, orderReq := range req.Orders {
+			var order *model.Order
+			err := acks[i]
+			if err == nil {
+				err = tx.Transaction(func(itx *gorm.DB) error {
+					var err error
+					order, err = s.withTx(itx, &tasks).createOrder(userID, orderReq, false)
+					return err
+				})
+			}
+			if err != nil {
+				results[i].Error = err
+				if mode == BatchModeAllOrNothing {
+					failed = i
+					return err
+				}
+				continue
+			}
+			results[i].Order = order
+		}
+		return nil
+	})
+
+	// 4. 整批回滚时，已处理的订单同样没有下单
+	if err != nil {
+		if failed < 0 {
+			return nil, fmt.Errorf("failed to create orders: %w", err)
+		}
+		for i := range results {
+			if i == failed {
+				continue
+			}
+			results[i] = BatchOrderResult{Error: fmt.Errorf("order not placed: order %d in the batch was rejected", failed)}
+		}
+		return results, nil
+	}
+
+	// 5. 事务提交后触发撮合
+	for _, task := range tasks {
+		go task(s)
+	}
+
+	s.logger.Info("Batch orders created",
+		zap.Uint("user_id", userID),
+		zap.String("mode", mode),
+		zap.Int("orders", len(req.Orders)),
+		zap.Int("tasks", len(tasks)),
+	)
+
+	return results, nil
+}
+
+// CancelAllOrders 撤销用户全部未完成订单（可按交易对、方向过滤），返回被撤销的订单
+// 在同一事务中逐笔撤销；订单组和算法单按整体撤销，撤单失败（如已被撮合）的订单跳过
+func (s *OrderService) CancelAllOrders(userID uint, req CancelAllOrdersRequest) ([]model.Order, error) {
+	if req.Side != "" && req.Side != "buy" && req.Side != "sell" {
+		return nil, fmt.Errorf("side must be buy or sell")
+	}
+
+	var cancelled []model.Order
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("user_id = ? AND status IN ?", userID, openOrderStatuses)
+		if req.Symbol != "" {
+			query = query.Where("symbol = ?", req.Symbol)
+		}
+		if req.Side != "" {
+			query = query.Where("side = ?", req.Side)
+		}
+
+		// 按 ID 顺序撤销：算法单父订单先于子订单，撤销父订单时子订单一并撤销
+		var ids []uint
+		if err := query.Model(&model.Order{}).Order("id ASC").Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to query open orders: %w", err)
+		}
+
+		for _, id := range ids {
+			err := tx.Transaction(func(itx *gorm.DB) error {
+				txService := s.withTx(itx, nil)
+				order, err := txService.GetOrderByID(id)
+				if err != nil {
+					return err
+				}
+				if order.Status != "new" && order.Status != "partially_filled" {
+					return nil
+				}
+				return txService.CancelOrder(userID, id)
+			})
+			if err != nil {
+				s.logger.Warn("Skip order in cancel-all",
+					zap.Uint("order_id", id),
+					zap.Error(err),
+				)
+			}
+		}
+
+		// 返回本次撤销的订单
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ? AND status = ?", ids, "cancelled").
+			Order("id ASC").
+			Find(&cancelled).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel orders: %w", err)
+	}
+
+	s.logger.Info("All open orders cancelled",
+		zap.Uint("user_id", userID),
+		zap.String("symbol", req.Symbol),
+		zap.String("side", req.Side),
+		zap.Int("orders", len(cancelled)),
+	)
+
+	return cancelled, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// ladder 生成价格依次降低的限价买单
+func ladder(symbol string, start float64, n int) []CreateOrderRequest {
+	reqs := make([]CreateOrderRequest, n)
+	for i := range reqs {
+		reqs[i] = CreateOrderRequest{
+			Symbol: symbol,
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(start - float64(i)*1000),
+		}
+	}
+	return reqs
+}
+
+// TestCreateOrders 测试批量下单
+func TestCreateOrders(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	lockedOf := func(userID uint) string {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, "USDT").First(&balance).Error)
+		return balance.Locked.String()
+	}
+	countOrders := func(userID uint) int64 {
+		var count int64
+		db.Model(&model.Order{}).Where("user_id = ?", userID).Count(&count)
+		return count
+	}
+
+	t.Run("Best effort places valid orders and reports failures", func(t *testing.T) {
+		// Given: 余额只够前两笔
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 9000.0, 0)
+
+		// When: 批量下 3 笔限价买单（4000、3900、3800 USDT）
+		results, err := orderService.CreateOrders(user.ID, BatchOrdersRequest{Orders: ladder("BTC/USDT", 40000, 3)})
+		require.NoError(t, err)
+
+		// Then: 前两笔成功，第三笔余额不足
+		require.Len(t, results, 3)
+		assert.NotNil(t, results[0].Order)
+		assert.NotNil(t, results[1].Order)
+		require.Error(t, results[2].Error)
+		assert.Contains(t, results[2].Error.Error(), "insufficient balance")
+		assert.Equal(t, int64(2), countOrders(user.ID))
+		assert.Equal(t, "7900", lockedOf(user.ID))
+	})
+
+	t.Run("All or nothing rolls back the whole batch", func(t *testing.T) {
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 9000.0, 0)
+
+		results, err := orderService.CreateOrders(user.ID, BatchOrdersRequest{
+			Orders: ladder("BTC/USDT", 40000, 3),
+			Mode:   BatchModeAllOrNothing,
+		})
+		require.NoError(t, err)
+
+		// Then: 没有订单写入，冻结余额不变
+		require.Len(t, results, 3)
+		for _, r := range results {
+			assert.Nil(t, r.Order)
+			assert.Error(t, r.Error)
+		}
+		assert.Contains(t, results[0].Error.Error(), "order 2 in the batch was rejected")
+		assert.Contains(t, results[2].Error.Error(), "insufficient balance")
+		assert.Zero(t, countOrders(user.ID))
+		assert.Equal(t, "0", lockedOf(user.ID))
+	})
+
+	t.Run("Orders are matched after the batch commits", func(t *testing.T) {
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+		bidPrice, askPrice := 49990.0, 50000.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+
+		// When: 批量下单，第一笔限价高于卖一价
+		results, err := orderService.CreateOrders(user.ID, BatchOrdersRequest{
+			Orders: ladder("BTC/USDT", 50500, 2),
+			Mode:   BatchModeAllOrNothing,
+		})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Error)
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 可成交的订单在提交后撮合
+		var first, second model.Order
+		require.NoError(t, db.First(&first, results[0].Order.ID).Error)
+		require.NoError(t, db.First(&second, results[1].Order.ID).Error)
+		assert.Equal(t, "filled", first.Status)
+		assert.Equal(t, "new", second.Status)
+	})
+
+	t.Run("Repeated clientOrderId returns the original order", func(t *testing.T) {
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+
+		// When: 同一批次中两笔订单使用相同的 clientOrderId
+		reqs := ladder("BTC/USDT", 40000, 2)
+		reqs[0].ClientOrderID, reqs[1].ClientOrderID = "batch-dup", "batch-dup"
+		results, err := orderService.CreateOrders(user.ID, BatchOrdersRequest{Orders: reqs})
+		require.NoError(t, err)
+
+		// Then: 第二笔返回第一笔订单，只冻结一次
+		require.NoError(t, results[0].Error)
+		require.NoError(t, results[1].Error)
+		assert.Equal(t, results[0].Order.ID, results[1].Order.ID)
+		assert.Equal(t, int64(1), countOrders(user.ID))
+		assert.Equal(t, "4000", lockedOf(user.ID))
+
+		// When: 并发重复提交的条件单在批量事务中写入时触发唯一索引冲突
+		var existing *model.Order
+		err = db.Transaction(func(tx *gorm.DB) error {
+			req := reqs[1]
+			req.Price = decimalPtr(39000)
+			order := newConditionalOrder(user.ID, req, "stop_loss_limit", decimal.NewFromInt(39500))
+			existing, err = orderService.withTx(tx, nil).createConditionalOrder(order)
+			if err != nil {
+				return err
+			}
+			// 回滚到保存点后事务仍可继续使用
+			return tx.Model(&model.Order{}).Where("id = ?", existing.ID).Update("price", decimalPtr(40000)).Error
+		})
+		require.NoError(t, err)
+
+		// Then: 返回原订单，条件单冻结的资金已回滚
+		assert.Equal(t, results[0].Order.ID, existing.ID)
+		assert.Equal(t, int64(1), countOrders(user.ID))
+		assert.Equal(t, "4000", lockedOf(user.ID))
+	})
+
+	t.Run("Invalid batches are rejected", func(t *testing.T) {
+		_, err := orderService.CreateOrders(1, BatchOrdersRequest{})
+		assert.Error(t, err)
+
+		_, err = orderService.CreateOrders(1, BatchOrdersRequest{Orders: ladder("BTC/USDT", 40000, MaxBatchOrders+1)})
+		assert.Error(t, err)
+
+		_, err = orderService.CreateOrders(1, BatchOrdersRequest{Orders: ladder("BTC/USDT", 40000, 1), Mode: "atomic"})
+		assert.Error(t, err)
+	})
+}
+
+// TestCancelAllOrders 测试撤销全部未完成订单
+func TestCancelAllOrders(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+	testutil.CreateTestBalance(t, db, user.ID, "ETH", 10.0, 0)
+
+	// Given: BTC 两笔买单、ETH 一笔买单和一笔卖单
+	reqs := append(ladder("BTC/USDT", 40000, 2), ladder("ETH/USDT", 2000, 1)...)
+	reqs = append(reqs, CreateOrderRequest{Symbol: "ETH/USDT", Side: "sell", Type: "limit", Amount: decimal.NewFromInt(1), Price: decimalPtr(5000)})
+	results, err := orderService.CreateOrders(user.ID, BatchOrdersRequest{Orders: reqs, Mode: BatchModeAllOrNothing})
+	require.NoError(t, err)
+	require.NoError(t, results[3].Error)
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("Filter by symbol and side", func(t *testing.T) {
+		cancelled, err := orderService.CancelAllOrders(user.ID, CancelAllOrdersRequest{Symbol: "ETH/USDT", Side: "sell"})
+		require.NoError(t, err)
+		require.Len(t, cancelled, 1)
+		assert.Equal(t, results[3].Order.ID, cancelled[0].ID)
+
+		var eth model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "ETH").First(&eth).Error)
+		assert.True(t, eth.Locked.IsZero())
+	})
+
+	t.Run("Cancel everything", func(t *testing.T) {
+		cancelled, err := orderService.CancelAllOrders(user.ID, CancelAllOrdersRequest{})
+		require.NoError(t, err)
+		assert.Len(t, cancelled, 3)
+
+		// Then: 没有未完成订单，冻结资金全部释放
+		open, err := orderService.GetOpenOrders(user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, open)
+
+		var usdt model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+		assert.True(t, usdt.Locked.IsZero())
+		assert.Equal(t, "100000", usdt.Available.String())
+	})
+
+	t.Run("Invalid side", func(t *testing.T) {
+		_, err := orderService.CancelAllOrders(user.ID, CancelAllOrdersRequest{Side: "hold"})
+		assert.Error(t, err)
+	})
+}
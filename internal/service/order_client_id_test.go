@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestClientOrderID 测试 clientOrderId 的幂等下单、查询和撤单
+func TestClientOrderID(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	newReq := func(clientOrderID string) CreateOrderRequest {
+		return CreateOrderRequest{
+			Symbol:        "BTC/USDT",
+			Side:          "buy",
+			Type:          "limit",
+			Amount:        decimal.NewFromFloat(0.1),
+			Price:         decimalPtr(40000),
+			ClientOrderID: clientOrderID,
+		}
+	}
+	lockedOf := func(userID uint) string {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, "USDT").First(&balance).Error)
+		return balance.Locked.String()
+	}
+
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 20000.0, 0)
+
+	t.Run("Duplicate submission returns the original order", func(t *testing.T) {
+		// Given: 以 my-order-1 下单
+		first, err := orderService.CreateOrder(user.ID, newReq("my-order-1"))
+		require.NoError(t, err)
+
+		// When: 重试同一请求（CCXT 参数名）
+		req := newReq("")
+		req.ClientOrderIDParam = "my-order-1"
+		second, err := orderService.CreateOrder(user.ID, req)
+		require.NoError(t, err)
+
+		// Then: 返回原订单，资金只冻结一次
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, "4000", lockedOf(user.ID))
+
+		var count int64
+		db.Model(&model.Order{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Other users can reuse the same clientOrderId", func(t *testing.T) {
+		other := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, other.ID, "USDT", 10000.0, 0)
+
+		order, err := orderService.CreateOrder(other.ID, newReq("my-order-1"))
+		require.NoError(t, err)
+		assert.Equal(t, other.ID, order.UserID)
+		assert.Equal(t, "4000", lockedOf(other.ID))
+	})
+
+	t.Run("Orders without clientOrderId are not deduplicated", func(t *testing.T) {
+		a, err := orderService.CreateOrder(user.ID, newReq(""))
+		require.NoError(t, err)
+		b, err := orderService.CreateOrder(user.ID, newReq(""))
+		require.NoError(t, err)
+		assert.NotEqual(t, a.ID, b.ID)
+	})
+
+	t.Run("Query and cancel by clientOrderId", func(t *testing.T) {
+		order, err := orderService.GetOrderByClientOrderID(user.ID, "my-order-1")
+		require.NoError(t, err)
+		assert.Equal(t, "my-order-1", order.ClientOrderID)
+
+		cancelled, err := orderService.CancelOrderByClientOrderID(user.ID, "my-order-1")
+		require.NoError(t, err)
+		assert.Equal(t, order.ID, cancelled.ID)
+		assert.Equal(t, "cancelled", cancelled.Status)
+
+		// 撤销后重复提交仍返回原订单，不会重新下单
+		again, err := orderService.CreateOrder(user.ID, newReq("my-order-1"))
+		require.NoError(t, err)
+		assert.Equal(t, order.ID, again.ID)
+		assert.Equal(t, "cancelled", again.Status)
+	})
+
+	t.Run("Unknown or invalid clientOrderId", func(t *testing.T) {
+		_, err := orderService.GetOrderByClientOrderID(user.ID, "missing")
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrOrderNotFound, ccxt.ErrorCode(err))
+
+		_, err = orderService.CancelOrderByClientOrderID(user.ID+100, "my-order-1")
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrOrderNotFound, ccxt.ErrorCode(err))
+
+		_, err = orderService.CreateOrder(user.ID, newReq(string(make([]byte, 65))))
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrInvalidOrder, ccxt.ErrorCode(err))
+	})
+}
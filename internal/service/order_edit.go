@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// EditOrderRequest 改单请求（CCXT editOrder）
+// symbol/side 可选，设置时必须与原订单一致；amount 为新的总数量（含已成交部分）
+type EditOrderRequest struct {
+	Symbol       string           `json:"symbol,omitempty"`
+	Side         string           `json:"side,omitempty"`
+	Amount       *decimal.Decimal `json:"amount,omitempty"`       // 新的总数量
+	Price        *decimal.Decimal `json:"price,omitempty"`        // 新的限价（限价单、*_limit 条件单）
+	StopPrice    *decimal.Decimal `json:"stopPrice,omitempty"`    // 新的触发价（同 triggerPrice）
+	TriggerPrice *decimal.Decimal `json:"triggerPrice,omitempty"` // 新的触发价（条件单）
+}
+
+// editableOrderTypes 可以改单的订单类型
+var editableOrderTypes = []string{"limit", "stop_loss", "take_profit", "stop_loss_limit", "take_profit_limit"}
+
+// EditOrder 修改未完成的限价单或条件单的价格/数量
+// 在同一事务中按新旧冻结资金的差额调整冻结余额，是否保留时间优先级由 trading.matching.amend_priority 决定
+func (s *OrderService) EditOrder(userID, orderID uint, req EditOrderRequest) (*model.Order, error) {
+	// 1. 验证请求和订单
+	triggerPrice, err := resolveEditTrigger(req)
+	if err != nil {
+		return nil, ccxt.WithCode(ccxt.ErrInvalidOrder, err)
+	}
+	if req.Amount == nil && req.Price == nil && triggerPrice == nil {
+		return nil, ccxt.NewError(ccxt.ErrInvalidOrder, "amount, price or triggerPrice is required")
+	}
+
+	current, err := s.GetOrderByID(orderID)
+	if err != nil || current.UserID != userID {
+		return nil, ccxt.NewError(ccxt.ErrOrderNotFound, "order not found")
+	}
+	if (req.Symbol != "" && req.Symbol != current.Symbol) || (req.Side != "" && req.Side != current.Side) {
+		return nil, ccxt.NewError(ccxt.ErrInvalidOrder, "symbol and side of an order cannot be changed")
+	}
+	mkt, err := s.market(current.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 锁定订单，修改并调整冻结资金
+	var order model.Order
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&order, orderID).Error; err != nil {
+			return fmt.Errorf("failed to lock order: %w", err)
+		}
+		if err := s.checkEditable(tx, &order); err != nil {
+			return ccxt.WithCode(ccxt.ErrInvalidOrder, err)
+		}
+
+		reservedBefore := engine.RemainingReservation(&order)
+		prevAmount := order.Amount
+
+		priceChanged, err := applyAmendment(&order, mkt, req, triggerPrice)
+		if err != nil {
+			return ccxt.WithCode(ccxt.ErrInvalidOrder, err)
+		}
+		amountChanged := !order.Amount.Equal(prevAmount)
+		if !priceChanged && !amountChanged {
+			return nil
+		}
+		// 市价条件买单按新的触发价加滑点缓冲冻结
+		reserveStopMarketBuy(s.cfg, &order, mkt)
+
+		if order.PostOnly && priceChanged {
+			if err := engine.NewMatchingEngine(tx, s.cfg, s.logger).CheckPostOnly(&order); err != nil {
+				return fmt.Errorf("order rejected: %w", err)
+			}
+		}
+
+		frozenAmount, frozenAsset := s.remainingReservation(&order)
+		if err := s.balanceService.WithTx(tx).AdjustFrozenBalance(userID, frozenAsset, frozenAmount.Sub(reservedBefore)); err != nil {
+			return fmt.Errorf("failed to adjust frozen balance: %w", err)
+		}
+
+		if !s.cfg.Trading.Matching.AmendKeepsPriority(priceChanged, order.Amount.GreaterThan(prevAmount)) {
+			now := time.Now()
+			order.QueuedAt = &now
+		}
+
+		// 改价后的限价单重新按新进入的订单撮合，立即成交的部分为 taker
+		if priceChanged {
+			order.RestedAt = nil
+		}
+
+		if err := tx.Save(&order).Error; err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Order amended",
+		zap.Uint("order_id", order.ID),
+		zap.Uint("user_id", userID),
+		zap.Stringer("amount", order.Amount),
+		zap.Stringer("price", order.Price),
+		zap.Stringer("stop_price", order.StopPrice),
+	)
+
+	// 限价单改价后可能立即可成交
+	if order.Type == "limit" {
+		go func() {
+			if err := s.createMatchingEngine().MatchOrder(order.ID); err != nil {
+				s.logger.Debug("Amended order not matched",
+					zap.Uint("order_id", order.ID),
+					zap.Error(err),
+				)
+			}
+		}()
+	}
+
+	return &order, nil
+}
+
+// resolveEditTrigger 合并 stopPrice/triggerPrice，二者同时设置时必须相同
+func resolveEditTrigger(req EditOrderRequest) (*decimal.Decimal, error) {
+	if req.StopPrice != nil && req.TriggerPrice != nil && !req.StopPrice.Equal(*req.TriggerPrice) {
+		return nil, fmt.Errorf("conflicting stop prices")
+	}
+	if req.StopPrice != nil {
+		return req.StopPrice, nil
+	}
+	return req.TriggerPrice, nil
+}
+
+// checkEditable 检查订单是否可以修改：未完成的限价单或条件单，不属于订单组或算法单
+func (s *OrderService) checkEditable(tx *gorm.DB, order *model.Order) error {
+	if order.Status != "new" && order.Status != "partially_filled" {
+		return fmt.Errorf("cannot edit order with status: %s", order.Status)
+	}
+	if !slices.Contains(editableOrderTypes, order.Type) {
+		return fmt.Errorf("%s orders cannot be edited", order.Type)
+	}
+	if order.OrderListID != nil {
+		return fmt.Errorf("orders in an order list cannot be edited")
+	}
+	if order.ParentOrderID != nil {
+		var parent model.Order
+		if err := tx.First(&parent, *order.ParentOrderID).Error; err == nil && isAlgoOrderType(parent.Type) {
+			return fmt.Errorf("order is a slice of algo order %d and cannot be edited", parent.ID)
+		}
+	}
+	return nil
+}
+
+// applyAmendment 按交易对精度取整并修改订单的数量、限价和触发价，返回价格是否变化
+func applyAmendment(order *model.Order, mkt *market.Market, req EditOrderRequest, triggerPrice *decimal.Decimal) (priceChanged bool, err error) {
+	isLimit := order.Type == "limit" || order.Type == "stop_loss_limit" || order.Type == "take_profit_limit"
+
+	if req.Amount != nil {
+		amount := mkt.RoundAmount(*req.Amount)
+		if !amount.GreaterThan(order.Filled) {
+			return false, fmt.Errorf("amount must be greater than filled amount %s", order.Filled)
+		}
+		if err := checkAmount(mkt, amount); err != nil {
+			return false, err
+		}
+		order.Amount = amount
+	}
+
+	if req.Price != nil {
+		if !isLimit {
+			return false, fmt.Errorf("price can only be changed on limit orders")
+		}
+		if !req.Price.IsPositive() {
+			return false, fmt.Errorf("price must be positive")
+		}
+		price := mkt.RoundPrice(order.Side, *req.Price)
+		if !price.Equal(*order.Price) {
+			order.Price = &price
+			// 限价单改价后按新限价冻结资金
+			if order.Type == "limit" {
+				order.ReservePrice = nil
+			}
+			priceChanged = true
+		}
+	}
+
+	if triggerPrice != nil {
+		if order.StopPrice == nil || order.Type == "limit" {
+			return false, fmt.Errorf("trigger price can only be changed on stop orders")
+		}
+		if !triggerPrice.IsPositive() {
+			return false, fmt.Errorf("trigger price must be positive")
+		}
+		stop := mkt.RoundTick(*triggerPrice)
+		if !stop.Equal(*order.StopPrice) {
+			order.StopPrice = &stop
+			priceChanged = true
+		}
+	}
+
+	// 最小名义价值按限价（条件市价单按触发价）验证
+	notionalPrice := order.StopPrice
+	if order.Price != nil {
+		notionalPrice = order.Price
+	}
+	if notionalPrice != nil {
+		if err := checkNotional(mkt, order.Amount, *notionalPrice); err != nil {
+			return false, err
+		}
+	}
+
+	return priceChanged, nil
+}
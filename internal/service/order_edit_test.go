@@ -0,0 +1,187 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestEditOrder 测试修改限价单和条件单
+func TestEditOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+	testutil.CreateTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+
+	balanceOf := func(asset string) model.Balance {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, asset).First(&balance).Error)
+		return balance
+	}
+
+	// Given: 40000 的限价买单 0.1 BTC（冻结 4000 USDT）
+	order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+		Symbol: "BTC/USDT",
+		Side:   "buy",
+		Type:   "limit",
+		Amount: decimal.NewFromFloat(0.1),
+		Price:  decimalPtr(40000),
+	})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("Raising price and amount freezes the difference", func(t *testing.T) {
+		// When: 改为 0.2 BTC @ 45000
+		edited, err := orderService.EditOrder(user.ID, order.ID, EditOrderRequest{
+			Amount: decimalPtr(0.2),
+			Price:  decimalPtr(45000),
+		})
+		require.NoError(t, err)
+
+		// Then: 冻结资金调整为 9000 USDT
+		assert.Equal(t, "45000", edited.Price.String())
+		assert.Equal(t, "0.2", edited.Amount.String())
+		assert.Equal(t, "9000", balanceOf("USDT").Locked.String())
+		assert.Equal(t, "1000", balanceOf("USDT").Available.String())
+	})
+
+	t.Run("Lowering amount releases the difference", func(t *testing.T) {
+		_, err := orderService.EditOrder(user.ID, order.ID, EditOrderRequest{Amount: decimalPtr(0.1)})
+		require.NoError(t, err)
+		assert.Equal(t, "4500", balanceOf("USDT").Locked.String())
+	})
+
+	t.Run("Insufficient balance leaves the order unchanged", func(t *testing.T) {
+		// When: 需要冻结的资金超过可用余额
+		_, err := orderService.EditOrder(user.ID, order.ID, EditOrderRequest{Amount: decimalPtr(1)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "insufficient balance")
+
+		// Then: 订单和冻结资金都没有变化
+		var reloaded model.Order
+		require.NoError(t, db.First(&reloaded, order.ID).Error)
+		assert.Equal(t, "0.1", reloaded.Amount.String())
+		assert.Equal(t, "4500", balanceOf("USDT").Locked.String())
+	})
+
+	t.Run("Stop order trigger price and amount", func(t *testing.T) {
+		// Given: 止损卖单 0.5 BTC
+		stop, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:    "BTC/USDT",
+			Side:      "sell",
+			Type:      "market",
+			Amount:    decimal.NewFromFloat(0.5),
+			StopPrice: decimalPtr(30000),
+		})
+		require.NoError(t, err)
+
+		// When: 修改触发价并减少数量
+		edited, err := orderService.EditOrder(user.ID, stop.ID, EditOrderRequest{
+			Amount:       decimalPtr(0.3),
+			TriggerPrice: decimalPtr(32000),
+		})
+		require.NoError(t, err)
+
+		// Then: 冻结的 BTC 随数量减少
+		assert.Equal(t, "32000", edited.StopPrice.String())
+		assert.Equal(t, "0.3", balanceOf("BTC").Locked.String())
+
+		// And: 条件市价单不能修改限价
+		_, err = orderService.EditOrder(user.ID, stop.ID, EditOrderRequest{Price: decimalPtr(31000)})
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrInvalidOrder, ccxt.ErrorCode(err))
+	})
+
+	t.Run("Invalid amendments are rejected", func(t *testing.T) {
+		_, err := orderService.EditOrder(user.ID, order.ID, EditOrderRequest{})
+		assert.Error(t, err)
+
+		_, err = orderService.EditOrder(user.ID, order.ID, EditOrderRequest{Side: "sell", Price: decimalPtr(41000)})
+		assert.Error(t, err)
+
+		_, err = orderService.EditOrder(user.ID+1, order.ID, EditOrderRequest{Price: decimalPtr(41000)})
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrOrderNotFound, ccxt.ErrorCode(err))
+
+		require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+		_, err = orderService.EditOrder(user.ID, order.ID, EditOrderRequest{Price: decimalPtr(41000)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot edit order with status: cancelled")
+	})
+}
+
+// TestEditOrderPriority 测试改单后的时间优先级规则
+func TestEditOrderPriority(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	cfg.Trading.Matching.Mode = "internal"
+	cfg.Trading.Matching.AmendPriority = "reduce_only"
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	bidPrice, askPrice := 40000.0, 60000.0
+	require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+
+	// Given: A、B 先后挂出同价卖单
+	sellers := make([]*model.Order, 2)
+	for i := range sellers {
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "sell",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.2),
+			Price:  decimalPtr(50000),
+		})
+		require.NoError(t, err)
+		sellers[i] = order
+		time.Sleep(50 * time.Millisecond)
+	}
+	a, b := sellers[0], sellers[1]
+
+	t.Run("Reducing amount keeps priority", func(t *testing.T) {
+		edited, err := orderService.EditOrder(a.UserID, a.ID, EditOrderRequest{Amount: decimalPtr(0.1)})
+		require.NoError(t, err)
+		assert.Nil(t, edited.QueuedAt)
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	t.Run("Increasing amount loses priority", func(t *testing.T) {
+		// When: A 增加数量
+		edited, err := orderService.EditOrder(a.UserID, a.ID, EditOrderRequest{Amount: decimalPtr(0.3)})
+		require.NoError(t, err)
+		require.NotNil(t, edited.QueuedAt)
+		time.Sleep(100 * time.Millisecond) // 等待改单后的异步撮合结束
+
+		// Then: 新的买单先与 B 成交
+		buyer := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, buyer.ID, "USDT", 100000.0, 0)
+		_, err = orderService.CreateOrder(buyer.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.2),
+			Price:  decimalPtr(50000),
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		var reloadedA, reloadedB model.Order
+		require.NoError(t, db.First(&reloadedA, a.ID).Error)
+		require.NoError(t, db.First(&reloadedB, b.ID).Error)
+		assert.Equal(t, "filled", reloadedB.Status)
+		assert.Equal(t, "new", reloadedA.Status)
+	})
+}
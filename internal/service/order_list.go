@@ -0,0 +1,420 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// CreateOrderListRequest 创建订单组请求（OCO / bracket）
+type CreateOrderListRequest struct {
+	Symbol       string          `json:"symbol"`
+	Type         string          `json:"type"`   // oco | bracket
+	Side         string          `json:"side"`   // oco: 两腿方向；bracket: 入场单方向
+	Amount       decimal.Decimal `json:"amount"` // 数量
+	ClientListID string          `json:"listClientOrderId,omitempty"`
+
+	// OCO：限价腿 + 止损腿
+	Price          *decimal.Decimal `json:"price"`                    // oco: 限价腿价格；bracket: 入场限价（为空时入场为市价单）
+	StopPrice      *decimal.Decimal `json:"stopPrice,omitempty"`      // oco: 止损腿触发价
+	StopLimitPrice *decimal.Decimal `json:"stopLimitPrice,omitempty"` // oco: 止损腿限价（为空时触发后下市价单）
+
+	// bracket：入场单成交后激活的止盈止损腿
+	StopLossPrice   *decimal.Decimal `json:"stopLossPrice,omitempty"`
+	TakeProfitPrice *decimal.Decimal `json:"takeProfitPrice,omitempty"`
+}
+
+// CreateOrderList 创建订单组
+// OCO 两腿共用一份冻结资金；bracket 先冻结入场单资金，止盈止损腿在入场单成交后激活
+func (s *OrderService) CreateOrderList(userID uint, req CreateOrderListRequest) (*model.OrderList, error) {
+	// 1. 参数验证（先按交易对精度取整）
+	req = s.normalizeOrderListPrecision(req)
+	if err := s.validateOrderListRequest(req); err != nil {
+		return nil, fmt.Errorf("invalid order list request: %w", ccxt.WithCode(ccxt.ErrInvalidOrder, err))
+	}
+
+	// 2. 生成订单组中的订单
+	list := &model.OrderList{
+		UserID:       userID,
+		Symbol:       req.Symbol,
+		Type:         req.Type,
+		ClientListID: req.ClientListID,
+	}
+
+	var entry *model.Order
+	var legs []*model.Order
+	var frozenAmount decimal.Decimal
+	var frozenAsset string
+
+	if req.Type == "oco" {
+		// 两腿互斥，冻结两腿所需的最大值
+		legs = s.buildOCOLegs(userID, req)
+		for _, leg := range legs {
+			amount, asset := s.remainingReservation(leg)
+			frozenAmount = decimal.Max(frozenAmount, amount)
+			frozenAsset = asset
+		}
+	} else {
+		entry, legs = s.buildBracketOrders(userID, req)
+
+		// 市价入场买单按当前市场价格加滑点缓冲冻结
+		if entry.Price == nil && entry.Side == "buy" {
+			var ticker model.Ticker
+			if err := s.db.Where("symbol = ?", req.Symbol).First(&ticker).Error; err != nil {
+				return nil, fmt.Errorf("ticker not found for symbol %s", req.Symbol)
+			}
+			reservePrice := marketBuyReservePrice(s.cfg, s.markets.ForSymbol(req.Symbol), decimal.NewFromFloat(ticker.LastPrice))
+			entry.ReservePrice = &reservePrice
+		}
+		frozenAmount, frozenAsset = s.remainingReservation(entry)
+	}
+
+	// 3. 冻结资金
+	if err := s.balanceService.FreezeBalance(userID, frozenAsset, frozenAmount); err != nil {
+		return nil, fmt.Errorf("failed to freeze balance: %w", err)
+	}
+
+	// 4. 创建订单组及订单
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(list).Error; err != nil {
+			return fmt.Errorf("failed to create order list: %w", err)
+		}
+
+		if entry != nil {
+			entry.OrderListID = &list.ID
+			if err := tx.Create(entry).Error; err != nil {
+				return fmt.Errorf("failed to create entry order: %w", err)
+			}
+		}
+
+		for _, leg := range legs {
+			leg.OrderListID = &list.ID
+			if entry != nil {
+				leg.ParentOrderID = &entry.ID
+			}
+			if err := tx.Create(leg).Error; err != nil {
+				return fmt.Errorf("failed to create order list leg: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = s.balanceService.UnfreezeBalance(userID, frozenAsset, frozenAmount)
+		return nil, err
+	}
+
+	s.logger.Info("Order list created",
+		zap.Uint("order_list_id", list.ID),
+		zap.Uint("user_id", userID),
+		zap.String("symbol", list.Symbol),
+		zap.String("type", list.Type),
+	)
+
+	// 5. 触发撮合引擎（异步）：bracket 撮合入场单，OCO 撮合限价腿
+	matchable := entry
+	if matchable == nil {
+		matchable = legs[0]
+	}
+	go func() {
+		matchEngine := s.createMatchingEngine()
+		if err := matchEngine.MatchOrder(matchable.ID); err != nil {
+			s.logger.Error("Failed to match order list order",
+				zap.Uint("order_id", matchable.ID),
+				zap.Error(err),
+			)
+		}
+	}()
+
+	return s.GetOrderList(userID, list.ID)
+}
+
+// GetOrderList 查询订单组（含组内订单）
+func (s *OrderService) GetOrderList(userID, listID uint) (*model.OrderList, error) {
+	var list model.OrderList
+	if err := s.db.Preload("Orders", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&list, listID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("order list not found")
+		}
+		return nil, fmt.Errorf("failed to get order list: %w", err)
+	}
+
+	if list.UserID != userID {
+		return nil, fmt.Errorf("order list not found")
+	}
+
+	return &list, nil
+}
+
+// CancelOrderList 撤销订单组中所有未完成的订单并解冻资金
+// 同一组互斥的腿共用一份冻结资金，只解冻一次
+func (s *OrderService) CancelOrderList(userID, listID uint) error {
+	list, err := s.GetOrderList(userID, listID)
+	if err != nil {
+		return err
+	}
+
+	// 按父订单分组计算冻结资金：同组互斥的腿取最大值，未激活的腿没有冻结资金
+	type reservation struct {
+		asset  string
+		amount decimal.Decimal
+	}
+	reservations := make(map[uint]*reservation)
+
+	var cancelled []model.Order
+	for _, order := range list.Orders {
+		if order.Status != "new" && order.Status != "partially_filled" && order.Status != "pending" {
+			continue
+		}
+		cancelled = append(cancelled, order)
+		if order.Status == "pending" {
+			continue
+		}
+
+		var group uint
+		if order.ParentOrderID != nil {
+			group = *order.ParentOrderID
+		}
+		amount, asset := s.remainingReservation(&order)
+		if r, ok := reservations[group]; ok {
+			r.amount = decimal.Max(r.amount, amount)
+		} else {
+			reservations[group] = &reservation{asset: asset, amount: amount}
+		}
+	}
+
+	if len(cancelled) == 0 {
+		return fmt.Errorf("order list has no open orders")
+	}
+
+	// 更新订单状态（仅限状态未变化的订单，避免与撮合竞争）
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, order := range cancelled {
+			result := tx.Model(&model.Order{}).
+				Where("id = ? AND status = ?", order.ID, order.Status).
+				Updates(map[string]interface{}{
+					"status":      "cancelled",
+					"canceled_at": now,
+				})
+			if result.Error != nil {
+				return fmt.Errorf("failed to cancel order %d: %w", order.ID, result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("order %d changed during cancellation", order.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to cancel order list",
+			zap.Uint("order_list_id", listID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// 解冻资金
+	for _, r := range reservations {
+		if !r.amount.IsPositive() {
+			continue
+		}
+		if err := s.balanceService.UnfreezeBalance(userID, r.asset, r.amount); err != nil {
+			s.logger.Error("Failed to unfreeze balance",
+				zap.Uint("order_list_id", listID),
+				zap.Uint("user_id", userID),
+				zap.Error(err),
+			)
+			return fmt.Errorf("failed to unfreeze balance: %w", err)
+		}
+	}
+
+	s.logger.Info("Order list cancelled",
+		zap.Uint("order_list_id", listID),
+		zap.Uint("user_id", userID),
+		zap.Int("orders", len(cancelled)),
+	)
+
+	return nil
+}
+
+// buildOCOLegs 生成 OCO 的限价腿和止损腿
+func (s *OrderService) buildOCOLegs(userID uint, req CreateOrderListRequest) []*model.Order {
+	limitLeg := &model.Order{
+		UserID:      userID,
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		Type:        "limit",
+		TimeInForce: engine.TimeInForceGTC,
+		Status:      "new",
+		Amount:      req.Amount,
+		Price:       req.Price,
+	}
+
+	stopType := "stop_loss"
+	if req.StopLimitPrice != nil {
+		stopType = "stop_loss_limit"
+	}
+	stopLeg := &model.Order{
+		UserID:           userID,
+		Symbol:           req.Symbol,
+		Side:             req.Side,
+		Type:             stopType,
+		TimeInForce:      engine.TimeInForceGTC,
+		Status:           "new",
+		Amount:           req.Amount,
+		Price:            req.StopLimitPrice,
+		StopPrice:        req.StopPrice,
+		TriggerCondition: triggerConditionFor(stopType, req.Side),
+	}
+	reserveStopMarketBuy(s.cfg, stopLeg, s.markets.ForSymbol(req.Symbol))
+
+	return []*model.Order{limitLeg, stopLeg}
+}
+
+// buildBracketOrders 生成 bracket 的入场单和未激活的止盈止损腿
+func (s *OrderService) buildBracketOrders(userID uint, req CreateOrderListRequest) (*model.Order, []*model.Order) {
+	entryType := "market"
+	if req.Price != nil {
+		entryType = "limit"
+	}
+	entry := &model.Order{
+		UserID:      userID,
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		Type:        entryType,
+		TimeInForce: engine.TimeInForceGTC,
+		Status:      "new",
+		Amount:      req.Amount,
+		Price:       req.Price,
+	}
+
+	exitSide := "sell"
+	if req.Side == "sell" {
+		exitSide = "buy"
+	}
+
+	var legs []*model.Order
+	for _, leg := range []struct {
+		orderType string
+		price     *decimal.Decimal
+	}{
+		{"stop_loss", req.StopLossPrice},
+		{"take_profit", req.TakeProfitPrice},
+	} {
+		legs = append(legs, &model.Order{
+			UserID:           userID,
+			Symbol:           req.Symbol,
+			Side:             exitSide,
+			Type:             leg.orderType,
+			TimeInForce:      engine.TimeInForceGTC,
+			Status:           "pending",
+			Amount:           req.Amount,
+			StopPrice:        leg.price,
+			TriggerCondition: triggerConditionFor(leg.orderType, exitSide),
+		})
+	}
+
+	return entry, legs
+}
+
+// validateOrderListRequest 验证订单组请求参数
+func (s *OrderService) validateOrderListRequest(req CreateOrderListRequest) error {
+	if req.Symbol == "" {
+		return ccxt.NewError(ccxt.ErrBadSymbol, "symbol is required")
+	}
+	mkt, err := s.market(req.Symbol)
+	if err != nil {
+		return err
+	}
+	if mkt.IsSwap() {
+		return fmt.Errorf("order lists are not supported for perpetual markets")
+	}
+	if req.Side != "buy" && req.Side != "sell" {
+		return fmt.Errorf("side must be buy or sell")
+	}
+	if !req.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	if err := checkAmount(mkt, req.Amount); err != nil {
+		return err
+	}
+	if !positiveOrNil(req.Price) || !positiveOrNil(req.StopPrice) || !positiveOrNil(req.StopLimitPrice) ||
+		!positiveOrNil(req.StopLossPrice) || !positiveOrNil(req.TakeProfitPrice) {
+		return fmt.Errorf("prices must be positive")
+	}
+	if req.Price != nil {
+		if err := checkNotional(mkt, req.Amount, *req.Price); err != nil {
+			return err
+		}
+	}
+
+	switch req.Type {
+	case "oco":
+		// 卖出：限价腿在上方止盈、止损腿在下方；买入相反
+		if req.Price == nil || req.StopPrice == nil {
+			return fmt.Errorf("price and stopPrice are required for oco orders")
+		}
+		if req.Side == "sell" && req.Price.LessThanOrEqual(*req.StopPrice) {
+			return fmt.Errorf("sell oco requires price above stopPrice")
+		}
+		if req.Side == "buy" && req.Price.GreaterThanOrEqual(*req.StopPrice) {
+			return fmt.Errorf("buy oco requires price below stopPrice")
+		}
+	case "bracket":
+		// 多头入场：止损 < 入场价 < 止盈；空头入场相反
+		if req.StopLossPrice == nil || req.TakeProfitPrice == nil {
+			return fmt.Errorf("stopLossPrice and takeProfitPrice are required for bracket orders")
+		}
+		low, high := *req.StopLossPrice, *req.TakeProfitPrice
+		if req.Side == "sell" {
+			low, high = high, low
+		}
+		if low.GreaterThanOrEqual(high) {
+			return fmt.Errorf("stopLossPrice and takeProfitPrice are on the wrong side for a %s entry", req.Side)
+		}
+		if req.Price != nil && (req.Price.LessThanOrEqual(low) || req.Price.GreaterThanOrEqual(high)) {
+			return fmt.Errorf("entry price must be between stopLossPrice and takeProfitPrice")
+		}
+	default:
+		return fmt.Errorf("type must be oco or bracket")
+	}
+
+	return nil
+}
+
+// normalizeOrderListPrecision 按交易对步长和价格单位规范化订单组参数
+// 数量向下截断，限价（含止损腿限价）向保守方向取整，触发价四舍五入
+func (s *OrderService) normalizeOrderListPrecision(req CreateOrderListRequest) CreateOrderListRequest {
+	mkt := s.markets.ForSymbol(req.Symbol)
+
+	req.Amount = mkt.RoundAmount(req.Amount)
+	for _, field := range []**decimal.Decimal{&req.Price, &req.StopLimitPrice} {
+		if *field != nil {
+			rounded := mkt.RoundPrice(req.Side, **field)
+			*field = &rounded
+		}
+	}
+	for _, field := range []**decimal.Decimal{&req.StopPrice, &req.StopLossPrice, &req.TakeProfitPrice} {
+		if *field != nil {
+			rounded := mkt.RoundTick(**field)
+			*field = &rounded
+		}
+	}
+
+	return req
+}
+
+// positiveOrNil 价格未设置或为正数
+func positiveOrNil(price *decimal.Decimal) bool {
+	return price == nil || price.IsPositive()
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// TestCreateOrderList 测试创建订单组
+func TestCreateOrderList(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	t.Run("Create sell OCO with shared lock", func(t *testing.T) {
+		// Given: 用户持有 1 BTC
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+
+		// When: 创建卖出 OCO（52000 止盈，48000 止损）
+		price := 52000.0
+		stopPrice := 48000.0
+		list, err := orderService.CreateOrderList(user.ID, CreateOrderListRequest{
+			Symbol:    "BTC/USDT",
+			Type:      "oco",
+			Side:      "sell",
+			Amount:    decimal.NewFromFloat(0.5),
+			Price:     decimalPtr(price),
+			StopPrice: decimalPtr(stopPrice),
+		})
+
+		// Then: 两腿创建成功，只冻结一份 0.5 BTC
+		require.NoError(t, err)
+		require.Len(t, list.Orders, 2)
+		assert.Equal(t, "limit", list.Orders[0].Type)
+		assert.Equal(t, "stop_loss", list.Orders[1].Type)
+		assert.Equal(t, "<=", list.Orders[1].TriggerCondition)
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&balance).Error)
+		assert.InDelta(t, 0.5, balance.Locked.InexactFloat64(), 1e-9)
+
+		// When: 撤销其中一腿
+		require.NoError(t, orderService.CancelOrder(user.ID, list.Orders[1].ID))
+
+		// Then: 整组撤销，冻结只解冻一次
+		cancelled, err := orderService.GetOrderList(user.ID, list.ID)
+		require.NoError(t, err)
+		for _, order := range cancelled.Orders {
+			assert.Equal(t, "cancelled", order.Status)
+		}
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&balance).Error)
+		assert.InDelta(t, 1.0, balance.Available.InexactFloat64(), 1e-9)
+		assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-9)
+	})
+
+	t.Run("Create bracket with pending legs", func(t *testing.T) {
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+
+		price := 45000.0
+		stopLossPrice := 44000.0
+		takeProfitPrice := 47000.0
+		list, err := orderService.CreateOrderList(user.ID, CreateOrderListRequest{
+			Symbol:          "BTC/USDT",
+			Type:            "bracket",
+			Side:            "buy",
+			Amount:          decimal.NewFromFloat(0.1),
+			Price:           decimalPtr(price),
+			StopLossPrice:   decimalPtr(stopLossPrice),
+			TakeProfitPrice: decimalPtr(takeProfitPrice),
+		})
+
+		require.NoError(t, err)
+		require.Len(t, list.Orders, 3)
+		entry := list.Orders[0]
+		assert.Equal(t, "limit", entry.Type)
+		for _, leg := range list.Orders[1:] {
+			assert.Equal(t, "pending", leg.Status)
+			assert.Equal(t, "sell", leg.Side)
+			require.NotNil(t, leg.ParentOrderID)
+			assert.Equal(t, entry.ID, *leg.ParentOrderID)
+		}
+
+		// 只冻结入场单资金
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 0.1*price, balance.Locked.InexactFloat64(), 1e-6)
+
+		// 撤销订单组后全部解冻
+		require.NoError(t, orderService.CancelOrderList(user.ID, list.ID))
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("Reject invalid order lists", func(t *testing.T) {
+		user := createTestUser(t, db)
+		price := 48000.0
+		stopPrice := 52000.0
+
+		tests := []struct {
+			name   string
+			req    CreateOrderListRequest
+			errMsg string
+		}{
+			{
+				name:   "Sell OCO with stop above price",
+				req:    CreateOrderListRequest{Symbol: "BTC/USDT", Type: "oco", Side: "sell", Amount: decimal.NewFromFloat(0.1), Price: decimalPtr(price), StopPrice: decimalPtr(stopPrice)},
+				errMsg: "sell oco requires price above stopPrice",
+			},
+			{
+				name:   "Bracket without take profit",
+				req:    CreateOrderListRequest{Symbol: "BTC/USDT", Type: "bracket", Side: "buy", Amount: decimal.NewFromFloat(0.1), StopLossPrice: decimalPtr(price)},
+				errMsg: "stopLossPrice and takeProfitPrice are required",
+			},
+			{
+				name:   "Unknown type",
+				req:    CreateOrderListRequest{Symbol: "BTC/USDT", Type: "oto", Side: "buy", Amount: decimal.NewFromFloat(0.1)},
+				errMsg: "type must be oco or bracket",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := orderService.CreateOrderList(user.ID, tt.req)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			})
+		}
+	})
+}
+
+// TestTriggerOCOStopLeg 测试 OCO 止损腿触发后撤销限价腿
+func TestTriggerOCOStopLeg(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+	marketService := NewMarketService(db, cfg, logger)
+
+	// Given: 卖出 OCO
+	user := createTestUser(t, db)
+	createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+	createTestBalance(t, db, user.ID, "USDT", 0, 0)
+
+	price := 52000.0
+	stopPrice := 48000.0
+	list, err := orderService.CreateOrderList(user.ID, CreateOrderListRequest{
+		Symbol:    "BTC/USDT",
+		Type:      "oco",
+		Side:      "sell",
+		Amount:    decimal.NewFromFloat(0.5),
+		Price:     decimalPtr(price),
+		StopPrice: decimalPtr(stopPrice),
+	})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond) // 等待限价腿异步撮合结束（无行情，不会成交）
+
+	// When: 价格跌破止损价
+	bidPrice := 47500.0
+	db.Save(&model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 47500.0,
+		BidPrice:  &bidPrice,
+	})
+	require.NoError(t, marketService.TriggerStopOrders())
+	time.Sleep(100 * time.Millisecond)
+
+	// Then: 止损腿触发，限价腿被撤销
+	updated, err := orderService.GetOrderList(user.ID, list.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", updated.Orders[0].Status)
+	assert.Equal(t, "triggered", updated.Orders[1].Status)
+
+	// And: 冻结的 BTC 由止损子订单卖出
+	var balance model.Balance
+	require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&balance).Error)
+	assert.InDelta(t, 0.5, balance.Available.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-9)
+}
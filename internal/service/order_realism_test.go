@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestCreateOrderRealism 测试执行模拟的拒单和交易对故障错误码
+func TestCreateOrderRealism(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	cfg.Trading.Realism = config.RealismConfig{
+		Enabled:    true,
+		Seed:       1,
+		RejectRate: 1,
+		Symbols:    []config.SymbolRealismConfig{{Symbol: "ETH/USDT", OutageRate: 1}},
+	}
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+	req := CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "limit", Amount: decimal.NewFromFloat(0.1), Price: decimalPtr(40000)}
+
+	t.Run("Random rejection", func(t *testing.T) {
+		_, err := orderService.CreateOrder(user.ID, req)
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrExchangeError, ccxt.ErrorCode(err))
+	})
+
+	t.Run("Batch orders acknowledged before the transaction", func(t *testing.T) {
+		// When: 批量下单时每笔订单都被随机拒绝
+		results, err := orderService.CreateOrders(user.ID, BatchOrdersRequest{Orders: []CreateOrderRequest{req, req}})
+		require.NoError(t, err)
+
+		// Then: 每笔订单返回拒单错误码，资金未被冻结
+		require.Len(t, results, 2)
+		for _, result := range results {
+			require.Error(t, result.Error)
+			assert.Equal(t, ccxt.ErrExchangeError, ccxt.ErrorCode(result.Error))
+		}
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.True(t, balance.Locked.IsZero())
+	})
+
+	t.Run("Symbol outage", func(t *testing.T) {
+		// Given: ETH/USDT 撮合时进入故障
+		cfg.Trading.Realism.RejectRate = 0
+		eth := &model.Order{UserID: user.ID, Symbol: "ETH/USDT", Side: "buy", Type: "limit", Amount: decimal.NewFromInt(1), Price: decimalPtr(2000), Status: "new"}
+		require.NoError(t, db.Create(eth).Error)
+		require.NoError(t, orderService.createMatchingEngine().MatchOrder(eth.ID))
+
+		// Then: ETH/USDT 新订单返回 ExchangeNotAvailable，BTC/USDT 正常下单
+		ethReq := req
+		ethReq.Symbol, ethReq.Amount, ethReq.Price = "ETH/USDT", decimal.NewFromInt(1), decimalPtr(2000)
+		_, err := orderService.CreateOrder(user.ID, ethReq)
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrExchangeNotAvailable, ccxt.ErrorCode(err))
+
+		_, err = orderService.CreateOrder(user.ID, req)
+		assert.NoError(t, err)
+	})
+}
@@ -6,12 +6,15 @@ import (
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/talkincode/quicksilver/internal/ccxt"
 	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/market"
 	"github.com/talkincode/quicksilver/internal/model"
 )
 
@@ -27,8 +30,18 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&model.User{},
 		&model.Balance{},
 		&model.Order{},
+		&model.OrderList{},
 		&model.Trade{},
+		&model.UserFeeRate{},
+		&model.Position{},
+		&model.FundingRate{},
+		&model.FundingPayment{},
+		&model.Liquidation{},
+		&model.InsuranceFund{},
+		&model.MarginLoan{},
+		&model.BorrowInterest{},
 		&model.Ticker{},
+		&model.Market{},
 	)
 	require.NoError(t, err)
 
@@ -76,8 +89,8 @@ func createTestBalance(t *testing.T, db *gorm.DB, userID uint, asset string, ava
 	balance := &model.Balance{
 		UserID:    userID,
 		Asset:     asset,
-		Available: available,
-		Locked:    locked,
+		Available: decimal.NewFromFloat(available),
+		Locked:    decimal.NewFromFloat(locked),
 	}
 
 	err := db.Create(balance).Error
@@ -102,6 +115,12 @@ func createTestTicker(t *testing.T, db *gorm.DB, symbol string, price float64) *
 	return ticker
 }
 
+// decimalPtr 构造精确小数指针
+func decimalPtr(v float64) *decimal.Decimal {
+	d := decimal.NewFromFloat(v)
+	return &d
+}
+
 // TestNewOrderService 测试服务创建
 func TestNewOrderService(t *testing.T) {
 	db := setupTestDB(t)
@@ -140,7 +159,7 @@ func TestCreateMarketBuyOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 		}
 
 		order, err := orderService.CreateOrder(user.ID, req)
@@ -152,7 +171,7 @@ func TestCreateMarketBuyOrder(t *testing.T) {
 		assert.Equal(t, "BTC/USDT", order.Symbol)
 		assert.Equal(t, "buy", order.Side)
 		assert.Equal(t, "market", order.Type)
-		assert.Equal(t, 0.1, order.Amount)
+		assert.Equal(t, 0.1, order.Amount.InexactFloat64())
 		assert.Equal(t, "new", order.Status)
 		assert.Nil(t, order.Price) // 市价单无价格
 
@@ -160,7 +179,7 @@ func TestCreateMarketBuyOrder(t *testing.T) {
 		var balance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error
 		require.NoError(t, err)
-		assert.Greater(t, balance.Locked, 0.0) // 应该冻结了一定金额
+		assert.Greater(t, balance.Locked.InexactFloat64(), 0.0) // 应该冻结了一定金额
 	})
 
 	t.Run("Create market buy order with insufficient balance", func(t *testing.T) {
@@ -183,7 +202,7 @@ func TestCreateMarketBuyOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 		}
 
 		order, err := orderService.CreateOrder(user.ID, req)
@@ -193,6 +212,136 @@ func TestCreateMarketBuyOrder(t *testing.T) {
 		assert.Nil(t, order)
 		assert.Contains(t, err.Error(), "insufficient balance")
 	})
+
+	t.Run("Market buy reserves with slippage buffer and refunds the unused part", func(t *testing.T) {
+		db := setupTestDB(t)
+		cfg := setupTestConfig(t)
+		cfg.Trading.MarketBuySlippage = 0.05
+		logger := zap.NewNop()
+
+		balanceService := NewBalanceService(db, cfg, logger)
+		orderService := NewOrderService(db, cfg, logger, balanceService)
+
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+		askPrice := 50100.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, AskPrice: &askPrice}).Error)
+
+		// When: 下 0.1 BTC 市价买单
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "market",
+			Amount: decimal.NewFromFloat(0.1),
+		})
+		require.NoError(t, err)
+
+		// Then: 按当前价上浮 5% 冻结
+		assert.Equal(t, "52500", order.ReservePrice.String())
+
+		// And: 按 ask 价成交后冻结全部释放，只扣除实际成交金额
+		time.Sleep(100 * time.Millisecond)
+		var filled model.Order
+		require.NoError(t, db.First(&filled, order.ID).Error)
+		assert.Equal(t, "filled", filled.Status)
+		assert.Equal(t, "5010", filled.QuoteFilled.String())
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.True(t, balance.Locked.IsZero())
+		assert.Equal(t, "4990", balance.Available.String())
+	})
+}
+
+// TestCreateMarketBuyOrderWithCost 测试按金额下市价买单
+func TestCreateMarketBuyOrderWithCost(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	cfg.Market.Definitions = []config.MarketDefinitionConfig{
+		{Symbol: "BTC/USDT", MinNotional: 10},
+	}
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	user := createTestUser(t, db)
+	createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+	askPrice := 50100.0
+	require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50000.0, AskPrice: &askPrice}).Error)
+
+	t.Run("Spends at most the cost and refunds the remainder", func(t *testing.T) {
+		// When: 花费 1000 USDT 市价买入
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "market",
+			Cost:   decimalPtr(1000),
+		})
+		require.NoError(t, err)
+
+		// Then: 冻结金额即为预算，数量按当前价估算
+		assert.Equal(t, "1000", order.QuoteOrderQty.String())
+		assert.Equal(t, "0.02", order.Amount.String())
+		assert.Nil(t, order.ReservePrice)
+
+		// And: 按 ask 价成交不超过预算，未花完的部分退回
+		time.Sleep(100 * time.Millisecond)
+		var filled model.Order
+		require.NoError(t, db.First(&filled, order.ID).Error)
+		assert.Equal(t, "filled", filled.Status)
+		assert.Equal(t, "0.01996007", filled.Filled.String())
+		assert.True(t, filled.Amount.Equal(filled.Filled))
+		assert.Equal(t, "999.999507", filled.QuoteFilled.String())
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.True(t, balance.Locked.IsZero())
+		assert.Equal(t, "9000.000493", balance.Available.String())
+	})
+
+	t.Run("Invalid cost orders are rejected", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			req     CreateOrderRequest
+			message string
+		}{
+			{
+				name:    "Cost on limit order",
+				req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "limit", Price: decimalPtr(50000), Cost: decimalPtr(1000)},
+				message: "cost is only supported for market buy orders",
+			},
+			{
+				name:    "Cost on sell order",
+				req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "sell", Type: "market", Cost: decimalPtr(1000)},
+				message: "cost is only supported for market buy orders",
+			},
+			{
+				name:    "Amount and cost together",
+				req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "market", Amount: decimal.NewFromFloat(0.1), Cost: decimalPtr(1000)},
+				message: "amount and cost cannot both be set",
+			},
+			{
+				name:    "FOK cost order",
+				req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "market", TimeInForce: "FOK", Cost: decimalPtr(1000)},
+				message: "cost orders cannot be FOK",
+			},
+			{
+				name:    "Cost below min notional",
+				req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "market", Cost: decimalPtr(5)},
+				message: "order notional is too small, minimum is 10 USDT",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := orderService.CreateOrder(user.ID, tt.req)
+				require.Error(t, err)
+				assert.Equal(t, ccxt.ErrInvalidOrder, ccxt.ErrorCode(err))
+				assert.Contains(t, err.Error(), tt.message)
+			})
+		}
+	})
 }
 
 // TestCreateMarketSellOrder 测试创建市价卖单
@@ -217,7 +366,7 @@ func TestCreateMarketSellOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "sell",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 		}
 
 		order, err := orderService.CreateOrder(user.ID, req)
@@ -231,8 +380,8 @@ func TestCreateMarketSellOrder(t *testing.T) {
 		var balance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&balance).Error
 		require.NoError(t, err)
-		assert.Equal(t, 0.1, balance.Locked)
-		assert.Equal(t, 0.9, balance.Available)
+		assert.Equal(t, 0.1, balance.Locked.InexactFloat64())
+		assert.Equal(t, 0.9, balance.Available.InexactFloat64())
 	})
 }
 
@@ -256,8 +405,8 @@ func TestCreateLimitOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Amount: 0.1,
-			Price:  &price,
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(price),
 		}
 
 		order, err := orderService.CreateOrder(user.ID, req)
@@ -267,14 +416,14 @@ func TestCreateLimitOrder(t *testing.T) {
 		assert.NotZero(t, order.ID)
 		assert.Equal(t, "limit", order.Type)
 		assert.NotNil(t, order.Price)
-		assert.Equal(t, 49000.0, *order.Price)
+		assert.Equal(t, 49000.0, order.Price.InexactFloat64())
 
 		// 验证资金被冻结（按限价计算）
 		var balance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error
 		require.NoError(t, err)
 		expectedLocked := 0.1 * 49000.0 // 金额 * 价格
-		assert.InDelta(t, expectedLocked, balance.Locked, 0.01)
+		assert.InDelta(t, expectedLocked, balance.Locked.InexactFloat64(), 0.01)
 	})
 
 	t.Run("Create limit order without price", func(t *testing.T) {
@@ -293,7 +442,7 @@ func TestCreateLimitOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			// Price 为 nil
 		}
 
@@ -306,6 +455,247 @@ func TestCreateLimitOrder(t *testing.T) {
 	})
 }
 
+// TestCreateOrderTimeInForce 测试有效方式与只做 maker
+func TestCreateOrderTimeInForce(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	user := createTestUser(t, db)
+	createTestBalance(t, db, user.ID, "USDT", 100000.0, 0)
+
+	bidPrice := 49990.0
+	askPrice := 50010.0
+	ticker := createTestTicker(t, db, "BTC/USDT", 50000.0)
+	ticker.BidPrice = &bidPrice
+	ticker.AskPrice = &askPrice
+	require.NoError(t, db.Save(ticker).Error)
+
+	t.Run("Default time in force is GTC", func(t *testing.T) {
+		price := 49000.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(price),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "GTC", order.TimeInForce)
+		assert.False(t, order.PostOnly)
+	})
+
+	t.Run("PO maps to post-only GTC", func(t *testing.T) {
+		price := 49000.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:      "BTC/USDT",
+			Side:        "buy",
+			Type:        "limit",
+			Amount:      decimal.NewFromFloat(0.1),
+			Price:       decimalPtr(price),
+			TimeInForce: "po",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "GTC", order.TimeInForce)
+		assert.True(t, order.PostOnly)
+	})
+
+	t.Run("Reject crossing post-only order without freezing funds", func(t *testing.T) {
+		var before model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&before).Error)
+
+		price := 50100.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:   "BTC/USDT",
+			Side:     "buy",
+			Type:     "limit",
+			Amount:   decimal.NewFromFloat(0.1),
+			Price:    decimalPtr(price),
+			PostOnly: true,
+		})
+		require.Error(t, err)
+		assert.Nil(t, order)
+		assert.Contains(t, err.Error(), "post-only order would immediately match")
+
+		var after model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&after).Error)
+		assert.Equal(t, before.Locked.InexactFloat64(), after.Locked.InexactFloat64())
+	})
+
+	t.Run("Reject post-only IOC", func(t *testing.T) {
+		price := 49000.0
+		_, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:      "BTC/USDT",
+			Side:        "buy",
+			Type:        "limit",
+			Amount:      decimal.NewFromFloat(0.1),
+			Price:       decimalPtr(price),
+			TimeInForce: "IOC",
+			PostOnly:    true,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "postOnly orders cannot be IOC or FOK")
+	})
+}
+
+// TestCreateOrderPrecision 测试按交易对步长和价格单位取整及冻结资金精确对账
+func TestCreateOrderPrecision(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	cfg.Market.Definitions = []config.MarketDefinitionConfig{
+		{Symbol: "BTC/USDT", AmountStep: 0.00001, PriceTick: 0.01},
+	}
+	cfg.Trading.Liquidity = config.LiquidityConfig{Model: "fixed", Depth: 0.05}
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	user := createTestUser(t, db)
+	createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+	createTestTicker(t, db, "BTC/USDT", 50000.0)
+
+	// When: 下单数量和价格超出交易对精度
+	order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+		Symbol: "BTC/USDT",
+		Side:   "buy",
+		Type:   "limit",
+		Amount: decimal.RequireFromString("0.1234567"),
+		Price:  decimalPtr(49000.129),
+	})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	// Then: 数量向下截断，买单限价向下取整，冻结 = 数量 × 限价（向上取整到资金精度）
+	assert.Equal(t, "0.12345", order.Amount.String())
+	assert.Equal(t, "49000.12", order.Price.String())
+
+	var usdt model.Balance
+	require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+	assert.Equal(t, "6049.064814", usdt.Locked.String())
+
+	// When: 行情下跌后部分成交，再撤销剩余部分
+	bidPrice, askPrice := 48000.0, 48000.015
+	require.NoError(t, db.Save(&model.Ticker{
+		Symbol:    "BTC/USDT",
+		LastPrice: 48000.0,
+		BidPrice:  &bidPrice,
+		AskPrice:  &askPrice,
+	}).Error)
+	require.NoError(t, orderService.createMatchingEngine().MatchOrder(order.ID))
+	require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+
+	// Then: 冻结资金全部释放，可用余额 = 初始余额 - 实际成交金额（成交价按价格精度取整）
+	var trade model.Trade
+	require.NoError(t, db.Where("order_id = ?", order.ID).First(&trade).Error)
+	assert.Equal(t, "48000.02", trade.Price.String())
+	assert.Equal(t, "0.05", trade.Amount.String())
+	assert.Equal(t, "2400.001", trade.QuoteAmount.String())
+
+	require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+	assert.True(t, usdt.Locked.IsZero())
+	assert.Equal(t, "7599.999", usdt.Available.String())
+}
+
+// TestCreateOrderMarketRules 测试交易对规则验证及 CCXT 错误码
+func TestCreateOrderMarketRules(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	cfg.Market.Symbols = []string{"BTC/USDT", "ETH/USDT", "SOL/USDT"}
+	cfg.Market.Definitions = []config.MarketDefinitionConfig{
+		{Symbol: "BTC/USDT", AmountStep: 0.00001, PriceTick: 0.01, MinAmount: 0.0001, MaxAmount: 10, MinNotional: 10},
+		{Symbol: "SOL/USDT", Status: market.StatusInactive},
+	}
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	user := createTestUser(t, db)
+	createTestBalance(t, db, user.ID, "USDT", 1000000.0, 0)
+	createTestTicker(t, db, "BTC/USDT", 50000.0)
+
+	tests := []struct {
+		name    string
+		req     CreateOrderRequest
+		code    string
+		message string
+	}{
+		{
+			name:    "Unknown symbol",
+			req:     CreateOrderRequest{Symbol: "DOGE/USDT", Side: "buy", Type: "limit", Amount: decimal.NewFromInt(1), Price: decimalPtr(0.1)},
+			code:    ccxt.ErrBadSymbol,
+			message: "unknown symbol DOGE/USDT",
+		},
+		{
+			name:    "Inactive market",
+			req:     CreateOrderRequest{Symbol: "SOL/USDT", Side: "buy", Type: "limit", Amount: decimal.NewFromInt(1), Price: decimalPtr(100)},
+			code:    ccxt.ErrMarketClosed,
+			message: "market SOL/USDT is disabled",
+		},
+		{
+			name:    "Amount below minimum after step rounding",
+			req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "limit", Amount: decimal.RequireFromString("0.000099"), Price: decimalPtr(50000)},
+			code:    ccxt.ErrInvalidOrder,
+			message: "amount is too small, minimum is 0.0001",
+		},
+		{
+			name:    "Amount above maximum",
+			req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "limit", Amount: decimal.NewFromInt(11), Price: decimalPtr(50000)},
+			code:    ccxt.ErrInvalidOrder,
+			message: "amount is too large, maximum is 10",
+		},
+		{
+			name:    "Limit order below min notional",
+			req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "limit", Amount: decimal.RequireFromString("0.0001"), Price: decimalPtr(40000)},
+			code:    ccxt.ErrInvalidOrder,
+			message: "order notional is too small, minimum is 10 USDT",
+		},
+		{
+			name:    "Market order below min notional at current price",
+			req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "buy", Type: "market", Amount: decimal.RequireFromString("0.0001")},
+			code:    ccxt.ErrInvalidOrder,
+			message: "order notional is too small, minimum is 10 USDT",
+		},
+		{
+			name:    "Other validation errors are InvalidOrder",
+			req:     CreateOrderRequest{Symbol: "BTC/USDT", Side: "hold", Type: "limit", Amount: decimal.NewFromInt(1), Price: decimalPtr(50000)},
+			code:    ccxt.ErrInvalidOrder,
+			message: "side must be buy or sell",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When: 提交不符合交易对规则的订单
+			_, err := orderService.CreateOrder(user.ID, tt.req)
+
+			// Then: 返回对应的 CCXT 错误码
+			require.Error(t, err)
+			assert.Equal(t, tt.code, ccxt.ErrorCode(err))
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+
+	t.Run("Order meeting the rules is accepted", func(t *testing.T) {
+		// When: 数量和名义价值满足交易对规则
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.RequireFromString("0.0002"),
+			Price:  decimalPtr(50000),
+		})
+
+		// Then: 订单创建成功
+		require.NoError(t, err)
+		assert.Equal(t, "0.0002", order.Amount.String())
+	})
+}
+
 // TestValidateOrderRequest 测试订单参数验证
 func TestValidateOrderRequest(t *testing.T) {
 	db := setupTestDB(t)
@@ -328,7 +718,7 @@ func TestValidateOrderRequest(t *testing.T) {
 				Symbol: "BTC/USDT",
 				Side:   "buy",
 				Type:   "market",
-				Amount: 0.1,
+				Amount: decimal.NewFromFloat(0.1),
 			},
 			wantErr: false,
 		},
@@ -338,7 +728,7 @@ func TestValidateOrderRequest(t *testing.T) {
 				Symbol: "",
 				Side:   "buy",
 				Type:   "market",
-				Amount: 0.1,
+				Amount: decimal.NewFromFloat(0.1),
 			},
 			wantErr: true,
 			errMsg:  "symbol is required",
@@ -349,7 +739,7 @@ func TestValidateOrderRequest(t *testing.T) {
 				Symbol: "BTC/USDT",
 				Side:   "invalid",
 				Type:   "market",
-				Amount: 0.1,
+				Amount: decimal.NewFromFloat(0.1),
 			},
 			wantErr: true,
 			errMsg:  "side must be buy or sell",
@@ -360,7 +750,7 @@ func TestValidateOrderRequest(t *testing.T) {
 				Symbol: "BTC/USDT",
 				Side:   "buy",
 				Type:   "invalid",
-				Amount: 0.1,
+				Amount: decimal.NewFromFloat(0.1),
 			},
 			wantErr: true,
 			errMsg:  "type must be market or limit",
@@ -371,7 +761,7 @@ func TestValidateOrderRequest(t *testing.T) {
 				Symbol: "BTC/USDT",
 				Side:   "buy",
 				Type:   "market",
-				Amount: 0.00001,
+				Amount: decimal.NewFromFloat(0.00001),
 			},
 			wantErr: true,
 			errMsg:  "amount is too small",
@@ -382,11 +772,35 @@ func TestValidateOrderRequest(t *testing.T) {
 				Symbol: "BTC/USDT",
 				Side:   "buy",
 				Type:   "market",
-				Amount: -0.1,
+				Amount: decimal.NewFromFloat(-0.1),
 			},
 			wantErr: true,
 			errMsg:  "amount must be positive",
 		},
+		{
+			name: "Invalid time in force",
+			req: CreateOrderRequest{
+				Symbol:      "BTC/USDT",
+				Side:        "buy",
+				Type:        "market",
+				Amount:      decimal.NewFromFloat(0.1),
+				TimeInForce: "GTD",
+			},
+			wantErr: true,
+			errMsg:  "timeInForce must be GTC, IOC, FOK or PO",
+		},
+		{
+			name: "Post-only market order",
+			req: CreateOrderRequest{
+				Symbol:   "BTC/USDT",
+				Side:     "buy",
+				Type:     "market",
+				Amount:   decimal.NewFromFloat(0.1),
+				PostOnly: true,
+			},
+			wantErr: true,
+			errMsg:  "postOnly is only supported for limit orders",
+		},
 	}
 
 	for _, tt := range tests {
@@ -423,7 +837,7 @@ func TestGetOrderByID(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		err := db.Create(order).Error
@@ -474,11 +888,11 @@ func TestCancelOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		price := 50000.0
-		order.Price = &price
+		order.Price = decimalPtr(price)
 		err := db.Create(order).Error
 		require.NoError(t, err)
 
@@ -497,8 +911,8 @@ func TestCancelOrder(t *testing.T) {
 		var balance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error
 		require.NoError(t, err)
-		assert.Equal(t, 0.0, balance.Locked) // 冻结金额应该被释放
-		assert.Equal(t, 15000.0, balance.Available)
+		assert.Equal(t, 0.0, balance.Locked.InexactFloat64()) // 冻结金额应该被释放
+		assert.Equal(t, 15000.0, balance.Available.InexactFloat64())
 	})
 
 	t.Run("Cannot cancel filled order", func(t *testing.T) {
@@ -517,7 +931,7 @@ func TestCancelOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "filled",
 		}
 		err := db.Create(order).Error
@@ -547,11 +961,11 @@ func TestCancelOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "buy",
 			Type:   "limit",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		price := 50000.0
-		order.Price = &price
+		order.Price = decimalPtr(price)
 		err := db.Create(order).Error
 		require.NoError(t, err)
 
@@ -579,7 +993,7 @@ func TestCancelOrder(t *testing.T) {
 			Symbol: "BTC/USDT",
 			Side:   "sell",
 			Type:   "market",
-			Amount: 0.1,
+			Amount: decimal.NewFromFloat(0.1),
 			Status: "new",
 		}
 		err := db.Create(order).Error
@@ -594,8 +1008,47 @@ func TestCancelOrder(t *testing.T) {
 		var balance model.Balance
 		err = db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&balance).Error
 		require.NoError(t, err)
-		assert.Equal(t, 0.0, balance.Locked)
-		assert.Equal(t, 1.1, balance.Available) // 1.0 + 0.1
+		assert.Equal(t, 0.0, balance.Locked.InexactFloat64())
+		assert.Equal(t, 1.1, balance.Available.InexactFloat64()) // 1.0 + 0.1
+	})
+
+	t.Run("Cancel partially filled order unfreezes remaining amount", func(t *testing.T) {
+		db := setupTestDB(t)
+		cfg := setupTestConfig(t)
+		logger := zap.NewNop()
+
+		balanceService := NewBalanceService(db, cfg, logger)
+		orderService := NewOrderService(db, cfg, logger, balanceService)
+
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 0.5, 0.3) // 剩余 0.3 BTC 仍冻结
+
+		// 卖单已成交 0.2，剩余 0.3
+		price := 51000.0
+		order := &model.Order{
+			UserID: user.ID,
+			Symbol: "BTC/USDT",
+			Side:   "sell",
+			Type:   "limit",
+			Price:  decimalPtr(price),
+			Amount: decimal.NewFromFloat(0.5),
+			Filled: decimal.NewFromFloat(0.2),
+			Status: "partially_filled",
+		}
+		require.NoError(t, db.Create(order).Error)
+
+		err := orderService.CancelOrder(user.ID, order.ID)
+		require.NoError(t, err)
+
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "cancelled", updated.Status)
+		assert.NotNil(t, updated.CanceledAt)
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "BTC").First(&balance).Error)
+		assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-9)
+		assert.InDelta(t, 0.8, balance.Available.InexactFloat64(), 1e-9)
 	})
 
 	t.Run("Cancel non-existent order", func(t *testing.T) {
@@ -615,7 +1068,7 @@ func TestCancelOrder(t *testing.T) {
 		assert.Contains(t, err.Error(), "not found")
 	})
 
-	t.Run("Cancel market buy order with ticker price", func(t *testing.T) {
+	t.Run("Cancel market buy order releases its recorded reservation", func(t *testing.T) {
 		db := setupTestDB(t)
 		cfg := setupTestConfig(t)
 		logger := zap.NewNop()
@@ -626,27 +1079,28 @@ func TestCancelOrder(t *testing.T) {
 		user := createTestUser(t, db)
 		createTestBalance(t, db, user.ID, "USDT", 10000.0, 5000.0)
 
-		// 创建行情数据
+		// 行情已变化
 		ticker := &model.Ticker{
 			Symbol:    "BTC/USDT",
-			LastPrice: 50000.0,
+			LastPrice: 60000.0,
 		}
 		err := db.Save(ticker).Error
 		require.NoError(t, err)
 
-		// 创建市价买单
+		// 创建市价买单（下单时按 50000 冻结）
 		order := &model.Order{
-			UserID: user.ID,
-			Symbol: "BTC/USDT",
-			Side:   "buy",
-			Type:   "market",
-			Amount: 0.1,
-			Status: "new",
+			UserID:       user.ID,
+			Symbol:       "BTC/USDT",
+			Side:         "buy",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(0.1),
+			ReservePrice: decimalPtr(50000.0),
+			Status:       "new",
 		}
 		err = db.Create(order).Error
 		require.NoError(t, err)
 
-		// 撤销订单（应该按当前行情计算冻结金额）
+		// 撤销订单（按下单时记录的冻结价格解冻，而不是当前行情）
 		err = orderService.CancelOrder(user.ID, order.ID)
 
 		require.NoError(t, err)
@@ -656,6 +1110,11 @@ func TestCancelOrder(t *testing.T) {
 		err = db.First(&updated, order.ID).Error
 		require.NoError(t, err)
 		assert.Equal(t, "cancelled", updated.Status)
+
+		var usdt model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&usdt).Error)
+		assert.True(t, usdt.Locked.IsZero())
+		assert.Equal(t, "15000", usdt.Available.String())
 	})
 }
 
@@ -678,7 +1137,7 @@ func TestGetUserOrders(t *testing.T) {
 				Symbol: "BTC/USDT",
 				Side:   "buy",
 				Type:   "market",
-				Amount: 0.1,
+				Amount: decimal.NewFromFloat(0.1),
 				Status: "new",
 			}
 			err := db.Create(order).Error
@@ -708,14 +1167,14 @@ func TestGetOpenOrders(t *testing.T) {
 		user := createTestUser(t, db)
 
 		// 创建不同状态的订单
-		statuses := []string{"new", "new", "filled", "cancelled"}
+		statuses := []string{"new", "partially_filled", "filled", "cancelled"}
 		for _, status := range statuses {
 			order := &model.Order{
 				UserID: user.ID,
 				Symbol: "BTC/USDT",
 				Side:   "buy",
 				Type:   "market",
-				Amount: 0.1,
+				Amount: decimal.NewFromFloat(0.1),
 				Status: status,
 			}
 			err := db.Create(order).Error
@@ -726,9 +1185,9 @@ func TestGetOpenOrders(t *testing.T) {
 		orders, err := orderService.GetOpenOrders(user.ID)
 
 		require.NoError(t, err)
-		assert.Len(t, orders, 2) // 只有 2 个 new 状态的订单
+		assert.Len(t, orders, 2) // 只有 new 和 partially_filled 状态的订单
 		for _, order := range orders {
-			assert.Equal(t, "new", order.Status)
+			assert.Contains(t, []string{"new", "partially_filled"}, order.Status)
 		}
 	})
 }
@@ -748,16 +1207,16 @@ func TestCreateStopLossOrder(t *testing.T) {
 
 		// When: 创建止损卖单（当价格跌破 48000 时卖出）
 		stopPrice := 48000.0
-		order, err := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", 0.5, stopPrice)
+		order, err := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", decimal.NewFromFloat(0.5), decimal.NewFromFloat(stopPrice))
 
 		// Then: 订单创建成功
 		require.NoError(t, err)
 		assert.NotZero(t, order.ID)
 		assert.Equal(t, "stop_loss", order.Type)
 		assert.Equal(t, "new", order.Status)
-		assert.Equal(t, stopPrice, *order.StopPrice)
+		assert.Equal(t, stopPrice, order.StopPrice.InexactFloat64())
 		assert.Equal(t, "<=", order.TriggerCondition) // 价格 <= 止损价时触发
-		assert.Equal(t, 0.5, order.Amount)
+		assert.Equal(t, 0.5, order.Amount.InexactFloat64())
 	})
 
 	t.Run("Create stop loss with insufficient balance", func(t *testing.T) {
@@ -766,7 +1225,7 @@ func TestCreateStopLossOrder(t *testing.T) {
 		createTestBalance(t, db, user.ID, "BTC", 0.1, 0)
 
 		stopPrice := 48000.0
-		_, err := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", 1.0, stopPrice)
+		_, err := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", decimal.NewFromFloat(1.0), decimal.NewFromFloat(stopPrice))
 
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "insufficient balance")
@@ -788,16 +1247,118 @@ func TestCreateTakeProfitOrder(t *testing.T) {
 
 		// When: 创建止盈卖单（当价格涨到 52000 时卖出）
 		takeProfitPrice := 52000.0
-		order, err := orderService.CreateTakeProfitOrder(user.ID, "BTC/USDT", "sell", 0.5, takeProfitPrice)
+		order, err := orderService.CreateTakeProfitOrder(user.ID, "BTC/USDT", "sell", decimal.NewFromFloat(0.5), decimal.NewFromFloat(takeProfitPrice))
 
 		// Then: 订单创建成功
 		require.NoError(t, err)
 		assert.NotZero(t, order.ID)
 		assert.Equal(t, "take_profit", order.Type)
 		assert.Equal(t, "new", order.Status)
-		assert.Equal(t, takeProfitPrice, *order.StopPrice)
+		assert.Equal(t, takeProfitPrice, order.StopPrice.InexactFloat64())
 		assert.Equal(t, ">=", order.TriggerCondition) // 价格 >= 止盈价时触发
-		assert.Equal(t, 0.5, order.Amount)
+		assert.Equal(t, 0.5, order.Amount.InexactFloat64())
+	})
+}
+
+// TestCreateOrderWithTriggerParams 测试通过 CCXT 参数创建条件单
+func TestCreateOrderWithTriggerParams(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+
+	t.Run("stopPrice creates stop market order", func(t *testing.T) {
+		// Given: 用户持有 USDT
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+
+		// When: 突破 51000 时买入
+		stopPrice := 51000.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:    "BTC/USDT",
+			Side:      "buy",
+			Type:      "market",
+			Amount:    decimal.NewFromFloat(0.1),
+			StopPrice: decimalPtr(stopPrice),
+		})
+
+		// Then: 创建止损单，按触发价冻结资金
+		require.NoError(t, err)
+		assert.Equal(t, "stop_loss", order.Type)
+		assert.Equal(t, ">=", order.TriggerCondition)
+		assert.Equal(t, stopPrice, order.StopPrice.InexactFloat64())
+		assert.Nil(t, order.Price)
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 0.1*51000.0, balance.Locked.InexactFloat64(), 1e-6)
+
+		// When: 撤单
+		require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+
+		// Then: 冻结资金全部退回
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 10000.0, balance.Available.InexactFloat64(), 1e-6)
+		assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("takeProfitPrice with limit type creates take profit limit order", func(t *testing.T) {
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+
+		takeProfitPrice := 45000.0
+		price := 45100.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:          "BTC/USDT",
+			Side:            "buy",
+			Type:            "limit",
+			Amount:          decimal.NewFromFloat(0.1),
+			Price:           decimalPtr(price),
+			TakeProfitPrice: decimalPtr(takeProfitPrice),
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "take_profit_limit", order.Type)
+		assert.Equal(t, "<=", order.TriggerCondition)
+
+		// 限价条件单按限价冻结
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 0.1*price, balance.Locked.InexactFloat64(), 1e-6)
+
+		require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("Reject conflicting trigger params", func(t *testing.T) {
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+
+		stopLossPrice := 48000.0
+		takeProfitPrice := 52000.0
+		_, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:          "BTC/USDT",
+			Side:            "sell",
+			Type:            "market",
+			Amount:          decimal.NewFromFloat(0.1),
+			StopLossPrice:   decimalPtr(stopLossPrice),
+			TakeProfitPrice: decimalPtr(takeProfitPrice),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be combined")
+
+		negative := -1.0
+		_, err = orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:       "BTC/USDT",
+			Side:         "sell",
+			Type:         "market",
+			Amount:       decimal.NewFromFloat(0.1),
+			TriggerPrice: decimalPtr(negative),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "trigger price must be positive")
 	})
 }
 
@@ -818,7 +1379,7 @@ func TestTriggerStopOrders(t *testing.T) {
 		createTestBalance(t, db, user.ID, "USDT", 0, 0)
 
 		stopPrice := 48000.0
-		stopOrder, _ := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", 0.5, stopPrice)
+		stopOrder, _ := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", decimal.NewFromFloat(0.5), decimal.NewFromFloat(stopPrice))
 
 		// 创建行情（价格跌破止损价）
 		bidPrice := 47500.0
@@ -857,7 +1418,7 @@ func TestTriggerStopOrders(t *testing.T) {
 		createTestBalance(t, db, user.ID, "USDT", 0, 0)
 
 		takeProfitPrice := 52000.0
-		takeProfitOrder, _ := orderService.CreateTakeProfitOrder(user.ID, "BTC/USDT", "sell", 0.5, takeProfitPrice)
+		takeProfitOrder, _ := orderService.CreateTakeProfitOrder(user.ID, "BTC/USDT", "sell", decimal.NewFromFloat(0.5), decimal.NewFromFloat(takeProfitPrice))
 
 		// 创建行情（价格突破止盈价）
 		bidPrice := 52500.0
@@ -880,6 +1441,80 @@ func TestTriggerStopOrders(t *testing.T) {
 		assert.Equal(t, "triggered", updatedOrder.Status)
 	})
 
+	t.Run("Trigger stop loss limit creates limit child order", func(t *testing.T) {
+		// Given: 止损限价卖单，跌破 48000 时以 47000 挂卖单
+		cleanupTestDB(t, db)
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+		createTestBalance(t, db, user.ID, "USDT", 0, 0)
+
+		stopLossPrice := 48000.0
+		price := 47000.0
+		stopOrder, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:        "BTC/USDT",
+			Side:          "sell",
+			Type:          "limit",
+			Amount:        decimal.NewFromFloat(0.5),
+			Price:         decimalPtr(price),
+			StopLossPrice: decimalPtr(stopLossPrice),
+		})
+		require.NoError(t, err)
+
+		bidPrice := 47500.0
+		db.Save(&model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: 47500.0,
+			BidPrice:  &bidPrice,
+		})
+
+		// When: 触发止盈止损检查
+		require.NoError(t, marketService.TriggerStopOrders())
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 生成限价子订单
+		var updatedOrder model.Order
+		db.First(&updatedOrder, stopOrder.ID)
+		assert.Equal(t, "triggered", updatedOrder.Status)
+
+		var children []model.Order
+		db.Where("parent_order_id = ?", stopOrder.ID).Find(&children)
+		require.Len(t, children, 1)
+		assert.Equal(t, "limit", children[0].Type)
+		require.NotNil(t, children[0].Price)
+		assert.Equal(t, price, children[0].Price.InexactFloat64())
+	})
+
+	t.Run("Stop market buy reserves with slippage buffer and fills above the trigger", func(t *testing.T) {
+		// Given: 滑点缓冲 5%，0.1 BTC 止损买单按 50000 * 1.05 冻结 5250
+		cleanupTestDB(t, db)
+		cfg.Trading.MarketBuySlippage = 0.05
+		defer func() { cfg.Trading.MarketBuySlippage = 0 }()
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+
+		stopOrder, err := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "buy", decimal.NewFromFloat(0.1), decimal.NewFromInt(50000))
+		require.NoError(t, err)
+		assert.Equal(t, "52500", stopOrder.ReservePrice.String())
+
+		// When: 价格涨破触发价，卖一价 51000 高于触发价
+		askPrice := 51000.0
+		db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: 50500.0, AskPrice: &askPrice})
+		require.NoError(t, marketService.TriggerStopOrders())
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 子市价单沿用冻结价格并按 51000 成交，冻结全部释放
+		var children []model.Order
+		db.Where("parent_order_id = ?", stopOrder.ID).Find(&children)
+		require.Len(t, children, 1)
+		assert.Equal(t, "52500", children[0].ReservePrice.String())
+		assert.Equal(t, "filled", children[0].Status)
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.True(t, balance.Locked.IsZero())
+		assert.Equal(t, "4900", balance.Available.String())
+	})
+
 	t.Run("Do not trigger when price condition not met", func(t *testing.T) {
 		// Given: 创建止损单
 		cleanupTestDB(t, db)
@@ -887,7 +1522,7 @@ func TestTriggerStopOrders(t *testing.T) {
 		createTestBalance(t, db, user.ID, "BTC", 1.0, 0.5)
 
 		stopPrice := 48000.0
-		stopOrder, _ := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", 0.5, stopPrice)
+		stopOrder, _ := orderService.CreateStopLossOrder(user.ID, "BTC/USDT", "sell", decimal.NewFromFloat(0.5), decimal.NewFromFloat(stopPrice))
 
 		// 创建行情（价格仍高于止损价）
 		bidPrice := 49000.0
@@ -912,11 +1547,185 @@ func TestTriggerStopOrders(t *testing.T) {
 	})
 }
 
+// TestTrailingStopOrder 测试跟踪止损
+func TestTrailingStopOrder(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := setupTestConfig(t)
+	logger := zap.NewNop()
+
+	balanceService := NewBalanceService(db, cfg, logger)
+	orderService := NewOrderService(db, cfg, logger, balanceService)
+	marketService := NewMarketService(db, cfg, logger)
+
+	setPrice := func(price float64) {
+		bidPrice := price
+		askPrice := price
+		require.NoError(t, db.Save(&model.Ticker{
+			Symbol:    "BTC/USDT",
+			LastPrice: price,
+			BidPrice:  &bidPrice,
+			AskPrice:  &askPrice,
+		}).Error)
+	}
+
+	t.Run("Sell trailing stop follows the high and triggers on pullback", func(t *testing.T) {
+		// Given: 当前价 50000，回撤 1000 触发卖出
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "BTC", 1.0, 0)
+		createTestBalance(t, db, user.ID, "USDT", 0, 0)
+		setPrice(50000.0)
+
+		trailingAmount := 1000.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:         "BTC/USDT",
+			Side:           "sell",
+			Type:           "trailing_stop",
+			Amount:         decimal.NewFromFloat(0.5),
+			TrailingAmount: decimalPtr(trailingAmount),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "trailing_stop", order.Type)
+		assert.Equal(t, 49000.0, order.StopPrice.InexactFloat64())
+		assert.Equal(t, 50000.0, order.Watermark.InexactFloat64())
+
+		// When: 价格上涨到 52000
+		setPrice(52000.0)
+		require.NoError(t, marketService.TriggerStopOrders())
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 最优价和触发价上移，订单未触发
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "new", updated.Status)
+		assert.Equal(t, 52000.0, updated.Watermark.InexactFloat64())
+		assert.Equal(t, 51000.0, updated.StopPrice.InexactFloat64())
+
+		// When: 价格回落到 51500，触发价不下移
+		setPrice(51500.0)
+		require.NoError(t, marketService.TriggerStopOrders())
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "new", updated.Status)
+		assert.Equal(t, 51000.0, updated.StopPrice.InexactFloat64())
+
+		// When: 价格跌破 51000
+		setPrice(50900.0)
+		require.NoError(t, marketService.TriggerStopOrders())
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 触发并生成市价卖单
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.Equal(t, "triggered", updated.Status)
+
+		var children []model.Order
+		db.Where("parent_order_id = ?", order.ID).Find(&children)
+		require.Len(t, children, 1)
+		assert.Equal(t, "market", children[0].Type)
+	})
+
+	t.Run("Buy trailing stop releases lock as the stop moves down", func(t *testing.T) {
+		// Given: 当前价 50000，回撤 2% 触发买入，按触发价 51000 冻结
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+		setPrice(50000.0)
+
+		trailingPercent := 2.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:          "BTC/USDT",
+			Side:            "buy",
+			Type:            "market",
+			Amount:          decimal.NewFromFloat(0.1),
+			TrailingPercent: decimalPtr(trailingPercent),
+		})
+		require.NoError(t, err)
+		assert.InDelta(t, 51000.0, order.StopPrice.InexactFloat64(), 1e-6)
+
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 5100.0, balance.Locked.InexactFloat64(), 1e-6)
+
+		// When: 价格下跌到 45000
+		setPrice(45000.0)
+		require.NoError(t, marketService.TriggerStopOrders())
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 触发价下移到 45900，多冻结的资金解冻
+		var updated model.Order
+		require.NoError(t, db.First(&updated, order.ID).Error)
+		assert.InDelta(t, 45900.0, updated.StopPrice.InexactFloat64(), 1e-6)
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 4590.0, balance.Locked.InexactFloat64(), 1e-6)
+
+		// And: 撤单后全部解冻
+		require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 10000.0, balance.Available.InexactFloat64(), 1e-6)
+		assert.InDelta(t, 0.0, balance.Locked.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("Concurrent trailing stop updates release the lock once", func(t *testing.T) {
+		// Given: 买入跟踪止损按触发价 51000 冻结 5100
+		user := createTestUser(t, db)
+		createTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+		setPrice(50000.0)
+
+		trailingPercent := 2.0
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:          "BTC/USDT",
+			Side:            "buy",
+			Type:            "market",
+			Amount:          decimal.NewFromFloat(0.1),
+			TrailingPercent: decimalPtr(trailingPercent),
+		})
+		require.NoError(t, err)
+
+		// When: 两个行情协程读到同一个旧订单，都按 45000 下移触发价
+		var first, second model.Order
+		require.NoError(t, db.First(&first, order.ID).Error)
+		require.NoError(t, db.First(&second, order.ID).Error)
+		mkt := marketService.markets.ForSymbol("BTC/USDT")
+		require.NoError(t, marketService.updateTrailingStop(&first, decimal.NewFromInt(45000), mkt))
+		require.NoError(t, marketService.updateTrailingStop(&second, decimal.NewFromInt(45000), mkt))
+
+		// Then: 差额只解冻一次
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		assert.InDelta(t, 4590.0, balance.Locked.InexactFloat64(), 1e-6)
+		assert.InDelta(t, 5410.0, balance.Available.InexactFloat64(), 1e-6)
+	})
+
+	t.Run("Reject invalid trailing params", func(t *testing.T) {
+		user := createTestUser(t, db)
+		trailingAmount := 1000.0
+		trailingPercent := 1.0
+
+		_, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:          "BTC/USDT",
+			Side:            "sell",
+			Type:            "trailing_stop",
+			Amount:          decimal.NewFromFloat(0.1),
+			TrailingAmount:  decimalPtr(trailingAmount),
+			TrailingPercent: decimalPtr(trailingPercent),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exactly one of trailingAmount or trailingPercent")
+
+		_, err = orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: "BTC/USDT",
+			Side:   "sell",
+			Type:   "trailing_stop",
+			Amount: decimal.NewFromFloat(0.1),
+		})
+		require.Error(t, err)
+	})
+}
+
 // cleanupTestDB 清理测试数据库
 func cleanupTestDB(t *testing.T, db *gorm.DB) {
 	t.Helper()
 	db.Exec("DELETE FROM trades")
 	db.Exec("DELETE FROM orders")
+	db.Exec("DELETE FROM order_lists")
 	db.Exec("DELETE FROM balances")
 	db.Exec("DELETE FROM tickers")
 }
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// PositionService 永续合约持仓服务：查询持仓和未实现盈亏，设置杠杆和保证金模式，调整逐仓保证金
+type PositionService struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	logger  *zap.Logger
+	markets *market.Registry
+}
+
+// NewPositionService 创建持仓服务
+func NewPositionService(db *gorm.DB, cfg *config.Config, logger *zap.Logger) *PositionService {
+	return &PositionService{
+		db:      db,
+		cfg:     cfg,
+		logger:  logger,
+		markets: market.NewRegistry(db, cfg),
+	}
+}
+
+// PositionInfo 持仓及按标记价格计算的未实现盈亏
+type PositionInfo struct {
+	Position      *model.Position
+	MarkPrice     decimal.Decimal
+	UnrealizedPnl decimal.Decimal
+}
+
+// GetPositions 查询用户未平仓的持仓（symbols 为空时返回全部交易对）
+func (s *PositionService) GetPositions(userID uint, symbols []string) ([]PositionInfo, error) {
+	query := s.db.Where("user_id = ? AND size > 0", userID)
+	if len(symbols) > 0 {
+		query = query.Where("symbol IN ?", symbols)
+	}
+
+	var positions []model.Position
+	if err := query.Order("symbol ASC").Find(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query positions: %w", err)
+	}
+
+	result := make([]PositionInfo, 0, len(positions))
+	for i := range positions {
+		info := PositionInfo{Position: &positions[i], MarkPrice: positions[i].EntryPrice}
+		if price, err := markPrice(s.db, s.cfg, positions[i].Symbol); err == nil {
+			info.MarkPrice = price
+		}
+		info.UnrealizedPnl = engine.UnrealizedPnl(&positions[i], info.MarkPrice)
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// SetLeverage 设置用户在永续合约交易对上的杠杆（CCXT setLeverage）
+// 有持仓或未完成订单时不能修改，保证冻结的保证金与持仓使用同一杠杆
+func (s *PositionService) SetLeverage(userID uint, symbol string, leverage int) (*model.Position, error) {
+	mkt, ok, err := s.markets.Get(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ccxt.NewError(ccxt.ErrBadSymbol, "unknown symbol %s", symbol)
+	}
+	if !mkt.IsSwap() {
+		return nil, fmt.Errorf("leverage can only be set on perpetual markets")
+	}
+	if limit := s.cfg.Trading.Perpetual.LeverageLimit(mkt.MaxLeverage); leverage < 1 || leverage > limit {
+		return nil, fmt.Errorf("leverage must be between 1 and %d", limit)
+	}
+
+	var pos *model.Position
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pos, err = engine.LockPosition(tx, userID, symbol, leverage)
+		if err != nil {
+			return err
+		}
+		if pos.Leverage == leverage {
+			return nil
+		}
+		if pos.Size.IsPositive() {
+			return fmt.Errorf("cannot change leverage with an open position")
+		}
+
+		var openOrders int64
+		if err := tx.Model(&model.Order{}).
+			Where("user_id = ? AND symbol = ? AND status IN ?", userID, symbol, openOrderStatuses).
+			Count(&openOrders).Error; err != nil {
+			return fmt.Errorf("failed to count open orders: %w", err)
+		}
+		if openOrders > 0 {
+			return fmt.Errorf("cannot change leverage with open orders")
+		}
+
+		pos.Leverage = leverage
+		if err := tx.Save(pos).Error; err != nil {
+			return fmt.Errorf("failed to update leverage: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Leverage updated",
+		zap.Uint("user_id", userID),
+		zap.String("symbol", symbol),
+		zap.Int("leverage", leverage),
+	)
+
+	return pos, nil
+}
+
+// SetMarginMode 设置用户在永续合约交易对上的保证金模式（CCXT setMarginMode）：cross 全仓 | isolated 逐仓
+// 全仓持仓共享结算币账户的全部余额，逐仓持仓只以仓位保证金承担亏损；有持仓或未完成订单时不能修改
+func (s *PositionService) SetMarginMode(userID uint, symbol, marginMode string) (*model.Position, error) {
+	if marginMode != engine.MarginModeCross && marginMode != engine.MarginModeIsolated {
+		return nil, ccxt.NewError(ccxt.ErrBadRequest, "marginMode must be %s or %s", engine.MarginModeCross, engine.MarginModeIsolated)
+	}
+	mkt, ok, err := s.markets.Get(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ccxt.NewError(ccxt.ErrBadSymbol, "unknown symbol %s", symbol)
+	}
+	if !mkt.IsSwap() {
+		return nil, fmt.Errorf("margin mode can only be set on perpetual markets")
+	}
+	perp := &s.cfg.Trading.Perpetual
+	leverage := perp.Leverage(perp.LeverageLimit(mkt.MaxLeverage))
+
+	var pos *model.Position
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pos, err = engine.LockPosition(tx, userID, symbol, leverage)
+		if err != nil {
+			return err
+		}
+		if pos.MarginMode == marginMode {
+			return nil
+		}
+		if pos.Size.IsPositive() {
+			return fmt.Errorf("cannot change margin mode with an open position")
+		}
+
+		var openOrders int64
+		if err := tx.Model(&model.Order{}).
+			Where("user_id = ? AND symbol = ? AND status IN ?", userID, symbol, openOrderStatuses).
+			Count(&openOrders).Error; err != nil {
+			return fmt.Errorf("failed to count open orders: %w", err)
+		}
+		if openOrders > 0 {
+			return fmt.Errorf("cannot change margin mode with open orders")
+		}
+
+		pos.MarginMode = marginMode
+		if err := tx.Save(pos).Error; err != nil {
+			return fmt.Errorf("failed to update margin mode: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Margin mode updated",
+		zap.Uint("user_id", userID),
+		zap.String("symbol", symbol),
+		zap.String("margin_mode", marginMode),
+	)
+
+	return pos, nil
+}
+
+// AddMargin 从结算币可用余额追加逐仓持仓的保证金（CCXT addMargin）
+func (s *PositionService) AddMargin(userID uint, symbol string, amount decimal.Decimal) (*model.Position, error) {
+	if !amount.IsPositive() {
+		return nil, ccxt.NewError(ccxt.ErrBadRequest, "amount must be positive")
+	}
+	return s.modifyMargin(userID, symbol, amount, decimal.Zero)
+}
+
+// ReduceMargin 减少逐仓持仓的保证金并转回可用余额（CCXT reduceMargin）
+// 减少后仓位保证金（计入未实现亏损）不得低于按标记价格计算的初始保证金
+func (s *PositionService) ReduceMargin(userID uint, symbol string, amount decimal.Decimal) (*model.Position, error) {
+	if !amount.IsPositive() {
+		return nil, ccxt.NewError(ccxt.ErrBadRequest, "amount must be positive")
+	}
+	price, err := markPrice(s.db, s.cfg, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return s.modifyMargin(userID, symbol, amount.Neg(), price)
+}
+
+// modifyMargin 调整逐仓持仓的保证金：delta 为正时追加，为负时减少（按 price 检查初始保证金）
+// 仓位保证金计入结算币冻结余额，追加和减少在可用与冻结之间划转
+func (s *PositionService) modifyMargin(userID uint, symbol string, delta, price decimal.Decimal) (*model.Position, error) {
+	_, _, settle := market.SplitSymbol(symbol)
+
+	var pos model.Position
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND symbol = ? AND size > 0", userID, symbol).
+			First(&pos).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ccxt.NewError(ccxt.ErrBadRequest, "no open position for %s", symbol)
+			}
+			return fmt.Errorf("failed to lock position: %w", err)
+		}
+		if pos.MarginMode != engine.MarginModeIsolated {
+			return ccxt.NewError(ccxt.ErrBadRequest, "margin can only be modified on isolated positions")
+		}
+
+		var balance model.Balance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND asset = ?", userID, settle).
+			First(&balance).Error; err != nil {
+			return fmt.Errorf("settle balance not found: %w", err)
+		}
+
+		if delta.IsPositive() {
+			if balance.Available.LessThan(delta) {
+				return ccxt.NewError(ccxt.ErrInsufficientFunds,
+					"insufficient balance: available %s, required %s", balance.Available, delta)
+			}
+		} else {
+			remaining := pos.Margin.Add(delta).Add(decimal.Min(engine.UnrealizedPnl(&pos, price), decimal.Zero))
+			if required := engine.InitialMargin(pos.Size, price, pos.Leverage); remaining.LessThan(required) {
+				return ccxt.NewError(ccxt.ErrInsufficientFunds,
+					"margin after reduction would be %s, initial margin %s", remaining, required)
+			}
+		}
+
+		pos.Margin = pos.Margin.Add(delta)
+		balance.Available = balance.Available.Sub(delta)
+		balance.Locked = balance.Locked.Add(delta)
+		if err := tx.Save(&pos).Error; err != nil {
+			return fmt.Errorf("failed to update position margin: %w", err)
+		}
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed to update settle balance: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Position margin modified",
+		zap.Uint("user_id", userID),
+		zap.String("symbol", symbol),
+		zap.Stringer("delta", delta),
+		zap.Stringer("margin", pos.Margin),
+	)
+
+	return &pos, nil
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestPerpetualTrading 测试永续合约的杠杆设置、保证金冻结和持仓查询
+func TestPerpetualTrading(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	cfg.Market.Symbols = append(cfg.Market.Symbols, "BTC/USDT:USDT")
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+	positionService := NewPositionService(db, cfg, logger)
+
+	const symbol = "BTC/USDT:USDT"
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 10000.0, 0)
+
+	usdt := func() model.Balance {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", user.ID, "USDT").First(&balance).Error)
+		return balance
+	}
+
+	t.Run("Set leverage", func(t *testing.T) {
+		_, err := positionService.SetLeverage(user.ID, "BTC/USDT", 10)
+		assert.Error(t, err)
+
+		_, err = positionService.SetLeverage(user.ID, symbol, 51)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "between 1 and 50")
+
+		pos, err := positionService.SetLeverage(user.ID, symbol, 20)
+		require.NoError(t, err)
+		assert.Equal(t, 20, pos.Leverage)
+		assert.Equal(t, "cross", pos.MarginMode)
+	})
+
+	t.Run("Limit order reserves margin instead of notional", func(t *testing.T) {
+		// When: 20 倍杠杆下 0.1 BTC @ 40000 的限价买单
+		order, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: symbol,
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.1),
+			Price:  decimalPtr(40000),
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 冻结保证金 4000 / 20 = 200 USDT
+		assert.Equal(t, 20, order.Leverage)
+		assert.Equal(t, "200", usdt().Locked.String())
+
+		// And: 有未完成订单时不能修改杠杆
+		_, err = positionService.SetLeverage(user.ID, symbol, 10)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "open orders")
+
+		// When: 撤单后保证金全部释放
+		require.NoError(t, orderService.CancelOrder(user.ID, order.ID))
+		assert.True(t, usdt().Locked.IsZero())
+	})
+
+	t.Run("Sell opens a short and positions report unrealized PnL", func(t *testing.T) {
+		bidPrice, askPrice := 50000.0, 50010.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: symbol, LastPrice: 50000.0, BidPrice: &bidPrice, AskPrice: &askPrice}).Error)
+
+		// When: 市价卖出 0.2 BTC（开空，不需要持有 BTC）
+		_, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: symbol,
+			Side:   "sell",
+			Type:   "market",
+			Amount: decimal.NewFromFloat(0.2),
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		// Then: 空仓 0.2 @ 50000，保证金 500
+		positions, err := positionService.GetPositions(user.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, positions, 1)
+		assert.Equal(t, "short", positions[0].Position.Side)
+		assert.Equal(t, "0.2", positions[0].Position.Size.String())
+		assert.Equal(t, "500", positions[0].Position.Margin.String())
+		assert.Equal(t, "500", usdt().Locked.String())
+
+		// When: 标记价格（盘口中间价）上涨到 51000
+		require.NoError(t, db.Model(&model.Ticker{}).Where("symbol = ?", symbol).
+			Updates(map[string]interface{}{"bid_price": 50995.0, "ask_price": 51005.0}).Error)
+
+		// Then: 未实现亏损 200
+		positions, err = positionService.GetPositions(user.ID, []string{symbol})
+		require.NoError(t, err)
+		require.Len(t, positions, 1)
+		assert.Equal(t, "-200", positions[0].UnrealizedPnl.String())
+
+		// And: 有持仓时不能修改杠杆
+		_, err = positionService.SetLeverage(user.ID, symbol, 5)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "open position")
+	})
+
+	t.Run("Stop and cost orders are rejected", func(t *testing.T) {
+		_, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol:    symbol,
+			Side:      "sell",
+			Type:      "market",
+			Amount:    decimal.NewFromFloat(0.1),
+			StopPrice: decimalPtr(45000),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only support market and limit orders")
+
+		_, err = orderService.CreateStopLossOrder(user.ID, symbol, "sell", decimal.NewFromFloat(0.1), decimal.NewFromInt(45000))
+		assert.Error(t, err)
+	})
+}
+
+// TestMarginModes 测试逐仓/全仓保证金模式切换、逐仓保证金调整和下单前的全仓组合保证金检查
+func TestMarginModes(t *testing.T) {
+	const btc, eth = "BTC/USDT:USDT", "ETH/USDT:USDT"
+
+	db := testutil.NewTestDB(t)
+	cfg := testutil.NewTestConfig()
+	cfg.Market.Symbols = append(cfg.Market.Symbols, btc, eth)
+	logger := testutil.NewTestLogger()
+	orderService := NewOrderService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+	positionService := NewPositionService(db, cfg, logger)
+
+	setTicker := func(t *testing.T, symbol string, bid, ask float64) {
+		require.NoError(t, db.Save(&model.Ticker{Symbol: symbol, LastPrice: bid, BidPrice: &bid, AskPrice: &ask}).Error)
+	}
+	usdt := func(t *testing.T, userID uint) model.Balance {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, "USDT").First(&balance).Error)
+		return balance
+	}
+
+	setTicker(t, btc, 50000, 50010)
+	user := testutil.CreateTestUser(t, db)
+	testutil.CreateTestBalance(t, db, user.ID, "USDT", 10000, 0)
+
+	t.Run("Set margin mode", func(t *testing.T) {
+		_, err := positionService.SetMarginMode(user.ID, btc, "portfolio")
+		assert.Equal(t, ccxt.ErrBadRequest, ccxt.ErrorCode(err))
+
+		_, err = positionService.SetMarginMode(user.ID, "BTC/USDT", engine.MarginModeIsolated)
+		assert.Error(t, err)
+
+		_, err = positionService.SetLeverage(user.ID, btc, 10)
+		require.NoError(t, err)
+		pos, err := positionService.SetMarginMode(user.ID, btc, engine.MarginModeIsolated)
+		require.NoError(t, err)
+		assert.Equal(t, engine.MarginModeIsolated, pos.MarginMode)
+		assert.Equal(t, 10, pos.Leverage)
+	})
+
+	t.Run("Isolated margin can be added and reduced", func(t *testing.T) {
+		// Given: 逐仓 10 倍杠杆市价买入 0.2 BTC @ 50010，仓位保证金 1000.2
+		_, err := orderService.CreateOrder(user.ID, CreateOrderRequest{
+			Symbol: btc,
+			Side:   "buy",
+			Type:   "market",
+			Amount: decimal.NewFromFloat(0.2),
+		})
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
+		_, err = positionService.SetMarginMode(user.ID, btc, engine.MarginModeCross)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "open position")
+
+		// When: 追加保证金 500
+		pos, err := positionService.AddMargin(user.ID, btc, decimal.NewFromInt(500))
+		require.NoError(t, err)
+
+		// Then: 仓位保证金 1500.2，从可用余额划入冻结余额（10000 - 1000.2 - 手续费 10.002 - 500）
+		assert.Equal(t, "1500.2", pos.Margin.String())
+		assert.Equal(t, "1500.2", usdt(t, user.ID).Locked.String())
+		assert.Equal(t, "8489.798", usdt(t, user.ID).Available.String())
+
+		// When: 标记价格 50005，未实现亏损 1，初始保证金 1000.1，最多可减少 499.1
+		_, err = positionService.ReduceMargin(user.ID, btc, decimal.NewFromInt(600))
+		assert.Equal(t, ccxt.ErrInsufficientFunds, ccxt.ErrorCode(err))
+
+		pos, err = positionService.ReduceMargin(user.ID, btc, decimal.NewFromInt(400))
+		require.NoError(t, err)
+		assert.Equal(t, "1100.2", pos.Margin.String())
+		assert.Equal(t, "8889.798", usdt(t, user.ID).Available.String())
+
+		_, err = positionService.AddMargin(user.ID, eth, decimal.NewFromInt(100))
+		assert.Equal(t, ccxt.ErrBadRequest, ccxt.ErrorCode(err))
+	})
+
+	t.Run("Cross unrealized losses reduce margin available for new orders", func(t *testing.T) {
+		// Given: 全仓多仓 1 ETH @ 3000（保证金 300），可用余额 700
+		trader := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, trader.ID, "USDT", 700, 300)
+		require.NoError(t, db.Create(&model.Position{
+			UserID:     trader.ID,
+			Symbol:     eth,
+			Side:       "long",
+			Size:       decimal.NewFromInt(1),
+			EntryPrice: decimal.NewFromInt(3000),
+			Leverage:   10,
+			MarginMode: engine.MarginModeCross,
+			Margin:     decimal.NewFromInt(300),
+		}).Error)
+
+		_, err := positionService.AddMargin(trader.ID, eth, decimal.NewFromInt(100))
+		assert.Equal(t, ccxt.ErrBadRequest, ccxt.ErrorCode(err))
+
+		// When: 标记价格 2500，未实现亏损 500，可用保证金 700 - 500 = 200
+		setTicker(t, eth, 2499, 2501)
+		_, err = orderService.CreateOrder(trader.ID, CreateOrderRequest{
+			Symbol: btc,
+			Side:   "buy",
+			Type:   "limit",
+			Amount: decimal.NewFromFloat(0.01),
+			Price:  decimalPtr(40000),
+		})
+
+		// Then: 默认 1 倍杠杆下新订单初始保证金 400 超过可用保证金
+		require.Error(t, err)
+		assert.Equal(t, ccxt.ErrInsufficientFunds, ccxt.ErrorCode(err))
+		assert.Equal(t, "700", usdt(t, trader.ID).Available.String())
+
+		// And: 平仓单只减少风险，不受组合保证金限制
+		order, err := orderService.CreateOrder(trader.ID, CreateOrderRequest{
+			Symbol: eth,
+			Side:   "sell",
+			Type:   "limit",
+			Amount: decimal.NewFromInt(1),
+			Price:  decimalPtr(5000),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "new", order.Status)
+	})
+}
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/engine"
+	"github.com/talkincode/quicksilver/internal/market"
+	"github.com/talkincode/quicksilver/internal/model"
+)
+
+// marginValuationAsset 现货杠杆账户的估值资产，其他资产按 ASSET/USDT 最新价折算
+const marginValuationAsset = "USDT"
+
+// SpotMarginService 现货杠杆借贷服务：以账户全部资产为抵押借入资产，按小时计息，保证金水平过低时自动强平
+type SpotMarginService struct {
+	db             *gorm.DB
+	cfg            *config.Config
+	logger         *zap.Logger
+	balanceService *BalanceService
+	running        sync.Mutex // 行情更新频繁触发检查，上一次检查未结束时跳过
+}
+
+// NewSpotMarginService 创建现货杠杆借贷服务
+func NewSpotMarginService(db *gorm.DB, cfg *config.Config, logger *zap.Logger, balanceService *BalanceService) *SpotMarginService {
+	return &SpotMarginService{
+		db:             db,
+		cfg:            cfg,
+		logger:         logger,
+		balanceService: balanceService,
+	}
+}
+
+// SpotMarginAccount 用户的现货杠杆账户，资产和负债按 USDT 估值
+type SpotMarginAccount struct {
+	UserID     uint
+	TotalAsset decimal.Decimal // (可用 + 冻结 - 永续合约占用) * 价格之和，没有行情的资产不计入
+	TotalDebt  decimal.Decimal // (借款 + 未还利息) * 价格之和
+}
+
+// Level 保证金水平 = 总资产 / 总负债，没有负债时返回 0
+func (a *SpotMarginAccount) Level() decimal.Decimal {
+	if !a.TotalDebt.IsPositive() {
+		return decimal.Zero
+	}
+	return a.TotalAsset.Div(a.TotalDebt)
+}
+
+// Below 账户有负债且保证金水平低于 level
+func (a *SpotMarginAccount) Below(level float64) bool {
+	return a.TotalDebt.IsPositive() && a.TotalAsset.LessThan(a.TotalDebt.Mul(decimal.NewFromFloat(level)))
+}
+
+// marginAssetPrice 资产的 USDT 价格，没有 ASSET/USDT 行情时返回错误
+func marginAssetPrice(db *gorm.DB, asset string) (decimal.Decimal, error) {
+	if asset == marginValuationAsset {
+		return decimal.NewFromInt(1), nil
+	}
+	var ticker model.Ticker
+	if err := db.Where("symbol = ?", asset+"/"+marginValuationAsset).Limit(1).Find(&ticker).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get ticker: %w", err)
+	}
+	if ticker.LastPrice <= 0 {
+		return decimal.Zero, fmt.Errorf("no price for %s", asset)
+	}
+	return decimal.NewFromFloat(ticker.LastPrice), nil
+}
+
+// GetAccount 按最新价计算用户现货杠杆账户的总资产和总负债，负债资产缺少行情时返回错误
+func (s *SpotMarginService) GetAccount(userID uint) (*SpotMarginAccount, error) {
+	return marginAccount(s.db, s.cfg, userID)
+}
+
+// marginAccount 在 db（可以是事务）中计算现货杠杆账户，永续合约占用的结算币不计入抵押资产
+func marginAccount(db *gorm.DB, cfg *config.Config, userID uint) (*SpotMarginAccount, error) {
+	var balances []model.Balance
+	if err := db.Where("user_id = ?", userID).Find(&balances).Error; err != nil {
+		return nil, fmt.Errorf("failed to get balances: %w", err)
+	}
+	perp, err := perpCommitted(db, cfg, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &SpotMarginAccount{UserID: userID}
+	for _, balance := range balances {
+		debt := balance.Borrowed.Add(balance.Interest)
+		price, err := marginAssetPrice(db, balance.Asset)
+		if err != nil {
+			if debt.IsPositive() {
+				return nil, err
+			}
+			continue
+		}
+		collateral := balance.Available.Add(balance.Locked).Sub(perp[balance.Asset])
+		account.TotalAsset = account.TotalAsset.Add(collateral.Mul(price))
+		account.TotalDebt = account.TotalDebt.Add(debt.Mul(price))
+	}
+	return account, nil
+}
+
+// perpCommitted 按结算币汇总永续合约占用的资金：持仓保证金、合约挂单冻结的保证金，
+// 以及全仓持仓按标记价格超过保证金的未实现亏损（逐仓亏损以仓位保证金为限）
+// 这些资金计入冻结余额但强平现货杠杆账户时不会释放，不能作为现货借款的抵押
+func perpCommitted(db *gorm.DB, cfg *config.Config, userID uint) (map[string]decimal.Decimal, error) {
+	committed := make(map[string]decimal.Decimal)
+
+	var positions []model.Position
+	if err := db.Where("user_id = ? AND size > 0", userID).Find(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	for i := range positions {
+		pos := &positions[i]
+		_, _, settle := market.SplitSymbol(pos.Symbol)
+		amount := pos.Margin
+		if pos.MarginMode != engine.MarginModeIsolated {
+			price, err := markPrice(db, cfg, pos.Symbol)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			if err == nil {
+				if loss := pos.Margin.Add(engine.UnrealizedPnl(pos, price)); loss.IsNegative() {
+					amount = amount.Sub(loss)
+				}
+			}
+		}
+		committed[settle] = committed[settle].Add(amount)
+	}
+
+	var orders []model.Order
+	if err := db.Where("user_id = ? AND leverage > 0 AND status IN ?", userID, []string{"new", "partially_filled"}).
+		Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to get open orders: %w", err)
+	}
+	for i := range orders {
+		_, quote, _ := market.SplitSymbol(orders[i].Symbol)
+		committed[quote] = committed[quote].Add(engine.RemainingReservation(&orders[i]))
+	}
+	return committed, nil
+}
+
+// BorrowMargin 借入资产（CCXT borrowMargin）：资产须可借入，不超过单用户借款上限，借款后保证金水平不低于 borrow_level
+func (s *SpotMarginService) BorrowMargin(userID uint, asset string, amount decimal.Decimal) (*model.MarginLoan, error) {
+	assetCfg, ok := s.cfg.Trading.SpotMargin.ForAsset(asset)
+	if !ok {
+		return nil, ccxt.NewError(ccxt.ErrBadRequest, "%s is not available for margin borrowing", asset)
+	}
+	if !amount.IsPositive() {
+		return nil, ccxt.NewError(ccxt.ErrBadRequest, "amount must be positive")
+	}
+
+	var loan *model.MarginLoan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户全部余额，同一用户的并发借款串行执行，额度和保证金水平按锁定后的余额检查
+		var balances []model.Balance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).Find(&balances).Error; err != nil {
+			return fmt.Errorf("failed to lock balances: %w", err)
+		}
+
+		if assetCfg.MaxBorrow > 0 {
+			borrowed := decimal.Zero
+			for _, balance := range balances {
+				if balance.Asset == asset {
+					borrowed = balance.Borrowed
+				}
+			}
+			if limit := decimal.NewFromFloat(assetCfg.MaxBorrow); borrowed.Add(amount).GreaterThan(limit) {
+				return ccxt.NewError(ccxt.ErrInsufficientFunds,
+					"borrow limit exceeded: borrowed %s, max %s", borrowed, limit)
+			}
+		}
+
+		price, err := marginAssetPrice(tx, asset)
+		if err != nil {
+			return err
+		}
+		account, err := marginAccount(tx, s.cfg, userID)
+		if err != nil {
+			return err
+		}
+		value := amount.Mul(price)
+		account.TotalAsset = account.TotalAsset.Add(value)
+		account.TotalDebt = account.TotalDebt.Add(value)
+		if minLevel := s.cfg.Trading.SpotMargin.MinBorrowLevel(); account.Below(minLevel) {
+			return ccxt.NewError(ccxt.ErrInsufficientFunds,
+				"margin level after borrowing would be %s, minimum %v", account.Level().StringFixed(4), minLevel)
+		}
+
+		loan, err = s.balanceService.WithTx(tx).Borrow(userID, asset, amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// RepayMargin 归还借款（CCXT repayMargin），先还利息再还本金
+func (s *SpotMarginService) RepayMargin(userID uint, asset string, amount decimal.Decimal) (*model.MarginLoan, error) {
+	if !amount.IsPositive() {
+		return nil, ccxt.NewError(ccxt.ErrBadRequest, "amount must be positive")
+	}
+	loan, err := s.balanceService.Repay(userID, asset, amount)
+	if err != nil {
+		return nil, ccxt.WithCode(ccxt.ErrInsufficientFunds, err)
+	}
+	return loan, nil
+}
+
+// StartScheduler 启动借款计息调度，每个整点计提上一小时的利息并检查保证金水平
+func (s *SpotMarginService) StartScheduler() {
+	if !s.cfg.Trading.SpotMargin.Enabled() {
+		return
+	}
+
+	go func() {
+		for {
+			next := time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
+			time.Sleep(time.Until(next))
+			if err := s.AccrueInterest(next); err != nil {
+				s.logger.Error("Failed to accrue borrow interest", zap.Time("accrued_at", next), zap.Error(err))
+			}
+			if err := s.CheckMarginLevels(); err != nil {
+				s.logger.Error("Failed to check margin levels", zap.Error(err))
+			}
+		}
+	}()
+
+	s.logger.Info("Borrow interest scheduler started",
+		zap.Int("assets", len(s.cfg.Trading.SpotMargin.Assets)),
+	)
+}
+
+// AccrueInterest 按各资产的小时利率计提 accruedAt 所在整点的借款利息，同一小时重复调用不会重复计息
+func (s *SpotMarginService) AccrueInterest(accruedAt time.Time) error {
+	accruedAt = accruedAt.UTC().Truncate(time.Hour)
+
+	var balances []model.Balance
+	if err := s.db.Where("borrowed > 0").Order("id ASC").Find(&balances).Error; err != nil {
+		return fmt.Errorf("failed to query borrowed balances: %w", err)
+	}
+
+	for _, balance := range balances {
+		rate := s.cfg.Trading.SpotMargin.HourlyRate
+		if assetCfg, ok := s.cfg.Trading.SpotMargin.ForAsset(balance.Asset); ok {
+			rate = assetCfg.HourlyRate
+		}
+		if _, err := s.balanceService.AccrueInterest(balance.UserID, balance.Asset, decimal.NewFromFloat(rate), accruedAt); err != nil {
+			return fmt.Errorf("user %d %s: %w", balance.UserID, balance.Asset, err)
+		}
+	}
+	return nil
+}
+
+// CheckMarginLevels 检查所有有负债的账户，保证金水平低于 liquidation_level 时强平
+func (s *SpotMarginService) CheckMarginLevels() error {
+	if !s.running.TryLock() {
+		return nil
+	}
+	defer s.running.Unlock()
+
+	var userIDs []uint
+	if err := s.db.Model(&model.Balance{}).
+		Where("borrowed > 0 OR interest > 0").
+		Distinct().Order("user_id ASC").
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return fmt.Errorf("failed to query borrowers: %w", err)
+	}
+
+	minLevel := s.cfg.Trading.SpotMargin.MinLiquidationLevel()
+	for _, userID := range userIDs {
+		account, err := s.GetAccount(userID)
+		if err != nil {
+			s.logger.Debug("Skip margin level check", zap.Uint("user_id", userID), zap.Error(err))
+			continue
+		}
+		if !account.Below(minLevel) {
+			continue
+		}
+		if err := s.liquidate(account); err != nil {
+			s.logger.Error("Failed to liquidate margin account", zap.Uint("user_id", userID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// liquidate 强平现货杠杆账户：撤销现货挂单，用同资产余额还款，卖出其余资产换成 USDT，
+// 再用 USDT 买回并归还负债；所有强平单全部成交后仍未还清的负债按 USDT 价值由保险基金核销
+func (s *SpotMarginService) liquidate(account *SpotMarginAccount) error {
+	s.logger.Warn("Margin level below liquidation level, liquidating",
+		zap.Uint("user_id", account.UserID),
+		zap.Stringer("total_asset", account.TotalAsset),
+		zap.Stringer("total_debt", account.TotalDebt))
+
+	matchEngine := engine.NewMatchingEngine(s.db, s.cfg, s.logger)
+	var symbols []string
+	if err := s.db.Model(&model.Order{}).
+		Where("user_id = ? AND leverage = 0 AND status IN ?", account.UserID, []string{"new", "partially_filled"}).
+		Distinct().Pluck("symbol", &symbols).Error; err != nil {
+		return fmt.Errorf("failed to query open orders: %w", err)
+	}
+	if len(symbols) > 0 {
+		if err := matchEngine.CancelForLiquidation(account.UserID, symbols); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repayFromAvailable(account.UserID); err != nil {
+		return err
+	}
+
+	balances, err := s.balanceService.GetAllBalances(account.UserID)
+	if err != nil {
+		return err
+	}
+
+	complete := true
+	for _, balance := range balances {
+		if balance.Asset == marginValuationAsset || !balance.Available.IsPositive() {
+			continue
+		}
+		// 没有行情的资产不计入抵押，也无法卖出
+		if _, err := marginAssetPrice(s.db, balance.Asset); err != nil {
+			continue
+		}
+		filled, err := s.executeLiquidationOrder(matchEngine, &model.Order{
+			UserID: account.UserID,
+			Symbol: balance.Asset + "/" + marginValuationAsset,
+			Side:   "sell",
+			Amount: balance.Available,
+		}, balance.Asset, balance.Available)
+		if err != nil {
+			return err
+		}
+		complete = complete && filled
+	}
+	if err := s.repayFromAvailable(account.UserID); err != nil {
+		return err
+	}
+
+	if balances, err = s.balanceService.GetAllBalances(account.UserID); err != nil {
+		return err
+	}
+	for _, balance := range balances {
+		debt := balance.Borrowed.Add(balance.Interest)
+		if balance.Asset == marginValuationAsset || !debt.IsPositive() {
+			continue
+		}
+		filled, err := s.buyBack(matchEngine, account.UserID, balance.Asset, debt)
+		if err != nil {
+			return err
+		}
+		complete = complete && filled
+	}
+	if err := s.repayFromAvailable(account.UserID); err != nil {
+		return err
+	}
+
+	if !complete {
+		s.logger.Warn("Margin liquidation incomplete, retrying on next check", zap.Uint("user_id", account.UserID))
+		return nil
+	}
+	return s.writeOffDebt(account.UserID)
+}
+
+// buyBack 用可用 USDT 市价买回负债资产，买单手续费以基础币收取，数量按吃单费率上浮；USDT 不足时按余额买入
+func (s *SpotMarginService) buyBack(matchEngine *engine.MatchingEngine, userID uint, asset string, debt decimal.Decimal) (bool, error) {
+	usdt, err := s.balanceService.GetBalance(userID, marginValuationAsset)
+	if err != nil || !usdt.Available.IsPositive() {
+		return true, nil
+	}
+	symbol := asset + "/" + marginValuationAsset
+	var ticker model.Ticker
+	if err := s.db.Where("symbol = ?", symbol).First(&ticker).Error; err != nil {
+		return false, fmt.Errorf("ticker not found for %s: %w", symbol, err)
+	}
+	// 市价买单按卖一价成交
+	price := decimal.NewFromFloat(ticker.LastPrice)
+	if ticker.AskPrice != nil && *ticker.AskPrice > 0 {
+		price = decimal.NewFromFloat(*ticker.AskPrice)
+	}
+
+	amount := engine.CeilAsset(debt.Div(decimal.NewFromInt(1).Sub(decimal.NewFromFloat(s.cfg.Trading.TakerFeeRate))))
+	slippage := decimal.NewFromInt(1).Add(decimal.NewFromFloat(s.cfg.Trading.MarketBuySlippage))
+	budget := decimal.Min(usdt.Available, engine.CeilAsset(amount.Mul(price).Mul(slippage)))
+
+	return s.executeLiquidationOrder(matchEngine, &model.Order{
+		UserID:        userID,
+		Symbol:        symbol,
+		Side:          "buy",
+		Amount:        amount,
+		QuoteOrderQty: &budget,
+	}, marginValuationAsset, budget)
+}
+
+// executeLiquidationOrder 冻结资金并创建强平市价单，立即撮合并撤销未成交部分，返回订单是否全部成交
+func (s *SpotMarginService) executeLiquidationOrder(matchEngine *engine.MatchingEngine, order *model.Order, asset string, reserve decimal.Decimal) (bool, error) {
+	order.Type = "market"
+	order.TimeInForce = engine.TimeInForceIOC
+	order.Status = "new"
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.balanceService.WithTx(tx).FreezeBalance(order.UserID, asset, reserve); err != nil {
+			return err
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("failed to create liquidation order: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+
+	if err := matchEngine.ExecuteLiquidation(order); err != nil {
+		return false, err
+	}
+	return order.Status == "filled", nil
+}
+
+// repayFromAvailable 用同资产的可用余额尽量归还负债
+func (s *SpotMarginService) repayFromAvailable(userID uint) error {
+	balances, err := s.balanceService.GetAllBalances(userID)
+	if err != nil {
+		return err
+	}
+	for _, balance := range balances {
+		amount := decimal.Min(balance.Available, balance.Borrowed.Add(balance.Interest))
+		if !amount.IsPositive() {
+			continue
+		}
+		if _, err := s.balanceService.repay(userID, balance.Asset, amount, LoanTypeLiquidation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOffDebt 核销强平后仍未还清的负债，按最新价折算的 USDT 价值从保险基金扣除
+func (s *SpotMarginService) writeOffDebt(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var balances []model.Balance
+		if err := tx.Where("user_id = ? AND (borrowed > 0 OR interest > 0)", userID).Find(&balances).Error; err != nil {
+			return fmt.Errorf("failed to query debts: %w", err)
+		}
+		if len(balances) == 0 {
+			return nil
+		}
+
+		fund, err := engine.LockInsuranceFund(tx, s.cfg, marginValuationAsset)
+		if err != nil {
+			return err
+		}
+		for i := range balances {
+			balance := &balances[i]
+			price, err := marginAssetPrice(tx, balance.Asset)
+			if err != nil {
+				return err
+			}
+			fund.Balance = fund.Balance.Sub(engine.CeilAsset(balance.Borrowed.Add(balance.Interest).Mul(price)))
+
+			loan := &model.MarginLoan{
+				UserID:   userID,
+				Asset:    balance.Asset,
+				Type:     LoanTypeLiquidation,
+				Amount:   balance.Borrowed,
+				Interest: balance.Interest,
+			}
+			balance.Borrowed, balance.Interest = decimal.Zero, decimal.Zero
+			if err := tx.Save(balance).Error; err != nil {
+				return fmt.Errorf("failed to write off debt: %w", err)
+			}
+			if err := tx.Create(loan).Error; err != nil {
+				return fmt.Errorf("failed to record loan: %w", err)
+			}
+
+			s.logger.Warn("Margin debt written off by insurance fund",
+				zap.Uint("user_id", userID),
+				zap.String("asset", balance.Asset),
+				zap.Stringer("principal", loan.Amount),
+				zap.Stringer("interest", loan.Interest))
+		}
+
+		if err := tx.Save(fund).Error; err != nil {
+			return fmt.Errorf("failed to update insurance fund: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetBorrowInterest 查询用户的借款利息计提记录（CCXT fetchBorrowInterest），按计息时间升序
+func (s *SpotMarginService) GetBorrowInterest(userID uint, asset string, since *time.Time, limit int) ([]model.BorrowInterest, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := s.db.Where("user_id = ?", userID)
+	if asset != "" {
+		query = query.Where("asset = ?", asset)
+	}
+	if since != nil {
+		query = query.Where("accrued_at >= ?", since.UTC())
+	}
+
+	var records []model.BorrowInterest
+	if err := query.Order("accrued_at ASC, id ASC").Limit(limit).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query borrow interest: %w", err)
+	}
+	return records, nil
+}
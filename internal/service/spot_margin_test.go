@@ -0,0 +1,244 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/talkincode/quicksilver/internal/ccxt"
+	"github.com/talkincode/quicksilver/internal/config"
+	"github.com/talkincode/quicksilver/internal/model"
+	"github.com/talkincode/quicksilver/internal/testutil"
+)
+
+// TestSpotMargin 测试现货杠杆借币额度、按小时计息、还款顺序和保证金水平过低时的自动强平
+func TestSpotMargin(t *testing.T) {
+	setup := func(t *testing.T) (*gorm.DB, *SpotMarginService) {
+		db := testutil.NewTestDB(t)
+		cfg := testutil.NewTestConfig()
+		cfg.Trading.SpotMargin = config.SpotMarginConfig{
+			HourlyRate: 0.0001,
+			Assets: []config.BorrowAssetConfig{
+				{Asset: "USDT"},
+				{Asset: "BTC", MaxBorrow: 1},
+			},
+		}
+		cfg.Trading.Liquidation.InsuranceFund = 1000
+		logger := testutil.NewTestLogger()
+		return db, NewSpotMarginService(db, cfg, logger, NewBalanceService(db, cfg, logger))
+	}
+
+	setTicker := func(t *testing.T, db *gorm.DB, last float64) {
+		bid, ask := last-10, last+10
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT", LastPrice: last, BidPrice: &bid, AskPrice: &ask}).Error)
+	}
+
+	balanceOf := func(t *testing.T, db *gorm.DB, userID uint, asset string) model.Balance {
+		var balance model.Balance
+		require.NoError(t, db.Where("user_id = ? AND asset = ?", userID, asset).First(&balance).Error)
+		return balance
+	}
+
+	t.Run("Borrowing is limited by asset config and margin level", func(t *testing.T) {
+		db, margin := setup(t)
+		setTicker(t, db, 50000)
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 10000, 0)
+
+		_, err := margin.BorrowMargin(user.ID, "ETH", decimal.NewFromInt(1))
+		assert.Equal(t, ccxt.ErrBadRequest, ccxt.ErrorCode(err))
+
+		_, err = margin.BorrowMargin(user.ID, "BTC", decimal.NewFromInt(2))
+		assert.Equal(t, ccxt.ErrInsufficientFunds, ccxt.ErrorCode(err))
+
+		// 借入 0.2 BTC（10000 USDT）后保证金水平 = 20000 / 10000 = 2
+		loan, err := margin.BorrowMargin(user.ID, "BTC", decimal.NewFromFloat(0.2))
+		require.NoError(t, err)
+		assert.Equal(t, LoanTypeBorrow, loan.Type)
+
+		btc := balanceOf(t, db, user.ID, "BTC")
+		assert.Equal(t, "0.2", btc.Available.String())
+		assert.Equal(t, "0.2", btc.Borrowed.String())
+
+		account, err := margin.GetAccount(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "2", account.Level().String())
+
+		// 再借任何数量都会低于 borrow_level
+		_, err = margin.BorrowMargin(user.ID, "USDT", decimal.NewFromInt(1))
+		assert.Equal(t, ccxt.ErrInsufficientFunds, ccxt.ErrorCode(err))
+	})
+
+	t.Run("Perpetual margin, order reservations and cross losses are not spot collateral", func(t *testing.T) {
+		db, margin := setup(t)
+		user := testutil.CreateTestUser(t, db)
+
+		// Given: 冻结 700 USDT = 多仓保证金 500 + 合约限价买单冻结 0.05 * 40000 / 10 = 200
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 1000, 700)
+		require.NoError(t, db.Create(&model.Position{
+			UserID: user.ID, Symbol: "BTC/USDT:USDT", Side: "long", Size: decimal.NewFromFloat(0.1),
+			EntryPrice: decimal.NewFromInt(50000), Leverage: 10, Margin: decimal.NewFromInt(500), MarginMode: "cross",
+		}).Error)
+		require.NoError(t, db.Create(&model.Order{
+			UserID: user.ID, Symbol: "BTC/USDT:USDT", Side: "buy", Type: "limit", Status: "new",
+			Amount: decimal.NewFromFloat(0.05), Price: decimalPtr(40000), Leverage: 10,
+		}).Error)
+		// 标记价格 44000，未实现亏损 600 超过保证金 100
+		bid, ask := 43990.0, 44010.0
+		require.NoError(t, db.Save(&model.Ticker{Symbol: "BTC/USDT:USDT", LastPrice: 44000, BidPrice: &bid, AskPrice: &ask}).Error)
+
+		// When: 借入 500 USDT
+		_, err := margin.BorrowMargin(user.ID, "USDT", decimal.NewFromInt(500))
+		require.NoError(t, err)
+
+		// Then: 抵押资产 = 1500 + 700 - 500 - 200 - 100 = 1400
+		account, err := margin.GetAccount(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "1400", account.TotalAsset.String())
+		assert.Equal(t, "500", account.TotalDebt.String())
+
+		// 再借 500 后保证金水平 1900 / 1000 低于 2
+		_, err = margin.BorrowMargin(user.ID, "USDT", decimal.NewFromInt(500))
+		assert.Equal(t, ccxt.ErrInsufficientFunds, ccxt.ErrorCode(err))
+
+		// When: 标记价格改为现货指数 43000，未实现亏损 700 超过保证金 200
+		margin.cfg.Trading.Perpetual.MarkPrice = MarkPriceIndex
+		setTicker(t, db, 43000)
+
+		// Then: 抵押资产 = 1500 + 700 - 500 - 200 - 200 = 1300
+		account, err = margin.GetAccount(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "1300", account.TotalAsset.String())
+	})
+
+	t.Run("Interest accrues once per hour and repayment pays interest first", func(t *testing.T) {
+		db, margin := setup(t)
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 10000, 0)
+
+		_, err := margin.BorrowMargin(user.ID, "USDT", decimal.NewFromInt(1000))
+		require.NoError(t, err)
+
+		// When: 同一小时内重复计息，再计提下一小时
+		hour := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+		require.NoError(t, margin.AccrueInterest(hour.Add(30*time.Minute)))
+		require.NoError(t, margin.AccrueInterest(hour.Add(59*time.Minute)))
+		require.NoError(t, margin.AccrueInterest(hour.Add(time.Hour)))
+
+		// Then: 每小时 1000 * 0.0001 = 0.1
+		assert.Equal(t, "0.2", balanceOf(t, db, user.ID, "USDT").Interest.String())
+		records, err := margin.GetBorrowInterest(user.ID, "USDT", nil, 0)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "0.1", records[0].Interest.String())
+		assert.Equal(t, "0.0001", records[0].Rate.String())
+		assert.True(t, records[0].AccruedAt.Equal(hour))
+
+		// When: 归还 0.5，先还利息 0.2，再还本金 0.3
+		loan, err := margin.RepayMargin(user.ID, "USDT", decimal.NewFromFloat(0.5))
+		require.NoError(t, err)
+		assert.Equal(t, "0.2", loan.Interest.String())
+		assert.Equal(t, "0.3", loan.Amount.String())
+
+		usdt := balanceOf(t, db, user.ID, "USDT")
+		assert.Equal(t, "999.7", usdt.Borrowed.String())
+		assert.True(t, usdt.Interest.IsZero())
+		assert.Equal(t, "10999.5", usdt.Available.String())
+
+		// When: 归还超过负债的数量，只扣除剩余负债
+		loan, err = margin.RepayMargin(user.ID, "USDT", decimal.NewFromInt(5000))
+		require.NoError(t, err)
+		assert.Equal(t, "999.7", loan.Amount.String())
+		usdt = balanceOf(t, db, user.ID, "USDT")
+		assert.True(t, usdt.Borrowed.IsZero())
+		assert.Equal(t, "9999.8", usdt.Available.String())
+
+		_, err = margin.RepayMargin(user.ID, "USDT", decimal.NewFromInt(1))
+		assert.Error(t, err)
+	})
+
+	// shortBTC 用 1000 USDT 抵押借入 0.01 BTC 并按 50000 卖出，账户持有 1500 USDT、负债 0.01 BTC
+	shortBTC := func(t *testing.T, db *gorm.DB, margin *SpotMarginService) *model.User {
+		setTicker(t, db, 50000)
+		user := testutil.CreateTestUser(t, db)
+		testutil.CreateTestBalance(t, db, user.ID, "USDT", 1000, 0)
+		_, err := margin.BorrowMargin(user.ID, "BTC", decimal.NewFromFloat(0.01))
+		require.NoError(t, err)
+
+		require.NoError(t, db.Model(&model.Balance{}).Where("user_id = ? AND asset = ?", user.ID, "BTC").
+			Update("available", decimal.Zero).Error)
+		require.NoError(t, db.Model(&model.Balance{}).Where("user_id = ? AND asset = ?", user.ID, "USDT").
+			Update("available", decimal.NewFromInt(1500)).Error)
+		return user
+	}
+
+	t.Run("Account below liquidation level buys back and repays the debt", func(t *testing.T) {
+		db, margin := setup(t)
+		user := shortBTC(t, db, margin)
+
+		// When: 价格 120000，保证金水平 1500 / 1200 = 1.25，不强平
+		setTicker(t, db, 120000)
+		require.NoError(t, margin.CheckMarginLevels())
+		assert.Equal(t, "0.01", balanceOf(t, db, user.ID, "BTC").Borrowed.String())
+
+		// When: 价格 140000，保证金水平 1500 / 1400 ≈ 1.07 低于 1.1
+		setTicker(t, db, 140000)
+		require.NoError(t, margin.CheckMarginLevels())
+
+		// Then: 按 ask 140010 买回 BTC（数量按吃单费率上浮），归还全部负债
+		var order model.Order
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&order).Error)
+		assert.Equal(t, "buy", order.Side)
+		assert.Equal(t, "filled", order.Status)
+
+		btc := balanceOf(t, db, user.ID, "BTC")
+		assert.True(t, btc.Borrowed.IsZero())
+		assert.True(t, btc.Interest.IsZero())
+		assert.False(t, btc.Available.IsNegative())
+
+		usdt := balanceOf(t, db, user.ID, "USDT")
+		assert.Equal(t, decimal.NewFromInt(1500).Sub(order.QuoteFilled).String(), usdt.Available.String())
+		assert.True(t, usdt.Locked.IsZero())
+
+		var loans []model.MarginLoan
+		require.NoError(t, db.Where("user_id = ? AND type = ?", user.ID, LoanTypeLiquidation).Find(&loans).Error)
+		require.Len(t, loans, 1)
+		assert.Equal(t, "0.01", loans[0].Amount.String())
+
+		var funds int64
+		require.NoError(t, db.Model(&model.InsuranceFund{}).Count(&funds).Error)
+		assert.Zero(t, funds)
+	})
+
+	t.Run("Debt left after collateral is spent is written off by the insurance fund", func(t *testing.T) {
+		db, margin := setup(t)
+		user := shortBTC(t, db, margin)
+
+		// When: 价格 200000，负债 2000 超过全部资产 1500
+		setTicker(t, db, 200000)
+		require.NoError(t, margin.CheckMarginLevels())
+
+		// Then: 用全部 USDT 买回 BTC 还款，剩余负债按 200000 折算从保险基金扣除
+		var order model.Order
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&order).Error)
+		assert.Equal(t, "filled", order.Status)
+
+		btc := balanceOf(t, db, user.ID, "BTC")
+		assert.True(t, btc.Borrowed.IsZero())
+		assert.True(t, btc.Available.IsZero())
+
+		var loans []model.MarginLoan
+		require.NoError(t, db.Where("user_id = ? AND type = ?", user.ID, LoanTypeLiquidation).Order("id ASC").Find(&loans).Error)
+		require.Len(t, loans, 2)
+		repaid, writtenOff := loans[0].Amount, loans[1].Amount
+		assert.Equal(t, "0.01", repaid.Add(writtenOff).String())
+
+		var fund model.InsuranceFund
+		require.NoError(t, db.Where("asset = ?", "USDT").First(&fund).Error)
+		assert.Equal(t, decimal.NewFromInt(1000).Sub(writtenOff.Mul(decimal.NewFromInt(200000))).String(), fund.Balance.String())
+	})
+}
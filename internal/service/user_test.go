@@ -3,6 +3,7 @@ package service
 import (
 	"testing"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
@@ -413,7 +414,7 @@ func TestHardDeleteUser(t *testing.T) {
 		require.NoError(t, err)
 
 		// 创建余额数据
-		err = balanceService.AddBalance(user.ID, "USDT", 1000.0)
+		err = balanceService.AddBalance(user.ID, "USDT", decimal.NewFromFloat(1000.0))
 		require.NoError(t, err)
 
 		// When: 彻底删除用户
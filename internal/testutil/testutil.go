@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
@@ -57,8 +58,18 @@ func NewTestDB(t *testing.T) *gorm.DB {
 		&model.User{},
 		&model.Balance{},
 		&model.Order{},
+		&model.OrderList{},
 		&model.Trade{},
+		&model.UserFeeRate{},
+		&model.Position{},
+		&model.FundingRate{},
+		&model.FundingPayment{},
+		&model.Liquidation{},
+		&model.InsuranceFund{},
+		&model.MarginLoan{},
+		&model.BorrowInterest{},
 		&model.Ticker{},
+		&model.Market{},
 	)
 	require.NoError(t, err, "failed to migrate test database")
 
@@ -70,7 +81,11 @@ func cleanupTestDB(t *testing.T, db *gorm.DB) {
 	t.Helper()
 
 	// 按照外键依赖顺序删除
-	tables := []string{"trades", "orders", "balances", "tickers", "users"}
+	tables := []string{
+		"trades", "orders", "order_lists", "balances", "tickers", "markets",
+		"user_fee_rates", "positions", "funding_payments", "funding_rates", "liquidations",
+		"insurance_funds", "margin_loans", "borrow_interests", "users",
+	}
 	for _, table := range tables {
 		err := db.Exec(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", table)).Error
 		if err != nil {
@@ -154,8 +169,17 @@ func CleanupDB(t *testing.T, db *gorm.DB) {
 	// 清空所有表
 	db.Exec("DELETE FROM trades")
 	db.Exec("DELETE FROM orders")
+	db.Exec("DELETE FROM order_lists")
 	db.Exec("DELETE FROM balances")
 	db.Exec("DELETE FROM tickers")
+	db.Exec("DELETE FROM user_fee_rates")
+	db.Exec("DELETE FROM positions")
+	db.Exec("DELETE FROM funding_payments")
+	db.Exec("DELETE FROM funding_rates")
+	db.Exec("DELETE FROM liquidations")
+	db.Exec("DELETE FROM insurance_funds")
+	db.Exec("DELETE FROM margin_loans")
+	db.Exec("DELETE FROM borrow_interests")
 	db.Exec("DELETE FROM users")
 }
 
@@ -187,8 +211,8 @@ func CreateTestBalance(t *testing.T, db *gorm.DB, userID uint, asset string, ava
 	balance := &model.Balance{
 		UserID:    userID,
 		Asset:     asset,
-		Available: available,
-		Locked:    locked,
+		Available: decimal.NewFromFloat(available),
+		Locked:    decimal.NewFromFloat(locked),
 	}
 
 	err := db.Create(balance).Error
@@ -223,9 +247,8 @@ func CreateTestOrder(t *testing.T, db *gorm.DB, userID uint, symbol, side, order
 		Side:   side,
 		Type:   orderType,
 		Status: "new",
-		Amount: amount,
-		Price:  price,
-		Filled: 0,
+		Amount: decimal.NewFromFloat(amount),
+		Price:  DecimalPtr(price),
 	}
 
 	err := db.Create(order).Error
@@ -239,6 +262,20 @@ func Float64Ptr(v float64) *float64 {
 	return &v
 }
 
+// Decimal 将 float64 转换为精确小数
+func Decimal(v float64) decimal.Decimal {
+	return decimal.NewFromFloat(v)
+}
+
+// DecimalPtr 将 float64 指针转换为精确小数指针（nil 保持 nil）
+func DecimalPtr(v *float64) *decimal.Decimal {
+	if v == nil {
+		return nil
+	}
+	d := decimal.NewFromFloat(*v)
+	return &d
+}
+
 // SeedUser 创建种子用户（简化版，每次生成唯一邮箱）
 func SeedUser(t *testing.T, db *gorm.DB) *model.User {
 	t.Helper()
@@ -272,8 +309,8 @@ func SeedBalance(t *testing.T, db *gorm.DB, userID uint, asset string, available
 	balance := &model.Balance{
 		UserID:    userID,
 		Asset:     asset,
-		Available: available,
-		Locked:    lockedAmount,
+		Available: decimal.NewFromFloat(available),
+		Locked:    decimal.NewFromFloat(lockedAmount),
 	}
 
 	err := db.Create(balance).Error
@@ -292,9 +329,8 @@ func SeedOrder(t *testing.T, db *gorm.DB, userID uint, symbol string) *model.Ord
 		Side:   "buy",
 		Type:   "limit",
 		Status: "new",
-		Price:  Float64Ptr(50000.0),
-		Amount: 0.1,
-		Filled: 0,
+		Price:  DecimalPtr(Float64Ptr(50000.0)),
+		Amount: Decimal(0.1),
 	}
 
 	err := db.Create(order).Error